@@ -1,8 +1,11 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"database/sql"
 
+	"github.com/fritzpay/paymentd/pkg/paymentd/authkey"
 	"github.com/fritzpay/paymentd/pkg/paymentd/config"
 	"github.com/fritzpay/paymentd/pkg/service"
 	"gopkg.in/inconshreveable/log15.v2"
@@ -14,6 +17,9 @@ import (
 //     emit a warning message and write the generated password to another warning msg
 //   - Check the authorization keychain for existing keys. If no authorization keys
 //     are present it will generate a new one and emit a warning
+//   - Check the signing keyring for existing keys. If no signing keys are present it
+//     will use the signing key stored in the payment database, generating it on the
+//     first start, and emit a warning
 func setDefaults(ctx *service.Context) error {
 	paymentDB := ctx.PaymentDB()
 	err := checkSystemPassword(paymentDB)
@@ -68,6 +74,16 @@ func setDefaults(ctx *service.Context) error {
 			"generatedAuthKey": generated,
 		})
 	}
+	if ctx.SigningKeys().KeyCount() == 0 {
+		log.Warn("no signing keys set. will use the signing key stored in the database...")
+		key, err := storedSigningKey(paymentDB)
+		if err != nil {
+			log.Crit("error retrieving the stored signing key", log15.Ctx{"err": err})
+			return err
+		}
+		ctx.SigningKeys().Add(key)
+		log.Info("using stored signing key", log15.Ctx{"signingKeyID": key.KeyID()})
+	}
 	return nil
 }
 
@@ -75,3 +91,19 @@ func checkSystemPassword(db *sql.DB) error {
 	_, err := config.EntryByNameDB(db, config.ConfigNameSystemPassword)
 	return err
 }
+
+// storedSigningKey returns the signing key stored in the payment database
+//
+// The key is generated and stored on the first start, so all instances sharing the
+// database sign with the same key.
+func storedSigningKey(db *sql.DB) (*service.SigningKey, error) {
+	k, err := authkey.PrimaryKeyDB(db, authkey.KeychainSigning, func() ([]byte, error) {
+		seed := make([]byte, ed25519.SeedSize)
+		_, err := rand.Read(seed)
+		return seed, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return service.NewSigningKey(k.Key)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fritzpay/paymentd/pkg/env"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// configureLog sets up the logger according to the config and switches the debug
+// mode on SIGTTIN
+//
+// SIGUSR1 traces in debug builds and SIGUSR2 restarts the server gracefully.
+func configureLog() {
+	err := env.ConfigureLog(cfg)
+	if err != nil {
+		log.Crit("error configuring log", log15.Ctx{"err": err})
+		log.Info("exiting...")
+		os.Exit(1)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTTIN)
+	go func() {
+		for range sigs {
+			env.ToggleDebugLog()
+		}
+	}()
+}
@@ -4,10 +4,13 @@ import (
 	"database/sql"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
 
 	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/dialect"
 	"github.com/fritzpay/paymentd/pkg/env"
+	"github.com/fritzpay/paymentd/pkg/envelope"
 	"github.com/fritzpay/paymentd/pkg/server"
 	"github.com/fritzpay/paymentd/pkg/service"
 	"github.com/fritzpay/paymentd/pkg/service/api"
@@ -59,6 +62,21 @@ func main() {
 	log.Info("loading config...")
 	loadConfig()
 
+	log.Info("configuring log...")
+	configureLog()
+
+	log.Info("loading encryption keys...")
+	keyring, err := envelope.NewKeyringFromConfig(cfg)
+	if err != nil {
+		log.Crit("error loading encryption keys", log15.Ctx{"err": err})
+		log.Info("exiting...")
+		os.Exit(1)
+	}
+	if keyring == nil {
+		log.Warn("no encryption master key configured. secrets will be stored unencrypted")
+	}
+	envelope.SetKeyring(keyring)
+
 	// initialize root context
 	ctx, cancel = context.WithCancel(context.Background())
 	ctx = context.WithValue(ctx, "log", log)
@@ -83,6 +101,23 @@ func main() {
 		log.Info("exiting...")
 		os.Exit(1)
 	}
+	log.Info("checking database schemas...")
+	err = checkSchema(serviceCtx)
+	if err != nil {
+		log.Crit("error on database schema", log15.Ctx{"err": err})
+		log.Info("exiting...")
+		os.Exit(1)
+	}
+
+	if cfg.Keychain.Database {
+		log.Info("loading authorization keys from database...")
+		err = serviceCtx.EnableKeychainStores()
+		if err != nil {
+			log.Crit("error loading authorization keys", log15.Ctx{"err": err})
+			log.Info("exiting...")
+			os.Exit(1)
+		}
+	}
 
 	log.Info("setting payment defaults...")
 	err = setDefaults(serviceCtx)
@@ -124,6 +159,18 @@ func main() {
 		}
 	}
 
+	if cfg.Metrics.Active {
+		log.Info("enabling Metrics service...")
+		err = srv.RegisterService(cfg.Metrics.Service, serviceCtx.MetricsHandler())
+		if err != nil {
+			log.Crit("error registering Metrics service", log15.Ctx{"err": err})
+			log.Info("exiting...")
+			os.Exit(1)
+		}
+	}
+
+	reloadOnHangup(serviceCtx)
+
 	log.Info("serving...")
 	err = srv.Serve()
 	if err != nil {
@@ -134,7 +181,6 @@ func main() {
 }
 
 func loadConfig() {
-	cfg = config.DefaultConfig()
 	if cfgFileName == "" && os.Getenv(envVarConfigFileName) != "" {
 		cfgFileName = os.Getenv(envVarConfigFileName)
 		log.Info("using config file name from env", log15.Ctx{
@@ -146,32 +192,46 @@ func loadConfig() {
 		log.Info("no config file provided. trying default config...")
 	} else {
 		log.Info("opening config file...", log15.Ctx{"cfgFileName": cfgFileName})
+	}
+	var err error
+	cfg, err = readConfig()
+	if err != nil {
+		log.Crit("error loading config", log15.Ctx{"cfgFileName": cfgFileName, "err": err})
+		log.Info("exiting...")
+		os.Exit(1)
+	}
+}
+
+// readConfig reads the config file, if any, and applies the environment overrides
+func readConfig() (config.Config, error) {
+	c := config.DefaultConfig()
+	if cfgFileName != "" {
 		cfgFile, err := os.Open(cfgFileName)
 		if err != nil {
-			log.Crit("could not open config file", log15.Ctx{"cfgFileName": cfgFileName})
-			log.Info("exiting...")
-			os.Exit(1)
+			return c, fmt.Errorf("could not open config file: %v", err)
 		}
-		err = (&cfg).ReadConfig(cfgFile)
+		err = (&c).ReadConfig(cfgFile)
 		if err != nil {
-			log.Crit("error reading config file", log15.Ctx{"cfgFileName": cfgFileName, "err": err})
-			log.Info("exiting...")
-			os.Exit(1)
+			cfgFile.Close()
+			return c, fmt.Errorf("error reading config file: %v", err)
 		}
 		err = cfgFile.Close()
 		if err != nil {
-			log.Crit("error closing config file", log15.Ctx{"cfgFileName": cfgFileName, "err": err})
-			log.Info("exiting...")
-			os.Exit(1)
+			return c, fmt.Errorf("error closing config file: %v", err)
 		}
 	}
+	err := c.ApplyEnv(os.Environ())
+	if err != nil {
+		return c, fmt.Errorf("error applying environment: %v", err)
+	}
+	return c, nil
 }
 
 func connectDB(ctx *service.Context) error {
 	if cfg.Database.Principal.Write == nil {
 		return errors.New("principal write DB config error")
 	}
-	principalDBW, err := sql.Open(cfg.Database.Principal.Write.Type(), cfg.Database.Principal.Write.DSN())
+	principalDBW, err := dialect.Open(cfg.Database.Principal.Write.Type(), cfg.Database.Principal.Write.DSN())
 	if err != nil {
 		return err
 	}
@@ -179,7 +239,7 @@ func connectDB(ctx *service.Context) error {
 	principalDBW.SetMaxIdleConns(cfg.Database.MaxIdleConns)
 	var principalDBRO *sql.DB
 	if cfg.Database.Principal.ReadOnly != nil {
-		principalDBRO, err = sql.Open(cfg.Database.Principal.ReadOnly.Type(), cfg.Database.Principal.ReadOnly.DSN())
+		principalDBRO, err = dialect.Open(cfg.Database.Principal.ReadOnly.Type(), cfg.Database.Principal.ReadOnly.DSN())
 		if err != nil {
 			return err
 		}
@@ -191,7 +251,7 @@ func connectDB(ctx *service.Context) error {
 	if cfg.Database.Payment.Write == nil {
 		return errors.New("payment write DB config error")
 	}
-	paymentDBW, err := sql.Open(cfg.Database.Payment.Write.Type(), cfg.Database.Payment.Write.DSN())
+	paymentDBW, err := dialect.Open(cfg.Database.Payment.Write.Type(), cfg.Database.Payment.Write.DSN())
 	if err != nil {
 		return err
 	}
@@ -199,7 +259,7 @@ func connectDB(ctx *service.Context) error {
 	paymentDBW.SetMaxIdleConns(cfg.Database.MaxIdleConns)
 	var paymentDBRO *sql.DB
 	if cfg.Database.Payment.ReadOnly != nil {
-		paymentDBRO, err = sql.Open(cfg.Database.Payment.ReadOnly.Type(), cfg.Database.Payment.ReadOnly.DSN())
+		paymentDBRO, err = dialect.Open(cfg.Database.Payment.ReadOnly.Type(), cfg.Database.Payment.ReadOnly.DSN())
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/env"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// reloadOnHangup reloads the config on SIGHUP
+func reloadOnHangup(serviceCtx *service.Context) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	go func() {
+		for range sigs {
+			reloadConfig(serviceCtx)
+		}
+	}()
+}
+
+// reloadConfig applies the reloadable values of the config file and the environment
+//
+// Changes which require a restart are logged and ignored. The TLS certificate files
+// of the services are reloaded.
+func reloadConfig(serviceCtx *service.Context) {
+	log.Info("reloading config...", log15.Ctx{"cfgFileName": cfgFileName})
+	next, err := readConfig()
+	if err != nil {
+		log.Error("error loading config. keeping current config", log15.Ctx{"err": err})
+		return
+	}
+	restart, err := serviceCtx.Reload(next)
+	if err != nil {
+		log.Error("invalid config. keeping current config", log15.Ctx{"err": err})
+		return
+	}
+	for _, path := range restart {
+		log.Warn("config change requires a restart. ignoring change", log15.Ctx{
+			"setting": path,
+			"envVar":  config.EnvName(path),
+		})
+	}
+	cur := serviceCtx.Config()
+	err = env.ConfigureLog(*cur)
+	if err != nil {
+		log.Error("error configuring log. keeping current log", log15.Ctx{"err": err})
+	}
+	services := []struct {
+		active bool
+		cfg    config.ServiceConfig
+	}{
+		{cur.API.Active, cur.API.Service},
+		{cur.Web.Active, cur.Web.Service},
+		{cur.Metrics.Active, cur.Metrics.Service},
+	}
+	for _, s := range services {
+		if !s.active {
+			continue
+		}
+		err = srv.SetTimeouts(s.cfg)
+		if err != nil {
+			log.Error("error changing server timeouts", log15.Ctx{
+				"address": s.cfg.Address,
+				"err":     err,
+			})
+		}
+		err = srv.SetTLS(s.cfg)
+		if err != nil {
+			log.Error("error changing server TLS config. keeping current TLS config", log15.Ctx{
+				"address": s.cfg.Address,
+				"err":     err,
+			})
+		}
+	}
+	log.Info("config reloaded")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/migrate"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// checkSchema checks the schema versions of the write databases against the
+// embedded migrations
+//
+// It returns an error if a database is dirty or outdated. A database with a newer
+// schema is only logged.
+func checkSchema(ctx *service.Context) error {
+	for _, database := range migrate.Databases {
+		var db *sql.DB
+		if database == migrate.DatabasePrincipal {
+			db = ctx.PrincipalDB()
+		} else {
+			db = ctx.PaymentDB()
+		}
+		migrations, err := migrate.Migrations(dialect.Of(db), database)
+		if err != nil {
+			return err
+		}
+		err = migrate.NewMigrator(db, migrations).Check()
+		switch err {
+		case nil:
+		case migrate.ErrNewer:
+			log.Warn("database schema is newer than paymentd", log15.Ctx{"db": database})
+		case migrate.ErrOutdated:
+			return fmt.Errorf("%s DB: %v. run paymentdctl db migrate up", database, err)
+		case migrate.ErrDirty:
+			return fmt.Errorf("%s DB: %v. a migration failed. repair the schema and run paymentdctl db migrate force -d %s <version>", database, err, database)
+		default:
+			return fmt.Errorf("error checking %s DB schema: %v", database, err)
+		}
+	}
+	return nil
+}
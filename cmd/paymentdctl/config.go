@@ -42,13 +42,18 @@ func readConfig(c *cli.Context) bool {
 			return false
 		}
 	} else {
-		fmt.Println("no config file flag provided. will use default config...")
+		notice("no config file flag provided. will use default config...\n")
+	}
+	err := cfg.ApplyEnv(os.Environ())
+	if err != nil {
+		fmt.Printf("error applying environment: %v\n", err)
+		return false
 	}
 	return true
 }
 
 func readConfigFile(cfgFileName string) bool {
-	fmt.Printf("will read config file %s...\n", cfgFileName)
+	notice("will read config file %s...\n", cfgFileName)
 	cfgFile, err := os.Open(cfgFileName)
 	if err != nil {
 		fmt.Printf("error opening config file %s: %v\n", cfgFileName, err)
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/envelope"
+	"github.com/fritzpay/paymentd/pkg/migrate"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const dbCommandDescription = `This command allows you to inspect and migrate the schemas of the
+principal and the payment database configured in the config file.
+
+The migrations are embedded in paymentdctl. New migrations created with the create
+command become available after rebuilding paymentdctl and paymentd.`
+
+const defaultMigrationDir = "pkg/migrate/sql"
+
+var dbCommand = cli.Command{
+	Name:        "db",
+	Usage:       "Database schema migrations.",
+	Description: dbCommandDescription,
+	Subcommands: []cli.Command{
+		statusDBCommand,
+		migrateDBCommand,
+		createDBCommand,
+	},
+}
+
+var databaseFlag = cli.StringFlag{
+	Name:  "db, d",
+	Usage: "The database (principal or payment). Defaults to both databases.",
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// databases returns the databases selected with the database flag
+func databases(c *cli.Context) ([]string, bool) {
+	if c.String("db") == "" {
+		return migrate.Databases, true
+	}
+	for _, db := range migrate.Databases {
+		if db == c.String("db") {
+			return []string{db}, true
+		}
+	}
+	fmt.Printf("unknown database %s\n", c.String("db"))
+	return nil, false
+}
+
+func databaseConfig(database string) config.DatabaseConfig {
+	if database == migrate.DatabasePrincipal {
+		return cfg.Database.Principal.Write
+	}
+	return cfg.Database.Payment.Write
+}
+
+// openMigrator opens the write connection of the given database and returns a
+// migrator with the embedded migrations
+//
+// The caller must close the returned DB.
+func openMigrator(database string) (*sql.DB, *migrate.Migrator) {
+	db := openDB(database)
+	if db == nil {
+		return nil, nil
+	}
+	migrations, err := migrate.Migrations(dialect.Of(db), database)
+	if err != nil {
+		db.Close()
+		fmt.Printf("error loading %s migrations: %v\n", database, err)
+		return nil, nil
+	}
+	return db, migrate.NewMigrator(db, migrations)
+}
+
+// loadKeyring reads the config and loads the master keys, which are required to
+// seal and open the secrets stored in the databases
+func loadKeyring(c *cli.Context) bool {
+	if !readConfig(c) {
+		return false
+	}
+	keyring, err := envelope.NewKeyringFromConfig(cfg)
+	if err != nil {
+		fmt.Printf("error loading master keys: %v\n", err)
+		return false
+	}
+	envelope.SetKeyring(keyring)
+	return true
+}
+
+func openDB(database string) *sql.DB {
+	dbCfg := databaseConfig(database)
+	if dbCfg == nil {
+		fmt.Printf("no %s write DB configured\n", database)
+		return nil
+	}
+	db, err := dialect.Open(dbCfg.Type(), dbCfg.DSN())
+	if err != nil {
+		fmt.Printf("error opening %s DB: %v\n", database, err)
+		return nil
+	}
+	return db
+}
+
+func openPrincipalDB(c *cli.Context) *sql.DB {
+	if !loadKeyring(c) {
+		return nil
+	}
+	return openDB(migrate.DatabasePrincipal)
+}
+
+func openPaymentDB(c *cli.Context) *sql.DB {
+	if !loadKeyring(c) {
+		return nil
+	}
+	return openDB(migrate.DatabasePayment)
+}
+
+// openDBs opens the principal and the payment DB
+//
+// The caller must close both DBs.
+func openDBs(c *cli.Context) (principalDB, paymentDB *sql.DB) {
+	if !loadKeyring(c) {
+		return nil, nil
+	}
+	principalDB = openDB(migrate.DatabasePrincipal)
+	if principalDB == nil {
+		return nil, nil
+	}
+	paymentDB = openDB(migrate.DatabasePayment)
+	if paymentDB == nil {
+		principalDB.Close()
+		return nil, nil
+	}
+	return principalDB, paymentDB
+}
+
+var statusDBCommand = cli.Command{
+	Name:      "status",
+	ShortName: "s",
+	Usage:     "Show the applied and pending migrations.",
+	Flags: []cli.Flag{
+		databaseFlag,
+	},
+	Action: statusDBAction,
+}
+
+func statusDBAction(c *cli.Context) {
+	dbs, ok := databases(c)
+	if !ok || !readConfig(c) {
+		return
+	}
+	for _, database := range dbs {
+		db, m := openMigrator(database)
+		if db == nil {
+			return
+		}
+		st, err := m.Status()
+		db.Close()
+		if err != nil {
+			fmt.Printf("error retrieving %s migration status: %v\n", database, err)
+			return
+		}
+		printStatus(database, st)
+	}
+}
+
+func printStatus(database string, st *migrate.Status) {
+	state := "up to date"
+	switch {
+	case st.Dirty:
+		state = "dirty"
+	case len(st.Pending) > 0:
+		state = "outdated"
+	case st.Version > st.Latest:
+		state = "newer than paymentdctl"
+	}
+	fmt.Printf("\n%s database: version %d of %d, %s\n\n", database, st.Version, st.Latest, state)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, a := range st.Applied {
+		applied := a.Applied.Format(time.RFC3339)
+		if a.Dirty {
+			applied += " (dirty)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", a.Version, a.Name, applied)
+	}
+	for _, mig := range st.Pending {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", mig.Version, mig.Name, "pending")
+	}
+	tw.Flush()
+}
+
+var migrateDBCommand = cli.Command{
+	Name:      "migrate",
+	ShortName: "m",
+	Usage:     "Apply or revert migrations.",
+	Subcommands: []cli.Command{
+		upMigrateDBCommand,
+		downMigrateDBCommand,
+		forceMigrateDBCommand,
+	},
+}
+
+var upMigrateDBCommand = cli.Command{
+	Name:  "up",
+	Usage: "Apply the pending migrations.",
+	Flags: []cli.Flag{
+		databaseFlag,
+		cli.IntFlag{
+			Name:  "to, t",
+			Usage: "Apply the migrations up to this version. Requires the db flag.",
+		},
+	},
+	Action: upMigrateDBAction,
+}
+
+func upMigrateDBAction(c *cli.Context) {
+	if c.Int("to") != 0 && c.String("db") == "" {
+		fmt.Print("no database provided\n\n")
+		cli.ShowCommandHelp(c, "up")
+		return
+	}
+	dbs, ok := databases(c)
+	if !ok || !readConfig(c) {
+		return
+	}
+	for _, database := range dbs {
+		db, m := openMigrator(database)
+		if db == nil {
+			return
+		}
+		done, err := m.Up(c.Int("to"))
+		db.Close()
+		for _, mig := range done {
+			fmt.Printf("%s: applied %s\n", database, migrate.FileName(mig.Version, mig.Name, "up"))
+		}
+		if err != nil {
+			printMigrateError(database, err)
+			return
+		}
+		if len(done) == 0 {
+			fmt.Printf("%s: no migrations to apply\n", database)
+		}
+	}
+}
+
+var downMigrateDBCommand = cli.Command{
+	Name:  "down",
+	Usage: "Revert migrations. Reverts the latest applied migration by default.",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "db, d",
+			Usage: "The database (principal or payment).",
+		},
+		cli.IntFlag{
+			Name:  "to, t",
+			Value: -1,
+			Usage: "Revert the migrations down to this version. 0 reverts all migrations.",
+		},
+	},
+	Action: downMigrateDBAction,
+}
+
+func downMigrateDBAction(c *cli.Context) {
+	if c.String("db") == "" {
+		fmt.Print("no database provided\n\n")
+		cli.ShowCommandHelp(c, "down")
+		return
+	}
+	dbs, ok := databases(c)
+	if !ok || !readConfig(c) {
+		return
+	}
+	database := dbs[0]
+	db, m := openMigrator(database)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	version := c.Int("to")
+	if version < 0 {
+		st, err := m.Status()
+		if err != nil {
+			fmt.Printf("error retrieving %s migration status: %v\n", database, err)
+			return
+		}
+		version = 0
+		if len(st.Applied) > 1 {
+			version = st.Applied[len(st.Applied)-2].Version
+		}
+	}
+	done, err := m.Down(version)
+	for _, mig := range done {
+		fmt.Printf("%s: reverted %s\n", database, migrate.FileName(mig.Version, mig.Name, "down"))
+	}
+	if err != nil {
+		printMigrateError(database, err)
+		return
+	}
+	if len(done) == 0 {
+		fmt.Printf("%s: no migrations to revert\n", database)
+	}
+}
+
+var forceMigrateDBCommand = cli.Command{
+	Name:  "force",
+	Usage: "Record the given version as the applied schema version and clear a failed migration without running any scripts. Usage: force <version>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "db, d",
+			Usage: "The database (principal or payment).",
+		},
+	},
+	Action: forceMigrateDBAction,
+}
+
+func forceMigrateDBAction(c *cli.Context) {
+	version, err := strconv.Atoi(c.Args().First())
+	if err != nil || version < 0 || c.String("db") == "" {
+		fmt.Print("no version or database provided\n\n")
+		cli.ShowCommandHelp(c, "force")
+		return
+	}
+	dbs, ok := databases(c)
+	if !ok || !readConfig(c) {
+		return
+	}
+	database := dbs[0]
+	db, m := openMigrator(database)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	err = m.Force(version)
+	if err != nil {
+		fmt.Printf("error forcing %s DB version: %v\n", database, err)
+		return
+	}
+	fmt.Printf("%s: forced version %d\n", database, version)
+}
+
+// printMigrateError prints the error of a migration and how to recover a dirty
+// database
+func printMigrateError(database string, err error) {
+	fmt.Printf("error migrating %s DB: %v\n", database, err)
+	if err == migrate.ErrDirty {
+		fmt.Printf("a migration failed. repair the schema and run paymentdctl db migrate force -d %s <version>\n", database)
+	}
+}
+
+var createDBCommand = cli.Command{
+	Name:      "create",
+	ShortName: "c",
+	Usage:     "Create the up and down scripts of a new migration for every SQL dialect. Usage: create <name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "db, d",
+			Usage: "The database (principal or payment).",
+		},
+		cli.StringFlag{
+			Name:  "dir",
+			Value: defaultMigrationDir,
+			Usage: "The migration directory in the source tree.",
+		},
+	},
+	Action: createDBAction,
+}
+
+func createDBAction(c *cli.Context) {
+	name := c.Args().First()
+	if name == "" || c.String("db") == "" {
+		fmt.Print("no migration name or database provided\n\n")
+		cli.ShowCommandHelp(c, "create")
+		return
+	}
+	if !migrationName.MatchString(name) {
+		fmt.Printf("invalid migration name %s. use lower case letters, digits and underscores.\n", name)
+		return
+	}
+	dbs, ok := databases(c)
+	if !ok {
+		return
+	}
+	// the migrations of all dialects share the versions
+	version := 1
+	for _, d := range dialect.Dialects {
+		dir := filepath.Join(c.String("dir"), d.Name(), dbs[0])
+		migrations, err := migrate.Load(os.DirFS(dir))
+		if err != nil {
+			fmt.Printf("error reading migrations in %s: %v\n", dir, err)
+			return
+		}
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version >= version {
+			version = migrations[len(migrations)-1].Version + 1
+		}
+	}
+	for _, d := range dialect.Dialects {
+		for _, direction := range []string{"up", "down"} {
+			fileName := filepath.Join(c.String("dir"), d.Name(), dbs[0], migrate.FileName(version, name, direction))
+			f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err != nil {
+				fmt.Printf("error creating migration file: %v\n", err)
+				return
+			}
+			fmt.Fprintf(f, "-- %s %s migration %d %s\n", d.Name(), dbs[0], version, name)
+			f.Close()
+			fmt.Printf("migration file %s created.\n", fileName)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+)
+
+const keyCommandDescription = `This command allows you to create, list, rotate, expire and revoke
+project keys. It operates directly on the principal database configured in the
+config file.`
+
+const (
+	defaultKeyRotationOverlap = 24 * time.Hour
+	ctlCreatedBy              = "paymentdctl"
+)
+
+var keyCommand = cli.Command{
+	Name:        "key",
+	ShortName:   "k",
+	Usage:       "Project key management.",
+	Description: keyCommandDescription,
+	Subcommands: []cli.Command{
+		listKeyCommand,
+		createKeyCommand,
+		rotateKeyCommand,
+		expireKeyCommand,
+		revokeKeyCommand,
+	},
+}
+
+var createdByFlag = cli.StringFlag{
+	Name:  "user, u",
+	Value: ctlCreatedBy,
+	Usage: "User name to record as the creator of the change.",
+}
+
+var keyFlag = cli.StringFlag{
+	Name:  "key, k",
+	Usage: "The project key.",
+}
+
+func parseExpires(s string) (*time.Time, bool) {
+	if s == "" {
+		return nil, true
+	}
+	exp, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		fmt.Printf("invalid expiry %s: %v\n", s, err)
+		return nil, false
+	}
+	exp = exp.UTC()
+	return &exp, true
+}
+
+func parseScopes(scopes []string) ([]project.Scope, bool) {
+	parsed := make([]project.Scope, 0, len(scopes))
+	for _, s := range scopes {
+		sc := project.Scope(s)
+		if !project.ValidScope(sc) {
+			fmt.Printf("unknown scope %s\n", s)
+			return nil, false
+		}
+		parsed = append(parsed, sc)
+	}
+	return parsed, true
+}
+
+func scopesString(pk *project.Projectkey) string {
+	if len(pk.Scopes) == 0 {
+		return "*"
+	}
+	s := make([]string, len(pk.Scopes))
+	for i, sc := range pk.Scopes {
+		s[i] = string(sc)
+	}
+	return strings.Join(s, ",")
+}
+
+// keyJSON is the JSON output of a project key
+type keyJSON struct {
+	Key          string
+	ProjectID    int64
+	Active       bool
+	Scopes       []project.Scope
+	Expires      *time.Time
+	OverlapUntil *time.Time `json:",omitempty"`
+	Changed      time.Time
+	ChangedBy    string
+	// Secret is only set for new secrets
+	Secret string `json:",omitempty"`
+}
+
+// printKeys prints the keys, including the given secret of a single key
+func printKeys(c *cli.Context, secret string, keys ...*project.Projectkey) {
+	now := time.Now()
+	if jsonOutput(c) {
+		out := make([]keyJSON, len(keys))
+		for i, pk := range keys {
+			out[i] = keyJSON{
+				Key:       pk.Key,
+				ProjectID: pk.Project.ID,
+				Active:    pk.Active,
+				Scopes:    pk.Scopes,
+				Expires:   pk.Expires,
+				Changed:   pk.Timestamp,
+				ChangedBy: pk.CreatedBy,
+				Secret:    secret,
+			}
+			if pk.InOverlap(now) {
+				out[i].OverlapUntil = pk.PreviousExpires
+			}
+		}
+		printJSON(out)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tPROJECT\tACTIVE\tSCOPES\tEXPIRES\tOVERLAP UNTIL\tCHANGED\tCHANGED BY")
+	for _, pk := range keys {
+		exp, overlap := "-", "-"
+		if pk.Expires != nil {
+			exp = pk.Expires.Format(time.RFC3339)
+		}
+		if pk.InOverlap(now) {
+			overlap = pk.PreviousExpires.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%t\t%s\t%s\t%s\t%s\t%s\n",
+			pk.Key,
+			pk.Project.ID,
+			pk.Active,
+			scopesString(pk),
+			exp,
+			overlap,
+			pk.Timestamp.Format(time.RFC3339),
+			pk.CreatedBy,
+		)
+	}
+	tw.Flush()
+	if secret != "" {
+		fmt.Printf("\nsecret: %s\n", secret)
+	}
+}
+
+var listKeyCommand = cli.Command{
+	Name:      "list",
+	ShortName: "ls",
+	Usage:     "List the keys of a project.",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "project, p",
+			Usage: "The project ID.",
+		},
+	},
+	Action: listKeyAction,
+}
+
+func listKeyAction(c *cli.Context) {
+	if c.Int("project") == 0 {
+		fmt.Print("no project ID provided\n\n")
+		cli.ShowCommandHelp(c, "list")
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	keys, err := project.ProjectKeysByProjectIDDB(db, int64(c.Int("project")))
+	if err != nil {
+		fmt.Printf("error retrieving project keys: %v\n", err)
+		return
+	}
+	printKeys(c, "", keys...)
+}
+
+var createKeyCommand = cli.Command{
+	Name:      "create",
+	ShortName: "c",
+	Usage:     "Create a new project key with a random secret.",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "project, p",
+			Usage: "The project ID.",
+		},
+		cli.StringFlag{
+			Name:  "expires, e",
+			Usage: "Scheduled expiry of the key (RFC 3339).",
+		},
+		cli.StringSliceFlag{
+			Name:  "scope, s",
+			Value: &cli.StringSlice{},
+			Usage: "Restrict the key to an operation (payment:init, payment:read, payment:refund, callback:sign). Can be repeated.",
+		},
+		cli.StringSliceFlag{
+			Name:  "public-key",
+			Value: &cli.StringSlice{},
+			Usage: "Hex-encoded Ed25519 public key of the merchant. Requests must be signed with Ed25519. Can be repeated.",
+		},
+		cli.StringSliceFlag{
+			Name:  "client-cert",
+			Value: &cli.StringSlice{},
+			Usage: "SHA-256 fingerprint of a TLS client certificate bound to the key. Requests must be made with the certificate. Can be repeated.",
+		},
+		createdByFlag,
+	},
+	Action: createKeyAction,
+}
+
+func createKeyAction(c *cli.Context) {
+	if c.Int("project") == 0 {
+		fmt.Print("no project ID provided\n\n")
+		cli.ShowCommandHelp(c, "create")
+		return
+	}
+	exp, ok := parseExpires(c.String("expires"))
+	if !ok {
+		return
+	}
+	scopes, ok := parseScopes(c.StringSlice("scope"))
+	if !ok {
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	pr, err := project.ProjectByIDDB(db, int64(c.Int("project")))
+	if err != nil {
+		fmt.Printf("error retrieving project %d: %v\n", c.Int("project"), err)
+		return
+	}
+	pk, err := project.NewProjectkey(*pr, c.String("user"))
+	if err != nil {
+		fmt.Printf("error generating project key: %v\n", err)
+		return
+	}
+	pk.Expires = exp
+	if err = pk.SetScopes(scopes); err != nil {
+		fmt.Printf("error setting scopes: %v\n", err)
+		return
+	}
+	if err = pk.SetPublicKeys(c.StringSlice("public-key")); err != nil {
+		fmt.Printf("error setting public keys: %v\n", err)
+		return
+	}
+	if err = pk.SetClientCerts(c.StringSlice("client-cert")); err != nil {
+		fmt.Printf("error setting client certificates: %v\n", err)
+		return
+	}
+	if !insertProjectKey(db, pk) {
+		return
+	}
+	printKeys(c, pk.Secret, pk)
+}
+
+var rotateKeyCommand = cli.Command{
+	Name:      "rotate",
+	ShortName: "r",
+	Usage:     "Replace the secret of a project key.",
+	Flags: []cli.Flag{
+		keyFlag,
+		cli.DurationFlag{
+			Name:  "overlap, o",
+			Value: defaultKeyRotationOverlap,
+			Usage: "Duration during which the previous secret remains valid.",
+		},
+		createdByFlag,
+	},
+	Action: rotateKeyAction,
+}
+
+func rotateKeyAction(c *cli.Context) {
+	changeKey(c, "rotate", func(pk *project.Projectkey) error {
+		if !pk.IsValid() {
+			return fmt.Errorf("cannot rotate an invalid project key")
+		}
+		return pk.Rotate(c.Duration("overlap"))
+	})
+}
+
+var expireKeyCommand = cli.Command{
+	Name:      "expire",
+	ShortName: "e",
+	Usage:     "Schedule the expiry of a project key.",
+	Flags: []cli.Flag{
+		keyFlag,
+		cli.StringFlag{
+			Name:  "at, a",
+			Usage: "Expiry time (RFC 3339).",
+		},
+		createdByFlag,
+	},
+	Action: expireKeyAction,
+}
+
+func expireKeyAction(c *cli.Context) {
+	if c.String("at") == "" {
+		fmt.Print("no expiry provided\n\n")
+		cli.ShowCommandHelp(c, "expire")
+		return
+	}
+	exp, ok := parseExpires(c.String("at"))
+	if !ok {
+		return
+	}
+	changeKey(c, "expire", func(pk *project.Projectkey) error {
+		pk.Expires = exp
+		return nil
+	})
+}
+
+var revokeKeyCommand = cli.Command{
+	Name:  "revoke",
+	Usage: "Deactivate a project key.",
+	Flags: []cli.Flag{
+		keyFlag,
+		createdByFlag,
+	},
+	Action: revokeKeyAction,
+}
+
+func revokeKeyAction(c *cli.Context) {
+	changeKey(c, "revoke", func(pk *project.Projectkey) error {
+		pk.Active = false
+		return nil
+	})
+}
+
+// changeKey stores a new version of the project key given in the key flag after
+// applying the change
+//
+// A new secret is printed.
+func changeKey(c *cli.Context, cmd string, change func(pk *project.Projectkey) error) {
+	if c.String("key") == "" {
+		fmt.Print("no project key provided\n\n")
+		cli.ShowCommandHelp(c, cmd)
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	pk, err := project.ProjectKeyByKeyDB(db, c.String("key"))
+	if err != nil {
+		fmt.Printf("error retrieving project key %s: %v\n", c.String("key"), err)
+		return
+	}
+	secret := pk.Secret
+	err = change(pk)
+	if err != nil {
+		fmt.Printf("error changing project key: %v\n", err)
+		return
+	}
+	pk.NextVersion(c.String("user"))
+	if !insertProjectKey(db, pk) {
+		return
+	}
+	if pk.Secret == secret {
+		secret = ""
+	} else {
+		secret = pk.Secret
+	}
+	printKeys(c, secret, pk)
+}
+
+func insertProjectKey(db *sql.DB, pk *project.Projectkey) bool {
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return false
+	}
+	err = project.InsertProjectKeyTx(tx, pk)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error saving project key: %v\n", err)
+		return false
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return false
+	}
+	return true
+}
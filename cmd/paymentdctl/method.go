@@ -0,0 +1,396 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/paymentd/provider"
+	"github.com/fritzpay/paymentd/pkg/service/provider/paypal_rest"
+	"github.com/fritzpay/paymentd/pkg/service/provider/stripe"
+)
+
+const methodCommandDescription = `This command allows you to add and list the payment methods of a
+project, to change their status and to set the configuration of their provider. It
+operates directly on the databases configured in the config file.
+
+The PayPal secret and the Stripe secret key can be passed in the environment
+variables PAYMENTDCTL_PAYPAL_SECRET and PAYMENTDCTL_STRIPE_SECRET_KEY.`
+
+const (
+	providerPaypal = "paypal_rest"
+	providerStripe = "stripe"
+)
+
+var methodCommand = cli.Command{
+	Name:        "method",
+	ShortName:   "m",
+	Usage:       "Payment method management.",
+	Description: methodCommandDescription,
+	Subcommands: []cli.Command{
+		listMethodCommand,
+		addMethodCommand,
+		configMethodCommand,
+		statusMethodCommand,
+	},
+}
+
+var methodFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "project, p",
+		Usage: "The project ID.",
+	},
+	cli.StringFlag{
+		Name:  "provider",
+		Usage: "The provider name (e.g. fritzpay, paypal_rest, stripe).",
+	},
+	cli.StringFlag{
+		Name:  "key, k",
+		Usage: "The method key.",
+	},
+}
+
+// providerConfigFlags set the provider config of a payment method
+var providerConfigFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "paypal-endpoint",
+		Usage: "PayPal: The REST API endpoint, e.g. https://api.sandbox.paypal.com.",
+	},
+	cli.StringFlag{
+		Name:  "paypal-client-id",
+		Usage: "PayPal: The client ID.",
+	},
+	cli.StringFlag{
+		Name:   "paypal-secret",
+		Usage:  "PayPal: The client secret.",
+		EnvVar: "PAYMENTDCTL_PAYPAL_SECRET",
+	},
+	cli.StringFlag{
+		Name:  "paypal-type",
+		Value: paypal_rest.IntentSale,
+		Usage: "PayPal: The payment intent (sale or authorize).",
+	},
+	cli.StringFlag{
+		Name:   "stripe-secret-key",
+		Usage:  "Stripe: The secret key.",
+		EnvVar: "PAYMENTDCTL_STRIPE_SECRET_KEY",
+	},
+	cli.StringFlag{
+		Name:  "stripe-public-key",
+		Usage: "Stripe: The publishable key.",
+	},
+}
+
+// methodArgs returns the project ID, provider and method key flags
+func methodArgs(c *cli.Context, cmd string) (projectID int64, providerName, methodKey string, ok bool) {
+	if c.Int("project") == 0 || c.String("provider") == "" || c.String("key") == "" {
+		fmt.Print("no project ID, provider or method key provided\n\n")
+		cli.ShowCommandHelp(c, cmd)
+		return 0, "", "", false
+	}
+	return int64(c.Int("project")), c.String("provider"), c.String("key"), true
+}
+
+func printMethods(c *cli.Context, methods ...*payment_method.Method) {
+	if jsonOutput(c) {
+		printJSON(methods)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPROJECT\tPROVIDER\tKEY\tSTATUS\tSTATUS CHANGED\tCHANGED BY")
+	for _, pm := range methods {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			pm.ID,
+			pm.ProjectID,
+			pm.Provider.Name,
+			pm.MethodKey,
+			pm.Status,
+			pm.StatusChanged.UTC().Format(time.RFC3339),
+			pm.StatusCreatedBy,
+		)
+	}
+	tw.Flush()
+}
+
+// insertProviderConfig saves a new version of the provider config of the payment
+// method from the provider config flags
+//
+// Providers without config are skipped.
+func insertProviderConfig(c *cli.Context, tx *sql.Tx, pm *payment_method.Method) error {
+	created := time.Now().UTC().Round(time.Second)
+	switch pm.Provider.Name {
+	case providerPaypal:
+		cfg := &paypal_rest.Config{
+			ProjectID: pm.ProjectID,
+			MethodKey: pm.MethodKey,
+			Created:   created,
+			CreatedBy: c.String("user"),
+			Endpoint:  c.String("paypal-endpoint"),
+			ClientID:  c.String("paypal-client-id"),
+			Secret:    c.String("paypal-secret"),
+			Type:      c.String("paypal-type"),
+		}
+		if cfg.Endpoint == "" || cfg.ClientID == "" || cfg.Secret == "" {
+			return fmt.Errorf("the PayPal endpoint, client ID and secret are required")
+		}
+		if cfg.Type != paypal_rest.IntentSale && cfg.Type != paypal_rest.IntentAuth {
+			return fmt.Errorf("invalid PayPal type %s", cfg.Type)
+		}
+		return paypal_rest.InsertConfigTx(tx, cfg)
+	case providerStripe:
+		cfg := &stripe.Config{
+			ProjectID: pm.ProjectID,
+			MethodKey: pm.MethodKey,
+			Created:   created,
+			CreatedBy: c.String("user"),
+			SecretKey: c.String("stripe-secret-key"),
+			PublicKey: c.String("stripe-public-key"),
+		}
+		if cfg.SecretKey == "" || cfg.PublicKey == "" {
+			return fmt.Errorf("the Stripe secret and publishable key are required")
+		}
+		return stripe.InsertConfigTx(tx, cfg)
+	}
+	return nil
+}
+
+var listMethodCommand = cli.Command{
+	Name:      "list",
+	ShortName: "ls",
+	Usage:     "List the payment methods of a project.",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "project, p",
+			Usage: "The project ID.",
+		},
+		cli.StringFlag{
+			Name:  "status, s",
+			Usage: "Only list methods with this status (active, inactive or disabled).",
+		},
+	},
+	Action: listMethodAction,
+}
+
+func listMethodAction(c *cli.Context) {
+	if c.Int("project") == 0 {
+		fmt.Print("no project ID provided\n\n")
+		cli.ShowCommandHelp(c, "list")
+		return
+	}
+	statuses := []string{
+		payment_method.PaymentMethodStatusActive.String(),
+		payment_method.PaymentMethodStatusInactive.String(),
+		payment_method.PaymentMethodStatusDisabled.String(),
+	}
+	if c.String("status") != "" {
+		statuses = []string{c.String("status")}
+	}
+	db := openPaymentDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	methods := make([]*payment_method.Method, 0)
+	for _, s := range statuses {
+		status, err := payment_method.ParseMethodStatus(s)
+		if err != nil {
+			fmt.Printf("invalid status %s\n", s)
+			return
+		}
+		m, err := payment_method.PaymentMethodsByProjectIDStatusDB(db, int64(c.Int("project")), status)
+		if err != nil {
+			fmt.Printf("error retrieving payment methods: %v\n", err)
+			return
+		}
+		methods = append(methods, m...)
+	}
+	printMethods(c, methods...)
+}
+
+var addMethodCommand = cli.Command{
+	Name:      "add",
+	ShortName: "a",
+	Usage:     "Add a payment method to a project.",
+	Flags: append(append(append([]cli.Flag{}, methodFlags...),
+		cli.StringFlag{
+			Name:  "status, s",
+			Value: payment_method.PaymentMethodStatusActive.String(),
+			Usage: "The status of the method (active, inactive or disabled).",
+		},
+		createdByFlag,
+	), providerConfigFlags...),
+	Action: addMethodAction,
+}
+
+func addMethodAction(c *cli.Context) {
+	projectID, providerName, methodKey, ok := methodArgs(c, "add")
+	if !ok {
+		return
+	}
+	if _, err := payment_method.ParseMethodStatus(c.String("status")); err != nil {
+		fmt.Printf("invalid status %s\n", c.String("status"))
+		return
+	}
+	principalDB, db := openDBs(c)
+	if db == nil {
+		return
+	}
+	defer principalDB.Close()
+	defer db.Close()
+	pr, err := project.ProjectByIDDB(principalDB, projectID)
+	if err != nil {
+		fmt.Printf("error retrieving project %d: %v\n", projectID, err)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	pm, err := addMethod(c, tx, pr, providerName, methodKey)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error adding payment method: %v\n", err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return
+	}
+	printMethods(c, pm)
+}
+
+// addMethod inserts the payment method with the status and the provider config
+// given in the flags
+func addMethod(c *cli.Context, tx *sql.Tx, pr *project.Project, providerName, methodKey string) (*payment_method.Method, error) {
+	prov, err := provider.ProviderByNameTx(tx, providerName)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving provider %s: %v", providerName, err)
+	}
+	_, err = payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, pr.ID, prov.Name, methodKey)
+	if err == nil {
+		return nil, fmt.Errorf("payment method %s already exists", methodKey)
+	}
+	if err != payment_method.ErrPaymentMethodNotFound {
+		return nil, err
+	}
+	pm := &payment_method.Method{
+		ProjectID: pr.ID,
+		Provider:  prov,
+		MethodKey: methodKey,
+		Created:   time.Now().UTC().Round(time.Second),
+		CreatedBy: c.String("user"),
+	}
+	pm.Status, _ = payment_method.ParseMethodStatus(c.String("status"))
+	pm.StatusCreatedBy = pm.CreatedBy
+	err = payment_method.InsertPaymentMethodTx(tx, pm)
+	if err != nil {
+		return nil, err
+	}
+	err = payment_method.InsertPaymentMethodStatusTx(tx, pm)
+	if err != nil {
+		return nil, err
+	}
+	err = insertProviderConfig(c, tx, pm)
+	if err != nil {
+		return nil, err
+	}
+	return payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, pm.ProjectID, prov.Name, pm.MethodKey)
+}
+
+var configMethodCommand = cli.Command{
+	Name:  "config",
+	Usage: "Set a new provider configuration of a payment method, e.g. to change credentials.",
+	Flags: append(append(append([]cli.Flag{}, methodFlags...),
+		createdByFlag,
+	), providerConfigFlags...),
+	Action: configMethodAction,
+}
+
+func configMethodAction(c *cli.Context) {
+	projectID, providerName, methodKey, ok := methodArgs(c, "config")
+	if !ok {
+		return
+	}
+	if providerName != providerPaypal && providerName != providerStripe {
+		fmt.Printf("provider %s has no configuration\n", providerName)
+		return
+	}
+	changeMethod(c, projectID, providerName, methodKey, func(tx *sql.Tx, pm *payment_method.Method) error {
+		return insertProviderConfig(c, tx, pm)
+	})
+}
+
+var statusMethodCommand = cli.Command{
+	Name:  "status",
+	Usage: "Change the status of a payment method.",
+	Flags: append(append([]cli.Flag{}, methodFlags...),
+		cli.StringFlag{
+			Name:  "status, s",
+			Usage: "The new status (active, inactive or disabled).",
+		},
+		createdByFlag,
+	),
+	Action: statusMethodAction,
+}
+
+func statusMethodAction(c *cli.Context) {
+	projectID, providerName, methodKey, ok := methodArgs(c, "status")
+	if !ok {
+		return
+	}
+	status, err := payment_method.ParseMethodStatus(c.String("status"))
+	if err != nil {
+		fmt.Printf("invalid status %s\n", c.String("status"))
+		return
+	}
+	changeMethod(c, projectID, providerName, methodKey, func(tx *sql.Tx, pm *payment_method.Method) error {
+		pm.Status = status
+		pm.StatusCreatedBy = c.String("user")
+		return payment_method.InsertPaymentMethodStatusTx(tx, pm)
+	})
+}
+
+// changeMethod applies the change to the payment method in a transaction
+func changeMethod(c *cli.Context, projectID int64, providerName, methodKey string, change func(tx *sql.Tx, pm *payment_method.Method) error) {
+	db := openPaymentDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	pm, err := payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, projectID, providerName, methodKey)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error retrieving payment method %s: %v\n", methodKey, err)
+		return
+	}
+	err = change(tx, pm)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error changing payment method: %v\n", err)
+		return
+	}
+	pm, err = payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, projectID, providerName, methodKey)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error retrieving payment method %s: %v\n", methodKey, err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return
+	}
+	printMethods(c, pm)
+}
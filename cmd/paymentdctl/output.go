@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/codegangsta/cli"
+)
+
+// jsonOutput returns true if the results should be printed as JSON
+func jsonOutput(c *cli.Context) bool {
+	return c.GlobalBool("json")
+}
+
+// printJSON prints the value as indented JSON
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Printf("error encoding JSON: %v\n", err)
+		return
+	}
+	fmt.Printf("%s\n", b)
+}
+
+// notice prints an informational message to stderr, so it does not mix with the
+// results
+func notice(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
@@ -0,0 +1,693 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/service"
+	paymentService "github.com/fritzpay/paymentd/pkg/service/payment"
+	"github.com/fritzpay/paymentd/pkg/service/provider/fritzpay"
+	"github.com/fritzpay/paymentd/pkg/service/provider/paypal_rest"
+	"golang.org/x/net/context"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+const paymentCommandDescription = `This command allows you to inspect payments and to intervene manually.
+It operates directly on the databases configured in the config file.
+
+Payments are identified by the payment ID as used in the API, e.g. 1-3735928559.
+With the internal flag, the ID is the internal payment ID as found in the log. With
+the project flag, the argument is the ident of the payment in the given project. With
+the request flag, the argument is the ID of a request which created a transaction of
+the payment, as found in the log and in the X-Request-Id headers.
+
+Manual interventions are recorded in the payment transactions and in the audit log.
+They send a callback notification unless the no-callback flag is set.`
+
+const auditEntityPayment = "payment"
+
+var paymentCommand = cli.Command{
+	Name:        "payment",
+	ShortName:   "pa",
+	Usage:       "Payment inspection and manual intervention.",
+	Description: paymentCommandDescription,
+	Subcommands: []cli.Command{
+		showPaymentCommand,
+		forceCancelPaymentCommand,
+		markPaidPaymentCommand,
+		resendCallbackPaymentCommand,
+	},
+}
+
+// paymentFlags select how the payment argument is interpreted
+var paymentFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "project, p",
+		Usage: "The project ID. The argument is the ident of the payment.",
+	},
+	cli.BoolFlag{
+		Name:  "internal, i",
+		Usage: "The argument is the internal payment ID.",
+	},
+	cli.BoolFlag{
+		Name:  "request, r",
+		Usage: "The argument is the ID of a request which created a transaction of the payment.",
+	},
+}
+
+// interventionFlags are the flags of the manual interventions
+var interventionFlags = append([]cli.Flag{
+	cli.StringFlag{
+		Name:  "comment, m",
+		Usage: "The reason of the intervention. It is stored with the payment transaction.",
+	},
+	cli.BoolFlag{
+		Name:  "no-callback",
+		Usage: "Do not send a callback notification.",
+	},
+	createdByFlag,
+}, paymentFlags...)
+
+func paymentIDEncoder() (*payment.IDEncoder, bool) {
+	enc, err := payment.NewIDEncoder(cfg.Payment.PaymentIDEncPrime, cfg.Payment.PaymentIDEncXOR)
+	if err != nil {
+		fmt.Printf("error initializing payment ID encoder: %v\n", err)
+		return nil, false
+	}
+	return enc, true
+}
+
+// paymentByArg retrieves the payment identified by the first argument
+func paymentByArg(c *cli.Context, db *sql.DB, enc *payment.IDEncoder) (*payment.Payment, bool) {
+	arg := c.Args().First()
+	var p *payment.Payment
+	var err error
+	if c.Int("project") != 0 {
+		p, err = payment.PaymentByProjectIDAndIdentDB(db, int64(c.Int("project")), arg)
+	} else if c.Bool("request") {
+		var ids []payment.PaymentID
+		ids, err = payment.PaymentIDsByRequestIDDB(db, arg)
+		if err != nil {
+			fmt.Printf("error retrieving payments of request %s: %v\n", arg, err)
+			return nil, false
+		}
+		switch len(ids) {
+		case 0:
+			err = payment.ErrPaymentNotFound
+		case 1:
+			p, err = payment.PaymentByIDDB(db, ids[0])
+		default:
+			fmt.Printf("request %s created transactions of multiple payments:\n", arg)
+			for _, id := range ids {
+				fmt.Printf("  %s\n", id.Encoded(enc))
+			}
+			return nil, false
+		}
+	} else {
+		var id payment.PaymentID
+		if c.Bool("internal") {
+			id, err = payment.ParsePaymentIDStr(arg)
+		} else {
+			id, err = payment.ParseEncodedPaymentIDStr(arg, enc)
+		}
+		if err != nil {
+			fmt.Printf("invalid payment ID %s: %v\n", arg, err)
+			return nil, false
+		}
+		p, err = payment.PaymentByIDDB(db, id)
+	}
+	if err == payment.ErrPaymentNotFound {
+		fmt.Printf("payment %s not found\n", arg)
+		return nil, false
+	}
+	if err != nil {
+		fmt.Printf("error retrieving payment %s: %v\n", arg, err)
+		return nil, false
+	}
+	return p, true
+}
+
+// paymentInfo is the output of the show command
+type paymentInfo struct {
+	PaymentID  payment.PaymentID
+	InternalID payment.PaymentID
+	Ident      string
+	Created    time.Time
+	Amount     string
+	Currency   string
+	Status     payment.PaymentTransactionStatus
+
+	Config       paymentConfigInfo
+	Metadata     map[string]string
+	Transactions []transactionInfo
+	Balance      payment.Balance
+	Tokens       []tokenInfo
+
+	FritzpayTransactions []fritzpayTransactionInfo `json:",omitempty"`
+	PaypalTransactions   []paypalTransactionInfo   `json:",omitempty"`
+}
+
+type paymentConfigInfo struct {
+	Timestamp          *time.Time `json:",omitempty"`
+	PaymentMethodID    int64      `json:",string,omitempty"`
+	Provider           string     `json:",omitempty"`
+	MethodKey          string     `json:",omitempty"`
+	Country            string     `json:",omitempty"`
+	Locale             string     `json:",omitempty"`
+	CallbackURL        string     `json:",omitempty"`
+	CallbackAPIVersion string     `json:",omitempty"`
+	CallbackProjectKey string     `json:",omitempty"`
+	ReturnURL          string     `json:",omitempty"`
+	Expires            *time.Time `json:",omitempty"`
+}
+
+type transactionInfo struct {
+	Timestamp time.Time
+	Amount    string
+	Currency  string
+	Status    payment.PaymentTransactionStatus
+	Comment   string `json:",omitempty"`
+	RequestID string `json:",omitempty"`
+}
+
+type tokenInfo struct {
+	Token   string
+	Created time.Time
+	Valid   bool
+}
+
+type fritzpayTransactionInfo struct {
+	Timestamp  time.Time
+	Status     string
+	FritzpayID string `json:",omitempty"`
+	Payload    string `json:",omitempty"`
+}
+
+type paypalTransactionInfo struct {
+	Timestamp time.Time
+	Type      string
+	Intent    string          `json:",omitempty"`
+	PaypalID  string          `json:",omitempty"`
+	PayerID   string          `json:",omitempty"`
+	State     string          `json:",omitempty"`
+	Data      json.RawMessage `json:",omitempty"`
+}
+
+func newTransactionInfo(paymentTx *payment.PaymentTransaction) transactionInfo {
+	return transactionInfo{
+		Timestamp: paymentTx.Timestamp,
+		Amount:    paymentTx.Decimal().String(),
+		Currency:  paymentTx.Currency,
+		Status:    paymentTx.Status,
+		Comment:   paymentTx.Comment.String,
+		RequestID: paymentTx.RequestID.String,
+	}
+}
+
+// paymentInfoDB collects the payment details from the payment DB
+func paymentInfoDB(db *sql.DB, p *payment.Payment) (*paymentInfo, error) {
+	info := &paymentInfo{
+		InternalID: p.PaymentID(),
+		Ident:      p.Ident,
+		Created:    p.Created,
+		Amount:     p.Decimal().String(),
+		Currency:   p.Currency,
+		Status:     p.Status,
+		Config: paymentConfigInfo{
+			PaymentMethodID:    p.Config.PaymentMethodID.Int64,
+			Country:            p.Config.Country.String,
+			Locale:             p.Config.Locale.String,
+			CallbackURL:        p.Config.CallbackURL.String,
+			CallbackAPIVersion: p.Config.CallbackAPIVersion.String,
+			CallbackProjectKey: p.Config.CallbackProjectKey.String,
+			ReturnURL:          p.Config.ReturnURL.String,
+			Expires:            p.Config.Expires,
+		},
+	}
+	if !p.Config.Timestamp.IsZero() {
+		info.Config.Timestamp = &p.Config.Timestamp
+	}
+	if p.Config.PaymentMethodID.Valid {
+		pm, err := payment_method.PaymentMethodByIDDB(db, p.Config.PaymentMethodID.Int64)
+		if err != nil && err != payment_method.ErrPaymentMethodNotFound {
+			return nil, fmt.Errorf("error retrieving payment method: %v", err)
+		}
+		if err == nil {
+			info.Config.Provider, info.Config.MethodKey = pm.Provider.Name, pm.MethodKey
+		}
+	}
+	err := payment.PaymentMetadataDB(db, p)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving metadata: %v", err)
+	}
+	info.Metadata = p.Metadata
+
+	var txs payment.PaymentTransactionList
+	if p.HasTransaction() {
+		txs, err = payment.PaymentTransactionsBeforeTimestampDB(db, p, p.TransactionTimestamp)
+		if err != nil && err != payment.ErrPaymentTransactionNotFound {
+			return nil, fmt.Errorf("error retrieving transactions: %v", err)
+		}
+	}
+	info.Transactions = make([]transactionInfo, len(txs))
+	for i, paymentTx := range txs {
+		info.Transactions[i] = newTransactionInfo(paymentTx)
+	}
+	info.Balance = txs.Balance()
+
+	tokens, err := payment.PaymentTokensByPaymentIDDB(db, p.PaymentID())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving tokens: %v", err)
+	}
+	info.Tokens = make([]tokenInfo, len(tokens))
+	for i, t := range tokens {
+		info.Tokens[i] = tokenInfo{
+			Token:   t.Token,
+			Created: t.Created,
+			Valid:   t.Valid(paymentService.PaymentTokenMaxAgeDefault),
+		}
+	}
+
+	// the provider tables are read regardless of the current payment method, since
+	// the payment method can change until the payment is initialized
+	fp, err := fritzpay.PaymentByPaymentIDDB(db, p.PaymentID())
+	if err != nil && err != fritzpay.ErrPaymentNotFound {
+		return nil, fmt.Errorf("error retrieving fritzpay payment: %v", err)
+	}
+	if err == nil {
+		fpTxs, err := fritzpay.PaymentTransactionsByPaymentIDDB(db, fp.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving fritzpay transactions: %v", err)
+		}
+		for _, t := range fpTxs {
+			info.FritzpayTransactions = append(info.FritzpayTransactions, fritzpayTransactionInfo{
+				Timestamp:  t.Timestamp,
+				Status:     t.Status,
+				FritzpayID: t.FritzpayID.String,
+				Payload:    t.Payload.String,
+			})
+		}
+	}
+	ppTxs, err := paypal_rest.TransactionsByPaymentIDDB(db, p.PaymentID())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving PayPal transactions: %v", err)
+	}
+	for _, t := range ppTxs {
+		ppTx := paypalTransactionInfo{
+			Timestamp: t.Timestamp,
+			Type:      t.Type,
+			Intent:    t.Intent.String,
+			PaypalID:  t.PaypalID.String,
+			PayerID:   t.PayerID.String,
+			State:     t.PaypalState.String,
+		}
+		if json.Valid(t.Data) {
+			ppTx.Data = json.RawMessage(t.Data)
+		}
+		info.PaypalTransactions = append(info.PaypalTransactions, ppTx)
+	}
+	return info, nil
+}
+
+func printPaymentInfo(c *cli.Context, info *paymentInfo) {
+	if jsonOutput(c) {
+		printJSON(info)
+		return
+	}
+	orNone := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	orNoTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Payment ID:\t%s\n", info.PaymentID)
+	fmt.Fprintf(tw, "Internal ID:\t%s\n", info.InternalID)
+	fmt.Fprintf(tw, "Ident:\t%s\n", info.Ident)
+	fmt.Fprintf(tw, "Created:\t%s\n", info.Created.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Amount:\t%s %s\n", info.Amount, info.Currency)
+	fmt.Fprintf(tw, "Status:\t%s\n", info.Status)
+	fmt.Fprintf(tw, "Config changed:\t%s\n", orNoTime(info.Config.Timestamp))
+	method := "-"
+	if info.Config.PaymentMethodID != 0 {
+		method = fmt.Sprintf("%d %s %s", info.Config.PaymentMethodID, info.Config.Provider, info.Config.MethodKey)
+	}
+	fmt.Fprintf(tw, "Payment method:\t%s\n", method)
+	fmt.Fprintf(tw, "Country:\t%s\n", orNone(info.Config.Country))
+	fmt.Fprintf(tw, "Locale:\t%s\n", orNone(info.Config.Locale))
+	fmt.Fprintf(tw, "Callback URL:\t%s\n", orNone(info.Config.CallbackURL))
+	fmt.Fprintf(tw, "Callback API version:\t%s\n", orNone(info.Config.CallbackAPIVersion))
+	fmt.Fprintf(tw, "Callback project key:\t%s\n", orNone(info.Config.CallbackProjectKey))
+	fmt.Fprintf(tw, "Return URL:\t%s\n", orNone(info.Config.ReturnURL))
+	fmt.Fprintf(tw, "Expires:\t%s\n", orNoTime(info.Config.Expires))
+	tw.Flush()
+
+	fmt.Print("\nMetadata:\n\n")
+	names := make([]string, 0, len(info.Metadata))
+	for name := range info.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVALUE")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, info.Metadata[name])
+	}
+	tw.Flush()
+
+	fmt.Print("\nTransactions:\n\n")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIMESTAMP\tSTATUS\tAMOUNT\tCURRENCY\tREQUEST ID\tCOMMENT")
+	for _, t := range info.Transactions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.Timestamp.Format(time.RFC3339Nano),
+			t.Status,
+			t.Amount,
+			t.Currency,
+			orNone(t.RequestID),
+			orNone(t.Comment),
+		)
+	}
+	tw.Flush()
+
+	fmt.Print("\nBalance:\n\n")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CURRENCY\tAMOUNT")
+	for curr, amount := range info.Balance.FlatMap() {
+		fmt.Fprintf(tw, "%s\t%s\n", curr, amount)
+	}
+	tw.Flush()
+
+	fmt.Print("\nTokens:\n\n")
+	tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOKEN\tCREATED\tVALID")
+	for _, t := range info.Tokens {
+		fmt.Fprintf(tw, "%s\t%s\t%t\n", t.Token, t.Created.Format(time.RFC3339), t.Valid)
+	}
+	tw.Flush()
+
+	if len(info.FritzpayTransactions) > 0 {
+		fmt.Print("\nFritzPay transactions:\n\n")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIMESTAMP\tSTATUS\tFRITZPAY ID")
+		for _, t := range info.FritzpayTransactions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Timestamp.Format(time.RFC3339Nano), t.Status, orNone(t.FritzpayID))
+		}
+		tw.Flush()
+	}
+	if len(info.PaypalTransactions) > 0 {
+		fmt.Print("\nPayPal transactions:\n\n")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIMESTAMP\tTYPE\tINTENT\tSTATE\tPAYPAL ID\tPAYER ID")
+		for _, t := range info.PaypalTransactions {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.Timestamp.Format(time.RFC3339Nano),
+				t.Type,
+				orNone(t.Intent),
+				orNone(t.State),
+				orNone(t.PaypalID),
+				orNone(t.PayerID),
+			)
+		}
+		tw.Flush()
+	}
+}
+
+var showPaymentCommand = cli.Command{
+	Name:   "show",
+	Usage:  "Show a payment with its transactions. Usage: show <paymentId|ident>",
+	Flags:  paymentFlags,
+	Action: showPaymentAction,
+}
+
+func showPaymentAction(c *cli.Context) {
+	if c.Args().First() == "" {
+		fmt.Print("no payment provided\n\n")
+		cli.ShowCommandHelp(c, "show")
+		return
+	}
+	db := openPaymentDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	enc, ok := paymentIDEncoder()
+	if !ok {
+		return
+	}
+	p, ok := paymentByArg(c, db, enc)
+	if !ok {
+		return
+	}
+	info, err := paymentInfoDB(db, p)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	info.PaymentID = p.PaymentID().Encoded(enc)
+	printPaymentInfo(c, info)
+}
+
+// paymentEnv is a payment service on the configured databases
+type paymentEnv struct {
+	ctx    *service.Context
+	cancel context.CancelFunc
+	s      *paymentService.Service
+	enc    *payment.IDEncoder
+}
+
+// openPaymentEnv creates a payment service, which logs warnings and errors to stderr
+//
+// The caller must close the returned env.
+func openPaymentEnv(c *cli.Context) *paymentEnv {
+	principalDB, paymentDB := openDBs(c)
+	if principalDB == nil {
+		return nil
+	}
+	enc, ok := paymentIDEncoder()
+	if !ok {
+		principalDB.Close()
+		paymentDB.Close()
+		return nil
+	}
+	log := log15.New()
+	log.SetHandler(log15.LvlFilterHandler(log15.LvlWarn, log15.StreamHandler(os.Stderr, log15.LogfmtFormat())))
+	env := &paymentEnv{enc: enc}
+	var ctx context.Context
+	ctx, env.cancel = context.WithCancel(context.Background())
+	var err error
+	env.ctx, err = service.NewContext(ctx, cfg, log)
+	if err == nil {
+		env.ctx.SetPrincipalDB(principalDB, nil)
+		env.ctx.SetPaymentDB(paymentDB, nil)
+		env.s, err = paymentService.NewService(env.ctx)
+	}
+	if err != nil {
+		fmt.Printf("error initializing payment service: %v\n", err)
+		env.cancel()
+		principalDB.Close()
+		paymentDB.Close()
+		return nil
+	}
+	return env
+}
+
+func (e *paymentEnv) Close() {
+	e.cancel()
+	e.ctx.PrincipalDB().Close()
+	e.ctx.PaymentDB().Close()
+}
+
+// paymentAudit is the state of a payment as recorded in the audit log
+type paymentAudit struct {
+	Status               payment.PaymentTransactionStatus
+	TransactionTimestamp *time.Time `json:",omitempty"`
+	Comment              string     `json:",omitempty"`
+}
+
+func newPaymentAudit(p *payment.Payment) paymentAudit {
+	a := paymentAudit{Status: p.Status}
+	if p.HasTransaction() {
+		ts := p.TransactionTimestamp
+		a.TransactionTimestamp = &ts
+	}
+	return a
+}
+
+// auditPayment records the manual intervention on the payment in the audit log
+func (e *paymentEnv) auditPayment(c *cli.Context, action string, p *payment.Payment, before, after interface{}) {
+	id := p.PaymentID().Encoded(e.enc)
+	entry, err := audit.NewEntry(c.String("user"), action, auditEntityPayment, id.String(), before, after)
+	if err != nil {
+		fmt.Printf("error creating audit log entry: %v\n", err)
+		return
+	}
+	err = audit.InsertEntryDB(e.ctx.PrincipalDB(), entry, cfg.Database.TransactionMaxRetries)
+	if err != nil {
+		fmt.Printf("error saving audit log entry: %v\n", err)
+	}
+}
+
+// notify sends the callback notification of the payment transaction
+func (e *paymentEnv) notify(paymentTx *payment.PaymentTransaction) {
+	id := paymentTx.Payment.PaymentID().Encoded(e.enc)
+	err := e.s.Notify(paymentTx)
+	if err != nil {
+		notice("payment %s: error on callback notification: %v\n", id, err)
+		return
+	}
+	notice("payment %s: callback notification delivered\n", id)
+}
+
+func printTransaction(c *cli.Context, id payment.PaymentID, paymentTx *payment.PaymentTransaction) {
+	if jsonOutput(c) {
+		printJSON(newTransactionInfo(paymentTx))
+		return
+	}
+	fmt.Printf("payment %s: %s\n", id, paymentTx.Status)
+}
+
+// intervention changes the payment status within the given tx
+type intervention func(s *paymentService.Service, tx *sql.Tx, p *payment.Payment, comment string) (*payment.PaymentTransaction, error)
+
+// intervene runs the intervention on the payment given in the first argument
+func intervene(c *cli.Context, command string, f intervention) {
+	if c.Args().First() == "" {
+		fmt.Print("no payment provided\n\n")
+		cli.ShowCommandHelp(c, command)
+		return
+	}
+	e := openPaymentEnv(c)
+	if e == nil {
+		return
+	}
+	defer e.Close()
+	p, ok := paymentByArg(c, e.ctx.PaymentDB(), e.enc)
+	if !ok {
+		return
+	}
+	id := p.PaymentID().Encoded(e.enc)
+	before := newPaymentAudit(p)
+	tx, err := e.ctx.PaymentDB().Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	paymentTx, err := f(e.s, tx, p, c.String("comment"))
+	if err != nil {
+		tx.Rollback()
+		if err == paymentService.ErrIntentNotAllowed {
+			fmt.Printf("payment %s is %s. %s not allowed\n", id, p.Status, command)
+			return
+		}
+		fmt.Printf("error saving payment transaction: %v\n", err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return
+	}
+	after := newPaymentAudit(p)
+	after.Comment = c.String("comment")
+	e.auditPayment(c, audit.ActionChange, p, before, after)
+	printTransaction(c, id, paymentTx)
+	if !c.Bool("no-callback") {
+		e.notify(paymentTx)
+	}
+}
+
+var forceCancelPaymentCommand = cli.Command{
+	Name:   "force-cancel",
+	Usage:  "Cancel a payment regardless of its payment method. Usage: force-cancel <paymentId|ident>",
+	Flags:  interventionFlags,
+	Action: forceCancelPaymentAction,
+}
+
+func forceCancelPaymentAction(c *cli.Context) {
+	intervene(c, "force-cancel", func(s *paymentService.Service, tx *sql.Tx, p *payment.Payment, comment string) (*payment.PaymentTransaction, error) {
+		return s.ForceCancel(tx, p, comment)
+	})
+}
+
+var markPaidPaymentCommand = cli.Command{
+	Name:   "mark-paid",
+	Usage:  "Mark a payment paid, e.g. if the provider notification was lost. Requires a comment. Usage: mark-paid <paymentId|ident>",
+	Flags:  interventionFlags,
+	Action: markPaidPaymentAction,
+}
+
+func markPaidPaymentAction(c *cli.Context) {
+	if c.String("comment") == "" {
+		fmt.Print("no comment provided\n\n")
+		cli.ShowCommandHelp(c, "mark-paid")
+		return
+	}
+	intervene(c, "mark-paid", func(s *paymentService.Service, tx *sql.Tx, p *payment.Payment, comment string) (*payment.PaymentTransaction, error) {
+		return s.MarkPaid(tx, p, comment)
+	})
+}
+
+var resendCallbackPaymentCommand = cli.Command{
+	Name:  "resend-callback",
+	Usage: "Send the callback notification of the current payment transaction again. Usage: resend-callback <paymentId|ident>",
+	Flags: append([]cli.Flag{
+		createdByFlag,
+	}, paymentFlags...),
+	Action: resendCallbackPaymentAction,
+}
+
+func resendCallbackPaymentAction(c *cli.Context) {
+	if c.Args().First() == "" {
+		fmt.Print("no payment provided\n\n")
+		cli.ShowCommandHelp(c, "resend-callback")
+		return
+	}
+	e := openPaymentEnv(c)
+	if e == nil {
+		return
+	}
+	defer e.Close()
+	p, ok := paymentByArg(c, e.ctx.PaymentDB(), e.enc)
+	if !ok {
+		return
+	}
+	id := p.PaymentID().Encoded(e.enc)
+	tx, err := e.ctx.PaymentDB().Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	paymentTx, err := e.s.PaymentTransaction(tx, p)
+	tx.Rollback()
+	if err == payment.ErrPaymentTransactionNotFound {
+		fmt.Printf("payment %s is not initialized. nothing to notify\n", id)
+		return
+	}
+	if err != nil {
+		fmt.Printf("error retrieving payment transaction: %v\n", err)
+		return
+	}
+	err = e.s.Notify(paymentTx)
+	if err != nil {
+		fmt.Printf("payment %s: error on callback notification: %v\n", id, err)
+		return
+	}
+	e.auditPayment(c, audit.ActionNotify, p, nil, newPaymentAudit(p))
+	printTransaction(c, id, paymentTx)
+}
@@ -23,6 +23,13 @@ func main() {
 
 	app.Commands = []cli.Command{
 		configCommand,
+		keyCommand,
+		secretsCommand,
+		dbCommand,
+		principalCommand,
+		projectCommand,
+		methodCommand,
+		paymentCommand,
 	}
 
 	app.Flags = []cli.Flag{
@@ -30,6 +37,10 @@ func main() {
 			Name:  "config, c",
 			Usage: "config file name",
 		},
+		cli.BoolFlag{
+			Name:  "json, j",
+			Usage: "print the results as JSON",
+		},
 	}
 
 	app.Run(os.Args)
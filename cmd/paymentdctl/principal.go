@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
+)
+
+const principalCommandDescription = `This command allows you to create and list principals. It operates
+directly on the principal database configured in the config file.`
+
+var principalCommand = cli.Command{
+	Name:        "principal",
+	ShortName:   "pr",
+	Usage:       "Principal management.",
+	Description: principalCommandDescription,
+	Subcommands: []cli.Command{
+		listPrincipalCommand,
+		createPrincipalCommand,
+	},
+}
+
+func printPrincipals(c *cli.Context, principals ...*principal.Principal) {
+	if jsonOutput(c) {
+		printJSON(principals)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tCREATED\tCREATED BY")
+	for _, pr := range principals {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n",
+			pr.ID,
+			pr.Name,
+			pr.Status,
+			pr.Created.Format(time.RFC3339),
+			pr.CreatedBy,
+		)
+	}
+	tw.Flush()
+}
+
+var listPrincipalCommand = cli.Command{
+	Name:      "list",
+	ShortName: "ls",
+	Usage:     "List the principals.",
+	Action:    listPrincipalAction,
+}
+
+func listPrincipalAction(c *cli.Context) {
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	principals, err := principal.PrincipalAllDB(db)
+	if err == principal.ErrPrincipalNotFound {
+		principals = []*principal.Principal{}
+	} else if err != nil {
+		fmt.Printf("error retrieving principals: %v\n", err)
+		return
+	}
+	printPrincipals(c, principals...)
+}
+
+var createPrincipalCommand = cli.Command{
+	Name:      "create",
+	ShortName: "c",
+	Usage:     "Create a new principal.",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "name, n",
+			Usage: "The name of the principal.",
+		},
+		cli.StringFlag{
+			Name:  "status, s",
+			Value: principal.PrincipalStatusActive,
+			Usage: "The status of the principal (active or inactive).",
+		},
+		createdByFlag,
+	},
+	Action: createPrincipalAction,
+}
+
+func createPrincipalAction(c *cli.Context) {
+	if c.String("name") == "" {
+		fmt.Print("no principal name provided\n\n")
+		cli.ShowCommandHelp(c, "create")
+		return
+	}
+	pr := &principal.Principal{
+		Created:   time.Now().UTC().Round(time.Second),
+		CreatedBy: c.String("user"),
+		Name:      c.String("name"),
+		Status:    c.String("status"),
+	}
+	if err := pr.ValidStatus(); err != nil {
+		fmt.Printf("invalid status %s\n", pr.Status)
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	_, err := principal.PrincipalByNameDB(db, pr.Name)
+	if err == nil {
+		fmt.Printf("principal %s already exists\n", pr.Name)
+		return
+	}
+	if err != principal.ErrPrincipalNotFound {
+		fmt.Printf("error retrieving principal %s: %v\n", pr.Name, err)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	err = principal.InsertPrincipalTx(tx, pr)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error saving principal: %v\n", err)
+		return
+	}
+	err = principal.InsertPrincipalStatusTx(tx, *pr, pr.CreatedBy)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error saving principal status: %v\n", err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return
+	}
+	printPrincipals(c, pr)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+)
+
+const projectCommandDescription = `This command allows you to create and list projects and to change
+their configuration. It operates directly on the principal database configured in
+the config file.`
+
+var projectCommand = cli.Command{
+	Name:        "project",
+	ShortName:   "pj",
+	Usage:       "Project management.",
+	Description: projectCommandDescription,
+	Subcommands: []cli.Command{
+		listProjectCommand,
+		createProjectCommand,
+		configProjectCommand,
+	},
+}
+
+// projectConfigFlags set the values of the project config
+var projectConfigFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "web-url",
+		Usage: "The URL of the web payment pages.",
+	},
+	cli.StringFlag{
+		Name:  "callback-url",
+		Usage: "The URL receiving payment notifications.",
+	},
+	cli.StringFlag{
+		Name:  "callback-api-version",
+		Usage: "The API version of the payment notifications.",
+	},
+	cli.StringFlag{
+		Name:  "callback-project-key",
+		Usage: "The project key signing the payment notifications.",
+	},
+	cli.StringFlag{
+		Name:  "return-url",
+		Usage: "The URL the customer returns to after the payment.",
+	},
+	cli.StringSliceFlag{
+		Name:  "ip-allow",
+		Value: &cli.StringSlice{},
+		Usage: "Network (CIDR notation) from which the payment API may be used. Can be repeated. An empty value removes the restriction.",
+	},
+	cli.IntFlag{
+		Name:  "rate-limit-burst",
+		Usage: "Override of the rate limit burst size. 0 removes the override.",
+	},
+}
+
+// setProjectConfig applies the project config flags which are set to the config
+//
+// It returns whether a value was set.
+func setProjectConfig(c *cli.Context, cfg *project.Config) (bool, error) {
+	set := false
+	for name, setter := range map[string]func(string){
+		"web-url":              cfg.SetWebURL,
+		"callback-url":         cfg.SetCallbackURL,
+		"callback-api-version": cfg.SetCallbackAPIVersion,
+		"callback-project-key": cfg.SetCallbackProjectKey,
+		"return-url":           cfg.SetReturnURL,
+	} {
+		if c.IsSet(name) {
+			setter(c.String(name))
+			set = true
+		}
+	}
+	if c.IsSet("ip-allow") {
+		cfg.SetIPAllowlist(c.StringSlice("ip-allow"))
+		if _, err := cfg.AllowedNetworks(); err != nil {
+			return false, fmt.Errorf("invalid IP allowlist: %v", err)
+		}
+		set = true
+	}
+	if c.IsSet("rate-limit-burst") {
+		switch burst := c.Int("rate-limit-burst"); {
+		case burst < 0:
+			return false, fmt.Errorf("rate limit burst must be positive")
+		case burst == 0:
+			cfg.RateLimitBurst.Valid = false
+		default:
+			cfg.SetRateLimitBurst(int64(burst))
+		}
+		set = true
+	}
+	return set, nil
+}
+
+func printProjects(c *cli.Context, projects ...*project.Project) {
+	if jsonOutput(c) {
+		printJSON(projects)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tPRINCIPAL\tNAME\tCREATED\tCREATED BY\tWEB URL\tCALLBACK URL\tRETURN URL")
+	for _, pr := range projects {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			pr.ID,
+			pr.PrincipalID,
+			pr.Name,
+			pr.Created.Format(time.RFC3339),
+			pr.CreatedBy,
+			nullString(pr.Config.WebURL),
+			nullString(pr.Config.CallbackURL),
+			nullString(pr.Config.ReturnURL),
+		)
+	}
+	tw.Flush()
+}
+
+func nullString(s sql.NullString) string {
+	if !s.Valid || s.String == "" {
+		return "-"
+	}
+	return s.String
+}
+
+var listProjectCommand = cli.Command{
+	Name:      "list",
+	ShortName: "ls",
+	Usage:     "List the projects of a principal.",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "principal, p",
+			Usage: "The principal ID.",
+		},
+	},
+	Action: listProjectAction,
+}
+
+func listProjectAction(c *cli.Context) {
+	if c.Int("principal") == 0 {
+		fmt.Print("no principal ID provided\n\n")
+		cli.ShowCommandHelp(c, "list")
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	projects, err := project.AllProjectsByPrincipalIDDB(db, int64(c.Int("principal")))
+	if err == project.ErrProjectNotFound {
+		projects = []*project.Project{}
+	} else if err != nil {
+		fmt.Printf("error retrieving projects: %v\n", err)
+		return
+	}
+	printProjects(c, projects...)
+}
+
+var createProjectCommand = cli.Command{
+	Name:      "create",
+	ShortName: "c",
+	Usage:     "Create a new project.",
+	Flags: append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "principal, p",
+			Usage: "The principal ID.",
+		},
+		cli.StringFlag{
+			Name:  "name, n",
+			Usage: "The name of the project.",
+		},
+		createdByFlag,
+	}, projectConfigFlags...),
+	Action: createProjectAction,
+}
+
+func createProjectAction(c *cli.Context) {
+	if c.Int("principal") == 0 || c.String("name") == "" {
+		fmt.Print("no principal ID or project name provided\n\n")
+		cli.ShowCommandHelp(c, "create")
+		return
+	}
+	pr := &project.Project{
+		PrincipalID: int64(c.Int("principal")),
+		Name:        c.String("name"),
+		Created:     time.Now().UTC().Round(time.Second),
+		CreatedBy:   c.String("user"),
+	}
+	hasConfig, err := setProjectConfig(c, &pr.Config)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	_, err = principal.PrincipalByIDDB(db, pr.PrincipalID)
+	if err != nil {
+		fmt.Printf("error retrieving principal %d: %v\n", pr.PrincipalID, err)
+		return
+	}
+	_, err = project.ProjectByPrincipalIDNameDB(db, pr.PrincipalID, pr.Name)
+	if err == nil {
+		fmt.Printf("project %s already exists\n", pr.Name)
+		return
+	}
+	if err != project.ErrProjectNotFound {
+		fmt.Printf("error retrieving project %s: %v\n", pr.Name, err)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		fmt.Printf("error on begin tx: %v\n", err)
+		return
+	}
+	err = project.InsertProjectTx(tx, pr)
+	if err != nil {
+		tx.Rollback()
+		fmt.Printf("error saving project: %v\n", err)
+		return
+	}
+	if hasConfig {
+		err = project.InsertProjectConfigTx(tx, pr)
+		if err != nil {
+			tx.Rollback()
+			fmt.Printf("error saving project config: %v\n", err)
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		fmt.Printf("error on commit: %v\n", err)
+		return
+	}
+	printProjects(c, pr)
+}
+
+var configProjectCommand = cli.Command{
+	Name:  "config",
+	Usage: "Change the configuration of a project. Values which are not given are kept.",
+	Flags: append([]cli.Flag{
+		cli.IntFlag{
+			Name:  "project, p",
+			Usage: "The project ID.",
+		},
+	}, projectConfigFlags...),
+	Action: configProjectAction,
+}
+
+func configProjectAction(c *cli.Context) {
+	if c.Int("project") == 0 {
+		fmt.Print("no project ID provided\n\n")
+		cli.ShowCommandHelp(c, "config")
+		return
+	}
+	db := openPrincipalDB(c)
+	if db == nil {
+		return
+	}
+	defer db.Close()
+	pr, err := project.ProjectByIDDB(db, int64(c.Int("project")))
+	if err != nil {
+		fmt.Printf("error retrieving project %d: %v\n", c.Int("project"), err)
+		return
+	}
+	set, err := setProjectConfig(c, &pr.Config)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	if !set {
+		printProjects(c, pr)
+		return
+	}
+	err = project.InsertProjectConfigDB(db, pr)
+	if err != nil {
+		fmt.Printf("error saving project config: %v\n", err)
+		return
+	}
+	printProjects(c, pr)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/envelope"
+	"github.com/fritzpay/paymentd/pkg/service"
+)
+
+const secretsCommandDescription = `This command manages the encryption of secrets stored in the databases,
+i.e. project key secrets, TOTP secrets, authorization keys and payment service
+provider credentials.
+
+To rotate the master key, prepend a new key to the configured master keys and run
+the reencrypt command. After all secrets were reencrypted, the old key can be removed.
+
+Reencrypting updates the stored secrets in place. The configured database users
+require the UPDATE privilege on the affected tables.
+
+The signingkey command generates an Ed25519 key pair for signing responses and
+notifications to project keys with public keys.`
+
+var secretsCommand = cli.Command{
+	Name:        "secrets",
+	ShortName:   "s",
+	Usage:       "Encryption of stored secrets.",
+	Description: secretsCommandDescription,
+	Subcommands: []cli.Command{
+		reencryptSecretsCommand,
+		signingKeySecretsCommand,
+	},
+}
+
+var signingKeySecretsCommand = cli.Command{
+	Name:   "signingkey",
+	Usage:  "Generate an Ed25519 signing key pair.",
+	Action: signingKeySecretsAction,
+}
+
+func signingKeySecretsAction(c *cli.Context) {
+	key, err := service.GenerateSigningKey()
+	if err != nil {
+		fmt.Printf("error generating signing key: %v\n", err)
+		return
+	}
+	fmt.Printf("private key: %s\n", key.Seed())
+	fmt.Printf("public key:  %s\n", hex.EncodeToString(key.PublicKey()))
+	fmt.Printf("key ID:      %s\n", key.KeyID())
+}
+
+var reencryptSecretsCommand = cli.Command{
+	Name:  "reencrypt",
+	Usage: "Encrypt all stored secrets with the current master key.",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "dry-run, n",
+			Usage: "Only report the number of secrets to be reencrypted.",
+		},
+	},
+	Action: reencryptSecretsAction,
+}
+
+// tables with sealed columns
+var (
+	principalSecretTables = []envelope.Table{
+		{
+			Name:       "project_key",
+			PrimaryKey: []string{"key", "timestamp"},
+			Columns:    []string{"secret", "previous_secret"},
+		},
+		{
+			Name:       "user_mfa",
+			PrimaryKey: []string{"user_id", "timestamp"},
+			Columns:    []string{"totp_secret"},
+		},
+	}
+	paymentSecretTables = []envelope.Table{
+		{
+			Name:       "auth_key",
+			PrimaryKey: []string{"keychain", "generation"},
+			Columns:    []string{"key"},
+		},
+		{
+			Name:       "provider_paypal_config",
+			PrimaryKey: []string{"project_id", "method_key", "created"},
+			Columns:    []string{"client_id", "secret"},
+		},
+		{
+			Name:       "provider_stripe_config",
+			PrimaryKey: []string{"project_id", "method_key", "created"},
+			Columns:    []string{"secure_key"},
+		},
+	}
+)
+
+func reencryptSecretsAction(c *cli.Context) {
+	if !readConfig(c) {
+		return
+	}
+	keyring, err := envelope.NewKeyringFromConfig(cfg)
+	if err != nil {
+		fmt.Printf("error loading master keys: %v\n", err)
+		return
+	}
+	if keyring == nil {
+		fmt.Println("no master key configured.")
+		return
+	}
+	dryRun := c.Bool("dry-run")
+
+	principalDB, err := dialect.Open(cfg.Database.Principal.Write.Type(), cfg.Database.Principal.Write.DSN())
+	if err != nil {
+		fmt.Printf("error opening principal DB: %v\n", err)
+		return
+	}
+	defer principalDB.Close()
+	paymentDB, err := dialect.Open(cfg.Database.Payment.Write.Type(), cfg.Database.Payment.Write.DSN())
+	if err != nil {
+		fmt.Printf("error opening payment DB: %v\n", err)
+		return
+	}
+	defer paymentDB.Close()
+
+	errors := 0
+	reseal := func(db *sql.DB, t envelope.Table) {
+		n, err := keyring.ResealTable(db, dialect.Of(db), t, dryRun)
+		if err != nil {
+			errors++
+			fmt.Printf("error: %s: %v\n", t.Name, err)
+			return
+		}
+		if dryRun {
+			fmt.Printf("%s: %d secrets to reencrypt\n", t.Name, n)
+		} else {
+			fmt.Printf("%s: %d secrets reencrypted\n", t.Name, n)
+		}
+	}
+	for _, t := range principalSecretTables {
+		reseal(principalDB, t)
+	}
+	for _, t := range paymentSecretTables {
+		reseal(paymentDB, t)
+	}
+	fmt.Printf("\nreencryption complete with %d errors.\n", errors)
+}
@@ -48,6 +48,47 @@ type ServiceConfig struct {
 	ReadTimeout    Duration
 	WriteTimeout   Duration
 	MaxHeaderBytes int
+	// TLS config. TLS is enabled when a certificate file is set
+	TLS TLSConfig
+}
+
+// TLSConfig represents the TLS settings of a service
+type TLSConfig struct {
+	// PEM encoded certificate (chain) and private key. The files are reloaded when
+	// they change
+	CertFile string
+	KeyFile  string
+	// Minimum TLS version: 1.0, 1.1, 1.2 or 1.3. Defaults to 1.2
+	MinVersion string
+	// Names of the cipher suites for TLS 1.2 and below. Empty uses the Go defaults
+	CipherSuites []string
+	// Client certificate verification: none, request, verify or require
+	ClientAuth string
+	// PEM encoded CA certificates which verify the client certificates
+	ClientCAFile string
+}
+
+// Enabled returns whether TLS is enabled
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+const (
+	// SystemPasswordLoginEnabled permits the system password login
+	SystemPasswordLoginEnabled = "enabled"
+	// SystemPasswordLoginBootstrap permits the system password login only while no
+	// admin users exist
+	SystemPasswordLoginBootstrap = "bootstrap"
+	// SystemPasswordLoginDisabled rejects the system password login
+	SystemPasswordLoginDisabled = "disabled"
+)
+
+// RateLimitConfig represents a token bucket rate limit
+type RateLimitConfig struct {
+	// Requests per second. 0 disables the limit
+	Rate float64
+	// Maximum number of requests in a burst
+	Burst int
 }
 
 // Config represents a full configuration for any paymentd related applications
@@ -102,6 +143,13 @@ type Config struct {
 		AdminGUIPubWWWDir string
 
 		AuthKeys []string
+		// Login with the system password: enabled, bootstrap or disabled. In bootstrap
+		// mode, the system password is accepted only until the first user is created
+		SystemPasswordLogin string
+
+		// Networks (CIDR notation) of proxies which are trusted to pass on client
+		// addresses in the X-Forwarded-For header
+		TrustedProxies []string
 	}
 	// Web server config
 	Web struct {
@@ -130,11 +178,84 @@ type Config struct {
 		// Web auth keys for encrypting cookie auth containers
 		AuthKeys []string
 	}
+	// Metrics server config
+	Metrics struct {
+		// Whether the metrics service should be active
+		Active bool
+		// Metrics service config
+		Service ServiceConfig
+	}
+	// Log config
+	Log struct {
+		// Log format: daemon, logfmt or json
+		Format string
+		// Minimum log level: crit, error, warn, info or debug
+		Level string
+		// Minimum log levels per package, overriding Level. Keys are package prefixes
+		// like "service/api"
+		Packages map[string]string
+		// Log output: stderr, file or syslog
+		Output string
+		// Log file for the file output
+		File struct {
+			Path string
+			// Size in bytes after which the file is rotated, 0 disables
+			MaxSize int64
+			// Interval after which the file is rotated, empty disables
+			RotationInterval Duration
+			// Number of rotated files to keep, 0 keeps all
+			MaxBackups int
+		}
+		// Syslog config for the syslog output. Logs to the local syslog socket
+		Syslog struct {
+			Tag string
+		}
+	}
 	Provider struct {
 		URL string
 
 		ProviderTemplateDir string
 	}
+	// Encryption at rest of stored secrets
+	Encryption struct {
+		// Hex encoded AES-256 master keys. The first key encrypts new secrets, all keys
+		// can decrypt
+		MasterKeys []string
+		// File containing the master keys, one per line. Takes precedence over
+		// MasterKeys
+		MasterKeyFile string
+	}
+	// Rate limits of the payment API and the web payment entry point. The concurrency
+	// limit of Database.MaxOpenConns applies in addition
+	RateLimit struct {
+		// Limit per project key
+		ProjectKey RateLimitConfig
+		// Limit per project, may be overridden in the project config
+		Project RateLimitConfig
+		// Limit per client IP
+		ClientIP RateLimitConfig
+	}
+	// Authorization keychain config
+	Keychain struct {
+		// Store the authorization keys in the database instead of the AuthKeys of the
+		// API and Web sections. Keys will be rotated automatically
+		Database bool
+		// Interval after which a new primary key is generated
+		RotationInterval Duration
+		// Maximum age of authorization containers. Keys are retired when their
+		// successor is older
+		MaxAge Duration
+		// Interval in which the keys are reloaded from the database
+		RefreshInterval Duration
+	}
+	// Ed25519 key pairs for signing responses and notifications to project keys with
+	// public keys
+	Signing struct {
+		// Hex encoded Ed25519 seeds. The first key signs, all keys are published
+		PrivateKeys []string
+		// File containing the seeds, one per line. Takes precedence over PrivateKeys
+		PrivateKeyFile string
+	}
 }
 
 // DefaultConfig returns a default configuration
@@ -162,6 +283,8 @@ func DefaultConfig() Config {
 	cfg.API.Timeout = Duration("5s")
 	cfg.API.ServeAdmin = false
 	cfg.API.AuthKeys = make([]string, 0)
+	cfg.API.SystemPasswordLogin = SystemPasswordLoginBootstrap
+	cfg.API.TrustedProxies = make([]string, 0)
 
 	cfg.API.Cookie.HTTPOnly = true
 
@@ -174,8 +297,26 @@ func DefaultConfig() Config {
 
 	cfg.Web.Cookie.HTTPOnly = true
 
+	cfg.Metrics.Service.Address = ":9090"
+	cfg.Metrics.Service.ReadTimeout = Duration("10s")
+	cfg.Metrics.Service.WriteTimeout = Duration("10s")
+
+	cfg.Log.Format = "daemon"
+	cfg.Log.Level = "debug"
+	cfg.Log.Packages = make(map[string]string)
+	cfg.Log.Output = "stderr"
+	cfg.Log.Syslog.Tag = "paymentd"
+
 	cfg.Provider.URL = "http://localhost:8443"
 
+	cfg.Encryption.MasterKeys = make([]string, 0)
+
+	cfg.Keychain.RotationInterval = Duration("24h")
+	cfg.Keychain.MaxAge = Duration("48h")
+	cfg.Keychain.RefreshInterval = Duration("1m")
+
+	cfg.Signing.PrivateKeys = make([]string, 0)
+
 	return cfg
 }
 
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// EnvPrefix is the prefix of environment variables overriding config values
+	EnvPrefix = "PAYMENTD_"
+	// EnvFileSuffix is the suffix of environment variables naming a file which
+	// contains the config value
+	EnvFileSuffix = "_FILE"
+)
+
+// EnvName returns the name of the environment variable overriding the config value
+// at the given path
+//
+// The path consists of the field names, e.g. "API.Service.Address" is overridden by
+// PAYMENTD_API_SERVICE_ADDRESS.
+func EnvName(path string) string {
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		parts[i] = envWord(p)
+	}
+	return EnvPrefix + strings.Join(parts, "_")
+}
+
+// envWord converts a camel case field name to upper snake case, keeping acronyms
+// together, e.g. PaymentIDEncPrime becomes PAYMENT_ID_ENC_PRIME
+func envWord(name string) string {
+	r := []rune(name)
+	var b []rune
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			prevLower := unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1])
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if prevLower || (unicode.IsUpper(r[i-1]) && nextLower) {
+				b = append(b, '_')
+			}
+		}
+		b = append(b, unicode.ToUpper(c))
+	}
+	return string(b)
+}
+
+// ApplyEnv overrides config values with the environment variables of the given
+// environment in the form "key=value", as returned by os.Environ()
+//
+// Every config value can be set with the variable returned by EnvName. Alternatively
+// the variable with the EnvFileSuffix names a file containing the value, which
+// allows reading secrets from files. Lists are comma separated, or one entry per
+// line in files, where lines starting with # are ignored. Maps and lists can also be
+// given as JSON.
+//
+// Other variables with the EnvPrefix are ignored.
+func (c *Config) ApplyEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		env[kv[:i]] = kv[i+1:]
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return walkConfig(reflect.ValueOf(c).Elem(), nil, func(path []string, v reflect.Value) error {
+		name := EnvName(strings.Join(path, "."))
+		val, isSet := env[name]
+		file, isFile := env[name+EnvFileSuffix]
+		if isSet && isFile {
+			return fmt.Errorf("both %s and %s are set", name, name+EnvFileSuffix)
+		}
+		if isFile {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", name+EnvFileSuffix, err)
+			}
+			err = setEnvValue(v, string(b), true)
+			if err != nil {
+				return fmt.Errorf("invalid value in %s: %v", file, err)
+			}
+			return nil
+		}
+		if isSet {
+			err := setEnvValue(v, val, false)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// walkConfig calls fn for every config value, i.e. every field which is not a struct
+func walkConfig(v reflect.Value, path []string, fn func(path []string, v reflect.Value) error) error {
+	if v.Kind() != reflect.Struct {
+		return fn(path, v)
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		err := walkConfig(v.Field(i), append(path[:len(path):len(path)], t.Field(i).Name), fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, s string, fromFile bool) error {
+	if fromFile {
+		s = strings.TrimRight(s, "\r\n")
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		if strings.HasPrefix(strings.TrimSpace(s), "[") {
+			return json.Unmarshal([]byte(s), v.Addr().Interface())
+		}
+		sep := ","
+		if fromFile {
+			sep = "\n"
+		}
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, e := range strings.Split(s, sep) {
+			e = strings.TrimSpace(e)
+			if e == "" || (fromFile && strings.HasPrefix(e, "#")) {
+				continue
+			}
+			list = reflect.Append(list, reflect.ValueOf(e))
+		}
+		v.Set(list)
+	case reflect.Map:
+		m := reflect.New(v.Type())
+		err := json.Unmarshal([]byte(s), m.Interface())
+		if err != nil {
+			return err
+		}
+		v.Set(m.Elem())
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
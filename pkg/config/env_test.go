@@ -0,0 +1,120 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEnvName(t *testing.T) {
+	Convey("Given config value paths", t, func() {
+		Convey("The env names should be upper snake case with the prefix", func() {
+			So(EnvName("API.Service.Address"), ShouldEqual, "PAYMENTD_API_SERVICE_ADDRESS")
+			So(EnvName("Payment.PaymentIDEncPrime"), ShouldEqual, "PAYMENTD_PAYMENT_PAYMENT_ID_ENC_PRIME")
+			So(EnvName("API.AdminGUIPubWWWDir"), ShouldEqual, "PAYMENTD_API_ADMIN_GUI_PUB_WWW_DIR")
+			So(EnvName("Web.Cookie.HTTPOnly"), ShouldEqual, "PAYMENTD_WEB_COOKIE_HTTP_ONLY")
+		})
+	})
+}
+
+func TestApplyEnv(t *testing.T) {
+	Convey("Given a default config", t, func() {
+		cfg := DefaultConfig()
+
+		Convey("When applying an environment with overrides", func() {
+			err := cfg.ApplyEnv([]string{
+				"PATH=/bin",
+				"PAYMENTD_API_SERVICE_ADDRESS=:8081",
+				"PAYMENTD_API_ACTIVE=false",
+				"PAYMENTD_DATABASE_MAX_OPEN_CONNS=20",
+				"PAYMENTD_RATE_LIMIT_CLIENT_IP_RATE=2.5",
+				"PAYMENTD_WEB_AUTH_KEYS=aa, bb",
+				`PAYMENTD_DATABASE_PAYMENT_WRITE={"mysql":"test@tcp(db:3306)/payment"}`,
+				"PAYMENTD_SERVICE_HOST=10.0.0.1",
+			})
+
+			Convey("It should override the values", func() {
+				So(err, ShouldBeNil)
+				So(cfg.API.Service.Address, ShouldEqual, ":8081")
+				So(cfg.API.Active, ShouldBeFalse)
+				So(cfg.Database.MaxOpenConns, ShouldEqual, 20)
+				So(cfg.RateLimit.ClientIP.Rate, ShouldEqual, 2.5)
+				So(cfg.Web.AuthKeys, ShouldResemble, []string{"aa", "bb"})
+				So(cfg.Database.Payment.Write.DSN(), ShouldEqual, "test@tcp(db:3306)/payment")
+			})
+		})
+
+		Convey("When applying values from files", func() {
+			f, err := ioutil.TempFile("", "paymentd-env")
+			So(err, ShouldBeNil)
+			Reset(func() {
+				os.Remove(f.Name())
+			})
+			_, err = f.WriteString("# keys\naa\nbb\n")
+			So(err, ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+
+			err = cfg.ApplyEnv([]string{
+				"PAYMENTD_API_AUTH_KEYS_FILE=" + f.Name(),
+				"PAYMENTD_WEB_URL_FILE=" + f.Name(),
+			})
+
+			Convey("Lists should have one entry per line", func() {
+				So(err, ShouldBeNil)
+				So(cfg.API.AuthKeys, ShouldResemble, []string{"aa", "bb"})
+				So(cfg.Web.URL, ShouldEqual, "# keys\naa\nbb")
+			})
+		})
+
+		Convey("When a value is invalid", func() {
+			err := cfg.ApplyEnv([]string{"PAYMENTD_DATABASE_MAX_OPEN_CONNS=many"})
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a value and a file are set", func() {
+			err := cfg.ApplyEnv([]string{
+				"PAYMENTD_WEB_URL=http://localhost",
+				"PAYMENTD_WEB_URL_FILE=/dev/null",
+			})
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestReload(t *testing.T) {
+	Convey("Given a current and a changed config", t, func() {
+		cur := DefaultConfig()
+		next := DefaultConfig()
+		next.API.Timeout = Duration("30s")
+		next.Log.Level = "info"
+		next.RateLimit.Project.Rate = 10
+		next.API.Service.Address = ":8081"
+		next.API.TrustedProxies = nil
+		next.API.Service.TLS.CertFile = "cert.pem"
+
+		Convey("When reloading", func() {
+			cfg, applied, restart := Reload(cur, next)
+
+			Convey("The reloadable values should be applied", func() {
+				So(cfg.API.Timeout, ShouldEqual, Duration("30s"))
+				So(cfg.Log.Level, ShouldEqual, "info")
+				So(cfg.RateLimit.Project.Rate, ShouldEqual, 10)
+				So(cfg.API.Service.TLS.CertFile, ShouldEqual, "cert.pem")
+				So(applied, ShouldResemble, []string{"API.Service.TLS.CertFile", "API.Timeout", "Log.Level", "RateLimit.Project.Rate"})
+			})
+
+			Convey("The other changes should require a restart", func() {
+				So(cfg.API.Service.Address, ShouldEqual, ":8080")
+				So(restart, ShouldResemble, []string{"API.Service.Address"})
+			})
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable are the paths of the config values which can be changed without a
+// restart. A path includes all values below it
+var reloadable = []string{
+	"API.Service.ReadTimeout",
+	"API.Service.WriteTimeout",
+	"API.Service.TLS",
+	"API.Timeout",
+	"API.AuthKeys",
+	"Web.Service.ReadTimeout",
+	"Web.Service.WriteTimeout",
+	"Web.Service.TLS",
+	"Web.Timeout",
+	"Web.TemplateDir",
+	"Web.AuthKeys",
+	"Metrics.Service.ReadTimeout",
+	"Metrics.Service.WriteTimeout",
+	"Metrics.Service.TLS",
+	"Log",
+	"Provider.ProviderTemplateDir",
+	"RateLimit",
+}
+
+// Reloadable returns whether the config value at the given path can be changed
+// without a restart
+func Reloadable(path string) bool {
+	for _, p := range reloadable {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload returns the config cur with the reloadable values of next
+//
+// It returns the paths of the changed values which were applied and of the changed
+// values which require a restart and were not applied.
+func Reload(cur, next Config) (cfg Config, applied, restart []string) {
+	cfg = cur
+	nextV := reflect.ValueOf(next)
+	walkConfig(reflect.ValueOf(&cfg).Elem(), nil, func(path []string, v reflect.Value) error {
+		n := nextV
+		for _, name := range path {
+			n = n.FieldByName(name)
+		}
+		if equalValues(v, n) {
+			return nil
+		}
+		p := strings.Join(path, ".")
+		if !Reloadable(p) {
+			restart = append(restart, p)
+			return nil
+		}
+		v.Set(n)
+		applied = append(applied, p)
+		return nil
+	})
+	return cfg, applied, restart
+}
+
+// equalValues compares config values, treating nil and empty lists and maps as equal
+func equalValues(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Slice, reflect.Map:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
@@ -0,0 +1,116 @@
+package dialect
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownDialect = errors.New("unknown SQL dialect")
+)
+
+// Dialect describes a SQL database backend
+type Dialect interface {
+	// Name returns the name of the dialect, which is also the backend type in the
+	// database config
+	Name() string
+	// Rebind rewrites a query with ? placeholders and backtick quoted identifiers
+	// for the backend
+	Rebind(query string) string
+	// Upsert returns a statement which inserts a row with the given columns into the
+	// table. If a row with the same key exists, the columns which are not part of
+	// the key are updated instead.
+	//
+	// The statement uses ? placeholders for the column values.
+	Upsert(table string, key []string, columns ...string) string
+	// IsDeadlock returns true if the error was caused by a deadlock. The
+	// transaction should be retried.
+	IsDeadlock(err error) bool
+	// IsUniqueViolation returns true if the error was caused by a duplicate key
+	IsUniqueViolation(err error) bool
+	// Timestamp returns the time in UTC with the precision of the timestamp columns
+	Timestamp(t time.Time) time.Time
+}
+
+// Dialects are the supported dialects
+var Dialects = []Dialect{MySQL, Postgres, SQLite}
+
+// ByName returns the dialect with the given name
+func ByName(name string) (Dialect, error) {
+	for _, d := range Dialects {
+		if d.Name() == name {
+			return d, nil
+		}
+	}
+	return nil, ErrUnknownDialect
+}
+
+// Of returns the dialect of a database opened with Open
+func Of(db *sql.DB) Dialect {
+	if d, ok := db.Driver().(*dialectDriver); ok {
+		return d.dialect
+	}
+	return MySQL
+}
+
+// Open opens the database with the given backend type and DSN
+//
+// PostgreSQL and SQLite databases are opened with a driver which translates the
+// queries. Other backends are opened with sql.Open.
+func Open(backend, dsn string) (*sql.DB, error) {
+	for _, d := range wrappedDrivers {
+		if d.dialect.Name() == backend {
+			c, err := d.OpenConnector(dsn)
+			if err != nil {
+				return nil, err
+			}
+			return sql.OpenDB(c), nil
+		}
+	}
+	return sql.Open(backend, dsn)
+}
+
+// IsDeadlock returns true if the error was caused by a deadlock on any of the
+// supported backends
+func IsDeadlock(err error) bool {
+	for _, d := range Dialects {
+		if d.IsDeadlock(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUniqueViolation returns true if the error was caused by a duplicate key on any
+// of the supported backends
+func IsUniqueViolation(err error) bool {
+	for _, d := range Dialects {
+		if d.IsUniqueViolation(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// insertColumns returns the INSERT part of an upsert
+func insertColumns(table string, columns []string) string {
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" +
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+}
+
+// updateColumns returns the columns which are not part of the key
+func updateColumns(key, columns []string) []string {
+	isKey := make(map[string]bool, len(key))
+	for _, k := range key {
+		isKey[k] = true
+	}
+	update := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if !isKey[c] {
+			update = append(update, c)
+		}
+	}
+	return update
+}
@@ -0,0 +1,219 @@
+package dialect
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRebind(t *testing.T) {
+	Convey("Given a query with placeholders and quoted identifiers", t, func() {
+		query := "INSERT INTO `user` (name, `key`) VALUES (?, ?) -- why?\n/* ? */ ON CONFLICT DO NOTHING WHERE a = '?' AND b = \"?\" AND c = ?"
+
+		Convey("The MySQL and SQLite dialects should keep it", func() {
+			So(MySQL.Rebind(query), ShouldEqual, query)
+			So(SQLite.Rebind(query), ShouldEqual, query)
+		})
+		Convey("The Postgres dialect should number the placeholders outside of literals and comments", func() {
+			So(Postgres.Rebind(query), ShouldEqual, "INSERT INTO \"user\" (name, \"key\") VALUES ($1, $2) -- why?\n/* ? */ ON CONFLICT DO NOTHING WHERE a = '?' AND b = \"?\" AND c = $3")
+		})
+	})
+	Convey("Given a query with escaped quotes", t, func() {
+		query := "SELECT 'it''s ?' FROM t WHERE a = ?"
+
+		Convey("The Postgres dialect should only replace the placeholder", func() {
+			So(Postgres.Rebind(query), ShouldEqual, "SELECT 'it''s ?' FROM t WHERE a = $1")
+		})
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	Convey("Given a table with a key and a value column", t, func() {
+		Convey("The MySQL upsert should update on a duplicate key", func() {
+			So(MySQL.Upsert("config", []string{"name"}, "name", "value"), ShouldEqual,
+				"INSERT INTO config (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value)")
+		})
+		Convey("The Postgres upsert should update on a conflict", func() {
+			So(Postgres.Upsert("config", []string{"name"}, "name", "value"), ShouldEqual,
+				"INSERT INTO config (name, value) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value")
+		})
+		Convey("The SQLite upsert should update on a conflict", func() {
+			So(SQLite.Upsert("config", []string{"name"}, "name", "value"), ShouldEqual,
+				"INSERT INTO config (name, value) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET value = excluded.value")
+		})
+	})
+	Convey("Given only key columns", t, func() {
+		Convey("The MySQL upsert should ignore duplicates", func() {
+			So(MySQL.Upsert("provider", []string{"name"}, "name"), ShouldEqual,
+				"INSERT IGNORE INTO provider (name) VALUES (?)")
+		})
+		Convey("The Postgres upsert should do nothing on a conflict", func() {
+			So(Postgres.Upsert("provider", []string{"name"}, "name"), ShouldEqual,
+				"INSERT INTO provider (name) VALUES (?) ON CONFLICT (name) DO NOTHING")
+		})
+		Convey("The SQLite upsert should do nothing on a conflict", func() {
+			So(SQLite.Upsert("provider", []string{"name"}, "name"), ShouldEqual,
+				"INSERT INTO provider (name) VALUES (?) ON CONFLICT (name) DO NOTHING")
+		})
+	})
+}
+
+func TestErrorClassification(t *testing.T) {
+	Convey("Given driver errors", t, func() {
+		mysqlDeadlock := &mysql.MySQLError{Number: mysqlErrDeadlock}
+		mysqlDuplicate := &mysql.MySQLError{Number: mysqlErrDuplicate}
+		pgDeadlock := &pq.Error{Code: pgErrDeadlock}
+		pgSerialization := &pq.Error{Code: pgErrSerializationFailure}
+		pgDuplicate := &pq.Error{Code: pgErrUniqueViolation}
+		sqliteBusy := sqlite3.Error{Code: sqlite3.ErrBusy}
+		sqliteDuplicate := sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}
+		sqliteForeignKey := sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintForeignKey}
+
+		Convey("Deadlocks should be classified", func() {
+			So(MySQL.IsDeadlock(mysqlDeadlock), ShouldBeTrue)
+			So(MySQL.IsDeadlock(mysqlDuplicate), ShouldBeFalse)
+			So(Postgres.IsDeadlock(pgDeadlock), ShouldBeTrue)
+			So(Postgres.IsDeadlock(pgSerialization), ShouldBeTrue)
+			So(Postgres.IsDeadlock(pgDuplicate), ShouldBeFalse)
+			So(IsDeadlock(mysqlDeadlock), ShouldBeTrue)
+			So(SQLite.IsDeadlock(sqliteBusy), ShouldBeTrue)
+			So(SQLite.IsDeadlock(sqliteDuplicate), ShouldBeFalse)
+			So(IsDeadlock(pgDeadlock), ShouldBeTrue)
+			So(IsDeadlock(sqliteBusy), ShouldBeTrue)
+		})
+		Convey("Unique violations should be classified", func() {
+			So(MySQL.IsUniqueViolation(mysqlDuplicate), ShouldBeTrue)
+			So(Postgres.IsUniqueViolation(pgDuplicate), ShouldBeTrue)
+			So(IsUniqueViolation(mysqlDuplicate), ShouldBeTrue)
+			So(IsUniqueViolation(pgDuplicate), ShouldBeTrue)
+			So(SQLite.IsUniqueViolation(sqliteDuplicate), ShouldBeTrue)
+			So(SQLite.IsUniqueViolation(sqliteForeignKey), ShouldBeFalse)
+			So(IsUniqueViolation(sqliteDuplicate), ShouldBeTrue)
+			So(IsUniqueViolation(pgDeadlock), ShouldBeFalse)
+		})
+		Convey("Wrapped errors should be classified", func() {
+			So(IsDeadlock(fmt.Errorf("commit: %w", pgDeadlock)), ShouldBeTrue)
+		})
+		Convey("Other errors should not be classified", func() {
+			So(IsDeadlock(errors.New("deadlock")), ShouldBeFalse)
+			So(IsUniqueViolation(nil), ShouldBeFalse)
+		})
+	})
+}
+
+func TestTimestamp(t *testing.T) {
+	Convey("Given a time with fractional seconds", t, func() {
+		ts := time.Date(2015, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+
+		Convey("MySQL and PostgreSQL should round it to seconds in UTC", func() {
+			So(MySQL.Timestamp(ts), ShouldResemble, time.Date(2015, 1, 2, 2, 4, 6, 0, time.UTC))
+			So(Postgres.Timestamp(ts), ShouldResemble, time.Date(2015, 1, 2, 2, 4, 6, 0, time.UTC))
+		})
+		Convey("SQLite should truncate it to seconds in UTC", func() {
+			So(SQLite.Timestamp(ts), ShouldResemble, time.Date(2015, 1, 2, 2, 4, 5, 0, time.UTC))
+		})
+	})
+}
+
+func TestByName(t *testing.T) {
+	Convey("Given the dialect names", t, func() {
+		for _, d := range Dialects {
+			found, err := ByName(d.Name())
+			So(err, ShouldBeNil)
+			So(found.Name(), ShouldEqual, d.Name())
+		}
+		_, err := ByName("oracle")
+		So(err, ShouldEqual, ErrUnknownDialect)
+	})
+	Convey("Given databases opened with Open", t, func() {
+		db, err := Open(Postgres.Name(), "host=localhost dbname=paymentd sslmode=disable")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(Of(db).Name(), ShouldEqual, Postgres.Name())
+
+		db, err = Open(MySQL.Name(), "paymentd@tcp(localhost:3306)/paymentd")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(Of(db).Name(), ShouldEqual, MySQL.Name())
+
+		db, err = Open(SQLite.Name(), ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		So(Of(db).Name(), ShouldEqual, SQLite.Name())
+	})
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	Convey("Given a DSN without parameters", t, func() {
+		Convey("The defaults should be added", func() {
+			So(sqliteDSN("paymentd.db"), ShouldEqual, "paymentd.db?_foreign_keys=1&_txlock=immediate&_journal_mode=WAL")
+		})
+	})
+	Convey("Given a DSN with parameters", t, func() {
+		Convey("Only the missing defaults should be added", func() {
+			So(sqliteDSN("file:paymentd.db?_fk=0&_journal=DELETE"), ShouldEqual, "file:paymentd.db?_fk=0&_journal=DELETE&_txlock=immediate")
+		})
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	Convey("Given a SQLite database", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd_dialect")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		db, err := Open(SQLite.Name(), filepath.Join(dir, "test.db"))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			db.Close()
+		})
+		_, err = db.Exec("CREATE TABLE test (id INTEGER, name VARCHAR(64) NOT NULL, created DATETIME NOT NULL, PRIMARY KEY (id), CONSTRAINT test_name UNIQUE (name))")
+		So(err, ShouldBeNil)
+
+		Convey("When inserting a row", func() {
+			created := time.Date(2015, 1, 2, 3, 4, 5, 600000000, time.FixedZone("CET", 3600))
+			res, err := db.Exec("INSERT INTO `test` (`name`, `created`) VALUES (?, ?)", "test", created)
+			So(err, ShouldBeNil)
+
+			Convey("It should return the insert ID", func() {
+				id, err := res.LastInsertId()
+				So(err, ShouldBeNil)
+				So(id, ShouldEqual, 1)
+			})
+			Convey("The time should be stored with the precision of the dialect", func() {
+				var stored time.Time
+				err := db.QueryRow("SELECT created FROM test WHERE created = ?", created).Scan(&stored)
+				So(err, ShouldBeNil)
+				So(stored, ShouldResemble, SQLite.Timestamp(created))
+			})
+			Convey("When inserting a duplicate", func() {
+				_, err := db.Exec("INSERT INTO test (name, created) VALUES (?, ?)", "test", created)
+
+				Convey("It should be a unique violation", func() {
+					So(IsUniqueViolation(err), ShouldBeTrue)
+				})
+			})
+			Convey("When upserting the row", func() {
+				_, err := db.Exec(SQLite.Upsert("test", []string{"name"}, "name", "created"), "test", created.Add(time.Hour))
+				So(err, ShouldBeNil)
+
+				Convey("It should be updated", func() {
+					var count int
+					err := db.QueryRow("SELECT COUNT(*) FROM test WHERE created = ?", created.Add(time.Hour)).Scan(&count)
+					So(err, ShouldBeNil)
+					So(count, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,29 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package dialect hides the differences between the SQL database backends supported
+by paymentd, MySQL, PostgreSQL and the embedded SQLite.
+
+The queries of paymentd are written in the MySQL dialect, with ? placeholders and
+backtick quoted identifiers. Databases opened with Open translate the queries for
+the configured backend, so the SQL functions of the paymentd packages run unchanged
+on every backend.
+
+Errors of the database drivers are classified with IsDeadlock and
+IsUniqueViolation.
+*/
+package dialect
@@ -0,0 +1,268 @@
+package dialect
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	errNoInsertID = errors.New("no insert ID: statement is not an INSERT")
+)
+
+// wrappedConn is the set of interfaces required from the connections of a wrapped
+// driver
+type wrappedConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+type wrappedStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// dialectDriver wraps the driver of a backend. The connections translate the
+// queries with the dialect and apply the timestamp precision of the dialect to the
+// time arguments.
+type dialectDriver struct {
+	dialect Dialect
+	// connector returns a connector of the wrapped driver
+	connector func(dsn string) (driver.Connector, error)
+	// selectLastInsertID emulates the last insert ID of INSERT statements. It is
+	// empty if the wrapped driver supports it.
+	selectLastInsertID string
+}
+
+// wrappedDrivers are the drivers used by Open
+var wrappedDrivers = []*dialectDriver{postgresDriver, sqliteDriver}
+
+func (d *dialectDriver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+func (d *dialectDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	c, err := d.connector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return dialectConnector{connector: c, driver: d}, nil
+}
+
+type dialectConnector struct {
+	connector driver.Connector
+	driver    *dialectDriver
+}
+
+func (c dialectConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wc, ok := cn.(wrappedConn)
+	if !ok {
+		cn.Close()
+		return nil, errors.New("unsupported " + c.driver.dialect.Name() + " connection")
+	}
+	return &dialectConn{conn: wc, driver: c.driver}, nil
+}
+
+func (c dialectConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector is the connector of a driver without a connector of its own
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dialectConn rebinds the queries and rounds the time arguments
+//
+// If the last insert ID is emulated, the results of INSERT statements return the
+// value of the last used sequence of the session as the last insert ID, like
+// LAST_INSERT_ID() on MySQL. The ID must be retrieved before the connection runs
+// other statements, i.e. within a transaction.
+type dialectConn struct {
+	conn   wrappedConn
+	driver *dialectDriver
+}
+
+func (c *dialectConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *dialectConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.conn.PrepareContext(ctx, c.driver.dialect.Rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	ws, ok := stmt.(wrappedStmt)
+	if !ok {
+		stmt.Close()
+		return nil, errors.New("unsupported " + c.driver.dialect.Name() + " statement")
+	}
+	return &dialectStmt{stmt: ws, conn: c, insert: isInsert(query)}, nil
+}
+
+func (c *dialectConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *dialectConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *dialectConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *dialectConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.conn.ExecContext(ctx, c.driver.dialect.Rebind(query), c.roundTimes(args))
+	if err != nil {
+		return nil, err
+	}
+	return c.result(res, isInsert(query)), nil
+}
+
+func (c *dialectConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, c.driver.dialect.Rebind(query), c.roundTimes(args))
+}
+
+func (c *dialectConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *dialectConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *dialectConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// result wraps the result if the last insert ID is emulated
+func (c *dialectConn) result(res driver.Result, insert bool) driver.Result {
+	if c.driver.selectLastInsertID == "" {
+		return res
+	}
+	return dialectResult{Result: res, conn: c, insert: insert}
+}
+
+// lastInsertID returns the emulated last insert ID of the session
+func (c *dialectConn) lastInsertID() (int64, error) {
+	rows, err := c.conn.QueryContext(context.Background(), c.driver.selectLastInsertID, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	err = rows.Next(dest)
+	if err == io.EOF {
+		return 0, errNoInsertID
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, ok := dest[0].(int64)
+	if !ok {
+		return 0, errNoInsertID
+	}
+	return id, nil
+}
+
+// roundTimes applies the timestamp precision of the dialect to the time arguments
+func (c *dialectConn) roundTimes(args []driver.NamedValue) []driver.NamedValue {
+	for i := range args {
+		if t, ok := args[i].Value.(time.Time); ok {
+			args[i].Value = c.driver.dialect.Timestamp(t)
+		}
+	}
+	return args
+}
+
+type dialectStmt struct {
+	stmt   wrappedStmt
+	conn   *dialectConn
+	insert bool
+}
+
+func (s *dialectStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *dialectStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *dialectStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *dialectStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *dialectStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	res, err := s.stmt.ExecContext(ctx, s.conn.roundTimes(args))
+	if err != nil {
+		return nil, err
+	}
+	return s.conn.result(res, s.insert), nil
+}
+
+func (s *dialectStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.stmt.QueryContext(ctx, s.conn.roundTimes(args))
+}
+
+type dialectResult struct {
+	driver.Result
+	conn   *dialectConn
+	insert bool
+}
+
+func (r dialectResult) LastInsertId() (int64, error) {
+	if !r.insert {
+		return 0, errNoInsertID
+	}
+	return r.conn.lastInsertID()
+}
+
+func isInsert(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) >= 6 && strings.EqualFold(query[:6], "INSERT")
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
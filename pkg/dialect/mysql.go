@@ -0,0 +1,61 @@
+package dialect
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	// MySQL error numbers
+	mysqlErrDeadlock  = 1213
+	mysqlErrDuplicate = 1062
+)
+
+// MySQL is the dialect of MySQL, the default backend
+var MySQL Dialect = mysqlDialect{}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) Upsert(table string, key []string, columns ...string) string {
+	update := updateColumns(key, columns)
+	if len(update) == 0 {
+		return strings.Replace(insertColumns(table, columns), "INSERT", "INSERT IGNORE", 1)
+	}
+	set := make([]string, len(update))
+	for i, c := range update {
+		set[i] = c + " = VALUES(" + c + ")"
+	}
+	return insertColumns(table, columns) + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (mysqlDialect) IsDeadlock(err error) bool {
+	return mysqlErrorNumber(err) == mysqlErrDeadlock
+}
+
+func (mysqlDialect) IsUniqueViolation(err error) bool {
+	return mysqlErrorNumber(err) == mysqlErrDuplicate
+}
+
+// Timestamp rounds to seconds, like MySQL does on DATETIME columns
+func (mysqlDialect) Timestamp(t time.Time) time.Time {
+	return t.UTC().Round(time.Second)
+}
+
+func mysqlErrorNumber(err error) uint16 {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package dialect
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// PostgreSQL error codes
+	pgErrDeadlock             = "40P01"
+	pgErrSerializationFailure = "40001"
+	pgErrUniqueViolation      = "23505"
+)
+
+// Postgres is the dialect of PostgreSQL
+var Postgres Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+// Rebind replaces the ? placeholders with numbered placeholders ($1, $2, ...) and
+// the backticks with double quotes
+//
+// String literals, quoted identifiers and comments are left untouched.
+func (postgresDialect) Rebind(query string) string {
+	if !strings.ContainsAny(query, "?`") {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		case c == '`':
+			b.WriteByte('"')
+			continue
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+1])
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+4])
+			i += end + 3
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (postgresDialect) Upsert(table string, key []string, columns ...string) string {
+	insert := insertColumns(table, columns) + " ON CONFLICT (" + strings.Join(key, ", ") + ")"
+	update := updateColumns(key, columns)
+	if len(update) == 0 {
+		return insert + " DO NOTHING"
+	}
+	set := make([]string, len(update))
+	for i, c := range update {
+		set[i] = c + " = EXCLUDED." + c
+	}
+	return insert + " DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// IsDeadlock reports deadlocks and serialization failures, which are both resolved
+// by retrying the transaction
+func (postgresDialect) IsDeadlock(err error) bool {
+	code := pgErrorCode(err)
+	return code == pgErrDeadlock || code == pgErrSerializationFailure
+}
+
+func (postgresDialect) IsUniqueViolation(err error) bool {
+	return pgErrorCode(err) == pgErrUniqueViolation
+}
+
+// Timestamp rounds to seconds
+//
+// PostgreSQL keeps microseconds. The times are rounded like on the MySQL DATETIME
+// columns, so the stored values do not depend on the backend.
+func (postgresDialect) Timestamp(t time.Time) time.Time {
+	return t.UTC().Round(time.Second)
+}
+
+func pgErrorCode(err error) pq.ErrorCode {
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// postgresDriver wraps lib/pq, which has no last insert ID
+var postgresDriver = &dialectDriver{
+	dialect: Postgres,
+	connector: func(dsn string) (driver.Connector, error) {
+		return pq.NewConnector(dsn)
+	},
+	selectLastInsertID: "SELECT lastval()",
+}
@@ -0,0 +1,115 @@
+package dialect
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDSNDefaults are the connection parameters which are added to a SQLite DSN
+// unless it sets them
+//
+// Foreign keys are disabled by default on SQLite. Transactions acquire the write
+// lock when they begin, so concurrent transactions wait for each other instead of
+// failing when they start to write.
+var sqliteDSNDefaults = []struct {
+	names []string
+	value string
+}{
+	{[]string{"_foreign_keys", "_fk"}, "_foreign_keys=1"},
+	{[]string{"_txlock"}, "_txlock=immediate"},
+	{[]string{"_journal_mode", "_journal"}, "_journal_mode=WAL"},
+}
+
+// SQLite is the dialect of the embedded SQLite database
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+// Rebind keeps the query. SQLite understands ? placeholders and backtick quoted
+// identifiers.
+func (sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) Upsert(table string, key []string, columns ...string) string {
+	insert := insertColumns(table, columns) + " ON CONFLICT (" + strings.Join(key, ", ") + ")"
+	update := updateColumns(key, columns)
+	if len(update) == 0 {
+		return insert + " DO NOTHING"
+	}
+	set := make([]string, len(update))
+	for i, c := range update {
+		set[i] = c + " = excluded." + c
+	}
+	return insert + " DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// IsDeadlock reports a busy or locked database. The lock could not be acquired
+// within the busy timeout of the connection.
+func (sqliteDialect) IsDeadlock(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
+
+// Timestamp truncates to seconds in UTC, like the DATETIME columns of MySQL before
+// 5.6.4
+//
+// SQLite stores the times as text. The times are stored in the same zone and
+// precision, so they can be compared.
+func (sqliteDialect) Timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// sqliteDriver wraps mattn/go-sqlite3
+var sqliteDriver = &dialectDriver{
+	dialect: SQLite,
+	connector: func(dsn string) (driver.Connector, error) {
+		return dsnConnector{driver: &sqlite3.SQLiteDriver{}, dsn: sqliteDSN(dsn)}, nil
+	},
+}
+
+// sqliteDSN adds the default parameters to the DSN
+func sqliteDSN(dsn string) string {
+	var params url.Values
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		params, _ = url.ParseQuery(dsn[i+1:])
+	}
+	sep := "?"
+	if strings.ContainsRune(dsn, '?') {
+		sep = "&"
+	}
+	for _, def := range sqliteDSNDefaults {
+		set := false
+		for _, name := range def.names {
+			if _, ok := params[name]; ok {
+				set = true
+			}
+		}
+		if !set {
+			dsn += sep + def.value
+			sep = "&"
+		}
+	}
+	return dsn
+}
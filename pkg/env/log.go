@@ -40,7 +40,7 @@ func init() {
 	// We follow the new-style daemons approach
 	// see <http://0pointer.de/public/systemd-man/daemon.html#New-Style%20Daemons>
 	Log = log15.New()
-	Log.SetHandler(log15.StreamHandler(os.Stderr, DaemonFormat()))
+	Log.SetHandler(Levels.Handler(log15.StreamHandler(os.Stderr, DaemonFormat())))
 	golog.SetOutput(logBridge{Log})
 	err := mysql.SetLogger(mysqlLog{})
 	if err != nil {
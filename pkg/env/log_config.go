@@ -0,0 +1,238 @@
+package env
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// log formats
+const (
+	LogFormatDaemon = "daemon"
+	LogFormatLogfmt = "logfmt"
+	LogFormatJSON   = "json"
+)
+
+// log outputs
+const (
+	LogOutputStderr = "stderr"
+	LogOutputFile   = "file"
+	LogOutputSyslog = "syslog"
+)
+
+// logPkgKey is the context key holding the package of a logger
+const logPkgKey = "pkg"
+
+// Levels is the level filter of the default logger
+var Levels = NewLevelFilter(log15.LvlDebug)
+
+var (
+	logOutputMu sync.Mutex
+	logOutput   io.Closer
+)
+
+// LevelFilter filters log records by their level
+//
+// The minimum level can be overridden per package. Packages are matched by the "pkg"
+// context value of the logger. The longest matching package prefix wins.
+type LevelFilter struct {
+	mu    sync.RWMutex
+	lvl   log15.Lvl
+	pkgs  map[string]log15.Lvl
+	debug bool
+}
+
+// NewLevelFilter creates a level filter with the given minimum level
+func NewLevelFilter(lvl log15.Lvl) *LevelFilter {
+	return &LevelFilter{
+		lvl:  lvl,
+		pkgs: make(map[string]log15.Lvl),
+	}
+}
+
+// SetLevel sets the minimum level
+func (f *LevelFilter) SetLevel(lvl log15.Lvl) {
+	f.mu.Lock()
+	f.lvl = lvl
+	f.mu.Unlock()
+}
+
+// Level returns the minimum level
+func (f *LevelFilter) Level() log15.Lvl {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lvl
+}
+
+// SetPackageLevels replaces the level overrides per package
+func (f *LevelFilter) SetPackageLevels(pkgs map[string]log15.Lvl) {
+	cp := make(map[string]log15.Lvl, len(pkgs))
+	for pkg, lvl := range pkgs {
+		cp[pkg] = lvl
+	}
+	f.mu.Lock()
+	f.pkgs = cp
+	f.mu.Unlock()
+}
+
+// PackageLevels returns the level overrides per package
+func (f *LevelFilter) PackageLevels() map[string]log15.Lvl {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	cp := make(map[string]log15.Lvl, len(f.pkgs))
+	for pkg, lvl := range f.pkgs {
+		cp[pkg] = lvl
+	}
+	return cp
+}
+
+// SetDebug switches the debug mode, in which all records pass regardless of the
+// configured levels
+func (f *LevelFilter) SetDebug(debug bool) {
+	f.mu.Lock()
+	f.debug = debug
+	f.mu.Unlock()
+}
+
+// Debug returns whether the debug mode is on
+func (f *LevelFilter) Debug() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.debug
+}
+
+// Allow returns whether the record passes the filter
+func (f *LevelFilter) Allow(r *log15.Record) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.debug {
+		return true
+	}
+	lvl := f.lvl
+	if len(f.pkgs) > 0 {
+		var pkg string
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			if k, ok := r.Ctx[i].(string); ok && k == logPkgKey {
+				pkg, _ = r.Ctx[i+1].(string)
+			}
+		}
+		var match string
+		for prefix, pkgLvl := range f.pkgs {
+			if strings.HasPrefix(pkg, prefix) && len(prefix) >= len(match) {
+				match, lvl = prefix, pkgLvl
+			}
+		}
+	}
+	return r.Lvl <= lvl
+}
+
+// Handler wraps the given handler with the filter
+func (f *LevelFilter) Handler(h log15.Handler) log15.Handler {
+	return log15.FilterHandler(f.Allow, h)
+}
+
+// ConfigureLog sets up the default logger according to the Log section of the
+// configuration
+func ConfigureLog(cfg config.Config) error {
+	lvl, pkgs, err := logLevels(cfg)
+	if err != nil {
+		return err
+	}
+	format, err := logFormat(cfg.Log.Format, cfg.Log.Output)
+	if err != nil {
+		return err
+	}
+	var h log15.Handler
+	var closer io.Closer
+	switch cfg.Log.Output {
+	case "", LogOutputStderr:
+		h = log15.StreamHandler(os.Stderr, format)
+	case LogOutputFile:
+		var interval time.Duration
+		if cfg.Log.File.RotationInterval != "" {
+			interval, err = cfg.Log.File.RotationInterval.Duration()
+			if err != nil {
+				return fmt.Errorf("invalid log rotation interval: %v", err)
+			}
+		}
+		f, err := OpenRotatingFile(cfg.Log.File.Path, cfg.Log.File.MaxSize, interval, cfg.Log.File.MaxBackups)
+		if err != nil {
+			return err
+		}
+		h, closer = log15.StreamHandler(f, format), f
+	case LogOutputSyslog:
+		h, err = log15.SyslogHandler(cfg.Log.Syslog.Tag, format)
+		if err != nil {
+			return fmt.Errorf("error connecting to syslog: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown log output %s", cfg.Log.Output)
+	}
+
+	Levels.SetLevel(lvl)
+	Levels.SetPackageLevels(pkgs)
+	Log.SetHandler(Levels.Handler(h))
+
+	logOutputMu.Lock()
+	prev := logOutput
+	logOutput = closer
+	logOutputMu.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+	return nil
+}
+
+func logLevels(cfg config.Config) (log15.Lvl, map[string]log15.Lvl, error) {
+	lvl := log15.LvlDebug
+	if cfg.Log.Level != "" {
+		var err error
+		lvl, err = log15.LvlFromString(cfg.Log.Level)
+		if err != nil {
+			return lvl, nil, fmt.Errorf("invalid log level %s", cfg.Log.Level)
+		}
+	}
+	pkgs := make(map[string]log15.Lvl, len(cfg.Log.Packages))
+	for pkg, pkgLvl := range cfg.Log.Packages {
+		l, err := log15.LvlFromString(pkgLvl)
+		if err != nil {
+			return lvl, nil, fmt.Errorf("invalid log level %s for %s", pkgLvl, pkg)
+		}
+		pkgs[pkg] = l
+	}
+	return lvl, pkgs, nil
+}
+
+// logFormat returns the format with the given name
+//
+// Syslog assigns priorities itself, so the daemon format is replaced by logfmt for
+// syslog output.
+func logFormat(name, output string) (log15.Format, error) {
+	switch name {
+	case "", LogFormatDaemon:
+		if output == LogOutputSyslog {
+			return log15.LogfmtFormat(), nil
+		}
+		return DaemonFormat(), nil
+	case LogFormatLogfmt:
+		return log15.LogfmtFormat(), nil
+	case LogFormatJSON:
+		return log15.JsonFormat(), nil
+	}
+	return nil, fmt.Errorf("unknown log format %s", name)
+}
+
+// ToggleDebugLog switches the debug mode of the default logger and returns the new
+// state
+func ToggleDebugLog() bool {
+	debug := !Levels.Debug()
+	Levels.SetDebug(debug)
+	Log.Info("log debug mode switched", log15.Ctx{"debug": debug})
+	return debug
+}
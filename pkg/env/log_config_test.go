@@ -0,0 +1,170 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+func TestLevelFilter(t *testing.T) {
+	Convey("Given a level filter with package overrides", t, func() {
+		f := NewLevelFilter(log15.LvlInfo)
+		f.SetPackageLevels(map[string]log15.Lvl{
+			"service":     log15.LvlWarn,
+			"service/api": log15.LvlDebug,
+		})
+		h := &testHandler{}
+		log := log15.New()
+		log.SetHandler(f.Handler(h))
+
+		Convey("Records below the minimum level should be dropped", func() {
+			log.Debug("test")
+			So(h.record, ShouldBeNil)
+			log.Info("test")
+			So(h.record, ShouldNotBeNil)
+		})
+
+		Convey("The longest matching package prefix should win", func() {
+			log.New(log15.Ctx{logPkgKey: "service/web"}).Info("test")
+			So(h.record, ShouldBeNil)
+			log.New(log15.Ctx{logPkgKey: "service/api"}).Debug("test")
+			So(h.record, ShouldNotBeNil)
+		})
+
+		Convey("When the debug mode is switched on", func() {
+			f.SetDebug(true)
+
+			Convey("All records should pass", func() {
+				log.New(log15.Ctx{logPkgKey: "service/web"}).Debug("test")
+				So(h.record, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	Convey("Given a rotating file with a maximum size", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd-log")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		path := filepath.Join(dir, "paymentd.log")
+		f, err := OpenRotatingFile(path, 10, 0, 2)
+		So(err, ShouldBeNil)
+		Reset(func() {
+			f.Close()
+		})
+		now := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+		f.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+
+		Convey("When writing more than the maximum size", func() {
+			for i := 0; i < 4; i++ {
+				_, err = f.Write([]byte("12345678\n"))
+				So(err, ShouldBeNil)
+			}
+
+			Convey("The file should be rotated and old backups removed", func() {
+				backups, err := filepath.Glob(path + ".*")
+				So(err, ShouldBeNil)
+				So(len(backups), ShouldEqual, 2)
+				b, err := ioutil.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "12345678\n")
+			})
+		})
+
+		Convey("When an old backup cannot be removed", func() {
+			// a non-empty directory sorting before the rotated files
+			blocked := path + ".0"
+			So(os.MkdirAll(filepath.Join(blocked, "x"), 0750), ShouldBeNil)
+			_, err = f.Write([]byte("12345678\n"))
+			So(err, ShouldBeNil)
+			_, err = f.Write([]byte("12345678\n"))
+			So(err, ShouldBeNil)
+			n, err := f.Write([]byte("abcdefgh\n"))
+
+			Convey("The error should be returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+			Convey("The record should be written to the new file", func() {
+				So(n, ShouldEqual, 9)
+				b, err := ioutil.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "abcdefgh\n")
+			})
+		})
+	})
+
+	Convey("Given a rotating file with a rotation interval", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd-log")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		path := filepath.Join(dir, "paymentd.log")
+		f, err := OpenRotatingFile(path, 0, time.Hour, 0)
+		So(err, ShouldBeNil)
+		Reset(func() {
+			f.Close()
+		})
+		now := time.Now()
+		f.now = func() time.Time { return now }
+
+		Convey("When the interval passed", func() {
+			_, err = f.Write([]byte("first\n"))
+			So(err, ShouldBeNil)
+			now = now.Add(time.Hour)
+			_, err = f.Write([]byte("second\n"))
+			So(err, ShouldBeNil)
+
+			Convey("The file should be rotated", func() {
+				backups, err := filepath.Glob(path + ".*")
+				So(err, ShouldBeNil)
+				So(len(backups), ShouldEqual, 1)
+				b, err := ioutil.ReadFile(backups[0])
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "first\n")
+			})
+		})
+
+		Convey("When the file cannot be renamed", func() {
+			_, err = f.Write([]byte("first\n"))
+			So(err, ShouldBeNil)
+			now = now.Add(time.Hour)
+			// a non-empty directory in place of the rotated file
+			blocked := path + "." + now.UTC().Format(rotatedTimeFormat)
+			So(os.MkdirAll(filepath.Join(blocked, "x"), 0750), ShouldBeNil)
+			_, err = f.Write([]byte("second\n"))
+
+			Convey("The error should be returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+			Convey("The record should be written to the current file", func() {
+				b, err := ioutil.ReadFile(path)
+				So(err, ShouldBeNil)
+				So(string(b), ShouldEqual, "first\nsecond\n")
+			})
+
+			Convey("When the rotation is possible again", func() {
+				So(os.RemoveAll(blocked), ShouldBeNil)
+				_, err = f.Write([]byte("third\n"))
+
+				Convey("The file should be rotated", func() {
+					So(err, ShouldBeNil)
+					b, err := ioutil.ReadFile(path)
+					So(err, ShouldBeNil)
+					So(string(b), ShouldEqual, "third\n")
+				})
+			})
+		})
+	})
+}
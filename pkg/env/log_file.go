@@ -0,0 +1,175 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the format of the suffix of rotated log files
+const rotatedTimeFormat = "20060102T150405.000000000"
+
+var (
+	ErrNoLogFile = errors.New("no log file path configured")
+)
+
+// RotatingFile is a log file which is rotated when it exceeds a maximum size or after
+// an interval
+//
+// Rotated files get the time of the rotation as a suffix.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	now func() time.Time
+}
+
+// OpenRotatingFile opens the log file at the given path for appending
+//
+// A maxSize or interval of 0 disables the respective rotation. If maxBackups is
+// positive, only the given number of rotated files is kept.
+func OpenRotatingFile(path string, maxSize int64, interval time.Duration, maxBackups int) (*RotatingFile, error) {
+	if path == "" {
+		return nil, ErrNoLogFile
+	}
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	inf, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, inf.Size(), r.now()
+	return nil
+}
+
+// Write implements the io.Writer
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if r.due(int64(len(p))) {
+		rotateErr = r.rotate()
+		if r.f == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+func (r *RotatingFile) due(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.maxSize > 0 && r.size+n > r.maxSize {
+		return true
+	}
+	return r.interval > 0 && r.now().Sub(r.opened) >= r.interval
+}
+
+// rotate renames the log file and opens a new one
+//
+// If the rotation fails, the log file is opened again, so the records are still
+// written. The rotation is retried on the next write.
+func (r *RotatingFile) rotate() error {
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return r.reopen(err)
+	}
+	backup := r.path + "." + r.now().UTC().Format(rotatedTimeFormat)
+	err = os.Rename(r.path, backup)
+	if err != nil {
+		return r.reopen(err)
+	}
+	err = r.open()
+	if err != nil {
+		// move the file back to continue writing to it
+		os.Rename(backup, r.path)
+		return r.reopen(err)
+	}
+	return r.removeBackups()
+}
+
+// reopen opens the log file after a failed rotation and returns the cause of the
+// failure
+func (r *RotatingFile) reopen(cause error) error {
+	opened := r.opened
+	err := r.open()
+	if err != nil {
+		return err
+	}
+	// the rotation stays due
+	r.opened = opened
+	return cause
+}
+
+// removeBackups removes the oldest rotated files exceeding maxBackups
+func (r *RotatingFile) removeBackups() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	if len(backups) <= r.maxBackups {
+		return nil
+	}
+	// the time suffix sorts chronologically
+	sort.Strings(backups)
+	for _, b := range backups[:len(backups)-r.maxBackups] {
+		err = os.Remove(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,37 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package envelope provides envelope encryption for secrets stored in the database
+
+Every value is encrypted with its own random data key using AES-256-GCM. The data key
+is encrypted (wrapped) with a master key and stored alongside the ciphertext:
+
+	env2:<master key id>:<wrapped data key>:<ciphertext>
+
+The table, the column and the primary key of the row are authenticated as additional
+data (see AAD), so a sealed value cannot be moved to another row or column without
+being detected. Legacy env1 envelopes were sealed without additional data. They can
+still be opened and are resealed by ResealTable.
+
+Master keys are held in a Keyring. The first key of a keyring encrypts new values,
+all keys can decrypt. To rotate the master key, a new key is prepended to the keyring
+and all stored values are resealed with ResealTable.
+
+Values without an envelope prefix are considered plaintext. This allows enabling the
+encryption on an existing database.
+*/
+package envelope
@@ -0,0 +1,334 @@
+package envelope
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+)
+
+const (
+	// KeySize is the size of master and data keys in bytes (AES-256)
+	KeySize = 32
+
+	prefix = "env2:"
+	// legacyPrefix marks envelopes which are not bound to their row
+	legacyPrefix = "env1:"
+)
+
+var (
+	ErrInvalidKey      = errors.New("invalid master key")
+	ErrNoKeyring       = errors.New("no master key configured")
+	ErrUnknownKey      = errors.New("value was sealed with an unknown master key")
+	ErrInvalidEnvelope = errors.New("invalid envelope")
+)
+
+var encoding = base64.RawURLEncoding
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the master keys
+//
+// The first key is the current key, which is used for sealing new values.
+type Keyring struct {
+	keys []masterKey
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyID returns the identifier of a master key, which is stored with sealed values
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// NewKeyring creates a keyring with the given master keys
+//
+// The first key will be used for sealing.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeyring
+	}
+	k := &Keyring{keys: make([]masterKey, 0, len(keys))}
+	for _, key := range keys {
+		if len(key) != KeySize {
+			return nil, ErrInvalidKey
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, masterKey{id: KeyID(key), aead: aead})
+	}
+	return k, nil
+}
+
+// DecodeKeys decodes hex encoded master keys
+func DecodeKeys(hexKeys []string) ([][]byte, error) {
+	keys := make([][]byte, 0, len(hexKeys))
+	for _, h := range hexKeys {
+		key, err := hex.DecodeString(strings.TrimSpace(h))
+		if err != nil || len(key) != KeySize {
+			return nil, ErrInvalidKey
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ReadKeys reads hex encoded master keys, one per line
+//
+// Empty lines and lines starting with # are ignored.
+func ReadKeys(r io.Reader) ([][]byte, error) {
+	var hexKeys []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hexKeys = append(hexKeys, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return DecodeKeys(hexKeys)
+}
+
+// NewKeyringFromConfig creates a keyring with the master keys from the config
+//
+// If a master key file is configured, the keys are read from the file. Otherwise the
+// keys in the config are used. If no keys are configured, it returns a nil keyring.
+func NewKeyringFromConfig(cfg config.Config) (*Keyring, error) {
+	var keys [][]byte
+	var err error
+	if cfg.Encryption.MasterKeyFile != "" {
+		var f *os.File
+		f, err = os.Open(cfg.Encryption.MasterKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys, err = ReadKeys(f)
+		f.Close()
+	} else {
+		keys, err = DecodeKeys(cfg.Encryption.MasterKeys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return NewKeyring(keys...)
+}
+
+// AAD returns the additional data which binds a sealed value to its location in the
+// database
+//
+// The primary key values are formatted in a canonical way, so the additional data of
+// a row read from the database matches the additional data of the row written. Times
+// are formatted in UTC with a precision of seconds.
+func AAD(table, column string, primaryKey ...interface{}) []byte {
+	parts := make([]string, 0, len(primaryKey)+1)
+	parts = append(parts, table+"."+column)
+	for _, v := range primaryKey {
+		parts = append(parts, aadValue(v))
+	}
+	return []byte(strings.Join(parts, "\x00"))
+}
+
+func aadValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case int:
+		return strconv.Itoa(t)
+	case time.Time:
+		return t.UTC().Format("2006-01-02T15:04:05Z")
+	case *time.Time:
+		return aadValue(*t)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], aad)
+}
+
+// Seal encrypts the plaintext with a new data key and wraps the data key with the
+// current master key
+//
+// The additional data aad, see AAD, is authenticated along with the plaintext and
+// must be passed to Open again.
+func (k *Keyring) Seal(plaintext, aad []byte) (string, error) {
+	dataKey := make([]byte, KeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[0].aead, dataKey, aad)
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.keys[0].id + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+func parse(s string) (id string, wrapped, ciphertext []byte, err error) {
+	parts := strings.Split(s[len(prefix):], ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrInvalidEnvelope
+	}
+	wrapped, err = encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrInvalidEnvelope
+	}
+	ciphertext, err = encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrInvalidEnvelope
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
+
+// Open decrypts a sealed value
+//
+// The additional data must match the additional data the value was sealed with.
+// Values which are not sealed are returned as they are. Legacy envelopes, which were
+// sealed without additional data, are opened regardless of aad.
+func (k *Keyring) Open(s string, aad []byte) ([]byte, error) {
+	if !IsSealed(s) {
+		return []byte(s), nil
+	}
+	if strings.HasPrefix(s, legacyPrefix) {
+		aad = nil
+	}
+	id, wrapped, ciphertext, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, mk := range k.keys {
+		if mk.id != id {
+			continue
+		}
+		dataKey, err := open(mk.aead, wrapped, aad)
+		if err != nil {
+			return nil, fmt.Errorf("error unwrapping data key: %v", err)
+		}
+		dataAEAD, err := newAEAD(dataKey)
+		if err != nil {
+			return nil, err
+		}
+		return open(dataAEAD, ciphertext, aad)
+	}
+	return nil, ErrUnknownKey
+}
+
+// NeedsReseal returns true if the value is not sealed with the current master key or
+// is a legacy envelope
+func (k *Keyring) NeedsReseal(s string) bool {
+	return !strings.HasPrefix(s, prefix+k.keys[0].id+":")
+}
+
+// IsSealed returns true if the value is an envelope
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, prefix) || strings.HasPrefix(s, legacyPrefix)
+}
+
+var (
+	mKeyring       sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetKeyring sets the keyring used by SealString and OpenString
+//
+// A nil keyring disables sealing.
+func SetKeyring(k *Keyring) {
+	mKeyring.Lock()
+	defaultKeyring = k
+	mKeyring.Unlock()
+}
+
+// DefaultKeyring returns the keyring used by SealString and OpenString
+func DefaultKeyring() *Keyring {
+	mKeyring.RLock()
+	k := defaultKeyring
+	mKeyring.RUnlock()
+	return k
+}
+
+// SealString seals the value with the default keyring
+//
+// If no keyring is set, the value will be returned unchanged.
+func SealString(s string, aad []byte) (string, error) {
+	k := DefaultKeyring()
+	if k == nil {
+		return s, nil
+	}
+	return k.Seal([]byte(s), aad)
+}
+
+// OpenString opens the value with the default keyring
+//
+// Values which are not sealed are returned unchanged. Sealed values cannot be opened
+// without a keyring.
+func OpenString(s string, aad []byte) (string, error) {
+	if !IsSealed(s) {
+		return s, nil
+	}
+	k := DefaultKeyring()
+	if k == nil {
+		return "", ErrNoKeyring
+	}
+	b, err := k.Open(s, aad)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,211 @@
+package envelope
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestKeyring(t *testing.T) {
+	Convey("Given a keyring with a master key", t, func() {
+		k, err := NewKeyring(testKey(1))
+		So(err, ShouldBeNil)
+		aad := AAD("project_key", "secret", "key1", time.Date(2015, 1, 12, 10, 11, 12, 0, time.UTC))
+
+		Convey("When sealing a value", func() {
+			sealed, err := k.Seal([]byte("secret"), aad)
+			So(err, ShouldBeNil)
+
+			Convey("It should return an envelope", func() {
+				So(IsSealed(sealed), ShouldBeTrue)
+				So(sealed, ShouldNotContainSubstring, "secret")
+				So(k.NeedsReseal(sealed), ShouldBeFalse)
+			})
+			Convey("Sealing the same value again should use a new data key", func() {
+				again, err := k.Seal([]byte("secret"), aad)
+				So(err, ShouldBeNil)
+				So(again, ShouldNotEqual, sealed)
+			})
+			Convey("When opening the value", func() {
+				plain, err := k.Open(sealed, aad)
+				Convey("It should return the plaintext", func() {
+					So(err, ShouldBeNil)
+					So(string(plain), ShouldEqual, "secret")
+				})
+			})
+			Convey("When opening the value in another row", func() {
+				_, err := k.Open(sealed, AAD("project_key", "secret", "key2", time.Date(2015, 1, 12, 10, 11, 12, 0, time.UTC)))
+				Convey("Opening should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+			Convey("When opening the value in another column", func() {
+				_, err := k.Open(sealed, AAD("project_key", "previous_secret", "key1", time.Date(2015, 1, 12, 10, 11, 12, 0, time.UTC)))
+				Convey("Opening should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+			Convey("When the ciphertext was modified", func() {
+				parts := strings.Split(sealed, ":")
+				parts[3] = encoding.EncodeToString([]byte("modified ciphertext"))
+				_, err := k.Open(strings.Join(parts, ":"), aad)
+				Convey("Opening should fail", func() {
+					So(err, ShouldNotBeNil)
+				})
+			})
+
+			Convey("Given a keyring with a new master key", func() {
+				rotated, err := NewKeyring(testKey(2), testKey(1))
+				So(err, ShouldBeNil)
+
+				Convey("The value should need to be resealed", func() {
+					So(rotated.NeedsReseal(sealed), ShouldBeTrue)
+				})
+				Convey("The value should be opened with the previous key", func() {
+					plain, err := rotated.Open(sealed, aad)
+					So(err, ShouldBeNil)
+					So(string(plain), ShouldEqual, "secret")
+				})
+			})
+			Convey("Given a keyring without the master key", func() {
+				other, err := NewKeyring(testKey(2))
+				So(err, ShouldBeNil)
+
+				Convey("Opening should fail", func() {
+					_, err := other.Open(sealed, aad)
+					So(err, ShouldEqual, ErrUnknownKey)
+				})
+			})
+		})
+
+		Convey("Given a legacy envelope without additional data", func() {
+			legacy, err := k.Seal([]byte("secret"), nil)
+			So(err, ShouldBeNil)
+			legacy = legacyPrefix + strings.TrimPrefix(legacy, prefix)
+
+			Convey("It should be opened", func() {
+				plain, err := k.Open(legacy, aad)
+				So(err, ShouldBeNil)
+				So(string(plain), ShouldEqual, "secret")
+			})
+			Convey("It should need to be resealed", func() {
+				So(IsSealed(legacy), ShouldBeTrue)
+				So(k.NeedsReseal(legacy), ShouldBeTrue)
+			})
+		})
+
+		Convey("When opening a plaintext value", func() {
+			plain, err := k.Open("plaintext", aad)
+			Convey("It should return the value", func() {
+				So(err, ShouldBeNil)
+				So(string(plain), ShouldEqual, "plaintext")
+			})
+			Convey("It should need to be resealed", func() {
+				So(k.NeedsReseal("plaintext"), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given an invalid master key", t, func() {
+		_, err := NewKeyring([]byte("short"))
+		Convey("Creating a keyring should fail", func() {
+			So(err, ShouldEqual, ErrInvalidKey)
+		})
+	})
+}
+
+func TestReadKeys(t *testing.T) {
+	Convey("Given a key file", t, func() {
+		r := strings.NewReader("# current key\n" +
+			strings.Repeat("01", KeySize) + "\n\n" +
+			strings.Repeat("02", KeySize) + "\n")
+
+		Convey("When reading the keys", func() {
+			keys, err := ReadKeys(r)
+			Convey("It should return the keys in order", func() {
+				So(err, ShouldBeNil)
+				So(len(keys), ShouldEqual, 2)
+				So(keys[0], ShouldResemble, testKey(1))
+				So(keys[1], ShouldResemble, testKey(2))
+			})
+		})
+	})
+}
+
+func TestAAD(t *testing.T) {
+	Convey("Given the primary key of a row", t, func() {
+		ts := time.Date(2015, 1, 12, 10, 11, 12, 0, time.UTC)
+
+		Convey("The additional data should not depend on the types of the scanned values", func() {
+			So(AAD("auth_key", "key", []byte("web"), int64(3), ts.In(time.FixedZone("CET", 3600))), ShouldResemble, AAD("auth_key", "key", "web", 3, ts))
+		})
+		Convey("The additional data should differ by column", func() {
+			So(AAD("auth_key", "key", "web", 3), ShouldNotResemble, AAD("auth_key", "secret", "web", 3))
+		})
+	})
+}
+
+func TestDefaultKeyring(t *testing.T) {
+	Convey("Given no default keyring", t, func() {
+		SetKeyring(nil)
+		aad := AAD("project_key", "secret", "key1")
+
+		Convey("Sealing should return the plaintext", func() {
+			s, err := SealString("secret", aad)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, "secret")
+		})
+
+		Convey("Given a sealed value", func() {
+			k, err := NewKeyring(testKey(1))
+			So(err, ShouldBeNil)
+			sealed, err := k.Seal([]byte("secret"), aad)
+			So(err, ShouldBeNil)
+
+			Convey("Opening should fail", func() {
+				_, err := OpenString(sealed, aad)
+				So(err, ShouldEqual, ErrNoKeyring)
+			})
+
+			Convey("When setting the default keyring", func() {
+				SetKeyring(k)
+				Reset(func() {
+					SetKeyring(nil)
+				})
+
+				Convey("Opening should return the plaintext", func() {
+					s, err := OpenString(sealed, aad)
+					So(err, ShouldBeNil)
+					So(s, ShouldEqual, "secret")
+				})
+			})
+		})
+	})
+}
+
+func TestResealQueries(t *testing.T) {
+	Convey("Given a table with sealed columns", t, func() {
+		tbl := Table{
+			Name:       "project_key",
+			PrimaryKey: []string{"key", "timestamp"},
+			Columns:    []string{"secret"},
+		}
+
+		Convey("The MySQL queries should use backticks and ? placeholders", func() {
+			So(tbl.selectQuery(dialect.MySQL), ShouldEqual, "SELECT `key`, `timestamp`, `secret` FROM `project_key`")
+			So(tbl.updateQuery(dialect.MySQL, "secret"), ShouldEqual, "UPDATE `project_key` SET `secret` = ? WHERE `key` = ? AND `timestamp` = ?")
+		})
+		Convey("The PostgreSQL queries should use double quotes and numbered placeholders", func() {
+			So(tbl.selectQuery(dialect.Postgres), ShouldEqual, `SELECT "key", "timestamp", "secret" FROM "project_key"`)
+			So(tbl.updateQuery(dialect.Postgres, "secret"), ShouldEqual, `UPDATE "project_key" SET "secret" = $1 WHERE "key" = $2 AND "timestamp" = $3`)
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package envelope
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+)
+
+// Table describes a database table with sealed columns
+type Table struct {
+	Name       string
+	PrimaryKey []string
+	Columns    []string
+}
+
+func (t Table) selectQuery(d dialect.Dialect) string {
+	cols := append(append([]string{}, t.PrimaryKey...), t.Columns...)
+	return d.Rebind(fmt.Sprintf("SELECT `%s` FROM `%s`", strings.Join(cols, "`, `"), t.Name))
+}
+
+func (t Table) updateQuery(d dialect.Dialect, column string) string {
+	where := make([]string, len(t.PrimaryKey))
+	for i, pk := range t.PrimaryKey {
+		where[i] = fmt.Sprintf("`%s` = ?", pk)
+	}
+	return d.Rebind(fmt.Sprintf("UPDATE `%s` SET `%s` = ? WHERE %s", t.Name, column, strings.Join(where, " AND ")))
+}
+
+type resealValue struct {
+	column string
+	value  string
+	pk     []interface{}
+}
+
+// ResealTable seals all values in the table's columns which are not sealed with the
+// current master key
+//
+// Plaintext values will be sealed, values sealed with another master key of the keyring
+// and legacy envelopes will be resealed. The values are bound to the table, the column
+// and the primary key of their row, see AAD. The values are updated in place, so the database user requires
+// the UPDATE privilege on the table. If dryRun is true, no values will be updated.
+//
+// The queries are written for the given dialect of the database.
+//
+// It returns the number of (to be) updated values.
+func (k *Keyring) ResealTable(db *sql.DB, d dialect.Dialect, t Table, dryRun bool) (int, error) {
+	rows, err := db.Query(t.selectQuery(d))
+	if err != nil {
+		return 0, err
+	}
+	var reseal []resealValue
+	for rows.Next() {
+		pk := make([]interface{}, len(t.PrimaryKey))
+		values := make([]sql.NullString, len(t.Columns))
+		dest := make([]interface{}, 0, len(pk)+len(values))
+		for i := range pk {
+			dest = append(dest, &pk[i])
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		err = rows.Scan(dest...)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		for i, v := range values {
+			if !v.Valid || v.String == "" || !k.NeedsReseal(v.String) {
+				continue
+			}
+			aad := AAD(t.Name, t.Columns[i], pk...)
+			plain, err := k.Open(v.String, aad)
+			if err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("error opening %s.%s %v: %v", t.Name, t.Columns[i], pk, err)
+			}
+			sealed, err := k.Seal(plain, aad)
+			if err != nil {
+				rows.Close()
+				return 0, err
+			}
+			reseal = append(reseal, resealValue{column: t.Columns[i], value: sealed, pk: pk})
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if dryRun || len(reseal) == 0 {
+		return len(reseal), nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range reseal {
+		_, err = tx.Exec(t.updateQuery(d, r.column), append([]interface{}{r.value}, r.pk...)...)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return len(reseal), nil
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry(t *testing.T) {
+	Convey("Given a registry", t, func() {
+		r := NewRegistry()
+
+		Convey("When a counter is incremented", func() {
+			c := r.NewCounterVec("test_requests_total", "Requests.", "code")
+			c.Inc("200")
+			c.Inc("200")
+			c.Add(3, "500")
+			c.Add(-1, "500")
+
+			Convey("It should hold the values per label", func() {
+				So(c.Value("200"), ShouldEqual, 2)
+				So(c.Value("500"), ShouldEqual, 3)
+				So(c.Value("404"), ShouldEqual, 0)
+			})
+			Convey("It should be written in the text format", func() {
+				buf := &bytes.Buffer{}
+				So(r.Write(buf), ShouldBeNil)
+				So(buf.String(), ShouldEqual, `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="500"} 3
+`)
+			})
+			Convey("Registering the same name again should panic", func() {
+				So(func() { r.NewCounterVec("test_requests_total", "") }, ShouldPanic)
+			})
+			Convey("Using wrong label values should panic", func() {
+				So(func() { c.Inc() }, ShouldPanic)
+			})
+		})
+
+		Convey("When a histogram observes values", func() {
+			h := r.NewHistogramVec("test_duration_seconds", "Duration.", []float64{1, 0.1})
+			h.Observe(0.05)
+			h.Observe(0.5)
+			h.Observe(2)
+
+			Convey("It should be written with cumulative buckets", func() {
+				So(h.Count(), ShouldEqual, 3)
+				buf := &bytes.Buffer{}
+				So(r.Write(buf), ShouldBeNil)
+				So(buf.String(), ShouldEqual, `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 2.55
+test_duration_seconds_count 3
+`)
+			})
+		})
+
+		Convey("Given a gauge function with escaped label values", func() {
+			r.NewGaugeFunc("test_gauge", "A \\ gauge.", []string{"name"}, func() []Sample {
+				return []Sample{{LabelValues: []string{`a "b"`}, Value: 1.5}}
+			})
+
+			Convey("The handler should serve the gauge", func() {
+				w := httptest.NewRecorder()
+				r.Handler().ServeHTTP(w, &http.Request{})
+				So(w.Header().Get("Content-Type"), ShouldEqual, ContentType)
+				So(w.Body.String(), ShouldEqual, `# HELP test_gauge A \\ gauge.
+# TYPE test_gauge gauge
+test_gauge{name="a \"b\""} 1.5
+`)
+			})
+		})
+	})
+}
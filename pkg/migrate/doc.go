@@ -0,0 +1,30 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package migrate provides versioned schema migrations for the paymentd databases.
+
+The migrations are SQL files embedded in the binary. Each database has its own set
+of migrations per SQL dialect ("mysql", "postgres" or "sqlite3") in the directory
+sql/<dialect>/<database>, named <version>_<name>.up.sql and
+<version>_<name>.down.sql. The migrations of the dialects are kept in the same
+versions. The applied versions are recorded in the table schema_migration of the
+database.
+
+A migration which failed leaves the database dirty. A dirty database has to be
+repaired manually before further migrations can be run.
+*/
+package migrate
@@ -0,0 +1,189 @@
+package migrate
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+)
+
+const (
+	// DatabasePrincipal is the name of the principal database
+	DatabasePrincipal = "principal"
+	// DatabasePayment is the name of the payment database
+	DatabasePayment = "payment"
+)
+
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+var (
+	ErrUnknownDatabase = errors.New("unknown database")
+	ErrMissingScript   = errors.New("missing migration script")
+	ErrDuplicate       = errors.New("duplicate migration version")
+)
+
+// Databases are the names of the databases with migrations
+var Databases = []string{DatabasePrincipal, DatabasePayment}
+
+//go:embed sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a version of a database schema
+type Migration struct {
+	Version int
+	Name    string
+	// Up holds the SQL which migrates to this version
+	Up string
+	// Down holds the SQL which reverts this version
+	Down string
+}
+
+// FileName returns the file name of the migration script for the given direction
+// ("up" or "down")
+func FileName(version int, name, direction string) string {
+	return fmt.Sprintf("%04d_%s.%s.sql", version, name, direction)
+}
+
+// Migrations returns the embedded migrations of the database with the given name
+// for the given dialect, ordered by version
+func Migrations(d dialect.Dialect, database string) ([]Migration, error) {
+	for _, db := range Databases {
+		if db == database {
+			sub, err := fs.Sub(files, path.Join("sql", d.Name(), database))
+			if err != nil {
+				return nil, err
+			}
+			return Load(sub)
+		}
+	}
+	return nil, ErrUnknownDatabase
+}
+
+// Load reads the migrations from the files in the root directory of the given file
+// system, ordered by version
+//
+// Other files are ignored. Every migration must have an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	// the scripts found per version and direction
+	found := make(map[string]bool)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in %s: %v", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("%v: %d (%s and %s)", ErrDuplicate, version, mig.Name, m[2])
+		}
+		found[FileName(version, m[2], m[3])] = true
+		if m[3] == directionUp {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Version == 0 {
+			return nil, fmt.Errorf("invalid version 0 of migration %s", mig.Name)
+		}
+		for _, direction := range []string{directionUp, directionDown} {
+			if !found[FileName(mig.Version, mig.Name, direction)] {
+				return nil, fmt.Errorf("%v: %s", ErrMissingScript, FileName(mig.Version, mig.Name, direction))
+			}
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Sort(byVersionSort(migrations))
+	return migrations, nil
+}
+
+type byVersionSort []Migration
+
+func (m byVersionSort) Len() int           { return len(m) }
+func (m byVersionSort) Less(i, j int) bool { return m[i].Version < m[j].Version }
+func (m byVersionSort) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// Statements splits the SQL script into its statements
+//
+// Statements are separated by semicolons. Comments are removed. Semicolons in
+// quoted strings and identifiers are part of the statement. Changing the delimiter
+// is not supported.
+func Statements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	add := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	r := []rune(script)
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// quoted string or identifier
+			j := i + 1
+			for ; j < len(r) && r[j] != c; j++ {
+				if r[j] == '\\' && c != '`' {
+					j++
+				}
+			}
+			if j >= len(r) {
+				j = len(r) - 1
+			}
+			cur.WriteString(string(r[i : j+1]))
+			i = j
+		case c == '#' || (c == '-' && i+1 < len(r) && r[i+1] == '-' && (i+2 == len(r) || r[i+2] == ' ' || r[i+2] == '\t' || r[i+2] == '\n' || r[i+2] == '\r')):
+			// line comment
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+			cur.WriteRune('\n')
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			// block comment
+			i += 2
+			for i < len(r) && !(r[i] == '*' && i+1 < len(r) && r[i+1] == '/') {
+				i++
+			}
+			i++
+			cur.WriteRune(' ')
+		case c == ';':
+			add()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	add()
+	return stmts
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStatements(t *testing.T) {
+	Convey("Given a script with comments and quoted semicolons", t, func() {
+		script := `-- a comment; with a semicolon
+SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='TRADITIONAL,ALLOW_INVALID_DATES';
+
+/* block; comment */
+CREATE TABLE ` + "`a;b`" + ` (
+  id INT) # trailing; comment
+ENGINE = InnoDB;
+INSERT INTO t (s) VALUES ('it\'s; quoted'), ("--not a comment");
+SELECT 1--1;
+`
+		Convey("When splitting the script", func() {
+			stmts := Statements(script)
+
+			Convey("It should return the statements without comments", func() {
+				So(len(stmts), ShouldEqual, 4)
+				So(stmts[0], ShouldEqual, "SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='TRADITIONAL,ALLOW_INVALID_DATES'")
+				So(stmts[1], ShouldStartWith, "CREATE TABLE `a;b` (")
+				So(stmts[1], ShouldNotContainSubstring, "comment")
+				So(stmts[1], ShouldEndWith, "ENGINE = InnoDB")
+				So(stmts[2], ShouldEqual, `INSERT INTO t (s) VALUES ('it\'s; quoted'), ("--not a comment")`)
+				So(stmts[3], ShouldEqual, "SELECT 1--1")
+			})
+		})
+	})
+}
+
+func TestLoad(t *testing.T) {
+	Convey("Given migration files", t, func() {
+		fsys := fstest.MapFS{
+			"0002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+			"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+			"0001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+			"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+			"README":               {Data: []byte("ignored")},
+		}
+
+		Convey("When loading the migrations", func() {
+			migrations, err := Load(fsys)
+			So(err, ShouldBeNil)
+
+			Convey("They should be ordered by version", func() {
+				So(len(migrations), ShouldEqual, 2)
+				So(migrations[0].Version, ShouldEqual, 1)
+				So(migrations[0].Name, ShouldEqual, "first")
+				So(migrations[0].Down, ShouldEqual, "DROP TABLE a;")
+				So(migrations[1].Version, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a down script is missing", func() {
+			delete(fsys, "0002_second.down.sql")
+			_, err := Load(fsys)
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "0002_second.down.sql")
+			})
+		})
+
+		Convey("When a version is used twice", func() {
+			fsys["0002_other.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			_, err := Load(fsys)
+
+			Convey("It should return an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	Convey("Given the embedded migrations", t, func() {
+		for _, db := range Databases {
+			mysql, err := Migrations(dialect.MySQL, db)
+			So(err, ShouldBeNil)
+			So(len(mysql), ShouldBeGreaterThan, 0)
+			So(mysql[0].Version, ShouldEqual, 1)
+			for _, mig := range mysql {
+				So(len(Statements(mig.Up)), ShouldBeGreaterThan, 0)
+				So(len(Statements(mig.Down)), ShouldBeGreaterThan, 0)
+			}
+
+			for _, d := range dialect.Dialects {
+				if d == dialect.MySQL {
+					continue
+				}
+				migrations, err := Migrations(d, db)
+				So(err, ShouldBeNil)
+				So(len(migrations), ShouldEqual, len(mysql))
+				for i, mig := range migrations {
+					So(mig.Version, ShouldEqual, mysql[i].Version)
+					So(mig.Name, ShouldEqual, mysql[i].Name)
+					So(len(Statements(mig.Up)), ShouldBeGreaterThan, 0)
+					So(len(Statements(mig.Down)), ShouldBeGreaterThan, 0)
+				}
+			}
+		}
+		_, err := Migrations(dialect.MySQL, "unknown")
+		So(err, ShouldEqual, ErrUnknownDatabase)
+	})
+}
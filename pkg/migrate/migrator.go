@@ -0,0 +1,448 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+)
+
+const (
+	// VersionTable is the table holding the applied migrations
+	VersionTable = "schema_migration"
+
+	lockTimeout = 10 * time.Second
+)
+
+var (
+	ErrLocked         = errors.New("migrations are locked by another process")
+	ErrDirty          = errors.New("database is dirty")
+	ErrOutdated       = errors.New("database schema is outdated")
+	ErrNewer          = errors.New("database schema is newer than the known migrations")
+	ErrUnknownVersion = errors.New("unknown version")
+)
+
+const (
+	createVersionTableMySQL = `
+CREATE TABLE IF NOT EXISTS ` + VersionTable + ` (
+  version INT UNSIGNED NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty TINYINT(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (version))
+ENGINE = InnoDB
+`
+	createVersionTablePostgres = `
+CREATE TABLE IF NOT EXISTS ` + VersionTable + ` (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (version))
+`
+	createVersionTableSQLite = `
+CREATE TABLE IF NOT EXISTS ` + VersionTable + ` (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT 0,
+  PRIMARY KEY (version))
+`
+	selectVersionTableExistsMySQL = `
+SELECT COUNT(*)
+FROM information_schema.tables
+WHERE table_schema = DATABASE() AND table_name = ?
+`
+	selectVersionTableExistsPostgres = `
+SELECT COUNT(*)
+FROM information_schema.tables
+WHERE table_schema = current_schema() AND table_name = ?
+`
+	selectVersionTableExistsSQLite = `
+SELECT COUNT(*)
+FROM sqlite_master
+WHERE type = 'table' AND name = ?
+`
+	selectApplied = `
+SELECT version, name, applied, dirty
+FROM ` + VersionTable + `
+ORDER BY version
+`
+	insertApplied = `
+INSERT INTO ` + VersionTable + `
+(version, name, applied, dirty)
+VALUES
+(?, ?, ?, ?)
+`
+	updateDirty = `
+UPDATE ` + VersionTable + `
+SET dirty = ?
+WHERE version = ?
+`
+	deleteApplied = `
+DELETE FROM ` + VersionTable + `
+WHERE version = ?
+`
+	// the lock is per database schema
+	selectGetLockMySQL        = `SELECT GET_LOCK(CONCAT('paymentd.migrate.', DATABASE()), ?)`
+	selectReleaseLockMySQL    = `SELECT RELEASE_LOCK(CONCAT('paymentd.migrate.', DATABASE()))`
+	selectTryLockPostgres     = `SELECT pg_try_advisory_lock(hashtext('paymentd.migrate.' || current_schema()))`
+	selectReleaseLockPostgres = `SELECT pg_advisory_unlock(hashtext('paymentd.migrate.' || current_schema()))`
+	lockPollIntervalPostgres  = 100 * time.Millisecond
+)
+
+// backend holds the statements of the migrator which differ between the SQL
+// dialects
+type backend struct {
+	createVersionTable       string
+	selectVersionTableExists string
+	// lock acquires the migration lock, waiting at most lockTimeout
+	lock func(ctx context.Context, conn *sql.Conn) (bool, error)
+	// unlock releases the migration lock
+	unlock func(ctx context.Context, conn *sql.Conn)
+}
+
+var mysqlBackend = backend{
+	createVersionTable:       createVersionTableMySQL,
+	selectVersionTableExists: selectVersionTableExistsMySQL,
+	lock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var locked sql.NullInt64
+		err := conn.QueryRowContext(ctx, selectGetLockMySQL, int(lockTimeout/time.Second)).Scan(&locked)
+		return locked.Valid && locked.Int64 == 1, err
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) {
+		var released sql.NullInt64
+		conn.QueryRowContext(ctx, selectReleaseLockMySQL).Scan(&released)
+	},
+}
+
+var postgresBackend = backend{
+	createVersionTable:       createVersionTablePostgres,
+	selectVersionTableExists: selectVersionTableExistsPostgres,
+	lock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		// advisory locks have no timeout, so the lock is polled
+		deadline := time.Now().Add(lockTimeout)
+		for {
+			var locked bool
+			err := conn.QueryRowContext(ctx, selectTryLockPostgres).Scan(&locked)
+			if err != nil || locked || time.Now().After(deadline) {
+				return locked, err
+			}
+			time.Sleep(lockPollIntervalPostgres)
+		}
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) {
+		var released bool
+		conn.QueryRowContext(ctx, selectReleaseLockPostgres).Scan(&released)
+	},
+}
+
+var sqliteBackend = backend{
+	createVersionTable:       createVersionTableSQLite,
+	selectVersionTableExists: selectVersionTableExistsSQLite,
+	// SQLite has no named locks. The database file is used by a single node and
+	// writes to it are serialized, so the migrations are not locked.
+	lock: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		return true, nil
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) {},
+}
+
+// Applied is an applied migration
+type Applied struct {
+	Version int
+	Name    string
+	Applied time.Time
+	// Dirty is set when the migration failed
+	Dirty bool
+}
+
+// Status is the migration status of a database
+type Status struct {
+	// Version is the highest applied version, 0 if no migration was applied
+	Version int
+	// Latest is the version of the latest known migration
+	Latest  int
+	Dirty   bool
+	Applied []Applied
+	// Pending holds the known migrations which are not applied
+	Pending []Migration
+}
+
+// Migrator runs migrations on a database
+type Migrator struct {
+	db         *sql.DB
+	backend    backend
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given database with the given migrations
+// ordered by version
+//
+// The database must be opened with dialect.Open.
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	m := &Migrator{
+		db:         db,
+		backend:    mysqlBackend,
+		migrations: migrations,
+	}
+	switch dialect.Of(db) {
+	case dialect.Postgres:
+		m.backend = postgresBackend
+	case dialect.SQLite:
+		m.backend = sqliteBackend
+	}
+	return m
+}
+
+// Status returns the migration status
+//
+// It does not modify the database.
+func (m *Migrator) Status() (*Status, error) {
+	return m.status(m.db)
+}
+
+// queryer is implemented by the sql.DB and the sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (m *Migrator) status(q queryer) (*Status, error) {
+	st := &Status{}
+	if len(m.migrations) > 0 {
+		st.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+	var exists int
+	err := q.QueryRowContext(context.Background(), m.backend.selectVersionTableExists, VersionTable).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("error checking version table: %v", err)
+	}
+	applied := make(map[int]bool)
+	if exists > 0 {
+		rows, err := q.QueryContext(context.Background(), selectApplied)
+		if err != nil {
+			return nil, fmt.Errorf("error selecting applied migrations: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var a Applied
+			var ts int64
+			err = rows.Scan(&a.Version, &a.Name, &ts, &a.Dirty)
+			if err != nil {
+				return nil, fmt.Errorf("error scanning applied migration: %v", err)
+			}
+			a.Applied = time.Unix(ts, 0).UTC()
+			st.Applied = append(st.Applied, a)
+			st.Version = a.Version
+			st.Dirty = st.Dirty || a.Dirty
+			applied[a.Version] = true
+		}
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("error selecting applied migrations: %v", err)
+		}
+	}
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			st.Pending = append(st.Pending, mig)
+		}
+	}
+	return st, nil
+}
+
+// Check returns an error if the database is dirty, has pending migrations or
+// applied migrations which are not known
+//
+// The errors are ErrDirty, ErrOutdated or ErrNewer.
+func (m *Migrator) Check() error {
+	st, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if st.Dirty {
+		return ErrDirty
+	}
+	if len(st.Pending) > 0 {
+		return ErrOutdated
+	}
+	if st.Version > st.Latest {
+		return ErrNewer
+	}
+	return nil
+}
+
+// Up applies the pending migrations up to the given version, all pending
+// migrations if the version is 0
+//
+// It returns the applied migrations.
+func (m *Migrator) Up(version int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(conn *sql.Conn, st *Status) error {
+		for _, mig := range st.Pending {
+			if version > 0 && mig.Version > version {
+				break
+			}
+			err := m.run(conn, mig, directionUp)
+			if err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the applied migrations with a version greater than the given
+// version, all applied migrations if the version is 0
+//
+// It returns the reverted migrations.
+func (m *Migrator) Down(version int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(func(conn *sql.Conn, st *Status) error {
+		for i := len(st.Applied) - 1; i >= 0; i-- {
+			if st.Applied[i].Version <= version {
+				break
+			}
+			mig, ok := m.migration(st.Applied[i].Version)
+			if !ok {
+				return fmt.Errorf("%v: %d", ErrUnknownVersion, st.Applied[i].Version)
+			}
+			err := m.run(conn, mig, directionDown)
+			if err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force sets the recorded version to the given version without running any
+// scripts and clears the dirty flag
+//
+// It is used after a failed migration was repaired manually. Applied migrations
+// with a greater version are removed, known migrations up to the version are
+// recorded as applied.
+func (m *Migrator) Force(version int) error {
+	if version > 0 {
+		if _, ok := m.migration(version); !ok {
+			return fmt.Errorf("%v: %d", ErrUnknownVersion, version)
+		}
+	}
+	return m.withLock(func(conn *sql.Conn, st *Status) error {
+		ctx := context.Background()
+		for _, a := range st.Applied {
+			var err error
+			if a.Version > version {
+				_, err = conn.ExecContext(ctx, deleteApplied, a.Version)
+			} else if a.Dirty {
+				_, err = conn.ExecContext(ctx, updateDirty, false, a.Version)
+			}
+			if err != nil {
+				return fmt.Errorf("error forcing migration %d: %v", a.Version, err)
+			}
+		}
+		for _, mig := range st.Pending {
+			if mig.Version > version {
+				break
+			}
+			_, err := conn.ExecContext(ctx, insertApplied, mig.Version, mig.Name, time.Now().Unix(), false)
+			if err != nil {
+				return fmt.Errorf("error forcing migration %d: %v", mig.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) migration(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// locked calls f with a connection holding the migration lock and the current
+// status
+//
+// It returns ErrDirty if a previous migration failed.
+func (m *Migrator) locked(f func(conn *sql.Conn, st *Status) error) error {
+	return m.withLock(func(conn *sql.Conn, st *Status) error {
+		if st.Dirty {
+			return ErrDirty
+		}
+		return f(conn, st)
+	})
+}
+
+// withLock calls f with a connection holding the migration lock and the current
+// status
+//
+// All statements run on this connection, so the session variables set by the
+// scripts are kept.
+func (m *Migrator) withLock(f func(conn *sql.Conn, st *Status) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting database: %v", err)
+	}
+	defer conn.Close()
+	locked, err := m.backend.lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %v", err)
+	}
+	if !locked {
+		return ErrLocked
+	}
+	defer m.backend.unlock(ctx, conn)
+
+	_, err = conn.ExecContext(ctx, m.backend.createVersionTable)
+	if err != nil {
+		return fmt.Errorf("error creating version table: %v", err)
+	}
+	st, err := m.status(conn)
+	if err != nil {
+		return err
+	}
+	return f(conn, st)
+}
+
+// run runs the script of the migration for the given direction
+//
+// The migration is marked dirty until the script completed.
+func (m *Migrator) run(conn *sql.Conn, mig Migration, direction string) error {
+	ctx := context.Background()
+	script := mig.Up
+	var err error
+	if direction == directionUp {
+		_, err = conn.ExecContext(ctx, insertApplied, mig.Version, mig.Name, time.Now().Unix(), true)
+	} else {
+		script = mig.Down
+		_, err = conn.ExecContext(ctx, updateDirty, true, mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("error marking migration %d dirty: %v", mig.Version, err)
+	}
+	for _, stmt := range Statements(script) {
+		_, err = conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("error on migration %s: %v", FileName(mig.Version, mig.Name, direction), err)
+		}
+	}
+	if direction == directionUp {
+		_, err = conn.ExecContext(ctx, updateDirty, false, mig.Version)
+	} else {
+		_, err = conn.ExecContext(ctx, deleteApplied, mig.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording migration %d: %v", mig.Version, err)
+	}
+	return nil
+}
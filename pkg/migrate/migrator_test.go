@@ -0,0 +1,188 @@
+package migrate_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/migrate"
+	"github.com/fritzpay/paymentd/pkg/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testMigrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create",
+		Up:      "CREATE TABLE migrate_test (id INTEGER NOT NULL, PRIMARY KEY (id));",
+		Down:    "DROP TABLE migrate_test;",
+	},
+	{
+		Version: 2,
+		Name:    "add_name",
+		Up:      "ALTER TABLE migrate_test ADD COLUMN name VARCHAR(64) NULL;",
+		Down:    "ALTER TABLE migrate_test DROP COLUMN name;",
+	},
+}
+
+func TestMigrator(t *testing.T) {
+	Convey("Given a principal DB connection", t, testutil.WithPrincipalDB(t, func(db *sql.DB) {
+		Reset(func() {
+			db.Exec("DROP TABLE IF EXISTS migrate_test")
+			db.Exec("DROP TABLE IF EXISTS " + migrate.VersionTable)
+		})
+		db.Exec("DROP TABLE IF EXISTS " + migrate.VersionTable)
+
+		Convey("Given a migrator", func() {
+			m := migrate.NewMigrator(db, testMigrations)
+
+			Convey("The schema should be outdated", func() {
+				So(m.Check(), ShouldEqual, migrate.ErrOutdated)
+			})
+
+			Convey("When migrating up to version 1", func() {
+				done, err := m.Up(1)
+				So(err, ShouldBeNil)
+				So(len(done), ShouldEqual, 1)
+
+				Convey("The status should show the pending migration", func() {
+					st, err := m.Status()
+					So(err, ShouldBeNil)
+					So(st.Version, ShouldEqual, 1)
+					So(st.Latest, ShouldEqual, 2)
+					So(len(st.Pending), ShouldEqual, 1)
+				})
+
+				Convey("When migrating up", func() {
+					done, err := m.Up(0)
+					So(err, ShouldBeNil)
+					So(len(done), ShouldEqual, 1)
+					So(m.Check(), ShouldBeNil)
+
+					Convey("When migrating down to version 0", func() {
+						done, err := m.Down(0)
+						So(err, ShouldBeNil)
+						So(len(done), ShouldEqual, 2)
+						So(done[0].Version, ShouldEqual, 2)
+
+						st, err := m.Status()
+						So(err, ShouldBeNil)
+						So(st.Version, ShouldEqual, 0)
+						So(len(st.Applied), ShouldEqual, 0)
+					})
+				})
+
+				Convey("When a migration fails", func() {
+					failing := append(testMigrations[:1:1], migrate.Migration{
+						Version: 2,
+						Name:    "fail",
+						Up:      "ALTER TABLE migrate_missing ADD COLUMN name VARCHAR(64) NULL;",
+						Down:    "SELECT 1;",
+					})
+					m = migrate.NewMigrator(db, failing)
+					_, err := m.Up(0)
+					So(err, ShouldNotBeNil)
+
+					Convey("The database should be dirty", func() {
+						So(m.Check(), ShouldEqual, migrate.ErrDirty)
+						_, err = m.Up(0)
+						So(err, ShouldEqual, migrate.ErrDirty)
+					})
+
+					Convey("When forcing the previous version", func() {
+						So(m.Force(1), ShouldBeNil)
+
+						Convey("The failed migration should be pending", func() {
+							st, err := m.Status()
+							So(err, ShouldBeNil)
+							So(st.Dirty, ShouldBeFalse)
+							So(st.Version, ShouldEqual, 1)
+							So(m.Check(), ShouldEqual, migrate.ErrOutdated)
+						})
+					})
+
+					Convey("When forcing the failed version", func() {
+						So(m.Force(2), ShouldBeNil)
+
+						Convey("The database should be up to date", func() {
+							So(m.Check(), ShouldBeNil)
+						})
+					})
+
+					Convey("Forcing an unknown version should fail", func() {
+						So(m.Force(3), ShouldNotBeNil)
+					})
+				})
+			})
+		})
+	}))
+}
+
+func TestInstallSchemas(t *testing.T) {
+	Convey("Given the SQLite install schemas", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd_migrate")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+
+		for _, database := range migrate.Databases {
+			script, err := ioutil.ReadFile(filepath.Join("..", "..", "resources", "sqlite", "paymentd."+database+".sql"))
+			So(err, ShouldBeNil)
+			migrations, err := migrate.Migrations(dialect.SQLite, database)
+			So(err, ShouldBeNil)
+
+			installed, err := dialect.Open(dialect.SQLite.Name(), filepath.Join(dir, database+".installed.db"))
+			So(err, ShouldBeNil)
+			defer installed.Close()
+			for _, stmt := range migrate.Statements(string(script)) {
+				_, err = installed.Exec(stmt)
+				So(err, ShouldBeNil)
+			}
+			migrated, err := dialect.Open(dialect.SQLite.Name(), filepath.Join(dir, database+".migrated.db"))
+			So(err, ShouldBeNil)
+			defer migrated.Close()
+			_, err = migrate.NewMigrator(migrated, migrations).Up(0)
+			So(err, ShouldBeNil)
+
+			Convey("The "+database+" schema should be at the latest version", func() {
+				So(migrate.NewMigrator(installed, migrations).Check(), ShouldBeNil)
+			})
+			Convey("The "+database+" schema should match the migrated schema", func() {
+				So(sqliteColumns(installed), ShouldResemble, sqliteColumns(migrated))
+			})
+		}
+	})
+}
+
+// sqliteColumns returns the column definitions of all tables
+func sqliteColumns(db *sql.DB) map[string][]string {
+	tables := make(map[string][]string)
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table'")
+	So(err, ShouldBeNil)
+	var names []string
+	for rows.Next() {
+		var name string
+		So(rows.Scan(&name), ShouldBeNil)
+		names = append(names, name)
+	}
+	So(rows.Close(), ShouldBeNil)
+	for _, name := range names {
+		rows, err := db.Query("SELECT name, type, \"notnull\" FROM pragma_table_info(?)", name)
+		So(err, ShouldBeNil)
+		for rows.Next() {
+			var col, typ string
+			var notNull bool
+			So(rows.Scan(&col, &typ, &notNull), ShouldBeNil)
+			tables[name] = append(tables[name], col+" "+typ)
+			if notNull {
+				tables[name] = append(tables[name], col+" NOT NULL")
+			}
+		}
+		So(rows.Close(), ShouldBeNil)
+	}
+	return tables
+}
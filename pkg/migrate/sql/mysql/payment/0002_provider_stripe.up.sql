@@ -0,0 +1,14 @@
+-- -----------------------------------------------------
+-- Table `provider_stripe_config`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `provider_stripe_config` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `method_key` VARCHAR(64) NOT NULL,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `secure_key` TEXT NOT NULL,
+  `public_key` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `method_key`, `created`))
+ENGINE = InnoDB;
+
+INSERT IGNORE INTO `provider` (`name`) VALUES ('stripe');
@@ -0,0 +1,3 @@
+ALTER TABLE `payment_transaction`
+  DROP INDEX `request_id`,
+  DROP COLUMN `request_id`;
@@ -0,0 +1,3 @@
+ALTER TABLE `payment_transaction`
+  ADD COLUMN `request_id` VARCHAR(64) NULL AFTER `comment`,
+  ADD INDEX `request_id` (`request_id` ASC);
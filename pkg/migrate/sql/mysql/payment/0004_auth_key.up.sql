@@ -0,0 +1,10 @@
+-- -----------------------------------------------------
+-- Table `auth_key`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `auth_key` (
+  `keychain` VARCHAR(16) NOT NULL,
+  `generation` BIGINT UNSIGNED NOT NULL,
+  `created` BIGINT NOT NULL,
+  `key` TEXT NOT NULL,
+  PRIMARY KEY (`keychain`, `generation`))
+ENGINE = InnoDB;
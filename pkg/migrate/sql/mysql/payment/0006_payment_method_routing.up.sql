@@ -0,0 +1,10 @@
+-- -----------------------------------------------------
+-- Table `payment_method_routing`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `payment_method_routing` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `rules` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `timestamp`))
+ENGINE = InnoDB;
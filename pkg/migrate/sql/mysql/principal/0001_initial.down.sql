@@ -0,0 +1,11 @@
+SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0;
+
+DROP TABLE IF EXISTS `project_config`;
+DROP TABLE IF EXISTS `project_key`;
+DROP TABLE IF EXISTS `project_metadata`;
+DROP TABLE IF EXISTS `principal_metadata`;
+DROP TABLE IF EXISTS `project`;
+DROP TABLE IF EXISTS `principal_status`;
+DROP TABLE IF EXISTS `principal`;
+
+SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS;
@@ -0,0 +1,7 @@
+ALTER TABLE `project_key`
+  DROP COLUMN `public_keys`,
+  DROP COLUMN `ip_allowlist`,
+  DROP COLUMN `scopes`,
+  DROP COLUMN `previous_expires`,
+  DROP COLUMN `previous_secret`,
+  DROP COLUMN `expires`;
@@ -0,0 +1,7 @@
+ALTER TABLE `project_key`
+  ADD COLUMN `expires` DATETIME NULL AFTER `active`,
+  ADD COLUMN `previous_secret` TEXT NULL AFTER `expires`,
+  ADD COLUMN `previous_expires` DATETIME NULL AFTER `previous_secret`,
+  ADD COLUMN `scopes` VARCHAR(255) NULL AFTER `previous_expires`,
+  ADD COLUMN `ip_allowlist` TEXT NULL AFTER `scopes`,
+  ADD COLUMN `public_keys` TEXT NULL AFTER `ip_allowlist`;
@@ -0,0 +1,3 @@
+ALTER TABLE `project_config`
+  DROP COLUMN `rate_limit_burst`,
+  DROP COLUMN `ip_allowlist`;
@@ -0,0 +1,3 @@
+ALTER TABLE `project_config`
+  ADD COLUMN `ip_allowlist` TEXT NULL AFTER `return_url`,
+  ADD COLUMN `rate_limit_burst` INT UNSIGNED NULL AFTER `ip_allowlist`;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `user_mfa`;
+DROP TABLE IF EXISTS `user_role`;
+DROP TABLE IF EXISTS `user_password`;
+DROP TABLE IF EXISTS `user_status`;
+DROP TABLE IF EXISTS `user`;
@@ -0,0 +1,22 @@
+-- -----------------------------------------------------
+-- Table `audit_log`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `actor` VARCHAR(64) NOT NULL,
+  `action` VARCHAR(64) NOT NULL,
+  `entity` VARCHAR(64) NOT NULL,
+  `entity_id` VARCHAR(128) NOT NULL,
+  `before` TEXT NULL,
+  `after` TEXT NULL,
+  `source_ip` VARCHAR(64) NOT NULL,
+  `request_id` VARCHAR(64) NOT NULL,
+  `prev_hash` CHAR(64) NOT NULL,
+  `hash` CHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `prev_hash_UNIQUE` (`prev_hash` ASC),
+  INDEX `timestamp` (`timestamp` ASC),
+  INDEX `entity` (`entity` ASC, `entity_id` ASC),
+  INDEX `actor` (`actor` ASC))
+ENGINE = InnoDB;
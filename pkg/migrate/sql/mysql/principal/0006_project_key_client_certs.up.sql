@@ -0,0 +1,2 @@
+ALTER TABLE `project_key`
+  ADD COLUMN `client_certs` TEXT NULL AFTER `public_keys`;
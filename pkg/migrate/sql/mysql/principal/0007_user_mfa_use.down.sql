@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `user_recovery_code_use`;
+DROP TABLE IF EXISTS `user_totp_step`;
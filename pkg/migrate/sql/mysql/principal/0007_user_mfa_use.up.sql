@@ -0,0 +1,29 @@
+-- -----------------------------------------------------
+-- Table `user_totp_step`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `user_totp_step` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `step` BIGINT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `step`),
+  CONSTRAINT `fk_user_totp_step_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `user_recovery_code_use`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `user_recovery_code_use` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `code_hash` VARCHAR(64) NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `code_hash`),
+  CONSTRAINT `fk_user_recovery_code_use_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
@@ -0,0 +1,3 @@
+DELETE FROM provider WHERE name = 'stripe';
+
+DROP TABLE IF EXISTS provider_stripe_config;
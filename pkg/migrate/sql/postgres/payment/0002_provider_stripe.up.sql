@@ -0,0 +1,13 @@
+-- -----------------------------------------------------
+-- Table provider_stripe_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_stripe_config (
+  project_id BIGINT NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secure_key TEXT NOT NULL,
+  public_key TEXT NOT NULL,
+  PRIMARY KEY (project_id, method_key, created));
+
+INSERT INTO provider (name) VALUES ('stripe') ON CONFLICT DO NOTHING;
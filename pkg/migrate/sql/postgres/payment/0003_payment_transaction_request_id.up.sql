@@ -0,0 +1,3 @@
+ALTER TABLE payment_transaction ADD COLUMN IF NOT EXISTS request_id VARCHAR(64) NULL;
+
+CREATE INDEX IF NOT EXISTS payment_transaction_request_id ON payment_transaction (request_id);
@@ -0,0 +1,7 @@
+ALTER TABLE project_key
+  DROP COLUMN IF EXISTS public_keys,
+  DROP COLUMN IF EXISTS ip_allowlist,
+  DROP COLUMN IF EXISTS scopes,
+  DROP COLUMN IF EXISTS previous_expires,
+  DROP COLUMN IF EXISTS previous_secret,
+  DROP COLUMN IF EXISTS expires;
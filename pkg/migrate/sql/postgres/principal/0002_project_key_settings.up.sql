@@ -0,0 +1,7 @@
+ALTER TABLE project_key
+  ADD COLUMN IF NOT EXISTS expires TIMESTAMP(0) NULL,
+  ADD COLUMN IF NOT EXISTS previous_secret TEXT NULL,
+  ADD COLUMN IF NOT EXISTS previous_expires TIMESTAMP(0) NULL,
+  ADD COLUMN IF NOT EXISTS scopes VARCHAR(255) NULL,
+  ADD COLUMN IF NOT EXISTS ip_allowlist TEXT NULL,
+  ADD COLUMN IF NOT EXISTS public_keys TEXT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE project_config
+  DROP COLUMN IF EXISTS rate_limit_burst,
+  DROP COLUMN IF EXISTS ip_allowlist;
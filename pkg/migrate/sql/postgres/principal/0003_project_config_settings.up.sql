@@ -0,0 +1,3 @@
+ALTER TABLE project_config
+  ADD COLUMN IF NOT EXISTS ip_allowlist TEXT NULL,
+  ADD COLUMN IF NOT EXISTS rate_limit_burst BIGINT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE project_key
+  DROP COLUMN IF EXISTS client_certs;
@@ -0,0 +1,2 @@
+ALTER TABLE project_key
+  ADD COLUMN IF NOT EXISTS client_certs TEXT NULL;
@@ -0,0 +1,17 @@
+DROP TABLE IF EXISTS provider_paypal_authorization;
+DROP TABLE IF EXISTS provider_paypal_transaction;
+DROP TABLE IF EXISTS provider_paypal_config;
+DROP TABLE IF EXISTS provider_fritzpay_transaction;
+DROP TABLE IF EXISTS provider_fritzpay_payment;
+DROP TABLE IF EXISTS payment_transaction;
+DROP TABLE IF EXISTS payment_token;
+DROP TABLE IF EXISTS payment_metadata;
+DROP TABLE IF EXISTS payment_config;
+DROP TABLE IF EXISTS payment;
+DROP TABLE IF EXISTS payment_method_metadata;
+DROP TABLE IF EXISTS payment_method_status;
+DROP TABLE IF EXISTS currency;
+DROP TABLE IF EXISTS payment_method;
+DROP TABLE IF EXISTS provider;
+DROP TABLE IF EXISTS config;
+
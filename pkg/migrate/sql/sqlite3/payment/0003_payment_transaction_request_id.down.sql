@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS payment_transaction_request_id;
+
+ALTER TABLE payment_transaction DROP COLUMN request_id;
@@ -0,0 +1,14 @@
+-- -----------------------------------------------------
+-- Table payment_method_limits
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_limits (
+  payment_method_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  limits TEXT NOT NULL,
+  PRIMARY KEY (payment_method_id, timestamp),
+  CONSTRAINT fk_payment_method_limits_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
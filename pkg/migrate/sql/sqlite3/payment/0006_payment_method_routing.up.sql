@@ -0,0 +1,9 @@
+-- -----------------------------------------------------
+-- Table payment_method_routing
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_routing (
+  project_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  rules TEXT NOT NULL,
+  PRIMARY KEY (project_id, timestamp));
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS project_config;
+DROP TABLE IF EXISTS project_key;
+DROP TABLE IF EXISTS project_metadata;
+DROP TABLE IF EXISTS principal_metadata;
+DROP TABLE IF EXISTS project;
+DROP TABLE IF EXISTS principal_status;
+DROP TABLE IF EXISTS principal;
+
@@ -0,0 +1,116 @@
+-- -----------------------------------------------------
+-- Table principal
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal (
+  id INTEGER,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT principal_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table principal_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal_status (
+  principal_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (principal_id, timestamp),
+  CONSTRAINT fk_principal_status_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project (
+  id INTEGER,
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT project_project_name UNIQUE (principal_id, name),
+  CONSTRAINT fk_project_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table principal_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal_metadata (
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (principal_id, name, timestamp),
+  CONSTRAINT fk_principal_metadata_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_metadata (
+  project_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (project_id, name, timestamp),
+  CONSTRAINT fk_project_metadata_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project_key
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_key (
+  key VARCHAR(64) NOT NULL,
+  timestamp DATETIME NOT NULL,
+  project_id BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secret TEXT NOT NULL,
+  active BOOLEAN NOT NULL,
+  PRIMARY KEY (key, timestamp),
+  CONSTRAINT fk_project_key_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_key_project_id_idx ON project_key (project_id);
+
+-- -----------------------------------------------------
+-- Table project_config
+-- The callback project key has no foreign key, since the key column of the
+-- project_key table is not unique.
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_config (
+  project_id BIGINT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  web_url TEXT NULL,
+  callback_url TEXT NULL,
+  callback_api_version VARCHAR(32) NULL,
+  callback_project_key VARCHAR(64) NULL,
+  return_url TEXT NULL,
+  PRIMARY KEY (project_id, timestamp),
+  CONSTRAINT fk_project_config_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_config_project_key_idx ON project_config (callback_project_key);
+
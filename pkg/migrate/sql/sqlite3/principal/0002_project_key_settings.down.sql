@@ -0,0 +1,6 @@
+ALTER TABLE project_key DROP COLUMN public_keys;
+ALTER TABLE project_key DROP COLUMN ip_allowlist;
+ALTER TABLE project_key DROP COLUMN scopes;
+ALTER TABLE project_key DROP COLUMN previous_expires;
+ALTER TABLE project_key DROP COLUMN previous_secret;
+ALTER TABLE project_key DROP COLUMN expires;
@@ -0,0 +1,6 @@
+ALTER TABLE project_key ADD COLUMN expires DATETIME NULL;
+ALTER TABLE project_key ADD COLUMN previous_secret TEXT NULL;
+ALTER TABLE project_key ADD COLUMN previous_expires DATETIME NULL;
+ALTER TABLE project_key ADD COLUMN scopes VARCHAR(255) NULL;
+ALTER TABLE project_key ADD COLUMN ip_allowlist TEXT NULL;
+ALTER TABLE project_key ADD COLUMN public_keys TEXT NULL;
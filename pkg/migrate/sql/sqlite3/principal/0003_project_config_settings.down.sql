@@ -0,0 +1,2 @@
+ALTER TABLE project_config DROP COLUMN rate_limit_burst;
+ALTER TABLE project_config DROP COLUMN ip_allowlist;
@@ -0,0 +1,2 @@
+ALTER TABLE project_config ADD COLUMN ip_allowlist TEXT NULL;
+ALTER TABLE project_config ADD COLUMN rate_limit_burst BIGINT NULL;
@@ -0,0 +1,73 @@
+-- -----------------------------------------------------
+-- Table user
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS "user" (
+  id INTEGER,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT user_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table user_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_status (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_status_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_password
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_password (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  password VARCHAR(255) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_password_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_role
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_role (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  grants TEXT NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_role_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_mfa
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  required BOOLEAN NOT NULL,
+  totp_enabled BOOLEAN NOT NULL,
+  totp_secret TEXT NULL,
+  recovery_codes TEXT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_mfa_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
@@ -0,0 +1,22 @@
+-- -----------------------------------------------------
+-- Table audit_log
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER,
+  timestamp BIGINT NOT NULL,
+  actor VARCHAR(64) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  entity VARCHAR(64) NOT NULL,
+  entity_id VARCHAR(128) NOT NULL,
+  before TEXT NULL,
+  after TEXT NULL,
+  source_ip VARCHAR(64) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  prev_hash CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT audit_log_prev_hash_UNIQUE UNIQUE (prev_hash));
+
+CREATE INDEX IF NOT EXISTS audit_log_timestamp ON audit_log (timestamp);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor);
@@ -0,0 +1 @@
+ALTER TABLE project_key DROP COLUMN client_certs;
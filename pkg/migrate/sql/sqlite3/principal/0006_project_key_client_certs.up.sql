@@ -0,0 +1 @@
+ALTER TABLE project_key ADD COLUMN client_certs TEXT NULL;
@@ -0,0 +1,27 @@
+-- -----------------------------------------------------
+-- Table user_totp_step
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_totp_step (
+  user_id BIGINT NOT NULL,
+  step BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, step),
+  CONSTRAINT fk_user_totp_step_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_recovery_code_use
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_recovery_code_use (
+  user_id BIGINT NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, code_hash),
+  CONSTRAINT fk_user_recovery_code_use_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
@@ -0,0 +1,101 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForHeader is the header in which proxies pass on client addresses
+const ForwardedForHeader = "X-Forwarded-For"
+
+// Networks is a list of IP networks
+type Networks []*net.IPNet
+
+// ParseNetworks parses the given CIDR notations
+//
+// Plain IP addresses are accepted as single host networks.
+func ParseNetworks(cidrs []string) (Networks, error) {
+	nets := make(Networks, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %s", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s", c)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Contains returns true if any of the networks contains the given IP
+func (n Networks) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range n {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the CIDR notations of the networks
+func (n Networks) Strings() []string {
+	s := make([]string, len(n))
+	for i, ipNet := range n {
+		s[i] = ipNet.String()
+	}
+	return s
+}
+
+// RemoteIP returns the IP of the peer which sent the request
+func RemoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ClientIP returns the IP of the client which sent the request
+//
+// When the request was sent by a trusted proxy, the X-Forwarded-For header is
+// evaluated from right to left. The first address which is not a trusted proxy is
+// the client. Addresses left of it could be set by the client and are ignored.
+func ClientIP(r *http.Request, trustedProxies Networks) net.IP {
+	ip := RemoteIP(r)
+	if !trustedProxies.Contains(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header[ForwardedForHeader], ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// malformed, do not trust anything beyond
+			return ip
+		}
+		ip = hop
+		if !trustedProxies.Contains(ip) {
+			return ip
+		}
+	}
+	return ip
+}
@@ -0,0 +1,70 @@
+package netutil
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNetworks(t *testing.T) {
+	Convey("Given a list of networks", t, func() {
+		nets, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"})
+		So(err, ShouldBeNil)
+		So(len(nets), ShouldEqual, 3)
+
+		Convey("It should contain addresses in the networks", func() {
+			So(nets.Contains(net.ParseIP("10.1.2.3")), ShouldBeTrue)
+			So(nets.Contains(net.ParseIP("192.168.1.10")), ShouldBeTrue)
+			So(nets.Contains(net.ParseIP("2001:db8::1")), ShouldBeTrue)
+		})
+		Convey("It should not contain other addresses", func() {
+			So(nets.Contains(net.ParseIP("192.168.1.11")), ShouldBeFalse)
+			So(nets.Contains(net.ParseIP("11.0.0.1")), ShouldBeFalse)
+			So(nets.Contains(nil), ShouldBeFalse)
+		})
+		Convey("It should format as CIDR notation", func() {
+			So(nets.Strings(), ShouldResemble, []string{"10.0.0.0/8", "192.168.1.10/32", "2001:db8::/32"})
+		})
+	})
+
+	Convey("Given an invalid network", t, func() {
+		_, err := ParseNetworks([]string{"10.0.0.0/33"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	Convey("Given trusted proxies", t, func() {
+		proxies, err := ParseNetworks([]string{"10.0.0.0/8"})
+		So(err, ShouldBeNil)
+		r, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		Convey("When the request comes from an untrusted peer", func() {
+			r.RemoteAddr = "203.0.113.5:1234"
+			r.Header.Set(ForwardedForHeader, "198.51.100.1")
+
+			Convey("The header should be ignored", func() {
+				So(ClientIP(r, proxies).String(), ShouldEqual, "203.0.113.5")
+			})
+		})
+		Convey("When the request comes through trusted proxies", func() {
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set(ForwardedForHeader, "1.2.3.4, 198.51.100.1, 10.0.0.2")
+
+			Convey("The first untrusted address from the right should be the client", func() {
+				So(ClientIP(r, proxies).String(), ShouldEqual, "198.51.100.1")
+			})
+		})
+		Convey("When a trusted proxy sends a malformed header", func() {
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set(ForwardedForHeader, "unknown")
+
+			Convey("The proxy should be the client", func() {
+				So(ClientIP(r, proxies).String(), ShouldEqual, "10.0.0.1")
+			})
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// GenesisHash is the previous hash of the first entry in the audit log
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+const (
+	ActionCreate = "create"
+	ActionChange = "change"
+	ActionRotate = "rotate"
+	ActionRevoke = "revoke"
+	ActionNotify = "notify"
+)
+
+var (
+	// ErrChainBroken is returned by Verify if the hash chain is broken
+	ErrChainBroken = errors.New("audit log hash chain broken")
+)
+
+// Entry is an entry of the audit log
+type Entry struct {
+	ID        int64 `json:",string"`
+	Timestamp time.Time
+	Actor     string
+	Action    string
+	Entity    string
+	EntityID  string
+	// Before is the JSON representation of the entity before the change
+	Before json.RawMessage `json:",omitempty"`
+	// After is the JSON representation of the entity after the change
+	After     json.RawMessage `json:",omitempty"`
+	SourceIP  string
+	RequestID string
+	PrevHash  string
+	Hash      string
+}
+
+// NewEntry creates a new audit log entry
+//
+// The before and after values will be stored as their JSON representation. A nil
+// value will not be stored.
+func NewEntry(actor, action, entity, entityID string, before, after interface{}) (*Entry, error) {
+	e := &Entry{
+		Timestamp: time.Now(),
+		Actor:     actor,
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+	}
+	var err error
+	e.Before, err = marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	e.After, err = marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func marshal(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, []byte("null")) {
+		return nil, nil
+	}
+	return json.RawMessage(b), nil
+}
+
+// ComputeHash returns the hash of the entry, which includes the previous hash
+//
+// The ID is not part of the hash, since it is assigned by the database.
+func (e Entry) ComputeHash() string {
+	h := sha256.New()
+	for _, f := range []string{
+		e.PrevHash,
+		strconv.FormatInt(e.Timestamp.UnixNano(), 10),
+		e.Actor,
+		e.Action,
+		e.Entity,
+		e.EntityID,
+		string(e.Before),
+		string(e.After),
+		e.SourceIP,
+		e.RequestID,
+	} {
+		// length prefix to avoid ambiguities between field boundaries
+		fmt.Fprintf(h, "%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Chain sets the previous hash and the hash of the entry
+func (e *Entry) Chain(prevHash string) {
+	e.PrevHash = prevHash
+	e.Hash = e.ComputeHash()
+}
+
+// Change is a changed field of an entity
+type Change struct {
+	Before json.RawMessage `json:",omitempty"`
+	After  json.RawMessage `json:",omitempty"`
+}
+
+// Changes returns the top-level fields which differ between the before and after
+// representation of the entity
+//
+// If the entity is not a JSON object, the changes will contain the whole entity
+// under an empty key.
+func (e Entry) Changes() map[string]Change {
+	var before, after map[string]json.RawMessage
+	errBefore := unmarshalObject(e.Before, &before)
+	errAfter := unmarshalObject(e.After, &after)
+	if errBefore != nil || errAfter != nil {
+		if bytes.Equal(e.Before, e.After) {
+			return map[string]Change{}
+		}
+		return map[string]Change{"": {Before: e.Before, After: e.After}}
+	}
+	changes := make(map[string]Change)
+	for k, b := range before {
+		if a, ok := after[k]; !ok || !bytes.Equal(a, b) {
+			changes[k] = Change{Before: b, After: after[k]}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = Change{After: a}
+		}
+	}
+	return changes
+}
+
+func unmarshalObject(raw json.RawMessage, v *map[string]json.RawMessage) error {
+	if len(raw) == 0 {
+		*v = map[string]json.RawMessage{}
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Verify verifies the hash chain of the given entries
+//
+// The entries must be ordered by ID. The first entry is verified against the given
+// previous hash. It returns the ID of the first entry which breaks the chain.
+func Verify(prevHash string, entries []*Entry) (int64, error) {
+	for _, e := range entries {
+		if e.PrevHash != prevHash || e.ComputeHash() != e.Hash {
+			return e.ID, ErrChainBroken
+		}
+		prevHash = e.Hash
+	}
+	return 0, nil
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEntryChain(t *testing.T) {
+	Convey("Given a chain of audit log entries", t, func() {
+		first, err := NewEntry("root", ActionCreate, "principal", "1", nil, map[string]string{"Name": "test"})
+		So(err, ShouldBeNil)
+		first.ID = 1
+		first.Chain(GenesisHash)
+		second, err := NewEntry("root", ActionChange, "principal", "1", map[string]string{"Name": "test"}, map[string]string{"Name": "changed"})
+		So(err, ShouldBeNil)
+		second.ID = 2
+		second.Chain(first.Hash)
+		entries := []*Entry{first, second}
+
+		Convey("The hashes should be set", func() {
+			So(len(first.Hash), ShouldEqual, len(GenesisHash))
+			So(second.PrevHash, ShouldEqual, first.Hash)
+		})
+		Convey("The chain should verify", func() {
+			_, err := Verify(GenesisHash, entries)
+			So(err, ShouldBeNil)
+		})
+		Convey("When an entry was modified", func() {
+			first.Actor = "someone"
+			Convey("The chain should be broken at the modified entry", func() {
+				id, err := Verify(GenesisHash, entries)
+				So(err, ShouldEqual, ErrChainBroken)
+				So(id, ShouldEqual, 1)
+			})
+		})
+		Convey("When an entry was removed", func() {
+			Convey("The chain should be broken at the following entry", func() {
+				id, err := Verify(GenesisHash, entries[1:])
+				So(err, ShouldEqual, ErrChainBroken)
+				So(id, ShouldEqual, 2)
+			})
+		})
+		Convey("When an entry was rehashed without its successor", func() {
+			first.After = json.RawMessage(`{"Name":"other"}`)
+			first.Chain(GenesisHash)
+			Convey("The chain should be broken at the following entry", func() {
+				id, err := Verify(GenesisHash, entries)
+				So(err, ShouldEqual, ErrChainBroken)
+				So(id, ShouldEqual, 2)
+			})
+		})
+		Convey("When the timestamp was modified", func() {
+			second.Timestamp = second.Timestamp.Add(time.Nanosecond)
+			Convey("The chain should be broken", func() {
+				_, err := Verify(GenesisHash, entries)
+				So(err, ShouldEqual, ErrChainBroken)
+			})
+		})
+	})
+}
+
+func TestEntryChanges(t *testing.T) {
+	Convey("Given an entry of a changed entity", t, func() {
+		e, err := NewEntry("root", ActionChange, "project", "1",
+			map[string]interface{}{"Name": "test", "Active": true, "Removed": 1},
+			map[string]interface{}{"Name": "test", "Active": false, "Added": 2},
+		)
+		So(err, ShouldBeNil)
+
+		Convey("The changes should contain the changed fields", func() {
+			c := e.Changes()
+			So(len(c), ShouldEqual, 3)
+			So(string(c["Active"].Before), ShouldEqual, "true")
+			So(string(c["Active"].After), ShouldEqual, "false")
+			So(string(c["Removed"].Before), ShouldEqual, "1")
+			So(c["Removed"].After, ShouldBeNil)
+			So(c["Added"].Before, ShouldBeNil)
+			So(string(c["Added"].After), ShouldEqual, "2")
+		})
+	})
+	Convey("Given an entry of a created entity", t, func() {
+		e, err := NewEntry("root", ActionCreate, "project", "1", nil, map[string]string{"Name": "test"})
+		So(err, ShouldBeNil)
+
+		Convey("The before value should be empty", func() {
+			So(e.Before, ShouldBeNil)
+		})
+		Convey("The changes should contain all fields", func() {
+			c := e.Changes()
+			So(len(c), ShouldEqual, 1)
+			So(string(c["Name"].After), ShouldEqual, `"test"`)
+		})
+	})
+}
+
+func TestFilterQuery(t *testing.T) {
+	Convey("Given an empty filter", t, func() {
+		f := Filter{}
+		Convey("The query should use the default limit", func() {
+			_, args := f.query()
+			So(args, ShouldResemble, []interface{}{DefaultLimit})
+		})
+	})
+	Convey("Given a filter", t, func() {
+		from := time.Unix(10, 0)
+		f := Filter{
+			Actor:  "root",
+			Entity: "project",
+			From:   from,
+			Limit:  MaxLimit + 1,
+		}
+		Convey("The query should include the conditions", func() {
+			q, args := f.query()
+			So(q, ShouldContainSubstring, "actor = ?")
+			So(q, ShouldContainSubstring, "entity = ?")
+			So(q, ShouldContainSubstring, "timestamp >= ?")
+			So(q, ShouldNotContainSubstring, "action = ?")
+			So(args, ShouldResemble, []interface{}{"root", "project", from.UnixNano(), MaxLimit})
+		})
+	})
+}
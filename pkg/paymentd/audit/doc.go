@@ -0,0 +1,28 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package audit provides the audit log of administrative changes
+
+Every entry records who (actor) changed what (action, entity) from where (source IP,
+request ID), along with the JSON representation of the entity before and after
+the change.
+
+The audit log is append-only. Entries are chained by including the hash of the
+previous entry in the hash of each entry. Modifying or removing an entry will break
+the chain, which can be detected with Verify.
+*/
+package audit
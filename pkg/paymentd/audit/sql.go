@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+)
+
+const (
+	// DefaultLimit is the default number of entries returned by a query
+	DefaultLimit = 100
+	// MaxLimit is the maximum number of entries returned by a query
+	MaxLimit = 1000
+)
+
+var (
+	// ErrChainConflict is returned by InsertEntryDB if the entry could not be
+	// appended because of concurrent inserts
+	ErrChainConflict = errors.New("audit log chain conflict")
+)
+
+const selectEntry = `
+SELECT
+	id,
+	timestamp,
+	actor,
+	action,
+	entity,
+	entity_id,
+	` + "`before`" + `,
+	` + "`after`" + `,
+	source_ip,
+	request_id,
+	prev_hash,
+	hash
+FROM audit_log
+`
+
+const selectLastHash = `
+SELECT hash FROM audit_log
+ORDER BY id DESC
+LIMIT 1
+`
+
+const insertEntry = `
+INSERT INTO audit_log
+(timestamp, actor, action, entity, entity_id, ` + "`before`, `after`" + `, source_ip, request_id, prev_hash, hash)
+VALUES
+(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// Filter restricts the entries returned by EntriesDB
+//
+// Empty fields are not used for filtering.
+type Filter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID string
+	// From is the inclusive lower bound of the timestamp
+	From time.Time
+	// To is the exclusive upper bound of the timestamp
+	To time.Time
+	// BeforeID returns only entries with an ID lower than the given ID. It can be
+	// used for paging.
+	BeforeID int64
+	// Limit is the maximum number of returned entries
+	Limit int
+}
+
+func (f Filter) query() (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Entity != "" {
+		add("entity = ?", f.Entity)
+	}
+	if f.EntityID != "" {
+		add("entity_id = ?", f.EntityID)
+	}
+	if !f.From.IsZero() {
+		add("timestamp >= ?", f.From.UnixNano())
+	}
+	if !f.To.IsZero() {
+		add("timestamp < ?", f.To.UnixNano())
+	}
+	if f.BeforeID != 0 {
+		add("id < ?", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	q := selectEntry
+	if len(where) > 0 {
+		q += "WHERE\n\t" + strings.Join(where, "\n\tAND\n\t") + "\n"
+	}
+	q += "ORDER BY id DESC\nLIMIT ?\n"
+	args = append(args, limit)
+	return q, args
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEntry(row scanner) (*Entry, error) {
+	e := &Entry{}
+	var ts int64
+	var before, after sql.NullString
+	err := row.Scan(
+		&e.ID,
+		&ts,
+		&e.Actor,
+		&e.Action,
+		&e.Entity,
+		&e.EntityID,
+		&before,
+		&after,
+		&e.SourceIP,
+		&e.RequestID,
+		&e.PrevHash,
+		&e.Hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.Timestamp = time.Unix(0, ts)
+	if before.Valid {
+		e.Before = []byte(before.String)
+	}
+	if after.Valid {
+		e.After = []byte(after.String)
+	}
+	return e, nil
+}
+
+func scanEntries(rows *sql.Rows) ([]*Entry, error) {
+	entries := make([]*Entry, 0)
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	err := rows.Err()
+	rows.Close()
+	return entries, err
+}
+
+// EntriesDB selects the audit log entries matching the filter, newest first
+func EntriesDB(db *sql.DB, f Filter) ([]*Entry, error) {
+	q, args := f.query()
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanEntries(rows)
+}
+
+// VerifyDB verifies the hash chain of the whole audit log
+//
+// It returns the ID of the first entry which breaks the chain and the number of
+// verified entries.
+func VerifyDB(db *sql.DB) (int64, int, error) {
+	rows, err := db.Query(selectEntry + "ORDER BY id ASC\n")
+	if err != nil {
+		return 0, 0, err
+	}
+	prevHash := GenesisHash
+	var n int
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			rows.Close()
+			return 0, n, err
+		}
+		id, err := Verify(prevHash, []*Entry{e})
+		if err != nil {
+			rows.Close()
+			return id, n, err
+		}
+		prevHash = e.Hash
+		n++
+	}
+	err = rows.Err()
+	rows.Close()
+	return 0, n, err
+}
+
+func lastHashTx(db *sql.Tx) (string, error) {
+	var hash string
+	err := db.QueryRow(selectLastHash).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return GenesisHash, nil
+	}
+	return hash, err
+}
+
+// InsertEntryTx appends the entry to the audit log
+//
+// The entry will be chained to the last entry in the log. If another entry was
+// appended concurrently, it will return ErrChainConflict. Since the last entry is
+// read from the transaction's snapshot, retries require a new transaction.
+func InsertEntryTx(db *sql.Tx, e *Entry) error {
+	prevHash, err := lastHashTx(db)
+	if err != nil {
+		return err
+	}
+	e.Chain(prevHash)
+	stmt, err := db.Prepare(insertEntry)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var before, after sql.NullString
+	if len(e.Before) > 0 {
+		before.String, before.Valid = string(e.Before), true
+	}
+	if len(e.After) > 0 {
+		after.String, after.Valid = string(e.After), true
+	}
+	res, err := stmt.Exec(
+		e.Timestamp.UnixNano(),
+		e.Actor,
+		e.Action,
+		e.Entity,
+		e.EntityID,
+		before,
+		after,
+		e.SourceIP,
+		e.RequestID,
+		e.PrevHash,
+		e.Hash,
+	)
+	if err != nil {
+		if dialect.IsUniqueViolation(err) {
+			return ErrChainConflict
+		}
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// InsertEntryDB appends the entry to the audit log in its own transaction
+//
+// On concurrent inserts, it will retry up to maxRetries times.
+func InsertEntryDB(db *sql.DB, e *Entry, maxRetries int) error {
+	for i := 0; ; i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		err = InsertEntryTx(tx, e)
+		if err != nil {
+			tx.Rollback()
+			if err == ErrChainConflict && i < maxRetries {
+				continue
+			}
+			return err
+		}
+		return tx.Commit()
+	}
+}
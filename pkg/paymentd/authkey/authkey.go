@@ -0,0 +1,143 @@
+package authkey
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/envelope"
+)
+
+const (
+	// KeychainAPI is the name of the API keychain
+	KeychainAPI = "api"
+	// KeychainWeb is the name of the web keychain
+	KeychainWeb = "web"
+	// KeychainSigning is the name of the keychain holding the generated signing key
+	KeychainSigning = "signing"
+)
+
+var (
+	// ErrGenerationConflict is returned by InsertKeyDB if the generation was already
+	// inserted, i.e. by another node rotating at the same time
+	ErrGenerationConflict = errors.New("auth key generation conflict")
+)
+
+// Key is a stored key of a keychain
+type Key struct {
+	Keychain   string
+	Generation int64
+	Created    time.Time
+	Key        []byte
+}
+
+const selectActiveKeys = `
+SELECT
+	k.keychain,
+	k.generation,
+	k.created,
+	k.` + "`key`" + `
+FROM auth_key AS k
+LEFT JOIN auth_key AS n ON
+	n.keychain = k.keychain
+	AND
+	n.generation = k.generation + 1
+WHERE
+	k.keychain = ?
+	AND
+	(n.created IS NULL OR n.created > ?)
+ORDER BY k.generation DESC
+`
+
+const insertKey = `
+INSERT INTO auth_key
+(keychain, generation, created, ` + "`key`" + `)
+VALUES
+(?, ?, ?, ?)
+`
+
+// ActiveKeysDB selects the keys of the given keychain which are not retired, the
+// primary key first
+//
+// A key is retired when its successor was created more than maxAge ago.
+func ActiveKeysDB(db *sql.DB, keychain string, maxAge time.Duration) ([]*Key, error) {
+	rows, err := db.Query(selectActiveKeys, keychain, time.Now().Add(-maxAge).UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, 4)
+	for rows.Next() {
+		k := &Key{}
+		var created int64
+		var sealed string
+		err = rows.Scan(&k.Keychain, &k.Generation, &created, &sealed)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		k.Created = time.Unix(0, created)
+		hexKey, err := envelope.OpenString(sealed, envelope.AAD("auth_key", "key", k.Keychain, k.Generation))
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		k.Key, err = hex.DecodeString(hexKey)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	err = rows.Err()
+	rows.Close()
+	return keys, err
+}
+
+// InsertKeyDB saves the given key
+//
+// If the generation of the key already exists, it returns ErrGenerationConflict.
+func InsertKeyDB(db *sql.DB, k *Key) error {
+	sealed, err := envelope.SealString(hex.EncodeToString(k.Key), envelope.AAD("auth_key", "key", k.Keychain, k.Generation))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(insertKey, k.Keychain, k.Generation, k.Created.UnixNano(), sealed)
+	if dialect.IsUniqueViolation(err) {
+		return ErrGenerationConflict
+	}
+	return err
+}
+
+// PrimaryKeyDB returns the primary key of the given keychain
+//
+// If the keychain has no keys, the key returned by newKey is saved as the first
+// generation. When another node saved the first generation at the same time, its key
+// is returned instead, so all nodes share the same key.
+func PrimaryKeyDB(db *sql.DB, keychain string, newKey func() ([]byte, error)) (*Key, error) {
+	keys, err := ActiveKeysDB(db, keychain, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		return keys[0], nil
+	}
+	k := &Key{
+		Keychain:   keychain,
+		Generation: 1,
+		Created:    time.Now(),
+	}
+	k.Key, err = newKey()
+	if err != nil {
+		return nil, err
+	}
+	err = InsertKeyDB(db, k)
+	if err == ErrGenerationConflict {
+		return PrimaryKeyDB(db, keychain, newKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
@@ -0,0 +1,100 @@
+package authkey_test
+
+import (
+	"database/sql"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/authkey"
+	"github.com/fritzpay/paymentd/pkg/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestActiveKeys(t *testing.T) {
+	Convey("Given a DB connection", t, testutil.WithPaymentDB(t, func(db *sql.DB) {
+		Reset(func() {
+			db.Close()
+		})
+
+		Convey("Given a keychain with three generations", func() {
+			keychain := "t" + strconv.FormatInt(rand.Int63n(1e12), 36)
+			now := time.Now()
+			for i, age := range []time.Duration{3 * time.Hour, 2 * time.Hour, 0} {
+				err := authkey.InsertKeyDB(db, &authkey.Key{
+					Keychain:   keychain,
+					Generation: int64(i + 1),
+					Created:    now.Add(-age),
+					Key:        []byte{byte(i + 1)},
+				})
+				So(err, ShouldBeNil)
+			}
+
+			Convey("When selecting the active keys", func() {
+				keys, err := authkey.ActiveKeysDB(db, keychain, time.Hour)
+				So(err, ShouldBeNil)
+
+				Convey("The primary key should be first", func() {
+					So(len(keys), ShouldEqual, 2)
+					So(keys[0].Generation, ShouldEqual, 3)
+					So(keys[0].Key, ShouldResemble, []byte{3})
+				})
+				Convey("Keys with an old successor should be retired", func() {
+					So(keys[1].Generation, ShouldEqual, 2)
+				})
+			})
+
+			Convey("When inserting an existing generation", func() {
+				err := authkey.InsertKeyDB(db, &authkey.Key{
+					Keychain:   keychain,
+					Generation: 3,
+					Created:    now,
+					Key:        []byte{4},
+				})
+
+				Convey("It should return a conflict", func() {
+					So(err, ShouldEqual, authkey.ErrGenerationConflict)
+				})
+			})
+		})
+	}))
+}
+
+func TestPrimaryKey(t *testing.T) {
+	Convey("Given a DB connection", t, testutil.WithPaymentDB(t, func(db *sql.DB) {
+		Reset(func() {
+			db.Close()
+		})
+
+		Convey("Given an empty keychain", func() {
+			keychain := "t" + strconv.FormatInt(rand.Int63n(1e12), 36)
+			var generated int
+			newKey := func() ([]byte, error) {
+				generated++
+				return []byte{byte(generated)}, nil
+			}
+
+			Convey("When requesting the primary key", func() {
+				k, err := authkey.PrimaryKeyDB(db, keychain, newKey)
+				So(err, ShouldBeNil)
+
+				Convey("A new key should be saved as the first generation", func() {
+					So(generated, ShouldEqual, 1)
+					So(k.Generation, ShouldEqual, 1)
+					So(k.Key, ShouldResemble, []byte{1})
+				})
+
+				Convey("When requesting the primary key again", func() {
+					again, err := authkey.PrimaryKeyDB(db, keychain, newKey)
+					So(err, ShouldBeNil)
+
+					Convey("The saved key should be returned", func() {
+						So(generated, ShouldEqual, 1)
+						So(again.Key, ShouldResemble, k.Key)
+					})
+				})
+			})
+		})
+	}))
+}
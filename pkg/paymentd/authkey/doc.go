@@ -0,0 +1,29 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package authkey provides the database storage of the keys which encrypt authorization
+containers.
+
+Keys are stored per keychain (i.e. the API and the web keychain) with an increasing
+generation. The key with the highest generation is the primary key. A key is retired
+once its successor is older than the maximum age of authorization containers, since
+no valid container can be encrypted with it anymore.
+
+The storage is append-only. Concurrent rotations are resolved by the uniqueness of
+the generation.
+*/
+package authkey
@@ -211,6 +211,33 @@ func TestPaymentSQL(t *testing.T) {
 								})
 							})
 						})
+
+						Convey("Given the test payment has a transaction with a request ID", func() {
+							paymentTx := p.NewTransaction(payment.PaymentStatusOpen)
+							paymentTx.SetRequestID("test-request")
+							err = payment.InsertPaymentTransactionTx(tx, paymentTx)
+							So(err, ShouldBeNil)
+
+							Convey("When selecting the transaction", func() {
+								current, err := payment.PaymentTransactionCurrentTx(tx, p)
+								So(err, ShouldBeNil)
+
+								Convey("The request ID should be set", func() {
+									So(current.RequestID.Valid, ShouldBeTrue)
+									So(current.RequestID.String, ShouldEqual, "test-request")
+								})
+							})
+
+							Convey("When selecting the payments by the request ID", func() {
+								ids, err := payment.PaymentIDsByRequestIDTx(tx, "test-request")
+								So(err, ShouldBeNil)
+
+								Convey("It should return the payment", func() {
+									So(len(ids), ShouldEqual, 1)
+									So(ids[0], ShouldResemble, p.PaymentID())
+								})
+							})
+						})
 					}))
 				})
 			}))
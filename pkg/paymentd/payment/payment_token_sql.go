@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
+	"github.com/fritzpay/paymentd/pkg/dialect"
 )
 
 func InsertPaymentTokenTx(tx *sql.Tx, t *PaymentToken) error {
@@ -14,6 +14,12 @@ INSERT INTO payment_token
 VALUES
 (?, ?, ?, ?)
 `
+	// a duplicate key aborts the transaction on PostgreSQL. the savepoint keeps it
+	// usable for the retry with a new token
+	_, err := tx.Exec("SAVEPOINT payment_token")
+	if err != nil {
+		return err
+	}
 	stmt, err := tx.Prepare(insert)
 	if err != nil {
 		return err
@@ -25,15 +31,16 @@ VALUES
 		t.id.PaymentID)
 	stmt.Close()
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			// MySQL Error 1062 duplicate key
-			if mysqlErr.Number == 1062 {
-				err = t.GenerateToken()
-				if err != nil {
-					return err
-				}
-				return InsertPaymentTokenTx(tx, t)
+		if dialect.IsUniqueViolation(err) {
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT payment_token")
+			if err != nil {
+				return err
+			}
+			err = t.GenerateToken()
+			if err != nil {
+				return err
 			}
+			return InsertPaymentTokenTx(tx, t)
 		}
 		return err
 	}
@@ -94,3 +101,35 @@ func DeletePaymentTokenTx(db *sql.Tx, token string) error {
 	stmt.Close()
 	return err
 }
+
+const selectPaymentTokensByPaymentID = `
+SELECT
+	token,
+	created
+FROM payment_token
+WHERE
+	project_id = ?
+	AND
+	payment_id = ?
+ORDER BY created ASC
+`
+
+// PaymentTokensByPaymentIDDB returns all tokens of the given payment, including the
+// expired ones
+func PaymentTokensByPaymentIDDB(db *sql.DB, id PaymentID) ([]*PaymentToken, error) {
+	rows, err := db.Query(selectPaymentTokensByPaymentID, id.ProjectID, id.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := make([]*PaymentToken, 0)
+	for rows.Next() {
+		t := &PaymentToken{id: id}
+		err = rows.Scan(&t.Token, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
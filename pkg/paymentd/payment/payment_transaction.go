@@ -73,6 +73,15 @@ type PaymentTransaction struct {
 	Currency  string
 	Status    PaymentTransactionStatus
 	Comment   sql.NullString
+	// RequestID is the ID of the request which caused the transaction
+	RequestID sql.NullString
+}
+
+// SetRequestID sets the ID of the request which caused the transaction
+//
+// An empty ID will unset the request ID.
+func (p *PaymentTransaction) SetRequestID(id string) {
+	p.RequestID.String, p.RequestID.Valid = id, id != ""
 }
 
 func (p *PaymentTransaction) Decimal() *decimal.Decimal {
@@ -18,15 +18,16 @@ SELECT
 	tx.subunits,
 	tx.currency,
 	tx.status,
-	tx.comment
+	tx.comment,
+	tx.request_id
 FROM payment_transaction AS tx
 `
 
 const insertPaymentTransaction = `
 INSERT INTO payment_transaction
-(project_id, payment_id, timestamp, amount, subunits, currency, status, comment)
+(project_id, payment_id, timestamp, amount, subunits, currency, status, comment, request_id)
 VALUES
-(?, ?, ?, ?, ?, ?, ?, ?)
+(?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 func InsertPaymentTransactionTx(db *sql.Tx, paymentTx *PaymentTransaction) error {
@@ -43,6 +44,7 @@ func InsertPaymentTransactionTx(db *sql.Tx, paymentTx *PaymentTransaction) error
 		paymentTx.Currency,
 		paymentTx.Status,
 		paymentTx.Comment,
+		paymentTx.RequestID,
 	)
 	stmt.Close()
 	return err
@@ -76,6 +78,7 @@ func scanPaymentTx(r resultScanner, paymentTx *PaymentTransaction) error {
 		&paymentTx.Currency,
 		&paymentTx.Status,
 		&paymentTx.Comment,
+		&paymentTx.RequestID,
 	)
 	paymentTx.Timestamp = time.Unix(0, ts)
 	return err
@@ -169,3 +172,53 @@ func PaymentTransactionsBeforeTimestampDB(db *sql.DB, p *Payment, transactionTim
 	}
 	return scanTransactions(query, p)
 }
+
+const selectPaymentIDsByRequestID = `
+SELECT DISTINCT
+	project_id,
+	payment_id
+FROM payment_transaction
+WHERE
+	request_id = ?
+ORDER BY project_id, payment_id
+`
+
+func scanPaymentIDs(rows *sql.Rows) ([]PaymentID, error) {
+	var err error
+	ids := make([]PaymentID, 0, 1)
+	for rows.Next() {
+		var id PaymentID
+		err = rows.Scan(&id.ProjectID, &id.PaymentID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// PaymentIDsByRequestIDTx returns the IDs of the payments with transactions caused
+// by the request with the given ID
+func PaymentIDsByRequestIDTx(db *sql.Tx, requestID string) ([]PaymentID, error) {
+	rows, err := db.Query(selectPaymentIDsByRequestID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentIDs(rows)
+}
+
+// PaymentIDsByRequestIDDB returns the IDs of the payments with transactions caused
+// by the request with the given ID
+func PaymentIDsByRequestIDDB(db *sql.DB, requestID string) ([]PaymentID, error) {
+	rows, err := db.Query(selectPaymentIDsByRequestID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentIDs(rows)
+}
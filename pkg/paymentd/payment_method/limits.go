@@ -0,0 +1,117 @@
+package payment_method
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"code.google.com/p/godec/dec"
+	"github.com/fritzpay/paymentd/pkg/decimal"
+)
+
+var (
+	ErrLimitCurrency = errors.New("currency not supported by payment method")
+	ErrLimitCountry  = errors.New("country not supported by payment method")
+	ErrLimitAmount   = errors.New("amount out of payment method range")
+)
+
+// AmountRange is an amount range (inclusive) in decimal notation, e.g. "0.50"
+//
+// An empty Min or Max value means no limit.
+type AmountRange struct {
+	Min string `json:",omitempty"`
+	Max string `json:",omitempty"`
+}
+
+func parseLimitAmount(s string) (*dec.Dec, error) {
+	d, ok := new(dec.Dec).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %s", s)
+	}
+	return d, nil
+}
+
+// Limits are constraints on the payments a payment method can process
+//
+// Empty limits do not constrain payments.
+type Limits struct {
+	// Currencies is a list of allowed ISO 4217 currency codes
+	Currencies []string `json:",omitempty"`
+	// Countries is a list of allowed ISO 3166-1 alpha-2 country codes
+	Countries []string `json:",omitempty"`
+	// Amounts maps currency codes to allowed amount ranges
+	Amounts map[string]AmountRange `json:",omitempty"`
+}
+
+// Empty returns true if no limits are set
+func (l Limits) Empty() bool {
+	return len(l.Currencies) == 0 && len(l.Countries) == 0 && len(l.Amounts) == 0
+}
+
+// Validate returns an error if the limits contain invalid values
+func (l Limits) Validate() error {
+	for cur, r := range l.Amounts {
+		var min, max *dec.Dec
+		var err error
+		if r.Min != "" {
+			if min, err = parseLimitAmount(r.Min); err != nil {
+				return fmt.Errorf("currency %s: %v", cur, err)
+			}
+		}
+		if r.Max != "" {
+			if max, err = parseLimitAmount(r.Max); err != nil {
+				return fmt.Errorf("currency %s: %v", cur, err)
+			}
+		}
+		if min != nil && max != nil && min.Cmp(max) > 0 {
+			return fmt.Errorf("currency %s: min amount greater than max amount", cur)
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error if a payment with the given currency, country and amount
+// cannot be processed by the payment method
+//
+// An empty country will not be checked.
+func (l Limits) Check(currency, country string, amount *decimal.Decimal) error {
+	if len(l.Currencies) > 0 && !containsFold(l.Currencies, currency) {
+		return ErrLimitCurrency
+	}
+	if country != "" && len(l.Countries) > 0 && !containsFold(l.Countries, country) {
+		return ErrLimitCountry
+	}
+	for cur, r := range l.Amounts {
+		if !strings.EqualFold(cur, currency) {
+			continue
+		}
+		if r.Min != "" {
+			min, err := parseLimitAmount(r.Min)
+			if err != nil {
+				return err
+			}
+			if amount.Cmp(min) < 0 {
+				return ErrLimitAmount
+			}
+		}
+		if r.Max != "" {
+			max, err := parseLimitAmount(r.Max)
+			if err != nil {
+				return err
+			}
+			if amount.Cmp(max) > 0 {
+				return ErrLimitAmount
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package payment_method
+
+import (
+	"testing"
+
+	"code.google.com/p/godec/dec"
+	"github.com/fritzpay/paymentd/pkg/decimal"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func amount(s string) *decimal.Decimal {
+	d, ok := new(dec.Dec).SetString(s)
+	if !ok {
+		panic("invalid amount " + s)
+	}
+	return &decimal.Decimal{Dec: *d}
+}
+
+func TestLimits(t *testing.T) {
+	Convey("Given empty limits", t, func() {
+		l := Limits{}
+		So(l.Empty(), ShouldBeTrue)
+
+		Convey("It should accept any payment", func() {
+			So(l.Check("JPY", "JP", amount("0.01")), ShouldBeNil)
+		})
+	})
+
+	Convey("Given limits", t, func() {
+		l := Limits{
+			Currencies: []string{"EUR", "USD"},
+			Countries:  []string{"DE", "AT"},
+			Amounts: map[string]AmountRange{
+				"EUR": {Min: "1.00", Max: "500"},
+			},
+		}
+		So(l.Validate(), ShouldBeNil)
+
+		Convey("When checking a payment within the limits", func() {
+			Convey("It should be accepted", func() {
+				So(l.Check("EUR", "de", amount("1.00")), ShouldBeNil)
+				So(l.Check("EUR", "AT", amount("500.00")), ShouldBeNil)
+				So(l.Check("USD", "DE", amount("0.01")), ShouldBeNil)
+			})
+		})
+
+		Convey("When checking a payment with another currency", func() {
+			Convey("It should return a currency error", func() {
+				So(l.Check("JPY", "DE", amount("100")), ShouldEqual, ErrLimitCurrency)
+			})
+		})
+
+		Convey("When checking a payment from another country", func() {
+			Convey("It should return a country error", func() {
+				So(l.Check("EUR", "FR", amount("10")), ShouldEqual, ErrLimitCountry)
+			})
+		})
+
+		Convey("When checking a payment out of the amount range", func() {
+			Convey("It should return an amount error", func() {
+				So(l.Check("EUR", "DE", amount("0.99")), ShouldEqual, ErrLimitAmount)
+				So(l.Check("EUR", "DE", amount("500.01")), ShouldEqual, ErrLimitAmount)
+			})
+		})
+	})
+
+	Convey("Given limits with an invalid range", t, func() {
+		l := Limits{
+			Amounts: map[string]AmountRange{
+				"EUR": {Min: "10", Max: "1"},
+			},
+		}
+
+		Convey("It should not validate", func() {
+			So(l.Validate(), ShouldNotBeNil)
+		})
+	})
+}
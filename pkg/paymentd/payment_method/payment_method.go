@@ -72,6 +72,8 @@ type Method struct {
 	StatusChanged   time.Time
 	StatusCreatedBy string
 
+	Limits Limits
+
 	Metadata map[string]string
 }
 
@@ -2,6 +2,7 @@ package payment_method
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -23,7 +24,8 @@ SELECT
 	m.created_by,
 	s.status,
 	s.timestamp,
-	s.created_by
+	s.created_by,
+	l.limits
 FROM payment_method AS m
 INNER JOIN provider AS p ON
 	p.name = m.provider
@@ -35,6 +37,14 @@ INNER JOIN payment_method_status AS s ON
 		WHERE
 			payment_method_id = s.payment_method_id
 	)
+LEFT JOIN payment_method_limits AS l ON
+	l.payment_method_id = m.id
+	AND
+	l.timestamp = (
+		SELECT MAX(timestamp) FROM payment_method_limits
+		WHERE
+			payment_method_id = l.payment_method_id
+	)
 `
 
 const selectPaymentMethodByID = selectPaymentMethod + `
@@ -50,9 +60,25 @@ AND
 	m.method_key = ?
 `
 
+const selectPaymentMethodByProjectIDStatus = selectPaymentMethod + `
+WHERE
+	m.project_id = ?
+AND
+	s.status = ?
+ORDER BY m.id
+`
+
+func scanLimits(pm *Method, limits sql.NullString) error {
+	if !limits.Valid {
+		return nil
+	}
+	return json.Unmarshal([]byte(limits.String), &pm.Limits)
+}
+
 func scanSinglePaymentMethod(row *sql.Row) (*Method, error) {
 	pm := &Method{}
 	var ts int64
+	var limits sql.NullString
 	err := row.Scan(
 		&pm.ID,
 		&pm.ProjectID,
@@ -63,6 +89,7 @@ func scanSinglePaymentMethod(row *sql.Row) (*Method, error) {
 		&pm.Status,
 		&ts,
 		&pm.StatusCreatedBy,
+		&limits,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -71,7 +98,8 @@ func scanSinglePaymentMethod(row *sql.Row) (*Method, error) {
 		return pm, err
 	}
 	pm.StatusChanged = time.Unix(0, ts)
-	return pm, nil
+	err = scanLimits(pm, limits)
+	return pm, err
 }
 
 func PaymentMethodByIDDB(db *sql.DB, id int64) (*Method, error) {
@@ -94,6 +122,59 @@ func PaymentMethodByIDTx(db *sql.Tx, id int64) (*Method, error) {
 	return scanSinglePaymentMethod(row)
 }
 
+// PaymentMethodsByProjectIDStatusDB returns all payment methods of the given project
+// with the given status
+func PaymentMethodsByProjectIDStatusDB(db *sql.DB, projectID int64, status methodStatus) ([]*Method, error) {
+	rows, err := db.Query(selectPaymentMethodByProjectIDStatus, projectID, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentMethods(rows)
+}
+
+// PaymentMethodsByProjectIDStatusTx returns all payment methods of the given project
+// with the given status
+func PaymentMethodsByProjectIDStatusTx(db *sql.Tx, projectID int64, status methodStatus) ([]*Method, error) {
+	rows, err := db.Query(selectPaymentMethodByProjectIDStatus, projectID, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanPaymentMethods(rows)
+}
+
+func scanPaymentMethods(rows *sql.Rows) ([]*Method, error) {
+	defer rows.Close()
+	var methods []*Method
+	var err error
+	for rows.Next() {
+		pm := &Method{}
+		var ts int64
+		var limits sql.NullString
+		err = rows.Scan(
+			&pm.ID,
+			&pm.ProjectID,
+			&pm.Provider.Name,
+			&pm.MethodKey,
+			&pm.Created,
+			&pm.CreatedBy,
+			&pm.Status,
+			&ts,
+			&pm.StatusCreatedBy,
+			&limits,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pm.StatusChanged = time.Unix(0, ts)
+		err = scanLimits(pm, limits)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, pm)
+	}
+	return methods, rows.Err()
+}
+
 const insertPaymentMethod = `
 INSERT INTO payment_method
 (project_id, provider, method_key, created, created_by)
@@ -132,6 +213,30 @@ func InsertPaymentMethodStatusTx(db *sql.Tx, pm *Method) error {
 	return err
 }
 
+const insertPaymentMethodLimits = `
+INSERT INTO payment_method_limits
+(payment_method_id, timestamp, created_by, limits)
+VALUES
+(?, ?, ?, ?)`
+
+// InsertPaymentMethodLimitsTx saves a new version of the payment method limits
+func InsertPaymentMethodLimitsTx(db *sql.Tx, pm *Method, createdBy string) error {
+	if pm.ID == 0 {
+		return ErrPaymentMethodWithoutID
+	}
+	limits, err := json.Marshal(pm.Limits)
+	if err != nil {
+		return err
+	}
+	stmt, err := db.Prepare(insertPaymentMethodLimits)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(pm.ID, time.Now().UnixNano(), createdBy, string(limits))
+	stmt.Close()
+	return err
+}
+
 func InsertPaymentMethodMetadataTx(db *sql.Tx, pm *Method, createdBy string) error {
 	if pm.ID == 0 {
 		return ErrPaymentMethodWithoutID
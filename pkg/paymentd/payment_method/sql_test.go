@@ -51,6 +51,17 @@ func TestPaymentMethodSQL(t *testing.T) {
 								})
 							})
 
+							Convey("When retrieving the active payment methods of the project", func() {
+								methods, err := PaymentMethodsByProjectIDStatusTx(tx, proj.ID, PaymentMethodStatusActive)
+								Convey("It should return the active payment methods", func() {
+									So(err, ShouldBeNil)
+									for _, pm := range methods {
+										So(pm.ProjectID, ShouldEqual, proj.ID)
+										So(pm.Active(), ShouldBeTrue)
+									}
+								})
+							})
+
 							Convey("When retrieving an existent payment method", func() {
 								pm, err := PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, proj.ID, pr.Name, "test")
 								Convey("It should return a payment method", func() {
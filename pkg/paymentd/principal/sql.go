@@ -89,7 +89,7 @@ func PrincipalAllDB(db *sql.DB) ([]*Principal, error) {
 	for rows.Next() {
 
 		p := Principal{}
-		err := rows.Scan(&p.ID, &p.Created, &p.CreatedBy, &p.Name)
+		err := rows.Scan(&p.ID, &p.Created, &p.CreatedBy, &p.Name, &p.Status)
 		if err != nil {
 			rows.Close()
 			return d, err
@@ -3,7 +3,10 @@ package project
 import (
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"time"
+
+	"github.com/fritzpay/paymentd/pkg/netutil"
 )
 
 const (
@@ -40,6 +43,12 @@ type Config struct {
 	CallbackAPIVersion sql.NullString
 	CallbackProjectKey sql.NullString
 	ReturnURL          sql.NullString
+	// IPAllowlist contains the networks, separated by whitespace, from which the
+	// payment API may be used with keys of this project
+	IPAllowlist sql.NullString
+	// RateLimitBurst overrides the burst size of the rate limits of the project and its
+	// keys
+	RateLimitBurst sql.NullInt64
 }
 
 type ConfigJSON struct {
@@ -48,6 +57,8 @@ type ConfigJSON struct {
 	CallbackAPIVersion *string
 	CallbackProjectKey *string
 	ReturnURL          *string
+	IPAllowlist        []string `json:",omitempty"`
+	RateLimitBurst     *int64   `json:",omitempty"`
 }
 
 // IsSet returns true if the config was set and stored
@@ -57,7 +68,7 @@ func (c Config) IsSet() bool {
 
 // HasValues returns true if the config has any values set
 func (c Config) HasValues() bool {
-	return c.WebURL.Valid || c.CallbackURL.Valid || c.CallbackAPIVersion.Valid || c.CallbackProjectKey.Valid || c.ReturnURL.Valid
+	return c.WebURL.Valid || c.CallbackURL.Valid || c.CallbackAPIVersion.Valid || c.CallbackProjectKey.Valid || c.ReturnURL.Valid || c.IPAllowlist.Valid || c.RateLimitBurst.Valid
 }
 
 func (c Config) HasCallback() bool {
@@ -88,6 +99,27 @@ func (c *Config) SetReturnURL(url string) {
 	c.ReturnURL.String, c.ReturnURL.Valid = url, true
 }
 
+// SetIPAllowlist sets the networks (CIDR notation) from which the payment API may be
+// used
+//
+// An empty list removes the restriction.
+func (c *Config) SetIPAllowlist(cidrs []string) {
+	c.IPAllowlist.String = strings.Join(cidrs, " ")
+	c.IPAllowlist.Valid = c.IPAllowlist.String != ""
+}
+
+// AllowedNetworks returns the parsed IP allowlist
+//
+// An empty list means that there is no restriction.
+func (c Config) AllowedNetworks() (netutil.Networks, error) {
+	return netutil.ParseNetworks(strings.Fields(c.IPAllowlist.String))
+}
+
+// SetRateLimitBurst sets the burst size override of the rate limits
+func (c *Config) SetRateLimitBurst(burst int64) {
+	c.RateLimitBurst.Int64, c.RateLimitBurst.Valid = burst, true
+}
+
 func (c *Config) UnmarshalJSON(p []byte) error {
 	cfg := &ConfigJSON{}
 	err := json.Unmarshal(p, cfg)
@@ -109,6 +141,12 @@ func (c *Config) UnmarshalJSON(p []byte) error {
 	if cfg.ReturnURL != nil {
 		c.SetReturnURL(*cfg.ReturnURL)
 	}
+	if cfg.IPAllowlist != nil {
+		c.SetIPAllowlist(cfg.IPAllowlist)
+	}
+	if cfg.RateLimitBurst != nil {
+		c.SetRateLimitBurst(*cfg.RateLimitBurst)
+	}
 	return nil
 }
 
@@ -129,6 +167,12 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	if c.ReturnURL.Valid {
 		cfg.ReturnURL = &c.ReturnURL.String
 	}
+	if c.IPAllowlist.Valid {
+		cfg.IPAllowlist = strings.Fields(c.IPAllowlist.String)
+	}
+	if c.RateLimitBurst.Valid {
+		cfg.RateLimitBurst = &c.RateLimitBurst.Int64
+	}
 	return json.Marshal(cfg)
 }
 
@@ -1,10 +1,69 @@
 package project
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/hex"
+	"errors"
+	"strings"
 	"time"
+
+	"github.com/fritzpay/paymentd/pkg/netutil"
+)
+
+const (
+	// ProjectKeyIDBytes is the number of random bytes of generated project key IDs
+	ProjectKeyIDBytes = 16
+	// ProjectKeySecretBytes is the number of random bytes of generated secrets
+	ProjectKeySecretBytes = 32
 )
 
+// Scope restricts a project key to an API operation
+type Scope string
+
+// Project key scopes
+const (
+	// ScopePaymentInit allows initializing payments
+	ScopePaymentInit Scope = "payment:init"
+	// ScopePaymentRead allows reading payment status
+	ScopePaymentRead Scope = "payment:read"
+	// ScopePaymentRefund allows refunding payments
+	ScopePaymentRefund Scope = "payment:refund"
+	// ScopeCallbackSign allows using the key for signing callbacks
+	ScopeCallbackSign Scope = "callback:sign"
+)
+
+// Scopes contains all known project key scopes
+var Scopes = []Scope{
+	ScopePaymentInit,
+	ScopePaymentRead,
+	ScopePaymentRefund,
+	ScopeCallbackSign,
+}
+
+var (
+	// ErrUnknownScope is returned when a project key has a scope which is not known
+	ErrUnknownScope = errors.New("unknown project key scope")
+	// ErrInvalidPublicKey is returned when a public key is not a hex-encoded Ed25519
+	// public key
+	ErrInvalidPublicKey = errors.New("invalid public key")
+	// ErrInvalidClientCert is returned when a client certificate fingerprint is not a
+	// hex-encoded SHA-256 hash
+	ErrInvalidClientCert = errors.New("invalid client certificate fingerprint")
+)
+
+// ValidScope returns true if the given scope is known
+func ValidScope(s Scope) bool {
+	for _, known := range Scopes {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
 // ProjectKey represents a project key
 type Projectkey struct {
 	Key         string
@@ -14,14 +73,288 @@ type Projectkey struct {
 	Secret      string
 	secretBytes []byte
 	Active      bool
+	// Expires is the time after which the key is not valid anymore
+	Expires *time.Time
+
+	// PreviousSecret is the secret before the last rotation
+	PreviousSecret string
+	// PreviousExpires is the end of the rotation overlap, during which the previous
+	// secret remains valid
+	PreviousExpires *time.Time
+
+	// Scopes restricts the key to the listed operations
+	//
+	// A key without scopes is not restricted.
+	Scopes []Scope
+	// IPAllowlist restricts the networks from which the key may be used
+	//
+	// An empty list means that there is no restriction.
+	IPAllowlist netutil.Networks
+	// PublicKeys are the Ed25519 public keys of the merchant
+	//
+	// If the key has public keys, requests must be signed with one of the
+	// corresponding private keys and paymentd signs with its own key pair instead of
+	// the shared secret.
+	PublicKeys []ed25519.PublicKey
+	// ClientCerts are the hex-encoded SHA-256 fingerprints of the TLS client
+	// certificates bound to the key
+	//
+	// If the key has client certificates, requests must be made over a connection
+	// authenticated with one of them. Without, a verified client certificate only
+	// gates the transport.
+	ClientCerts []string
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewProjectkey creates a new active project key with a random key and secret
+func NewProjectkey(pr Project, createdBy string) (*Projectkey, error) {
+	key, err := randomHex(ProjectKeyIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(ProjectKeySecretBytes)
+	if err != nil {
+		return nil, err
+	}
+	pk := &Projectkey{
+		Key:     key,
+		Project: pr,
+		Secret:  secret,
+		Active:  true,
+	}
+	pk.NextVersion(createdBy)
+	return pk, nil
+}
+
+// NextVersion prepares the project key for storing a new version
+//
+// Versions are stored with a precision of seconds, so the timestamp will be at least
+// one second after the timestamp of the current version.
+func (p *Projectkey) NextVersion(createdBy string) {
+	ts := time.Now().UTC().Truncate(time.Second)
+	if !p.Timestamp.IsZero() && !ts.After(p.Timestamp) {
+		ts = p.Timestamp.Add(time.Second)
+	}
+	p.Timestamp = ts
+	p.CreatedBy = createdBy
+}
+
+// Rotate replaces the secret with a new random secret
+//
+// The current secret will remain valid for the given overlap duration.
+func (p *Projectkey) Rotate(overlap time.Duration) error {
+	secret, err := randomHex(ProjectKeySecretBytes)
+	if err != nil {
+		return err
+	}
+	exp := time.Now().UTC().Add(overlap).Truncate(time.Second)
+	p.PreviousSecret, p.PreviousExpires = p.Secret, &exp
+	p.Secret, p.secretBytes = secret, nil
+	return nil
+}
+
+// Expired returns true if the key is expired at the given time
+func (p *Projectkey) Expired(t time.Time) bool {
+	return p.Expires != nil && !t.Before(*p.Expires)
 }
 
 // IsValid returns true if the project key is considered valid
 func (p *Projectkey) IsValid() bool {
-	return p.Key != "" && p.Active
+	return p.Key != "" && p.Active && !p.Expired(time.Now())
+}
+
+// InOverlap returns true if the previous secret is valid at the given time
+func (p *Projectkey) InOverlap(t time.Time) bool {
+	return p.PreviousSecret != "" && p.PreviousExpires != nil && t.Before(*p.PreviousExpires)
 }
 
 // SecretBytes returns the binary representation of the shared secret
 func (p *Projectkey) SecretBytes() ([]byte, error) {
 	return hex.DecodeString(p.Secret)
 }
+
+// Secrets returns the binary representations of all secrets which are valid at the
+// given time
+//
+// During a rotation overlap, this includes the previous secret.
+func (p *Projectkey) Secrets(t time.Time) ([][]byte, error) {
+	secret, err := p.SecretBytes()
+	if err != nil {
+		return nil, err
+	}
+	secrets := [][]byte{secret}
+	if p.InOverlap(t) {
+		prev, err := hex.DecodeString(p.PreviousSecret)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, prev)
+	}
+	return secrets, nil
+}
+
+// SigningSecretBytes returns the secret for signing messages, i.e. callbacks, at the
+// given time
+//
+// During a rotation overlap, this is the previous secret, so receivers which were
+// not yet updated can still verify messages.
+func (p *Projectkey) SigningSecretBytes(t time.Time) ([]byte, error) {
+	if p.InOverlap(t) {
+		return hex.DecodeString(p.PreviousSecret)
+	}
+	return p.SecretBytes()
+}
+
+// HasScope returns true if the key is allowed to be used for the given operation
+func (p *Projectkey) HasScope(s Scope) bool {
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, sc := range p.Scopes {
+		if sc == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SetScopes validates and sets the scopes of the key
+//
+// Duplicate scopes will be removed.
+func (p *Projectkey) SetScopes(scopes []Scope) error {
+	set := make([]Scope, 0, len(scopes))
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return ErrUnknownScope
+		}
+		dup := false
+		for _, e := range set {
+			if e == s {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			set = append(set, s)
+		}
+	}
+	if len(set) == 0 {
+		set = nil
+	}
+	p.Scopes = set
+	return nil
+}
+
+func (p *Projectkey) scopesString() string {
+	s := make([]string, len(p.Scopes))
+	for i, sc := range p.Scopes {
+		s[i] = string(sc)
+	}
+	return strings.Join(s, " ")
+}
+
+func (p *Projectkey) parseScopes(s string) {
+	p.Scopes = nil
+	for _, sc := range strings.Fields(s) {
+		p.Scopes = append(p.Scopes, Scope(sc))
+	}
+}
+
+// SetIPAllowlist parses and sets the networks (CIDR notation) from which the key may
+// be used
+func (p *Projectkey) SetIPAllowlist(cidrs []string) error {
+	nets, err := netutil.ParseNetworks(cidrs)
+	if err != nil {
+		return err
+	}
+	if len(nets) == 0 {
+		nets = nil
+	}
+	p.IPAllowlist = nets
+	return nil
+}
+
+// Asymmetric returns true if messages are signed with key pairs instead of the shared
+// secret
+func (p *Projectkey) Asymmetric() bool {
+	return len(p.PublicKeys) > 0
+}
+
+// SetPublicKeys parses and sets the hex-encoded Ed25519 public keys of the merchant
+//
+// An empty list switches back to signing with the shared secret.
+func (p *Projectkey) SetPublicKeys(hexKeys []string) error {
+	var keys []ed25519.PublicKey
+	for _, h := range hexKeys {
+		b, err := hex.DecodeString(h)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return ErrInvalidPublicKey
+		}
+		keys = append(keys, ed25519.PublicKey(b))
+	}
+	p.PublicKeys = keys
+	return nil
+}
+
+// HexPublicKeys returns the hex-encoded public keys
+func (p *Projectkey) HexPublicKeys() []string {
+	keys := make([]string, len(p.PublicKeys))
+	for i, k := range p.PublicKeys {
+		keys[i] = hex.EncodeToString(k)
+	}
+	return keys
+}
+
+// SetClientCerts parses and sets the hex-encoded SHA-256 fingerprints of the client
+// certificates bound to the key
+//
+// Colons between the bytes are accepted. An empty list removes the binding.
+func (p *Projectkey) SetClientCerts(fingerprints []string) error {
+	var certs []string
+	for _, f := range fingerprints {
+		f = strings.ToLower(strings.Replace(f, ":", "", -1))
+		b, err := hex.DecodeString(f)
+		if err != nil || len(b) != sha256.Size {
+			return ErrInvalidClientCert
+		}
+		certs = append(certs, f)
+	}
+	p.ClientCerts = certs
+	return nil
+}
+
+// ClientCertFingerprint returns the hex-encoded SHA-256 fingerprint of the given
+// certificate
+func ClientCertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientCertAllowed returns true if the given verified client certificate may be used
+// with the key
+//
+// A key without client certificates accepts any connection. cert is nil if the
+// connection was not authenticated with a client certificate.
+func (p *Projectkey) ClientCertAllowed(cert *x509.Certificate) bool {
+	if len(p.ClientCerts) == 0 {
+		return true
+	}
+	if cert == nil {
+		return false
+	}
+	fingerprint := ClientCertFingerprint(cert)
+	for _, f := range p.ClientCerts {
+		if f == fingerprint {
+			return true
+		}
+	}
+	return false
+}
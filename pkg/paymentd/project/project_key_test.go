@@ -0,0 +1,180 @@
+package project_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProjectKeyLifecycle(t *testing.T) {
+	Convey("Given a new project key", t, func() {
+		pk, err := project.NewProjectkey(project.Project{ID: 1}, "test")
+		So(err, ShouldBeNil)
+
+		Convey("It should be valid", func() {
+			So(pk.IsValid(), ShouldBeTrue)
+			So(len(pk.Key), ShouldEqual, 2*project.ProjectKeyIDBytes)
+			So(len(pk.Secret), ShouldEqual, 2*project.ProjectKeySecretBytes)
+		})
+
+		Convey("When creating a new version", func() {
+			ts := pk.Timestamp
+			pk.NextVersion("test2")
+
+			Convey("The timestamp should be after the previous version", func() {
+				So(pk.Timestamp.After(ts), ShouldBeTrue)
+				So(pk.CreatedBy, ShouldEqual, "test2")
+			})
+		})
+
+		Convey("When the key is expired", func() {
+			exp := time.Now().Add(-time.Minute)
+			pk.Expires = &exp
+
+			Convey("It should not be valid", func() {
+				So(pk.IsValid(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When rotating the key", func() {
+			oldSecret, err := pk.SecretBytes()
+			So(err, ShouldBeNil)
+			err = pk.Rotate(time.Hour)
+			So(err, ShouldBeNil)
+			newSecret, err := pk.SecretBytes()
+			So(err, ShouldBeNil)
+			So(bytes.Equal(oldSecret, newSecret), ShouldBeFalse)
+
+			Convey("Both secrets should be valid during the overlap", func() {
+				secrets, err := pk.Secrets(time.Now())
+				So(err, ShouldBeNil)
+				So(len(secrets), ShouldEqual, 2)
+				So(bytes.Equal(secrets[0], newSecret), ShouldBeTrue)
+				So(bytes.Equal(secrets[1], oldSecret), ShouldBeTrue)
+			})
+			Convey("Messages should be signed with the previous secret during the overlap", func() {
+				s, err := pk.SigningSecretBytes(time.Now())
+				So(err, ShouldBeNil)
+				So(bytes.Equal(s, oldSecret), ShouldBeTrue)
+			})
+			Convey("Only the new secret should be valid after the overlap", func() {
+				after := time.Now().Add(2 * time.Hour)
+				secrets, err := pk.Secrets(after)
+				So(err, ShouldBeNil)
+				So(len(secrets), ShouldEqual, 1)
+				So(bytes.Equal(secrets[0], newSecret), ShouldBeTrue)
+
+				s, err := pk.SigningSecretBytes(after)
+				So(err, ShouldBeNil)
+				So(bytes.Equal(s, newSecret), ShouldBeTrue)
+			})
+		})
+
+		Convey("Without scopes", func() {
+			Convey("It should be allowed for all operations", func() {
+				for _, sc := range project.Scopes {
+					So(pk.HasScope(sc), ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("When setting scopes", func() {
+			err := pk.SetScopes([]project.Scope{project.ScopePaymentRead, project.ScopeCallbackSign, project.ScopePaymentRead})
+			So(err, ShouldBeNil)
+
+			Convey("Duplicates should be removed", func() {
+				So(len(pk.Scopes), ShouldEqual, 2)
+			})
+			Convey("It should only be allowed for the given operations", func() {
+				So(pk.HasScope(project.ScopePaymentRead), ShouldBeTrue)
+				So(pk.HasScope(project.ScopeCallbackSign), ShouldBeTrue)
+				So(pk.HasScope(project.ScopePaymentInit), ShouldBeFalse)
+				So(pk.HasScope(project.ScopePaymentRefund), ShouldBeFalse)
+			})
+			Convey("Setting an empty list should remove the restrictions", func() {
+				So(pk.SetScopes(nil), ShouldBeNil)
+				So(pk.HasScope(project.ScopePaymentInit), ShouldBeTrue)
+			})
+		})
+
+		Convey("When setting an unknown scope", func() {
+			err := pk.SetScopes([]project.Scope{"payment:delete"})
+
+			Convey("It should fail", func() {
+				So(err, ShouldEqual, project.ErrUnknownScope)
+			})
+		})
+
+		Convey("Without public keys", func() {
+			Convey("It should not be asymmetric", func() {
+				So(pk.Asymmetric(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When setting public keys", func() {
+			hexKey := strings.Repeat("ab", 32)
+			err := pk.SetPublicKeys([]string{hexKey})
+			So(err, ShouldBeNil)
+
+			Convey("It should be asymmetric", func() {
+				So(pk.Asymmetric(), ShouldBeTrue)
+				So(pk.HexPublicKeys(), ShouldResemble, []string{hexKey})
+			})
+			Convey("Setting an empty list should switch back to the shared secret", func() {
+				So(pk.SetPublicKeys(nil), ShouldBeNil)
+				So(pk.Asymmetric(), ShouldBeFalse)
+			})
+		})
+
+		Convey("When setting an invalid public key", func() {
+			err := pk.SetPublicKeys([]string{"abcd"})
+
+			Convey("It should fail", func() {
+				So(err, ShouldEqual, project.ErrInvalidPublicKey)
+			})
+		})
+
+		Convey("Without client certificates", func() {
+			Convey("It should accept any connection", func() {
+				So(pk.ClientCertAllowed(nil), ShouldBeTrue)
+			})
+		})
+
+		Convey("When binding a client certificate", func() {
+			cert := &x509.Certificate{Raw: []byte("merchant")}
+			other := &x509.Certificate{Raw: []byte("other")}
+			sum := sha256.Sum256(cert.Raw)
+			fingerprint := strings.ToUpper(hex.EncodeToString(sum[:2])) + ":" + hex.EncodeToString(sum[2:])
+			err := pk.SetClientCerts([]string{fingerprint})
+			So(err, ShouldBeNil)
+
+			Convey("It should store the normalized fingerprint", func() {
+				So(pk.ClientCerts, ShouldResemble, []string{project.ClientCertFingerprint(cert)})
+			})
+			Convey("It should accept only the bound certificate", func() {
+				So(pk.ClientCertAllowed(cert), ShouldBeTrue)
+				So(pk.ClientCertAllowed(other), ShouldBeFalse)
+				So(pk.ClientCertAllowed(nil), ShouldBeFalse)
+			})
+			Convey("Setting an empty list should remove the binding", func() {
+				So(pk.SetClientCerts(nil), ShouldBeNil)
+				So(pk.ClientCertAllowed(nil), ShouldBeTrue)
+			})
+		})
+
+		Convey("When binding an invalid fingerprint", func() {
+			err := pk.SetClientCerts([]string{"abcd"})
+
+			Convey("It should fail", func() {
+				So(err, ShouldEqual, project.ErrInvalidClientCert)
+			})
+		})
+	})
+}
@@ -55,5 +55,47 @@ func TestProjectConfig(t *testing.T) {
 				})
 			})
 		})
+
+		Convey("Given a config with a rate limit burst override", func() {
+			cfgStr := `{"WebURL":null,"CallbackURL":null,"CallbackAPIVersion":null,"CallbackProjectKey":null,"ReturnURL":null,"RateLimitBurst":50}`
+			err := json.Unmarshal([]byte(cfgStr), &pr.Config)
+			So(err, ShouldBeNil)
+
+			Convey("The config should be considered to have values", func() {
+				So(pr.Config.HasValues(), ShouldBeTrue)
+				So(pr.Config.RateLimitBurst.Int64, ShouldEqual, 50)
+			})
+			Convey("When re-marshalling the config", func() {
+				jsonStr, err := json.Marshal(pr.Config)
+
+				Convey("It should match the original input", func() {
+					So(err, ShouldBeNil)
+					So(string(jsonStr), ShouldEqual, cfgStr)
+				})
+			})
+		})
+
+		Convey("Given a config with an IP allowlist", func() {
+			cfgStr := `{"WebURL":null,"CallbackURL":null,"CallbackAPIVersion":null,"CallbackProjectKey":null,"ReturnURL":null,"IPAllowlist":["10.0.0.0/8","192.168.1.1"]}`
+			err := json.Unmarshal([]byte(cfgStr), &pr.Config)
+			So(err, ShouldBeNil)
+
+			Convey("The config should be considered to have values", func() {
+				So(pr.Config.HasValues(), ShouldBeTrue)
+			})
+			Convey("It should return the allowed networks", func() {
+				nets, err := pr.Config.AllowedNetworks()
+				So(err, ShouldBeNil)
+				So(nets.Strings(), ShouldResemble, []string{"10.0.0.0/8", "192.168.1.1/32"})
+			})
+			Convey("When re-marshalling the config", func() {
+				jsonStr, err := json.Marshal(pr.Config)
+
+				Convey("It should match the original input", func() {
+					So(err, ShouldBeNil)
+					So(string(jsonStr), ShouldEqual, cfgStr)
+				})
+			})
+		})
 	})
 }
@@ -3,7 +3,10 @@ package project
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/fritzpay/paymentd/pkg/envelope"
 )
 
 var (
@@ -57,9 +60,9 @@ func InsertProjectTx(db *sql.Tx, p *Project) error {
 
 const insertProjectConfig = `
 INSERT INTO project_config
-(project_id, timestamp, web_url, callback_url, callback_api_version, callback_project_key, return_url)
+(project_id, timestamp, web_url, callback_url, callback_api_version, callback_project_key, return_url, ip_allowlist, rate_limit_burst)
 VALUES
-(?, ?, ?, ?, ?, ?, ?)
+(?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 func execInsertProjectConfig(insert *sql.Stmt, p *Project) error {
@@ -72,6 +75,8 @@ func execInsertProjectConfig(insert *sql.Stmt, p *Project) error {
 		p.Config.CallbackAPIVersion,
 		p.Config.CallbackProjectKey,
 		p.Config.ReturnURL,
+		p.Config.IPAllowlist,
+		p.Config.RateLimitBurst,
 	)
 	insert.Close()
 	return err
@@ -106,12 +111,14 @@ SELECT
 	p.name,
 	p.created,
 	p.created_by,
-	UNIX_TIMESTAMP(c.timestamp),
+	c.timestamp,
 	c.web_url,
 	c.callback_url,
 	c.callback_api_version,
 	c.callback_project_key,
-	c.return_url
+	c.return_url,
+	c.ip_allowlist,
+	c.rate_limit_burst
 FROM project AS p
 LEFT JOIN project_config AS c ON
 	c.project_id = p.id
@@ -150,7 +157,7 @@ AND
 
 func scanProject(row *sql.Row) (*Project, error) {
 	p := &Project{}
-	var ts sql.NullInt64
+	var ts sql.NullTime
 	err := row.Scan(
 		&p.ID,
 		&p.PrincipalID,
@@ -163,6 +170,8 @@ func scanProject(row *sql.Row) (*Project, error) {
 		&p.Config.CallbackAPIVersion,
 		&p.Config.CallbackProjectKey,
 		&p.Config.ReturnURL,
+		&p.Config.IPAllowlist,
+		&p.Config.RateLimitBurst,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -171,7 +180,7 @@ func scanProject(row *sql.Row) (*Project, error) {
 		return p, err
 	}
 	if ts.Valid {
-		p.Config.Timestamp = time.Unix(ts.Int64, 0)
+		p.Config.Timestamp = ts.Time
 	}
 	return p, nil
 }
@@ -196,7 +205,7 @@ func AllProjectsByPrincipalIDDB(db *sql.DB, principalID int64) ([]*Project, erro
 
 	for rows.Next() {
 		p := &Project{}
-		var ts sql.NullInt64
+		var ts sql.NullTime
 		err := rows.Scan(
 			&p.ID,
 			&p.PrincipalID,
@@ -209,6 +218,8 @@ func AllProjectsByPrincipalIDDB(db *sql.DB, principalID int64) ([]*Project, erro
 			&p.Config.CallbackAPIVersion,
 			&p.Config.CallbackProjectKey,
 			&p.Config.ReturnURL,
+			&p.Config.IPAllowlist,
+			&p.Config.RateLimitBurst,
 		)
 		if err != nil {
 			rows.Close()
@@ -216,7 +227,7 @@ func AllProjectsByPrincipalIDDB(db *sql.DB, principalID int64) ([]*Project, erro
 
 		}
 		if ts.Valid {
-			p.Config.Timestamp = time.Unix(ts.Int64, 0)
+			p.Config.Timestamp = ts.Time
 		}
 		d = append(d, p)
 	}
@@ -280,17 +291,26 @@ SELECT
 	k.created_by,
 	k.secret,
 	k.active,
+	k.expires,
+	k.previous_secret,
+	k.previous_expires,
+	k.scopes,
+	k.ip_allowlist,
+	k.public_keys,
+	k.client_certs,
 	p.id,
 	p.principal_id,
 	p.name,
 	p.created,
 	p.created_by,
-	UNIX_TIMESTAMP(c.timestamp),
+	c.timestamp,
 	c.web_url,
 	c.callback_url,
 	c.callback_api_version,
 	c.callback_project_key,
-	c.return_url
+	c.return_url,
+	c.ip_allowlist,
+	c.rate_limit_burst
 FROM project_key AS k
 INNER JOIN project AS p ON
 	p.id = k.project_id
@@ -315,15 +335,39 @@ WHERE
 	)
 `
 
-func scanProjectKey(row *sql.Row) (*Projectkey, error) {
+const selectProjectKeysByProjectID = selectProjectKey + `
+WHERE
+	k.project_id = ?
+	AND
+	k.timestamp = (
+		SELECT MAX(timestamp) FROM project_key AS mk
+		WHERE
+			mk.key = k.key
+	)
+ORDER BY k.key
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanProjectKey(row scanner) (*Projectkey, error) {
 	pk := &Projectkey{}
-	var ts sql.NullInt64
+	var ts sql.NullTime
+	var prevSecret, scopes, ipAllowlist, publicKeys, clientCerts sql.NullString
 	err := row.Scan(
 		&pk.Key,
 		&pk.Timestamp,
 		&pk.CreatedBy,
 		&pk.Secret,
 		&pk.Active,
+		&pk.Expires,
+		&prevSecret,
+		&pk.PreviousExpires,
+		&scopes,
+		&ipAllowlist,
+		&publicKeys,
+		&clientCerts,
 		&pk.Project.ID,
 		&pk.Project.PrincipalID,
 		&pk.Project.Name,
@@ -335,6 +379,8 @@ func scanProjectKey(row *sql.Row) (*Projectkey, error) {
 		&pk.Project.Config.CallbackAPIVersion,
 		&pk.Project.Config.CallbackProjectKey,
 		&pk.Project.Config.ReturnURL,
+		&pk.Project.Config.IPAllowlist,
+		&pk.Project.Config.RateLimitBurst,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -343,7 +389,28 @@ func scanProjectKey(row *sql.Row) (*Projectkey, error) {
 		return pk, err
 	}
 	if ts.Valid {
-		pk.Project.Config.Timestamp = time.Unix(ts.Int64, 0)
+		pk.Project.Config.Timestamp = ts.Time
+	}
+	pk.parseScopes(scopes.String)
+	err = pk.SetIPAllowlist(strings.Fields(ipAllowlist.String))
+	if err != nil {
+		return pk, err
+	}
+	err = pk.SetPublicKeys(strings.Fields(publicKeys.String))
+	if err != nil {
+		return pk, err
+	}
+	err = pk.SetClientCerts(strings.Fields(clientCerts.String))
+	if err != nil {
+		return pk, err
+	}
+	pk.Secret, err = envelope.OpenString(pk.Secret, envelope.AAD("project_key", "secret", pk.Key, pk.Timestamp))
+	if err != nil {
+		return pk, err
+	}
+	pk.PreviousSecret, err = envelope.OpenString(prevSecret.String, envelope.AAD("project_key", "previous_secret", pk.Key, pk.Timestamp))
+	if err != nil {
+		return pk, err
 	}
 	return pk, nil
 }
@@ -359,3 +426,86 @@ func ProjectKeyByKeyDB(db *sql.DB, key string) (*Projectkey, error) {
 	row := db.QueryRow(selectProjectKeyByKey, key)
 	return scanProjectKey(row)
 }
+
+// ProjectKeysByProjectIDDB selects the current versions of all keys of the given
+// project
+func ProjectKeysByProjectIDDB(db *sql.DB, projectID int64) ([]*Projectkey, error) {
+	rows, err := db.Query(selectProjectKeysByProjectID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Projectkey, 0, 8)
+	for rows.Next() {
+		pk, err := scanProjectKey(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, pk)
+	}
+	err = rows.Err()
+	rows.Close()
+	return keys, err
+}
+
+const insertProjectKey = `
+INSERT INTO project_key
+(` + "`key`" + `, timestamp, project_id, created_by, secret, active, expires, previous_secret, previous_expires, scopes, ip_allowlist, public_keys, client_certs)
+VALUES
+(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// InsertProjectKeyTx saves a new version of the given project key
+//
+// The timestamp of the version must be set, see (*Projectkey).NextVersion.
+func InsertProjectKeyTx(db *sql.Tx, pk *Projectkey) error {
+	stmt, err := db.Prepare(insertProjectKey)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	secret, err := envelope.SealString(pk.Secret, envelope.AAD("project_key", "secret", pk.Key, pk.Timestamp))
+	if err != nil {
+		return err
+	}
+	var prevSecret sql.NullString
+	if pk.PreviousSecret != "" {
+		prevSecret.String, err = envelope.SealString(pk.PreviousSecret, envelope.AAD("project_key", "previous_secret", pk.Key, pk.Timestamp))
+		if err != nil {
+			return err
+		}
+		prevSecret.Valid = true
+	}
+	var scopes sql.NullString
+	if len(pk.Scopes) > 0 {
+		scopes.String, scopes.Valid = pk.scopesString(), true
+	}
+	var ipAllowlist sql.NullString
+	if len(pk.IPAllowlist) > 0 {
+		ipAllowlist.String, ipAllowlist.Valid = strings.Join(pk.IPAllowlist.Strings(), " "), true
+	}
+	var publicKeys sql.NullString
+	if pk.Asymmetric() {
+		publicKeys.String, publicKeys.Valid = strings.Join(pk.HexPublicKeys(), " "), true
+	}
+	var clientCerts sql.NullString
+	if len(pk.ClientCerts) > 0 {
+		clientCerts.String, clientCerts.Valid = strings.Join(pk.ClientCerts, " "), true
+	}
+	_, err = stmt.Exec(
+		pk.Key,
+		pk.Timestamp,
+		pk.Project.ID,
+		pk.CreatedBy,
+		secret,
+		pk.Active,
+		pk.Expires,
+		prevSecret,
+		pk.PreviousExpires,
+		scopes,
+		ipAllowlist,
+		publicKeys,
+		clientCerts,
+	)
+	return err
+}
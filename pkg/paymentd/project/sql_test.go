@@ -1,9 +1,11 @@
 package project_test
 
 import (
+	"bytes"
 	"database/sql"
 	"testing"
 
+	"github.com/fritzpay/paymentd/pkg/envelope"
 	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
 	"github.com/fritzpay/paymentd/pkg/paymentd/project"
 	"github.com/fritzpay/paymentd/pkg/testutil"
@@ -78,3 +80,66 @@ func TestProjectSQLMapping(t *testing.T) {
 		})
 	}))
 }
+
+func TestProjectKeySealedSecret(t *testing.T) {
+	Convey("Given a principal DB connection", t, testutil.WithPrincipalDB(t, func(prDB *sql.DB) {
+		k, err := envelope.NewKeyring(bytes.Repeat([]byte{1}, envelope.KeySize))
+		So(err, ShouldBeNil)
+		envelope.SetKeyring(k)
+		Reset(func() {
+			envelope.SetKeyring(nil)
+		})
+
+		Convey("Given a db transaction", func() {
+			tx, err := prDB.Begin()
+			So(err, ShouldBeNil)
+			Reset(func() {
+				err = tx.Rollback()
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Given two stored project keys", WithTestProject(tx, func(pr *project.Project) {
+				first, err := project.NewProjectkey(*pr, "test")
+				So(err, ShouldBeNil)
+				So(project.InsertProjectKeyTx(tx, first), ShouldBeNil)
+				second, err := project.NewProjectkey(*pr, "test")
+				So(err, ShouldBeNil)
+				So(project.InsertProjectKeyTx(tx, second), ShouldBeNil)
+
+				Convey("The secrets should be sealed and opened", func() {
+					var sealed string
+					err := tx.QueryRow("SELECT secret FROM project_key WHERE `key` = ?", first.Key).Scan(&sealed)
+					So(err, ShouldBeNil)
+					So(envelope.IsSealed(sealed), ShouldBeTrue)
+
+					pk, err := project.ProjectKeyByKeyTx(tx, first.Key)
+					So(err, ShouldBeNil)
+					So(pk.Secret, ShouldEqual, first.Secret)
+				})
+
+				Convey("The secrets should be bound to the primary key as scanned by ResealTable", func() {
+					var key, ts interface{}
+					var sealed string
+					err := tx.QueryRow("SELECT `key`, timestamp, secret FROM project_key WHERE `key` = ?", first.Key).Scan(&key, &ts, &sealed)
+					So(err, ShouldBeNil)
+					plain, err := k.Open(sealed, envelope.AAD("project_key", "secret", key, ts))
+					So(err, ShouldBeNil)
+					So(string(plain), ShouldEqual, first.Secret)
+				})
+
+				Convey("When a sealed secret is copied to another key", func() {
+					var sealed string
+					err := tx.QueryRow("SELECT secret FROM project_key WHERE `key` = ?", first.Key).Scan(&sealed)
+					So(err, ShouldBeNil)
+					_, err = tx.Exec("UPDATE project_key SET secret = ? WHERE `key` = ?", sealed, second.Key)
+					So(err, ShouldBeNil)
+
+					Convey("Opening it should fail", func() {
+						_, err := project.ProjectKeyByKeyTx(tx, second.Key)
+						So(err, ShouldNotBeNil)
+					})
+				})
+			}))
+		})
+	}))
+}
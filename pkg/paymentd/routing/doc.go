@@ -0,0 +1,29 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package routing provides rule-based selection of payment methods
+
+A project can define an ordered set of rules. Each rule matches payments by country,
+currency, amount range, metadata and time of day and names one or more payment
+methods with weights. The weights allow splitting traffic between methods, e.g. for
+A/B tests or to optimize PSP costs.
+
+All matching rules contribute to the list of candidate payment methods. If the
+initialization of a payment with the first candidate fails, the next candidate
+can be tried.
+*/
+package routing
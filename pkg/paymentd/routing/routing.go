@@ -0,0 +1,213 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+)
+
+const (
+	// TimeOfDayFormat is the format for time of day values in rules
+	TimeOfDayFormat = "15:04"
+)
+
+var (
+	ErrNoMethods = errors.New("rule has no payment methods")
+)
+
+// Target is a payment method a rule routes to
+type Target struct {
+	PaymentMethodID int64 `json:"PaymentMethodId,string"`
+	// Weight is the relative weight of the target within the rule
+	//
+	// A weight of 0 is treated as 1
+	Weight int
+}
+
+func (t Target) weight() int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+// Rule describes conditions under which a payment is routed to the rule's targets
+//
+// Empty conditions always match. All non-empty conditions must match for the rule
+// to match.
+type Rule struct {
+	// Countries is a list of ISO 3166-1 alpha-2 country codes
+	Countries []string `json:",omitempty"`
+	// Currencies is a list of ISO 4217 currency codes
+	Currencies []string `json:",omitempty"`
+	// MinAmount is the minimum amount (inclusive) in the smallest currency unit
+	MinAmount *int64 `json:",omitempty"`
+	// MaxAmount is the maximum amount (inclusive) in the smallest currency unit
+	MaxAmount *int64 `json:",omitempty"`
+	// Metadata entries which must be present in the payment metadata with the same value
+	Metadata map[string]string `json:",omitempty"`
+	// TimeFrom and TimeTo define a time of day window (UTC) in the format "15:04"
+	//
+	// If TimeTo is before TimeFrom, the window spans midnight.
+	TimeFrom string `json:",omitempty"`
+	TimeTo   string `json:",omitempty"`
+
+	Methods []Target
+}
+
+// Validate returns an error if the rule is invalid
+func (r Rule) Validate() error {
+	if len(r.Methods) == 0 {
+		return ErrNoMethods
+	}
+	for _, t := range r.Methods {
+		if t.PaymentMethodID == 0 {
+			return fmt.Errorf("invalid payment method id")
+		}
+		if t.Weight < 0 {
+			return fmt.Errorf("invalid weight %d", t.Weight)
+		}
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return fmt.Errorf("min amount greater than max amount")
+	}
+	if (r.TimeFrom == "") != (r.TimeTo == "") {
+		return fmt.Errorf("time window requires both TimeFrom and TimeTo")
+	}
+	if r.TimeFrom != "" {
+		if _, err := time.Parse(TimeOfDayFormat, r.TimeFrom); err != nil {
+			return fmt.Errorf("invalid TimeFrom: %v", err)
+		}
+		if _, err := time.Parse(TimeOfDayFormat, r.TimeTo); err != nil {
+			return fmt.Errorf("invalid TimeTo: %v", err)
+		}
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// minute of the day
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+func (r Rule) matchesTime(now time.Time) bool {
+	if r.TimeFrom == "" {
+		return true
+	}
+	from, err := time.Parse(TimeOfDayFormat, r.TimeFrom)
+	if err != nil {
+		return false
+	}
+	to, err := time.Parse(TimeOfDayFormat, r.TimeTo)
+	if err != nil {
+		return false
+	}
+	m, f, t := minuteOfDay(now.UTC()), minuteOfDay(from), minuteOfDay(to)
+	if f <= t {
+		return m >= f && m < t
+	}
+	// window spans midnight
+	return m >= f || m < t
+}
+
+// Matches returns true if the payment matches the rule at the given time
+func (r Rule) Matches(p *payment.Payment, now time.Time) bool {
+	if len(r.Countries) > 0 {
+		if !p.Config.Country.Valid || !containsFold(r.Countries, p.Config.Country.String) {
+			return false
+		}
+	}
+	if len(r.Currencies) > 0 && !containsFold(r.Currencies, p.Currency) {
+		return false
+	}
+	if r.MinAmount != nil && p.Amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && p.Amount > *r.MaxAmount {
+		return false
+	}
+	for k, v := range r.Metadata {
+		if p.Metadata == nil || p.Metadata[k] != v {
+			return false
+		}
+	}
+	return r.matchesTime(now)
+}
+
+// weightedOrder returns the payment method IDs of the rule targets in a weighted
+// random order
+func (r Rule) weightedOrder(intn func(int) int) []int64 {
+	targets := make([]Target, len(r.Methods))
+	copy(targets, r.Methods)
+	ids := make([]int64, 0, len(targets))
+	for len(targets) > 0 {
+		var total int
+		for _, t := range targets {
+			total += t.weight()
+		}
+		n := intn(total)
+		for i, t := range targets {
+			n -= t.weight()
+			if n < 0 {
+				ids = append(ids, t.PaymentMethodID)
+				targets = append(targets[:i], targets[i+1:]...)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// Ruleset is the ordered list of routing rules of a project
+type Ruleset struct {
+	ProjectID int64 `json:",string"`
+	Timestamp time.Time
+	CreatedBy string
+	Rules     []Rule
+}
+
+// Validate returns an error if any of the rules is invalid
+func (rs *Ruleset) Validate() error {
+	for i, r := range rs.Rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Candidates returns the payment method IDs eligible for the given payment
+//
+// The IDs of the first matching rule come first (in weighted random order), followed
+// by the IDs of subsequent matching rules. Every ID is returned only once.
+//
+// The intn function must return a random number in [0,n), e.g. rand.Intn.
+func (rs *Ruleset) Candidates(p *payment.Payment, now time.Time, intn func(n int) int) []int64 {
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, r := range rs.Rules {
+		if !r.Matches(p, now) {
+			continue
+		}
+		for _, id := range r.weightedOrder(intn) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,132 @@
+package routing_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/paymentd/routing"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRulesetCandidates(t *testing.T) {
+	Convey("Given a payment", t, func() {
+		p := &payment.Payment{
+			Amount:   5000,
+			Currency: "EUR",
+			Metadata: map[string]string{"segment": "vip"},
+		}
+		p.Config.SetCountry("DE")
+		now := time.Date(2014, 11, 1, 14, 30, 0, 0, time.UTC)
+		rnd := rand.New(rand.NewSource(1))
+
+		Convey("Given a ruleset with conditions", func() {
+			maxAmount := int64(1000)
+			rs := &routing.Ruleset{
+				Rules: []routing.Rule{
+					{
+						Currencies: []string{"USD"},
+						Methods:    []routing.Target{{PaymentMethodID: 1}},
+					},
+					{
+						Countries: []string{"de", "AT"},
+						Metadata:  map[string]string{"segment": "vip"},
+						TimeFrom:  "08:00",
+						TimeTo:    "18:00",
+						Methods:   []routing.Target{{PaymentMethodID: 2}},
+					},
+					{
+						MaxAmount: &maxAmount,
+						Methods:   []routing.Target{{PaymentMethodID: 3}},
+					},
+					{
+						Methods: []routing.Target{{PaymentMethodID: 4}, {PaymentMethodID: 2}},
+					},
+				},
+			}
+			So(rs.Validate(), ShouldBeNil)
+
+			Convey("When retrieving the candidates", func() {
+				ids := rs.Candidates(p, now, rnd.Intn)
+
+				Convey("It should return the methods of all matching rules in order", func() {
+					So(ids, ShouldResemble, []int64{2, 4})
+				})
+			})
+
+			Convey("When the time is outside of the time window", func() {
+				ids := rs.Candidates(p, now.Add(5*time.Hour), rnd.Intn)
+
+				Convey("It should skip the rule", func() {
+					So(len(ids), ShouldEqual, 2)
+					So(ids, ShouldContain, int64(2))
+					So(ids, ShouldContain, int64(4))
+				})
+			})
+		})
+
+		Convey("Given a rule with a time window spanning midnight", func() {
+			r := routing.Rule{
+				TimeFrom: "22:00",
+				TimeTo:   "06:00",
+				Methods:  []routing.Target{{PaymentMethodID: 1}},
+			}
+			So(r.Validate(), ShouldBeNil)
+
+			Convey("It should match at night only", func() {
+				So(r.Matches(p, now), ShouldBeFalse)
+				So(r.Matches(p, time.Date(2014, 11, 1, 23, 0, 0, 0, time.UTC)), ShouldBeTrue)
+				So(r.Matches(p, time.Date(2014, 11, 1, 5, 59, 0, 0, time.UTC)), ShouldBeTrue)
+			})
+		})
+
+		Convey("Given a weighted split", func() {
+			rs := &routing.Ruleset{
+				Rules: []routing.Rule{
+					{
+						Methods: []routing.Target{
+							{PaymentMethodID: 1, Weight: 3},
+							{PaymentMethodID: 2, Weight: 1},
+						},
+					},
+				},
+			}
+
+			Convey("When retrieving the candidates many times", func() {
+				counts := make(map[int64]int)
+				for i := 0; i < 4000; i++ {
+					ids := rs.Candidates(p, now, rnd.Intn)
+					So(len(ids), ShouldEqual, 2)
+					counts[ids[0]]++
+				}
+
+				Convey("The first candidate should follow the weights", func() {
+					So(counts[1], ShouldBeBetween, 2800, 3200)
+					So(counts[2], ShouldBeBetween, 800, 1200)
+				})
+			})
+		})
+	})
+}
+
+func TestRuleValidate(t *testing.T) {
+	Convey("Given a rule without methods", t, func() {
+		r := routing.Rule{}
+
+		Convey("It should not validate", func() {
+			So(r.Validate(), ShouldEqual, routing.ErrNoMethods)
+		})
+	})
+
+	Convey("Given a rule with an incomplete time window", t, func() {
+		r := routing.Rule{
+			TimeFrom: "10:00",
+			Methods:  []routing.Target{{PaymentMethodID: 1}},
+		}
+
+		Convey("It should not validate", func() {
+			So(r.Validate(), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRulesetNotFound = errors.New("ruleset not found")
+)
+
+const selectRuleset = `
+SELECT
+	r.project_id,
+	r.timestamp,
+	r.created_by,
+	r.rules
+FROM payment_method_routing AS r
+WHERE
+	r.project_id = ?
+	AND
+	r.timestamp = (
+		SELECT MAX(timestamp) FROM payment_method_routing
+		WHERE
+			project_id = r.project_id
+	)
+`
+
+func scanRuleset(row *sql.Row) (*Ruleset, error) {
+	rs := &Ruleset{}
+	var ts int64
+	var rules string
+	err := row.Scan(
+		&rs.ProjectID,
+		&ts,
+		&rs.CreatedBy,
+		&rules,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRulesetNotFound
+		}
+		return nil, err
+	}
+	rs.Timestamp = time.Unix(0, ts)
+	err = json.Unmarshal([]byte(rules), &rs.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// RulesetByProjectIDDB returns the current ruleset of the given project
+func RulesetByProjectIDDB(db *sql.DB, projectID int64) (*Ruleset, error) {
+	row := db.QueryRow(selectRuleset, projectID)
+	return scanRuleset(row)
+}
+
+// RulesetByProjectIDTx returns the current ruleset of the given project
+func RulesetByProjectIDTx(db *sql.Tx, projectID int64) (*Ruleset, error) {
+	row := db.QueryRow(selectRuleset, projectID)
+	return scanRuleset(row)
+}
+
+const insertRuleset = `
+INSERT INTO payment_method_routing
+(project_id, timestamp, created_by, rules)
+VALUES
+(?, ?, ?, ?)
+`
+
+// InsertRulesetTx saves a new version of the ruleset
+func InsertRulesetTx(db *sql.Tx, rs *Ruleset) error {
+	rules, err := json.Marshal(rs.Rules)
+	if err != nil {
+		return err
+	}
+	stmt, err := db.Prepare(insertRuleset)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(rs.ProjectID, rs.Timestamp.UnixNano(), rs.CreatedBy, string(rules))
+	stmt.Close()
+	return err
+}
@@ -0,0 +1,30 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package user provides admin API user accounts and their roles
+
+Users authenticate against the admin API with a bcrypt hashed password. What a user
+is allowed to do is determined by role grants. A grant assigns a role either
+globally, for a principal (and all of its projects) or for a single project.
+
+Roles are ordered. A role includes all permissions of the roles below it:
+
+	viewer < operator < admin < superadmin
+
+The superadmin role can only be granted globally.
+*/
+package user
@@ -0,0 +1,188 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPDigits is the number of digits of TOTP codes
+	TOTPDigits = 6
+	// TOTPPeriod is the time step of TOTP codes
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the number of time steps before and after the current step which
+	// are accepted to compensate clock drift
+	TOTPSkew = 1
+	// TOTPSecretBytes is the size of generated TOTP secrets
+	TOTPSecretBytes = 20
+	// RecoveryCodeCount is the number of generated recovery codes
+	RecoveryCodeCount = 10
+)
+
+var (
+	ErrTOTPNotEnrolled = errors.New("totp not enrolled")
+	ErrTOTPEnabled     = errors.New("totp already enabled")
+	ErrInvalidTOTPCode = errors.New("invalid totp code")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFA holds the two-factor authentication settings of a user
+type MFA struct {
+	// Timestamp is the version of the settings in Unix nanoseconds, 0 if there are no
+	// stored settings
+	Timestamp int64 `json:"-"`
+	// Required enforces two-factor authentication for the user
+	Required bool
+	// TOTPEnabled is true once the user confirmed the TOTP enrollment
+	TOTPEnabled bool
+	// TOTPSecret is the base32 encoded TOTP secret
+	TOTPSecret string `json:"-"`
+	// RecoveryCodes are the SHA-256 hashes of the unused recovery codes
+	RecoveryCodes []string `json:"-"`
+}
+
+// Enrolled returns true if the user has a confirmed second factor
+func (m MFA) Enrolled() bool {
+	return m.TOTPEnabled && m.TOTPSecret != ""
+}
+
+// NewTOTPSecret generates a new random base32 encoded TOTP secret
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, TOTPSecretBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// hotp computes the HOTP value (RFC 4226) for the given counter
+func hotp(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, code%mod)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// TOTP returns the TOTP code (RFC 6238) of the secret at the given time
+func TOTP(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(totpStep(t))), nil
+}
+
+// ValidateTOTP checks the given code against the user's TOTP secret
+//
+// It returns the time step of the matching code, which can be used to reject replayed
+// codes.
+func (m MFA) ValidateTOTP(code string, t time.Time) (int64, error) {
+	if m.TOTPSecret == "" {
+		return 0, ErrTOTPNotEnrolled
+	}
+	key, err := decodeTOTPSecret(m.TOTPSecret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, ErrInvalidTOTPCode
+	}
+	step := totpStep(t)
+	for i := int64(-TOTPSkew); i <= TOTPSkew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step+i))), []byte(code)) == 1 {
+			return step + i, nil
+		}
+	}
+	return 0, ErrInvalidTOTPCode
+}
+
+// TOTPURI returns the otpauth URI of the secret, which can be used to enroll
+// authenticator apps, e.g. by QR code
+func TOTPURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	v.Set("period", fmt.Sprintf("%d", int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + v.Encode()
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRecoveryCodes replaces the recovery codes with new random codes
+//
+// It returns the codes, which are only stored as hashes.
+func (m *MFA) NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	b := make([]byte, 5)
+	for i := range codes {
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		c := hex.EncodeToString(b)
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	m.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// UseRecoveryCode removes the given recovery code
+//
+// It returns false if the code is not a valid recovery code.
+func (m *MFA) UseRecoveryCode(code string) bool {
+	h := hashRecoveryCode(code)
+	for i, c := range m.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(c), []byte(h)) == 1 {
+			m.RecoveryCodes = append(m.RecoveryCodes[:i:i], m.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset removes the second factor and all recovery codes
+func (m *MFA) Reset() {
+	m.TOTPEnabled = false
+	m.TOTPSecret = ""
+	m.RecoveryCodes = nil
+}
@@ -0,0 +1,102 @@
+package user
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// rfc6238Secret is the SHA1 test secret of RFC 6238 appendix B
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTP(t *testing.T) {
+	Convey("Given the RFC 6238 test secret", t, func() {
+		Convey("The codes should match the test vectors", func() {
+			for ts, code := range map[int64]string{
+				59:         "287082",
+				1111111109: "081804",
+				1234567890: "005924",
+				2000000000: "279037",
+			} {
+				c, err := TOTP(rfc6238Secret, time.Unix(ts, 0))
+				So(err, ShouldBeNil)
+				So(c, ShouldEqual, code)
+			}
+		})
+
+		Convey("Given a user with TOTP enabled", func() {
+			m := MFA{TOTPEnabled: true, TOTPSecret: rfc6238Secret}
+			now := time.Unix(1111111109, 0)
+
+			Convey("The current code should be valid", func() {
+				step, err := m.ValidateTOTP("081804", now)
+				So(err, ShouldBeNil)
+				So(step, ShouldEqual, 1111111109/30)
+			})
+			Convey("The code of the previous time step should be valid", func() {
+				_, err := m.ValidateTOTP("081804", now.Add(TOTPPeriod))
+				So(err, ShouldBeNil)
+			})
+			Convey("Codes outside of the allowed skew should be invalid", func() {
+				_, err := m.ValidateTOTP("081804", now.Add(3*TOTPPeriod))
+				So(err, ShouldEqual, ErrInvalidTOTPCode)
+			})
+			Convey("Malformed codes should be invalid", func() {
+				_, err := m.ValidateTOTP("81804", now)
+				So(err, ShouldEqual, ErrInvalidTOTPCode)
+			})
+		})
+	})
+
+	Convey("Given a user without TOTP", t, func() {
+		m := MFA{}
+		Convey("Validating a code should fail", func() {
+			_, err := m.ValidateTOTP("123456", time.Now())
+			So(err, ShouldEqual, ErrTOTPNotEnrolled)
+		})
+	})
+
+	Convey("When generating a secret", t, func() {
+		secret, err := NewTOTPSecret()
+		So(err, ShouldBeNil)
+		Convey("It should generate valid codes", func() {
+			code, err := TOTP(secret, time.Now())
+			So(err, ShouldBeNil)
+			m := MFA{TOTPSecret: secret}
+			_, err = m.ValidateTOTP(code, time.Now())
+			So(err, ShouldBeNil)
+		})
+		Convey("The URI should include the secret", func() {
+			uri := TOTPURI("paymentd", "jane", secret)
+			So(strings.HasPrefix(uri, "otpauth://totp/paymentd:jane?"), ShouldBeTrue)
+			So(uri, ShouldContainSubstring, "secret="+secret)
+		})
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	Convey("Given new recovery codes", t, func() {
+		m := &MFA{}
+		codes, err := m.NewRecoveryCodes()
+		So(err, ShouldBeNil)
+		So(len(codes), ShouldEqual, RecoveryCodeCount)
+
+		Convey("Only hashes should be stored", func() {
+			So(len(m.RecoveryCodes), ShouldEqual, RecoveryCodeCount)
+			So(m.RecoveryCodes, ShouldNotContain, codes[0])
+		})
+		Convey("When using a code", func() {
+			ok := m.UseRecoveryCode(strings.ToUpper(codes[0]))
+			Convey("It should be accepted once", func() {
+				So(ok, ShouldBeTrue)
+				So(len(m.RecoveryCodes), ShouldEqual, RecoveryCodeCount-1)
+				So(m.UseRecoveryCode(codes[0]), ShouldBeFalse)
+			})
+		})
+		Convey("An unknown code should be rejected", func() {
+			So(m.UseRecoveryCode("00000-00000"), ShouldBeFalse)
+		})
+	})
+}
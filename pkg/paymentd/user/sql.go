@@ -0,0 +1,414 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/envelope"
+)
+
+var (
+	// ErrUserNotFound is returned by the various select methods if the requested
+	// user was not found
+	ErrUserNotFound = errors.New("user not found")
+)
+
+const selectUser = `
+SELECT
+	u.id,
+	u.created,
+	u.created_by,
+	u.name,
+	s.status,
+	p.password,
+	r.grants,
+	m.timestamp,
+	m.required,
+	m.totp_enabled,
+	m.totp_secret,
+	m.recovery_codes
+FROM ` + "`user`" + ` AS u
+INNER JOIN user_status AS s ON
+	s.user_id = u.id
+	AND
+	s.timestamp = (
+		SELECT MAX(timestamp) FROM user_status
+		WHERE
+			user_id = u.id
+	)
+INNER JOIN user_password AS p ON
+	p.user_id = u.id
+	AND
+	p.timestamp = (
+		SELECT MAX(timestamp) FROM user_password
+		WHERE
+			user_id = u.id
+	)
+LEFT JOIN user_role AS r ON
+	r.user_id = u.id
+	AND
+	r.timestamp = (
+		SELECT MAX(timestamp) FROM user_role
+		WHERE
+			user_id = u.id
+	)
+LEFT JOIN user_mfa AS m ON
+	m.user_id = u.id
+	AND
+	m.timestamp = (
+		SELECT MAX(timestamp) FROM user_mfa
+		WHERE
+			user_id = u.id
+	)
+`
+
+const selectUserByName = selectUser + `
+WHERE
+	u.name = ?
+`
+
+const selectUserByID = selectUser + `
+WHERE
+	u.id = ?
+`
+
+const selectUserAll = selectUser + `
+ORDER BY u.name
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*User, error) {
+	u := &User{}
+	var grants sql.NullString
+	var mfaTimestamp sql.NullInt64
+	var mfaRequired, totpEnabled sql.NullBool
+	var totpSecret, recoveryCodes sql.NullString
+	err := row.Scan(
+		&u.ID,
+		&u.Created,
+		&u.CreatedBy,
+		&u.Name,
+		&u.Status,
+		&u.Password,
+		&grants,
+		&mfaTimestamp,
+		&mfaRequired,
+		&totpEnabled,
+		&totpSecret,
+		&recoveryCodes,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if grants.Valid && grants.String != "" {
+		err = json.Unmarshal([]byte(grants.String), &u.Grants)
+		if err != nil {
+			return nil, err
+		}
+	}
+	u.MFA.Timestamp = mfaTimestamp.Int64
+	u.MFA.Required = mfaRequired.Bool
+	u.MFA.TOTPEnabled = totpEnabled.Bool
+	u.MFA.TOTPSecret, err = envelope.OpenString(totpSecret.String, totpSecretAAD(u.ID, u.MFA.Timestamp))
+	if err != nil {
+		return nil, err
+	}
+	if recoveryCodes.Valid && recoveryCodes.String != "" {
+		err = json.Unmarshal([]byte(recoveryCodes.String), &u.MFA.RecoveryCodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// UserByNameDB selects a user by its name
+func UserByNameDB(db *sql.DB, name string) (*User, error) {
+	row := db.QueryRow(selectUserByName, name)
+	return scanUser(row)
+}
+
+// UserByNameTx selects a user by its name
+func UserByNameTx(db *sql.Tx, name string) (*User, error) {
+	row := db.QueryRow(selectUserByName, name)
+	return scanUser(row)
+}
+
+// UserByIDTx selects a user by its ID
+func UserByIDTx(db *sql.Tx, id int64) (*User, error) {
+	row := db.QueryRow(selectUserByID, id)
+	return scanUser(row)
+}
+
+// UserAllDB selects all users
+func UserAllDB(db *sql.DB) ([]*User, error) {
+	rows, err := db.Query(selectUserAll)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, 16)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	err = rows.Err()
+	rows.Close()
+	return users, err
+}
+
+const selectUserCount = "SELECT COUNT(*) FROM `user`"
+
+// UserCountDB returns the number of users
+func UserCountDB(db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRow(selectUserCount).Scan(&n)
+	return n, err
+}
+
+const insertUser = `
+INSERT INTO ` + "`user`" + `
+(created, created_by, name)
+VALUES
+(?, ?, ?)
+`
+
+// InsertUserTx inserts a user
+//
+// This will modify the given user, setting the ID field. The status, password and
+// grants have to be inserted separately.
+func InsertUserTx(db *sql.Tx, u *User) error {
+	stmt, err := db.Prepare(insertUser)
+	if err != nil {
+		return err
+	}
+	res, err := stmt.Exec(u.Created, u.CreatedBy, u.Name)
+	stmt.Close()
+	if err != nil {
+		return err
+	}
+	u.ID, err = res.LastInsertId()
+	return err
+}
+
+const insertUserStatus = `
+INSERT INTO user_status
+(user_id, timestamp, created_by, status)
+VALUES
+(?, ?, ?, ?)
+`
+
+// InsertUserStatusTx adds a status entry for the given user
+func InsertUserStatusTx(db *sql.Tx, u *User, createdBy string) error {
+	stmt, err := db.Prepare(insertUserStatus)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, time.Now().UnixNano(), createdBy, u.Status)
+	stmt.Close()
+	return err
+}
+
+const insertUserPassword = `
+INSERT INTO user_password
+(user_id, timestamp, created_by, password)
+VALUES
+(?, ?, ?, ?)
+`
+
+// InsertUserPasswordTx adds a password entry for the given user
+func InsertUserPasswordTx(db *sql.Tx, u *User, createdBy string) error {
+	stmt, err := db.Prepare(insertUserPassword)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, time.Now().UnixNano(), createdBy, u.Password)
+	stmt.Close()
+	return err
+}
+
+const insertUserRole = `
+INSERT INTO user_role
+(user_id, timestamp, created_by, grants)
+VALUES
+(?, ?, ?, ?)
+`
+
+// InsertUserGrantsTx saves a new version of the user's role grants
+func InsertUserGrantsTx(db *sql.Tx, u *User, createdBy string) error {
+	grants := u.Grants
+	if grants == nil {
+		grants = []Grant{}
+	}
+	enc, err := json.Marshal(grants)
+	if err != nil {
+		return err
+	}
+	stmt, err := db.Prepare(insertUserRole)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, time.Now().UnixNano(), createdBy, string(enc))
+	stmt.Close()
+	return err
+}
+
+// totpSecretAAD binds the sealed TOTP secret to its settings version
+func totpSecretAAD(userID, timestamp int64) []byte {
+	return envelope.AAD("user_mfa", "totp_secret", userID, timestamp)
+}
+
+const insertUserMFA = `
+INSERT INTO user_mfa
+(user_id, timestamp, created_by, required, totp_enabled, totp_secret, recovery_codes)
+VALUES
+(?, ?, ?, ?, ?, ?, ?)
+`
+
+// InsertUserMFATx saves a new version of the user's two-factor authentication
+// settings
+//
+// The TOTP secret will be sealed.
+func InsertUserMFATx(db *sql.Tx, u *User, createdBy string) error {
+	ts := time.Now().UnixNano()
+	var secret, codes sql.NullString
+	var err error
+	if u.MFA.TOTPSecret != "" {
+		secret.String, err = envelope.SealString(u.MFA.TOTPSecret, totpSecretAAD(u.ID, ts))
+		if err != nil {
+			return err
+		}
+		secret.Valid = true
+	}
+	if len(u.MFA.RecoveryCodes) > 0 {
+		enc, err := json.Marshal(u.MFA.RecoveryCodes)
+		if err != nil {
+			return err
+		}
+		codes.String, codes.Valid = string(enc), true
+	}
+	stmt, err := db.Prepare(insertUserMFA)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, ts, createdBy, u.MFA.Required, u.MFA.TOTPEnabled, secret, codes)
+	stmt.Close()
+	if err != nil {
+		return err
+	}
+	u.MFA.Timestamp = ts
+	return nil
+}
+
+const selectUserTOTPStep = `
+SELECT MAX(step) FROM user_totp_step
+WHERE
+	user_id = ?
+`
+
+const insertUserTOTPStep = `
+INSERT INTO user_totp_step
+(user_id, step, timestamp)
+VALUES
+(?, ?, ?)
+`
+
+// UseTOTPStepTx records the time step of a verified TOTP code
+//
+// It returns ErrInvalidTOTPCode if the step or a later step was already used, so
+// each TOTP code is only accepted once.
+func UseTOTPStepTx(db *sql.Tx, u *User, step int64) error {
+	var last sql.NullInt64
+	err := db.QueryRow(selectUserTOTPStep, u.ID).Scan(&last)
+	if err != nil {
+		return err
+	}
+	if last.Valid && step <= last.Int64 {
+		return ErrInvalidTOTPCode
+	}
+	stmt, err := db.Prepare(insertUserTOTPStep)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, step, time.Now().UnixNano())
+	stmt.Close()
+	if dialect.IsUniqueViolation(err) {
+		return ErrInvalidTOTPCode
+	}
+	return err
+}
+
+const insertUserRecoveryCodeUse = `
+INSERT INTO user_recovery_code_use
+(user_id, code_hash, timestamp)
+VALUES
+(?, ?, ?)
+`
+
+const selectUserRecoveryCodeUse = `
+SELECT code_hash FROM user_recovery_code_use
+WHERE
+	user_id = ?
+`
+
+// UseRecoveryCodeTx consumes the given recovery code
+//
+// The use is recorded before a new version of the two-factor authentication settings
+// without the used codes is saved. A concurrent use of the same code violates the
+// unique key of the use and returns ErrInvalidTOTPCode, as does an unknown code.
+func UseRecoveryCodeTx(db *sql.Tx, u *User, code string) error {
+	if !u.MFA.UseRecoveryCode(code) {
+		return ErrInvalidTOTPCode
+	}
+	stmt, err := db.Prepare(insertUserRecoveryCodeUse)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(u.ID, hashRecoveryCode(code), time.Now().UnixNano())
+	stmt.Close()
+	if dialect.IsUniqueViolation(err) {
+		return ErrInvalidTOTPCode
+	}
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(selectUserRecoveryCodeUse, u.ID)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for rows.Next() {
+		var h string
+		err = rows.Scan(&h)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		used[h] = true
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	codes := u.MFA.RecoveryCodes[:0:0]
+	for _, h := range u.MFA.RecoveryCodes {
+		if !used[h] {
+			codes = append(codes, h)
+		}
+	}
+	u.MFA.RecoveryCodes = codes
+	return InsertUserMFATx(db, u, u.Name)
+}
@@ -0,0 +1,94 @@
+package user
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func WithUser(db *sql.Tx, f func(u *User)) func() {
+	return func() {
+		u := &User{
+			Created:   time.Now(),
+			CreatedBy: "test",
+			Name:      "test_user",
+			Status:    UserStatusActive,
+		}
+		So(u.SetPassword([]byte("test_password")), ShouldBeNil)
+		err := InsertUserTx(db, u)
+		So(err, ShouldBeNil)
+		err = InsertUserStatusTx(db, u, "test")
+		So(err, ShouldBeNil)
+		err = InsertUserPasswordTx(db, u, "test")
+		So(err, ShouldBeNil)
+
+		f(u)
+	}
+}
+
+func TestUserMFASQL(t *testing.T) {
+	Convey("Given a principal DB connection", t, testutil.WithPrincipalDB(t, func(db *sql.DB) {
+		Reset(func() {
+			db.Close()
+		})
+
+		Convey("Given a db transaction", func() {
+			tx, err := db.Begin()
+			So(err, ShouldBeNil)
+			Reset(func() {
+				err = tx.Rollback()
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Given a user", WithUser(tx, func(u *User) {
+
+				Convey("When using a TOTP step", func() {
+					err = UseTOTPStepTx(tx, u, 100)
+					So(err, ShouldBeNil)
+
+					Convey("It should reject the same step", func() {
+						So(UseTOTPStepTx(tx, u, 100), ShouldEqual, ErrInvalidTOTPCode)
+					})
+					Convey("It should reject earlier steps", func() {
+						So(UseTOTPStepTx(tx, u, 99), ShouldEqual, ErrInvalidTOTPCode)
+					})
+					Convey("It should accept later steps", func() {
+						So(UseTOTPStepTx(tx, u, 101), ShouldBeNil)
+					})
+				})
+
+				Convey("Given recovery codes", func() {
+					codes, err := u.MFA.NewRecoveryCodes()
+					So(err, ShouldBeNil)
+					u.MFA.TOTPEnabled = true
+					err = InsertUserMFATx(tx, u, u.Name)
+					So(err, ShouldBeNil)
+
+					Convey("When using a recovery code", func() {
+						err = UseRecoveryCodeTx(tx, u, codes[0])
+						So(err, ShouldBeNil)
+
+						Convey("It should be removed from the stored settings", func() {
+							sel, err := UserByIDTx(tx, u.ID)
+							So(err, ShouldBeNil)
+							So(len(sel.MFA.RecoveryCodes), ShouldEqual, RecoveryCodeCount-1)
+						})
+						Convey("It should be rejected when used again with outdated settings", func() {
+							stale, err := UserByIDTx(tx, u.ID)
+							So(err, ShouldBeNil)
+							stale.MFA.RecoveryCodes = append(stale.MFA.RecoveryCodes, hashRecoveryCode(codes[0]))
+							So(UseRecoveryCodeTx(tx, stale, codes[0]), ShouldEqual, ErrInvalidTOTPCode)
+						})
+					})
+
+					Convey("It should reject unknown codes", func() {
+						So(UseRecoveryCodeTx(tx, u, "00000-00000"), ShouldEqual, ErrInvalidTOTPCode)
+					})
+				})
+			}))
+		})
+	}))
+}
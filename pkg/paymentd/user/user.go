@@ -0,0 +1,218 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// PasswordBcryptCost is the cost for bcrypting user passwords
+	PasswordBcryptCost = 10
+	// MinPasswordLength is the minimum length of a user password
+	MinPasswordLength = 8
+)
+
+const (
+	UserStatusActive   = "active"
+	UserStatusInactive = "inactive"
+)
+
+var (
+	ErrInvalidStatus    = errors.New("invalid status")
+	ErrInvalidName      = errors.New("invalid user name")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrInvalidGrant     = errors.New("invalid grant")
+	ErrPasswordTooShort = errors.New("password too short")
+	ErrUserInactive     = errors.New("user is inactive")
+)
+
+var validName = regexp.MustCompile(`^[-A-Za-z0-9_.@]{1,64}$`)
+
+// Role is an admin API role
+type Role string
+
+const (
+	// RoleViewer can read resources
+	RoleViewer Role = "viewer"
+	// RoleOperator can additionally manage payment methods and routing
+	RoleOperator Role = "operator"
+	// RoleAdmin can additionally manage principals, projects and their keys
+	RoleAdmin Role = "admin"
+	// RoleSuperadmin can do everything, including user management
+	RoleSuperadmin Role = "superadmin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:     1,
+	RoleOperator:   2,
+	RoleAdmin:      3,
+	RoleSuperadmin: 4,
+}
+
+// Valid returns true if the role is a known role
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Includes returns true if the role includes all permissions of the other role
+func (r Role) Includes(other Role) bool {
+	if !r.Valid() || !other.Valid() {
+		return false
+	}
+	return roleLevels[r] >= roleLevels[other]
+}
+
+// Scope is the scope of a resource which is being accessed
+//
+// A zero scope describes global resources, e.g. the list of all principals.
+type Scope struct {
+	PrincipalID int64
+	ProjectID   int64
+}
+
+// Grant assigns a role to a user
+//
+// A grant without a principal ID and a project ID is a global grant. A grant with a
+// principal ID covers the principal and all of its projects. A grant with a project
+// ID covers only this project.
+type Grant struct {
+	Role        Role
+	PrincipalID int64 `json:",string,omitempty"`
+	ProjectID   int64 `json:",string,omitempty"`
+}
+
+// Global returns true if the grant is not restricted to a principal or project
+func (g Grant) Global() bool {
+	return g.PrincipalID == 0 && g.ProjectID == 0
+}
+
+// Validate returns an error if the grant is invalid
+func (g Grant) Validate() error {
+	if !g.Role.Valid() {
+		return ErrInvalidRole
+	}
+	if g.PrincipalID != 0 && g.ProjectID != 0 {
+		return fmt.Errorf("%v: only one of principal or project can be set", ErrInvalidGrant)
+	}
+	if g.Role == RoleSuperadmin && !g.Global() {
+		return fmt.Errorf("%v: role %s can only be granted globally", ErrInvalidGrant, RoleSuperadmin)
+	}
+	return nil
+}
+
+// Covers returns true if the grant applies to the given scope
+func (g Grant) Covers(s Scope) bool {
+	switch {
+	case g.Global():
+		return true
+	case g.ProjectID != 0:
+		return s.ProjectID == g.ProjectID
+	default:
+		return s.PrincipalID == g.PrincipalID
+	}
+}
+
+// User is an admin API user
+type User struct {
+	ID        int64 `json:",string"`
+	Created   time.Time
+	CreatedBy string
+	Name      string
+
+	Status string
+	// Password is the bcrypt hash of the user's password
+	Password string `json:"-"`
+
+	Grants []Grant
+
+	MFA MFA
+}
+
+// Empty returns true if the user is considered empty/uninitialized
+func (u User) Empty() bool {
+	return u.ID == 0 && u.Name == ""
+}
+
+// ValidName returns an ErrInvalidName if the user name is invalid
+func (u User) ValidName() error {
+	if !validName.MatchString(u.Name) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// ValidStatus returns an ErrInvalidStatus if the set status is considered invalid
+func (u User) ValidStatus() error {
+	if u.Status != UserStatusActive && u.Status != UserStatusInactive {
+		return ErrInvalidStatus
+	}
+	return nil
+}
+
+// Active will return an error if the status is not "active" or invalid
+func (u User) Active() error {
+	if err := u.ValidStatus(); err != nil {
+		return err
+	}
+	if u.Status != UserStatusActive {
+		return ErrUserInactive
+	}
+	return nil
+}
+
+// ValidGrants returns an error if any of the user's grants is invalid
+func (u User) ValidGrants() error {
+	for _, g := range u.Grants {
+		if err := g.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPassword sets the bcrypt hash of the given password
+func (u *User) SetPassword(pw []byte) error {
+	if len(pw) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	enc, err := bcrypt.GenerateFromPassword(pw, PasswordBcryptCost)
+	if err != nil {
+		return err
+	}
+	u.Password = string(enc)
+	return nil
+}
+
+// CheckPassword compares the given password with the user's password hash
+//
+// It will return bcrypt.ErrMismatchedHashAndPassword if the password does not match.
+func (u User) CheckPassword(pw []byte) error {
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), pw)
+}
+
+// Authorized returns true if the user has a role which includes the given role in
+// the given scope
+func (u User) Authorized(role Role, s Scope) bool {
+	for _, g := range u.Grants {
+		if g.Role.Includes(role) && g.Covers(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole returns true if the user has a role which includes the given role in any
+// scope
+func (u User) HasRole(role Role) bool {
+	for _, g := range u.Grants {
+		if g.Role.Includes(role) {
+			return true
+		}
+	}
+	return false
+}
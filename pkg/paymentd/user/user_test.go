@@ -0,0 +1,110 @@
+package user
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRoles(t *testing.T) {
+	Convey("Given the admin roles", t, func() {
+		Convey("Higher roles should include lower roles", func() {
+			So(RoleSuperadmin.Includes(RoleAdmin), ShouldBeTrue)
+			So(RoleAdmin.Includes(RoleOperator), ShouldBeTrue)
+			So(RoleOperator.Includes(RoleViewer), ShouldBeTrue)
+			So(RoleViewer.Includes(RoleViewer), ShouldBeTrue)
+		})
+		Convey("Lower roles should not include higher roles", func() {
+			So(RoleViewer.Includes(RoleOperator), ShouldBeFalse)
+			So(RoleAdmin.Includes(RoleSuperadmin), ShouldBeFalse)
+		})
+		Convey("Unknown roles should not include anything", func() {
+			So(Role("root").Includes(RoleViewer), ShouldBeFalse)
+			So(RoleSuperadmin.Includes(Role("root")), ShouldBeFalse)
+		})
+	})
+}
+
+func TestGrants(t *testing.T) {
+	Convey("Given a superadmin grant scoped to a principal", t, func() {
+		g := Grant{Role: RoleSuperadmin, PrincipalID: 1}
+
+		Convey("It should not validate", func() {
+			So(g.Validate(), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a grant with a principal and a project", t, func() {
+		g := Grant{Role: RoleViewer, PrincipalID: 1, ProjectID: 2}
+
+		Convey("It should not validate", func() {
+			So(g.Validate(), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a user with scoped grants", t, func() {
+		u := User{
+			Name:   "test",
+			Status: UserStatusActive,
+			Grants: []Grant{
+				{Role: RoleAdmin, PrincipalID: 1},
+				{Role: RoleOperator, ProjectID: 20},
+			},
+		}
+		So(u.ValidGrants(), ShouldBeNil)
+
+		Convey("It should be authorized in the scope of its principal", func() {
+			So(u.Authorized(RoleAdmin, Scope{PrincipalID: 1}), ShouldBeTrue)
+			So(u.Authorized(RoleAdmin, Scope{PrincipalID: 1, ProjectID: 10}), ShouldBeTrue)
+		})
+		Convey("It should be authorized for its project", func() {
+			So(u.Authorized(RoleOperator, Scope{PrincipalID: 2, ProjectID: 20}), ShouldBeTrue)
+			So(u.Authorized(RoleAdmin, Scope{PrincipalID: 2, ProjectID: 20}), ShouldBeFalse)
+		})
+		Convey("It should not be authorized for other principals", func() {
+			So(u.Authorized(RoleViewer, Scope{PrincipalID: 2}), ShouldBeFalse)
+			So(u.Authorized(RoleViewer, Scope{PrincipalID: 2, ProjectID: 21}), ShouldBeFalse)
+		})
+		Convey("It should not be authorized globally", func() {
+			So(u.Authorized(RoleViewer, Scope{}), ShouldBeFalse)
+		})
+		Convey("It should have the granted roles", func() {
+			So(u.HasRole(RoleAdmin), ShouldBeTrue)
+			So(u.HasRole(RoleSuperadmin), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a user with a global grant", t, func() {
+		u := User{Grants: []Grant{{Role: RoleViewer}}}
+
+		Convey("It should be authorized in any scope", func() {
+			So(u.Authorized(RoleViewer, Scope{}), ShouldBeTrue)
+			So(u.Authorized(RoleViewer, Scope{PrincipalID: 5, ProjectID: 6}), ShouldBeTrue)
+			So(u.Authorized(RoleOperator, Scope{PrincipalID: 5}), ShouldBeFalse)
+		})
+	})
+}
+
+func TestPassword(t *testing.T) {
+	Convey("Given a user", t, func() {
+		u := &User{Name: "test"}
+
+		Convey("When setting a short password", func() {
+			err := u.SetPassword([]byte("short"))
+
+			Convey("It should fail", func() {
+				So(err, ShouldEqual, ErrPasswordTooShort)
+			})
+		})
+
+		Convey("When setting a password", func() {
+			err := u.SetPassword([]byte("password"))
+			So(err, ShouldBeNil)
+
+			Convey("It should match the password", func() {
+				So(u.CheckPassword([]byte("password")), ShouldBeNil)
+				So(u.CheckPassword([]byte("wrong")), ShouldNotBeNil)
+			})
+		})
+	})
+}
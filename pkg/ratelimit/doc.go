@@ -0,0 +1,25 @@
+/*
+   Copyright 2014 Fritz Payment GmbH
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+/*
+Package ratelimit provides token bucket rate limiting per key, e.g. per project or
+per client IP.
+
+Every key has a bucket which holds up to burst tokens and is refilled with rate
+tokens per second. Each event takes a token. If the bucket is empty, the event is
+rejected and the time until the next token is available is returned.
+*/
+package ratelimit
@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval in which idle buckets are removed
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	burst  int
+}
+
+// Limiter limits events per key with token buckets
+//
+// A nil limiter allows all events.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	rejected  uint64
+
+	now func() time.Time
+}
+
+// NewLimiter creates a limiter which allows rate events per second with bursts of up
+// to burst events per key
+//
+// If rate is not positive, it returns nil, i.e. the limit is disabled.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the given key
+//
+// If no token is available, it returns false and the duration after which the next
+// token will be available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowBurst(key, 0)
+}
+
+// AllowBurst is like Allow, but overrides the burst size of the bucket
+//
+// If burst is not positive, the default burst size of the limiter is used.
+func (l *Limiter) AllowBurst(key string, burst int) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if burst <= 0 {
+		burst = l.burst
+	}
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now, burst: burst}
+		l.buckets[key] = b
+	}
+	b.burst = burst
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	l.rejected++
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// SetLimit changes the rate and the default burst size of the limiter
+//
+// The buckets keep their tokens. A rate which is not positive is ignored, since a
+// disabled limit is a nil limiter.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	if l == nil || rate <= 0 {
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	l.rate = rate
+	l.burst = burst
+	l.mu.Unlock()
+}
+
+// Limit returns the rate and the default burst size of the limiter
+func (l *Limiter) Limit() (float64, int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.burst
+}
+
+// sweep removes the buckets which are refilled completely, since they are not
+// different from new buckets
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds()*l.rate+b.tokens >= float64(b.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns the number of tracked buckets
+func (l *Limiter) Len() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	n := len(l.buckets)
+	l.mu.Unlock()
+	return n
+}
+
+// Rejected returns the number of rejected events
+func (l *Limiter) Rejected() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	n := l.rejected
+	l.mu.Unlock()
+	return n
+}
+
+// RetryAfter returns the value of a Retry-After header for the given duration, i.e.
+// the number of seconds rounded up
+func RetryAfter(d time.Duration) int {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimiter(t *testing.T) {
+	Convey("Given a limiter with a rate of 2/s and a burst of 3", t, func() {
+		l := NewLimiter(2, 3)
+		now := time.Unix(1000, 0)
+		l.now = func() time.Time { return now }
+
+		Convey("It should allow a burst of 3", func() {
+			for i := 0; i < 3; i++ {
+				ok, _ := l.Allow("a")
+				So(ok, ShouldBeTrue)
+			}
+			Convey("It should reject the next event", func() {
+				ok, wait := l.Allow("a")
+				So(ok, ShouldBeFalse)
+				So(wait, ShouldEqual, 500*time.Millisecond)
+				So(RetryAfter(wait), ShouldEqual, 1)
+				So(l.Rejected(), ShouldEqual, 1)
+			})
+			Convey("Other keys should not be affected", func() {
+				ok, _ := l.Allow("b")
+				So(ok, ShouldBeTrue)
+			})
+			Convey("It should refill the bucket over time", func() {
+				now = now.Add(time.Second)
+				for i := 0; i < 2; i++ {
+					ok, _ := l.Allow("a")
+					So(ok, ShouldBeTrue)
+				}
+				ok, _ := l.Allow("a")
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When overriding the burst", func() {
+			for i := 0; i < 5; i++ {
+				ok, _ := l.AllowBurst("a", 5)
+				So(ok, ShouldBeTrue)
+			}
+			ok, _ := l.AllowBurst("a", 5)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("When changing the limit", func() {
+			l.SetLimit(10, 1)
+			rate, burst := l.Limit()
+			So(rate, ShouldEqual, 10)
+			So(burst, ShouldEqual, 1)
+
+			Convey("New buckets should use the new burst", func() {
+				ok, _ := l.Allow("a")
+				So(ok, ShouldBeTrue)
+				ok, wait := l.Allow("a")
+				So(ok, ShouldBeFalse)
+				So(wait, ShouldEqual, 100*time.Millisecond)
+			})
+		})
+
+		Convey("When buckets were idle", func() {
+			l.Allow("a")
+			l.Allow("b")
+			So(l.Len(), ShouldEqual, 2)
+			now = now.Add(2 * sweepInterval)
+			l.Allow("c")
+
+			Convey("They should be removed", func() {
+				So(l.Len(), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a disabled limiter", t, func() {
+		l := NewLimiter(0, 10)
+
+		Convey("It should allow all events", func() {
+			So(l, ShouldBeNil)
+			ok, _ := l.Allow("a")
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
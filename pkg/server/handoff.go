@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// acceptRetryDelay is the delay after temporary accept errors
+const acceptRetryDelay = 5 * time.Millisecond
+
+// errHandedOff is returned by a handoff listener after its connections were handed
+// off to another server
+var errHandedOff = errors.New("listener handed off")
+
+// acceptor accepts the connections of a listener, so the serving HTTP server can be
+// replaced without closing the listener
+type acceptor struct {
+	l     net.Listener
+	conns chan net.Conn
+	// done is closed when the listener fails
+	done chan struct{}
+	err  error
+}
+
+func newAcceptor(l net.Listener) *acceptor {
+	a := &acceptor{
+		l:     l,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *acceptor) run() {
+	for {
+		c, err := a.l.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(acceptRetryDelay)
+				continue
+			}
+			a.err = err
+			close(a.done)
+			return
+		}
+		a.conns <- c
+	}
+}
+
+// handoffListener passes the connections of an acceptor to an HTTP server until it
+// is closed
+//
+// Closing it does not close the underlying listener.
+type handoffListener struct {
+	a    *acceptor
+	stop chan struct{}
+	once sync.Once
+}
+
+func newHandoffListener(a *acceptor) *handoffListener {
+	return &handoffListener{
+		a:    a,
+		stop: make(chan struct{}),
+	}
+}
+
+// Accept implements the net.Listener
+func (l *handoffListener) Accept() (net.Conn, error) {
+	select {
+	case <-l.stop:
+		return nil, errHandedOff
+	default:
+	}
+	select {
+	case c := <-l.a.conns:
+		return c, nil
+	case <-l.a.done:
+		return nil, l.a.err
+	case <-l.stop:
+		return nil, errHandedOff
+	}
+}
+
+// Close implements the net.Listener
+func (l *handoffListener) Close() error {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+	return nil
+}
+
+// Addr implements the net.Listener
+func (l *handoffListener) Addr() net.Addr {
+	return l.a.l.Addr()
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandoffListener(t *testing.T) {
+	Convey("Given an acceptor on a TCP listener", t, func() {
+		tcp, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		a := newAcceptor(tcp)
+		first := newHandoffListener(a)
+
+		Convey("When the first handoff listener is closed", func() {
+			So(first.Close(), ShouldBeNil)
+			_, err := first.Accept()
+			So(err, ShouldEqual, errHandedOff)
+
+			Convey("A new handoff listener should accept the connections", func() {
+				second := newHandoffListener(a)
+				c, err := net.Dial("tcp", tcp.Addr().String())
+				So(err, ShouldBeNil)
+				defer c.Close()
+				s, err := second.Accept()
+				So(err, ShouldBeNil)
+				So(s.RemoteAddr().String(), ShouldEqual, c.LocalAddr().String())
+				s.Close()
+			})
+		})
+
+		Convey("When the listener is closed", func() {
+			So(tcp.Close(), ShouldBeNil)
+
+			Convey("The handoff listener should return the error", func() {
+				_, err := first.Accept()
+				So(err, ShouldNotBeNil)
+				So(err, ShouldNotEqual, errHandedOff)
+			})
+		})
+
+		Reset(func() {
+			tcp.Close()
+		})
+	})
+}
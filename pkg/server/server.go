@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -21,6 +22,10 @@ const (
 	serverWaitTimeout = 10 * time.Second
 )
 
+var (
+	ErrUnknownService = errors.New("no service registered for address")
+)
+
 // Wait is a global WaitGroup for the Server(s). During Shutdown() of the server(s)
 // the final close will wait for the waitgroup to be resolved or for a set timeout.
 var Wait sync.WaitGroup
@@ -31,7 +36,17 @@ type Server struct {
 	log    log15.Logger
 	Cancel context.CancelFunc
 
+	// mu guards the HTTP servers, which are replaced when their timeouts change or
+	// TLS is enabled or disabled
+	mu          sync.Mutex
 	httpServers []*http.Server
+	// TLS configs of the HTTP servers, nil if TLS is disabled
+	tlsServices []*tlsService
+	// replaced HTTP servers, which may still serve open connections
+	retired []*http.Server
+	// acceptors and the handoff listeners of the serving HTTP servers
+	acceptors []*acceptor
+	handoffs  []*handoffListener
 
 	// graceful restart
 	// net.Listeners for graceful restart
@@ -69,18 +84,155 @@ func (s *Server) RegisterService(cfg config.ServiceConfig, handler http.Handler)
 		MaxHeaderBytes: cfg.MaxHeaderBytes,
 	}
 	var err error
-	srv.ReadTimeout, err = cfg.ReadTimeout.Duration()
+	srv.ReadTimeout, srv.WriteTimeout, err = timeouts(cfg)
 	if err != nil {
-		return fmt.Errorf("error parsing duration for server %s: %v", cfg.Address, err)
+		return err
 	}
-	srv.WriteTimeout, err = cfg.WriteTimeout.Duration()
-	if err != nil {
-		return fmt.Errorf("error parsing duration for server %s: %v", cfg.Address, err)
+	var t *tlsService
+	if cfg.TLS.Enabled() {
+		t, err = s.newTLSService(cfg)
+		if err != nil {
+			return err
+		}
 	}
+	s.mu.Lock()
 	s.httpServers = append(s.httpServers, srv)
+	s.tlsServices = append(s.tlsServices, t)
+	s.mu.Unlock()
 	return nil
 }
 
+func (s *Server) newTLSService(cfg config.ServiceConfig) (*tlsService, error) {
+	t, err := newTLSService(cfg.TLS, s.log.New(log15.Ctx{"address": cfg.Address}))
+	if err != nil {
+		return nil, fmt.Errorf("error on TLS config for server %s: %v", cfg.Address, err)
+	}
+	s.log.Info("TLS enabled", log15.Ctx{
+		"address":    cfg.Address,
+		"certFile":   cfg.TLS.CertFile,
+		"clientAuth": cfg.TLS.ClientAuth,
+	})
+	return t, nil
+}
+
+func timeouts(cfg config.ServiceConfig) (read, write time.Duration, err error) {
+	read, err = cfg.ReadTimeout.Duration()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error parsing duration for server %s: %v", cfg.Address, err)
+	}
+	write, err = cfg.WriteTimeout.Duration()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error parsing duration for server %s: %v", cfg.Address, err)
+	}
+	return read, write, nil
+}
+
+// SetTimeouts changes the read and write timeouts of the service registered for the
+// address of the given config
+//
+// A serving service hands its listener off to a new HTTP server with the new
+// timeouts. Open connections keep the previous timeouts.
+func (s *Server) SetTimeouts(cfg config.ServiceConfig) error {
+	read, write, err := timeouts(cfg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, srv := range s.httpServers {
+		if srv.Addr != cfg.Address {
+			continue
+		}
+		if srv.ReadTimeout == read && srv.WriteTimeout == write {
+			return nil
+		}
+		s.replace(i, &http.Server{
+			Addr:           srv.Addr,
+			Handler:        srv.Handler,
+			MaxHeaderBytes: srv.MaxHeaderBytes,
+			ReadTimeout:    read,
+			WriteTimeout:   write,
+		})
+		s.log.Info("server timeouts changed", log15.Ctx{
+			"address":      cfg.Address,
+			"readTimeout":  read,
+			"writeTimeout": write,
+		})
+		return nil
+	}
+	return ErrUnknownService
+}
+
+// SetTLS changes the TLS settings of the service registered for the address of the
+// given config and reloads its certificate files
+//
+// If TLS is enabled or disabled, a serving service hands its listener off to a new
+// HTTP server. Otherwise the settings apply to new connections. If the settings or the
+// files cannot be loaded, the current TLS config is kept.
+func (s *Server) SetTLS(cfg config.ServiceConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, srv := range s.httpServers {
+		if srv.Addr != cfg.Address {
+			continue
+		}
+		t := s.tlsServices[i]
+		if t != nil && cfg.TLS.Enabled() {
+			err := t.update(cfg.TLS)
+			if err != nil {
+				return fmt.Errorf("error on TLS config for server %s: %v", cfg.Address, err)
+			}
+			return nil
+		}
+		if t == nil && !cfg.TLS.Enabled() {
+			return nil
+		}
+		if cfg.TLS.Enabled() {
+			var err error
+			t, err = s.newTLSService(cfg)
+			if err != nil {
+				return err
+			}
+		} else {
+			t = nil
+			s.log.Info("TLS disabled", log15.Ctx{"address": cfg.Address})
+		}
+		s.tlsServices[i] = t
+		s.replace(i, &http.Server{
+			Addr:           srv.Addr,
+			Handler:        srv.Handler,
+			MaxHeaderBytes: srv.MaxHeaderBytes,
+			ReadTimeout:    srv.ReadTimeout,
+			WriteTimeout:   srv.WriteTimeout,
+		})
+		return nil
+	}
+	return ErrUnknownService
+}
+
+// replace replaces the HTTP server i
+//
+// If serving, the listener is handed off to the new server. The replaced server keeps
+// serving its open connections.
+//
+// The caller must hold s.mu.
+func (s *Server) replace(i int, srv *http.Server) {
+	prev := s.httpServers[i]
+	s.httpServers[i] = srv
+	if s.handoffs != nil {
+		s.handoffs[i].Close()
+		s.retired = append(s.retired, prev)
+		s.serve(i)
+	}
+}
+
+// servers returns the serving and the replaced HTTP servers
+func (s *Server) servers() []*http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append(append([]*http.Server{}, s.httpServers...), s.retired...)
+}
+
 // Serve starts serving
 func (s *Server) Serve() error {
 	if len(s.httpServers) == 0 {
@@ -193,17 +345,38 @@ func (s *Server) newListeners() ([]grace.Listener, error) {
 
 // serve all HTTP servers without blocking
 func (s *Server) serveHTTP() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acceptors = make([]*acceptor, len(s.listeners))
+	s.handoffs = make([]*handoffListener, len(s.listeners))
 	for i, l := range s.listeners {
-		go func(i int, l grace.Listener) {
-			server := s.httpServers[i]
-			err := server.Serve(l)
-			if err != nil && err != grace.ErrAlreadyClosed {
-				s.errors <- fmt.Errorf("error serving HTTP %s: %v", server.Addr, err)
-			}
-		}(i, l)
+		s.acceptors[i] = newAcceptor(l)
+		s.serve(i)
 	}
 }
 
+// serve the HTTP server i on a new handoff listener without blocking
+//
+// TLS is terminated on the handoff listener, so the grace listener passes on plain
+// TCP connections.
+//
+// The caller must hold s.mu.
+func (s *Server) serve(i int) {
+	server := s.httpServers[i]
+	handoff := newHandoffListener(s.acceptors[i])
+	s.handoffs[i] = handoff
+	var l net.Listener = handoff
+	if t := s.tlsServices[i]; t != nil {
+		l = tls.NewListener(handoff, t.serverConfig())
+	}
+	go func() {
+		err := server.Serve(l)
+		if err != nil && err != grace.ErrAlreadyClosed && err != errHandedOff {
+			s.errors <- fmt.Errorf("error serving HTTP %s: %v", server.Addr, err)
+		}
+	}()
+}
+
 // the final blocking, wait for server to stop serving
 func (s *Server) wait() error {
 	sigs := make(chan os.Signal, 1)
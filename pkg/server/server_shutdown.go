@@ -14,7 +14,7 @@ import (
 func (s *Server) Shutdown() {
 	s.log.Warn("server going into shutdown mode")
 	// SetKeepAlivesEnabled introduced in Go 1.3
-	for _, srv := range s.httpServers {
+	for _, srv := range s.servers() {
 		srv.SetKeepAlivesEnabled(false)
 	}
 	if s.Cancel != nil {
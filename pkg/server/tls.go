@@ -0,0 +1,254 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// tlsCheckInterval is the minimum interval between checks of the certificate
+	// files for changes
+	tlsCheckInterval = 10 * time.Second
+)
+
+var (
+	ErrTLSKeyFile  = errors.New("no TLS key file configured")
+	ErrTLSClientCA = errors.New("client certificate verification requires a client CA file")
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsClientAuthTypes = map[string]tls.ClientAuthType{
+	"":        tls.NoClientCert,
+	"none":    tls.NoClientCert,
+	"request": tls.RequestClientCert,
+	"verify":  tls.VerifyClientCertIfGiven,
+	"require": tls.RequireAndVerifyClientCert,
+}
+
+// newTLSConfig loads the certificate files and returns the TLS config for the given
+// settings
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.KeyFile == "" {
+		return nil, ErrTLSKeyFile
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS certificate: %v", err)
+	}
+	c := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.MinVersion != "" {
+		var ok bool
+		c.MinVersion, ok = tlsVersions[cfg.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %s", cfg.MinVersion)
+		}
+	}
+	c.CipherSuites, err = tlsCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	c.ClientAuth, ok = tlsClientAuthTypes[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS client auth %s", cfg.ClientAuth)
+	}
+	if c.ClientAuth >= tls.VerifyClientCertIfGiven {
+		if cfg.ClientCAFile == "" {
+			return nil, ErrTLSClientCA
+		}
+		c.ClientCAs, err = loadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// tlsCipherSuites returns the IDs of the named cipher suites
+//
+// Only the cipher suites without known security issues are accepted.
+func tlsCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint16, len(names))
+	for i, name := range names {
+		found := false
+		for _, s := range tls.CipherSuites() {
+			if s.Name == name {
+				ids[i] = s.ID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %s", name)
+		}
+	}
+	return ids, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in client CA file %s", file)
+	}
+	return pool, nil
+}
+
+// tlsService holds the TLS config of a service
+//
+// The config is handed to the clients with GetConfigForClient, so a reloaded config
+// applies to new connections without replacing the HTTP server. The certificate files
+// are reloaded when they change.
+type tlsService struct {
+	log log15.Logger
+
+	// load serializes the loading of configs
+	load sync.Mutex
+
+	mu     sync.Mutex
+	cfg    config.TLSConfig
+	config *tls.Config
+	// modification times of the loaded files
+	modTimes map[string]time.Time
+	checked  time.Time
+}
+
+func newTLSService(cfg config.TLSConfig, log log15.Logger) (*tlsService, error) {
+	t := &tlsService{log: log}
+	err := t.update(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// update loads the given settings and the certificate files
+//
+// If they cannot be loaded, the current config is kept.
+func (t *tlsService) update(cfg config.TLSConfig) error {
+	t.load.Lock()
+	defer t.load.Unlock()
+	return t.loadConfig(cfg)
+}
+
+// reload loads the certificate files of the current settings
+func (t *tlsService) reload() error {
+	t.load.Lock()
+	defer t.load.Unlock()
+	t.mu.Lock()
+	cfg := t.cfg
+	t.mu.Unlock()
+	return t.loadConfig(cfg)
+}
+
+// loadConfig loads the config
+//
+// The caller must hold t.load.
+func (t *tlsService) loadConfig(cfg config.TLSConfig) error {
+	// files changed while loading will be reloaded on the next check
+	modTimes := tlsModTimes(cfg)
+	c, err := newTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.cfg = cfg
+	t.config = c
+	t.modTimes = modTimes
+	t.checked = time.Now()
+	t.mu.Unlock()
+	return nil
+}
+
+// tlsModTimes returns the modification times of the files of the given settings
+//
+// Files which cannot be read have a zero time.
+func tlsModTimes(cfg config.TLSConfig) map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		var mod time.Time
+		if inf, err := os.Stat(file); err == nil {
+			mod = inf.ModTime()
+		}
+		modTimes[file] = mod
+	}
+	return modTimes
+}
+
+// changed returns whether the loaded files were modified
+//
+// The caller must hold t.mu.
+func (t *tlsService) changed() bool {
+	for file, mod := range tlsModTimes(t.cfg) {
+		if !mod.Equal(t.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverConfig returns the TLS config for the HTTP server
+func (t *tlsService) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: t.getConfigForClient,
+	}
+}
+
+// getConfigForClient returns the current config
+//
+// The certificate files are checked for changes at most every tlsCheckInterval. If
+// changed files cannot be loaded, the current config is kept.
+func (t *tlsService) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	t.mu.Lock()
+	c := t.config
+	changed := false
+	if time.Since(t.checked) >= tlsCheckInterval {
+		t.checked = time.Now()
+		changed = t.changed()
+	}
+	certFile := t.cfg.CertFile
+	t.mu.Unlock()
+	if !changed {
+		return c, nil
+	}
+	err := t.reload()
+	if err != nil {
+		t.log.Error("error reloading TLS certificate. keeping current certificate", log15.Ctx{
+			"certFile": certFile,
+			"err":      err,
+		})
+		return c, nil
+	}
+	t.log.Info("TLS certificate reloaded", log15.Ctx{"certFile": certFile})
+	t.mu.Lock()
+	c = t.config
+	t.mu.Unlock()
+	return c, nil
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/grace"
+	"github.com/fritzpay/paymentd/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by the given parent or a self-signed CA
+// certificate if parent is nil
+func newTestCert(name string, parent *testCert, usage x509.ExtKeyUsage) (*testCert, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &testCert{cert: cert, key: key}, nil
+}
+
+// write writes the certificate and the key as PEM files
+func (c *testCert) write(certFile, keyFile string) error {
+	err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600)
+	if err != nil {
+		return err
+	}
+	key, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600)
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func TestTLSConfig(t *testing.T) {
+	Convey("Given a certificate and a CA", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd_tls")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		ca, err := newTestCert("ca", nil, x509.ExtKeyUsageAny)
+		So(err, ShouldBeNil)
+		cert, err := newTestCert("server", ca, x509.ExtKeyUsageServerAuth)
+		So(err, ShouldBeNil)
+		cfg := config.TLSConfig{
+			CertFile:     filepath.Join(dir, "cert.pem"),
+			KeyFile:      filepath.Join(dir, "key.pem"),
+			ClientCAFile: filepath.Join(dir, "ca.pem"),
+		}
+		So(cert.write(cfg.CertFile, cfg.KeyFile), ShouldBeNil)
+		So(ca.write(cfg.ClientCAFile, filepath.Join(dir, "ca.key")), ShouldBeNil)
+
+		Convey("When loading the default settings", func() {
+			c, err := newTLSConfig(cfg)
+			So(err, ShouldBeNil)
+
+			Convey("TLS 1.2 should be required and no client certificates requested", func() {
+				So(c.MinVersion, ShouldEqual, tls.VersionTLS12)
+				So(c.ClientAuth, ShouldEqual, tls.NoClientCert)
+				So(c.CipherSuites, ShouldBeNil)
+				So(len(c.Certificates), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When loading valid settings", func() {
+			cfg.MinVersion = "1.3"
+			cfg.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+			cfg.ClientAuth = "require"
+			c, err := newTLSConfig(cfg)
+			So(err, ShouldBeNil)
+
+			Convey("They should be applied", func() {
+				So(c.MinVersion, ShouldEqual, tls.VersionTLS13)
+				So(c.CipherSuites, ShouldResemble, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256})
+				So(c.ClientAuth, ShouldEqual, tls.RequireAndVerifyClientCert)
+				So(c.ClientCAs, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When loading invalid settings", func() {
+			Convey("They should be rejected", func() {
+				invalid := cfg
+				invalid.KeyFile = ""
+				_, err = newTLSConfig(invalid)
+				So(err, ShouldEqual, ErrTLSKeyFile)
+
+				invalid = cfg
+				invalid.MinVersion = "1.4"
+				_, err = newTLSConfig(invalid)
+				So(err, ShouldNotBeNil)
+
+				invalid = cfg
+				invalid.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+				_, err = newTLSConfig(invalid)
+				So(err, ShouldNotBeNil)
+
+				invalid = cfg
+				invalid.ClientAuth = "always"
+				_, err = newTLSConfig(invalid)
+				So(err, ShouldNotBeNil)
+
+				invalid = cfg
+				invalid.ClientAuth = "verify"
+				invalid.ClientCAFile = ""
+				_, err = newTLSConfig(invalid)
+				So(err, ShouldEqual, ErrTLSClientCA)
+			})
+		})
+
+		Convey("Given a TLS service", func() {
+			log := log15.New()
+			log.SetHandler(log15.DiscardHandler())
+			s, err := newTLSService(cfg, log)
+			So(err, ShouldBeNil)
+
+			Convey("When the certificate files change", func() {
+				next, err := newTestCert("next", ca, x509.ExtKeyUsageServerAuth)
+				So(err, ShouldBeNil)
+				So(next.write(cfg.CertFile, cfg.KeyFile), ShouldBeNil)
+				mod := time.Now().Add(time.Minute)
+				So(os.Chtimes(cfg.CertFile, mod, mod), ShouldBeNil)
+				So(os.Chtimes(cfg.KeyFile, mod, mod), ShouldBeNil)
+
+				Convey("The certificate should be kept until the next check", func() {
+					c, err := s.getConfigForClient(nil)
+					So(err, ShouldBeNil)
+					So(c.Certificates[0].Certificate[0], ShouldResemble, cert.cert.Raw)
+				})
+				Convey("The certificate should be reloaded on the next check", func() {
+					s.checked = time.Time{}
+					c, err := s.getConfigForClient(nil)
+					So(err, ShouldBeNil)
+					So(c.Certificates[0].Certificate[0], ShouldResemble, next.cert.Raw)
+				})
+			})
+
+			Convey("When the changed files are invalid", func() {
+				So(ioutil.WriteFile(cfg.CertFile, []byte("invalid"), 0600), ShouldBeNil)
+				mod := time.Now().Add(time.Minute)
+				So(os.Chtimes(cfg.CertFile, mod, mod), ShouldBeNil)
+				s.checked = time.Time{}
+
+				Convey("The current certificate should be kept", func() {
+					c, err := s.getConfigForClient(nil)
+					So(err, ShouldBeNil)
+					So(c.Certificates[0].Certificate[0], ShouldResemble, cert.cert.Raw)
+				})
+			})
+		})
+	})
+}
+
+func TestMutualTLS(t *testing.T) {
+	Convey("Given a serving server requiring client certificates", t, func() {
+		dir, err := ioutil.TempDir("", "paymentd_tls")
+		So(err, ShouldBeNil)
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+		ca, err := newTestCert("ca", nil, x509.ExtKeyUsageAny)
+		So(err, ShouldBeNil)
+		cert, err := newTestCert("server", ca, x509.ExtKeyUsageServerAuth)
+		So(err, ShouldBeNil)
+		client, err := newTestCert("merchant", ca, x509.ExtKeyUsageClientAuth)
+		So(err, ShouldBeNil)
+		cfg := config.ServiceConfig{
+			Address:      "127.0.0.1:0",
+			ReadTimeout:  config.Duration("10s"),
+			WriteTimeout: config.Duration("10s"),
+			TLS: config.TLSConfig{
+				CertFile:     filepath.Join(dir, "cert.pem"),
+				KeyFile:      filepath.Join(dir, "key.pem"),
+				ClientAuth:   "require",
+				ClientCAFile: filepath.Join(dir, "ca.pem"),
+			},
+		}
+		So(cert.write(cfg.TLS.CertFile, cfg.TLS.KeyFile), ShouldBeNil)
+		So(ca.write(cfg.TLS.ClientCAFile, filepath.Join(dir, "ca.key")), ShouldBeNil)
+
+		log := log15.New()
+		log.SetHandler(log15.DiscardHandler())
+		s := NewServer(context.WithValue(context.Background(), "log", log))
+		err = s.RegisterService(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+			}
+		}))
+		So(err, ShouldBeNil)
+		l, err := s.newGraceListener(cfg.Address)
+		So(err, ShouldBeNil)
+		Reset(func() {
+			l.Close()
+		})
+		cfg.Address = l.Addr().String()
+		s.httpServers[0].Addr = cfg.Address
+		s.listeners = []grace.Listener{l}
+		s.errors = make(chan error, 1)
+		s.serveHTTP()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		get := func(scheme string, certs ...tls.Certificate) (string, error) {
+			c := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      roots,
+					Certificates: certs,
+				},
+				DisableKeepAlives: true,
+			}}
+			resp, err := c.Get(scheme + "://" + cfg.Address)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			return string(body), err
+		}
+
+		Convey("A client with a certificate should be authenticated", func() {
+			body, err := get("https", client.tlsCertificate())
+			So(err, ShouldBeNil)
+			So(body, ShouldEqual, "merchant")
+		})
+		Convey("A client without a certificate should be rejected", func() {
+			_, err := get("https")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When TLS is disabled", func() {
+			cfg.TLS = config.TLSConfig{}
+			So(s.SetTLS(cfg), ShouldBeNil)
+
+			Convey("The listener should be handed off to a plain HTTP server", func() {
+				_, err := get("http")
+				So(err, ShouldBeNil)
+				So(s.tlsServices[0], ShouldBeNil)
+				So(len(s.retired), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the timeouts change", func() {
+			cfg.ReadTimeout = config.Duration("5s")
+			So(s.SetTimeouts(cfg), ShouldBeNil)
+
+			Convey("The new server should keep serving TLS", func() {
+				body, err := get("https", client.tlsCertificate())
+				So(err, ShouldBeNil)
+				So(body, ShouldEqual, "merchant")
+			})
+		})
+	})
+}
@@ -55,6 +55,14 @@ func NewHandler(ctx *service.Context) (*Handler, error) {
 		}
 	}
 
+	h.log.Info("registering health endpoints...")
+	h.mux.Handle(service.LivenessPath, h.ctx.LivenessHandler()).Methods("GET")
+	var checks []service.HealthCheck
+	if cfg.API.ServeAdmin && len(adminGUIPubWWWDir) > 0 {
+		checks = append(checks, service.DirHealthCheck("dir.admin_gui", adminGUIPubWWWDir))
+	}
+	h.mux.Handle(service.ReadinessPath, h.ctx.ReadinessHandler(checks...)).Methods("GET")
+
 	h.log.Info("registering API service v1...")
 	v1.NewService(h.ctx, h.mux)
 	v1.Log = h.log.New(log15.Ctx{
@@ -66,14 +74,15 @@ func NewHandler(ctx *service.Context) (*Handler, error) {
 
 // ServeHTTP implements the http.Handler
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.SetRequestContext(r, h.ctx)
+	defer service.ClearRequestContext(r)
+	requestID := service.SetRequestID(w, r)
 	defer func() {
 		if err := recover(); err != nil {
-			h.log.Crit("panic on serving HTTP", log15.Ctx{"panic": err})
+			h.log.Crit("panic on serving HTTP", log15.Ctx{"panic": err, "requestID": requestID})
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}()
-	service.SetRequestContext(r, h.ctx)
-	defer service.ClearRequestContext(r)
 	h.mux.ServeHTTP(w, r)
 	// service.TimeoutHandler(h.log.Warn, h.timeout, h.mux).ServeHTTP(w, r)
 }
@@ -17,6 +17,9 @@ const (
 	AuthLifetime = 15 * time.Minute
 	// AuthUserIDKey is the key for the user ID entry in the authorization container
 	AuthUserIDKey = "userID"
+	// AuthMFAKey is the key for the entry in the authorization container which records
+	// whether the second factor was verified
+	AuthMFAKey = "mfa"
 	// AuthCookieName is the cookie name for cookie-based authentication
 	AuthCookieName = "auth"
 )
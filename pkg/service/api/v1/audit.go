@@ -0,0 +1,236 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// audited entities
+const (
+	auditEntityUser           = "user"
+	auditEntitySystemPassword = "system_password"
+	auditEntityPrincipal      = "principal"
+	auditEntityProject        = "project"
+	auditEntityProjectKey     = "project_key"
+	auditEntityPaymentMethod  = "payment_method"
+	auditEntityRouting        = "routing"
+	auditEntityLog            = "log"
+)
+
+// AuditEntryResponse is an audit log entry with the changed fields
+type AuditEntryResponse struct {
+	*audit.Entry
+	Changes map[string]audit.Change
+}
+
+// AuditVerifyResponse is the result of an audit log verification
+type AuditVerifyResponse struct {
+	Valid    bool
+	Verified int
+	// BrokenID is the ID of the first entry which breaks the chain
+	BrokenID int64 `json:",string,omitempty"`
+}
+
+// requestActor returns the name of the authorized user of the request
+func requestActor(r *http.Request) string {
+	if ctx := service.RequestContext(r); ctx != nil {
+		if u, ok := ctx.Value(contextVarUserKey).(*user.User); ok {
+			return u.Name
+		}
+	}
+	auth, err := getAuthContainer(r)
+	if err != nil {
+		return ""
+	}
+	userID, _ := auth[AuthUserIDKey].(string)
+	return userID
+}
+
+func auditID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// newAuditEntry creates the audit log entry of a change made by the request
+func (a *AdminAPI) newAuditEntry(r *http.Request, action, entity, entityID string, before, after interface{}) (*audit.Entry, error) {
+	e, err := audit.NewEntry(requestActor(r), action, entity, entityID, before, after)
+	if err != nil {
+		return nil, err
+	}
+	if ip := a.ctx.ClientIP(r); ip != nil {
+		e.SourceIP = ip.String()
+	}
+	e.RequestID = service.RequestID(r)
+	if e.RequestID == "" {
+		e.RequestID = service.NewRequestID()
+	}
+	return e, nil
+}
+
+func (a *AdminAPI) auditLog(r *http.Request, action, entity, entityID string) log15.Logger {
+	return a.log.New(log15.Ctx{
+		"method":    "audit",
+		"action":    action,
+		"entity":    entity,
+		"entityID":  entityID,
+		"requestID": service.RequestID(r),
+	})
+}
+
+// auditTx records a change of the principal database in the audit log
+//
+// The entry is written in the transaction of the change, so it must be called before
+// the transaction is committed. If it returns an error, the transaction must be
+// rolled back. Errors are logged.
+func (a *AdminAPI) auditTx(tx *sql.Tx, r *http.Request, action, entity, entityID string, before, after interface{}) error {
+	log := a.auditLog(r, action, entity, entityID)
+	e, err := a.newAuditEntry(r, action, entity, entityID, before, after)
+	if err != nil {
+		log.Error("error creating audit log entry", log15.Ctx{"err": err})
+		return err
+	}
+	err = audit.InsertEntryTx(tx, e)
+	if err != nil {
+		log.Error("error saving audit log entry", log15.Ctx{"err": err})
+		return err
+	}
+	return nil
+}
+
+// audit records a change which is not stored in the principal database in the audit
+// log
+//
+// It must be called before the change is applied or committed. If it returns an
+// error, the change must not be applied. Errors are logged.
+func (a *AdminAPI) audit(r *http.Request, action, entity, entityID string, before, after interface{}) error {
+	log := a.auditLog(r, action, entity, entityID)
+	e, err := a.newAuditEntry(r, action, entity, entityID, before, after)
+	if err != nil {
+		log.Error("error creating audit log entry", log15.Ctx{"err": err})
+		return err
+	}
+	err = audit.InsertEntryDB(a.ctx.PrincipalDB(), e, a.ctx.Config().Database.TransactionMaxRetries)
+	if err != nil {
+		log.Error("error saving audit log entry", log15.Ctx{"err": err})
+		return err
+	}
+	return nil
+}
+
+// AuditRequest handles audit log queries
+func (a *AdminAPI) AuditRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "AuditRequest", "requestID": service.RequestID(r)})
+		if r.Method != "GET" {
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		f, err := auditFilter(r)
+		if err != nil {
+			log.Warn("invalid filter", log15.Ctx{"err": err})
+			ErrReadParam.Write(w)
+			return
+		}
+		entries, err := audit.EntriesDB(a.ctx.PrincipalDB(service.ReadOnly), f)
+		if err != nil {
+			log.Error("error retrieving audit log", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+		result := make([]AuditEntryResponse, len(entries))
+		for i, e := range entries {
+			result[i] = AuditEntryResponse{Entry: e, Changes: e.Changes()}
+		}
+		resp := AdminAPIResponse{}
+		resp.Status = StatusSuccess
+		resp.Info = strconv.Itoa(len(result)) + " audit log entries found"
+		resp.Response = result
+		err = resp.Write(w)
+		if err != nil {
+			log.Error("write error", log15.Ctx{"err": err})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+func auditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		Actor:    q.Get("actor"),
+		Action:   q.Get("action"),
+		Entity:   q.Get("entity"),
+		EntityID: q.Get("entityid"),
+	}
+	var err error
+	if s := q.Get("from"); s != "" {
+		f.From, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return f, err
+		}
+	}
+	if s := q.Get("to"); s != "" {
+		f.To, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return f, err
+		}
+	}
+	if s := q.Get("before"); s != "" {
+		f.BeforeID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return f, err
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		f.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// AuditVerifyRequest verifies the hash chain of the audit log
+func (a *AdminAPI) AuditVerifyRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "AuditVerifyRequest", "requestID": service.RequestID(r)})
+		if r.Method != "GET" {
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		brokenID, n, err := audit.VerifyDB(a.ctx.PrincipalDB(service.ReadOnly))
+		if err != nil && err != audit.ErrChainBroken {
+			log.Error("error verifying audit log", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+		result := AuditVerifyResponse{
+			Valid:    err == nil,
+			Verified: n,
+			BrokenID: brokenID,
+		}
+		resp := AdminAPIResponse{}
+		resp.Status = StatusSuccess
+		if result.Valid {
+			resp.Info = "audit log is valid"
+		} else {
+			log.Crit("audit log hash chain broken", log15.Ctx{"entryID": brokenID})
+			resp.Info = "audit log hash chain broken"
+		}
+		resp.Response = result
+		err = resp.Write(w)
+		if err != nil {
+			log.Error("write error", log15.Ctx{"err": err})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
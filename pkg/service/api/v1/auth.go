@@ -14,7 +14,9 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
 	"github.com/fritzpay/paymentd/pkg/paymentd/config"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
 	"github.com/fritzpay/paymentd/pkg/service"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/inconshreveable/log15.v2"
@@ -22,12 +24,31 @@ import (
 
 const badAuthWaitTime = 2 * time.Second
 
+// OTPHeader is the request header for the second factor, either a TOTP code or a
+// recovery code
+//
+// If a second factor is required but not provided, the response will carry this
+// header with the value "required".
+const OTPHeader = "X-Otp"
+
 func (a *AdminAPI) authorizationHash() func() hash.Hash {
 	return sha256.New
 }
 
 func (a *AdminAPI) authenticateSystemPassword(pw string, w http.ResponseWriter) {
 	log := a.log.New(log15.Ctx{"method": "authenticateSystemPassword"})
+	permitted, err := a.systemPasswordLogin()
+	if err != nil {
+		log.Error("error checking system password login", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !permitted {
+		log.Warn("system password login not permitted")
+		time.Sleep(badAuthWaitTime)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
 	pwEntry, err := config.EntryByNameDB(a.ctx.PaymentDB(), config.ConfigNameSystemPassword)
 	if err != nil {
 		if err == config.ErrEntryNotFound {
@@ -50,7 +71,97 @@ func (a *AdminAPI) authenticateSystemPassword(pw string, w http.ResponseWriter)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	a.respondWithAuthorization(w)
+	a.respondWithAuthorization(w, systemUserID, false)
+}
+
+func (a *AdminAPI) authenticateUserPassword(name, pw string, w http.ResponseWriter, r *http.Request) {
+	log := a.log.New(log15.Ctx{
+		"method":    "authenticateUserPassword",
+		"userName":  name,
+		"requestID": service.RequestID(r),
+	})
+	u, err := user.UserByNameDB(a.ctx.PrincipalDB(service.ReadOnly), name)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			log.Warn("unknown user")
+			time.Sleep(badAuthWaitTime)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		log.Error("error retrieving user", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = u.CheckPassword([]byte(pw))
+	if err != nil {
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			log.Warn("password mismatch")
+			time.Sleep(badAuthWaitTime)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		log.Error("error checking password", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err = u.Active(); err != nil {
+		log.Warn("inactive user", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !u.MFA.Enrolled() {
+		a.respondWithAuthorization(w, u.Name, false)
+		return
+	}
+	code := r.Header.Get(OTPHeader)
+	if code == "" {
+		w.Header().Set(OTPHeader, "required")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	err = a.verifySecondFactor(u, code)
+	if err != nil {
+		if err == user.ErrInvalidTOTPCode {
+			log.Warn("invalid second factor")
+			time.Sleep(badAuthWaitTime)
+			w.Header().Set(OTPHeader, "required")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		log.Error("error verifying second factor", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	a.respondWithAuthorization(w, u.Name, true)
+}
+
+// verifySecondFactor verifies the given TOTP or recovery code
+//
+// The use of the code is recorded in the principal database. TOTP codes can only be
+// used once and recovery codes are removed after use, also across several instances.
+// It returns user.ErrInvalidTOTPCode if the code is invalid.
+func (a *AdminAPI) verifySecondFactor(u *user.User, code string) error {
+	tx, err := a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		return err
+	}
+	// read the current settings, the given user might be read from a replica
+	u, err = user.UserByIDTx(tx, u.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	step, err := u.MFA.ValidateTOTP(code, time.Now())
+	if err == nil {
+		err = user.UseTOTPStepTx(tx, u, step)
+	} else if err == user.ErrInvalidTOTPCode {
+		err = user.UseRecoveryCodeTx(tx, u, code)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetCredentialsResponse is the response for all GET /user/credentials requests
@@ -59,11 +170,15 @@ type GetCredentialsResponse struct {
 	Authorization string
 }
 
-func (a *AdminAPI) respondWithAuthorization(w http.ResponseWriter) {
+// respondWithAuthorization writes a new authorization container for the given user
+//
+// The mfa flag records whether the user verified a second factor.
+func (a *AdminAPI) respondWithAuthorization(w http.ResponseWriter, userID string, mfa bool) {
 	log := a.log.New(log15.Ctx{"method": "respondWithAuthorization"})
 
 	auth := service.NewAuthorization(a.authorizationHash())
-	auth.Payload[AuthUserIDKey] = systemUserID
+	auth.Payload[AuthUserIDKey] = userID
+	auth.Payload[AuthMFAKey] = mfa
 	auth.Expires(time.Now().Add(AuthLifetime))
 	key, err := a.ctx.APIKeychain().BinKey()
 	if err != nil {
@@ -113,10 +228,15 @@ func (a *AdminAPI) AuthorizationHandler() http.Handler {
 		w.Header().Set("Content-Type", "application/json")
 		switch r.Method {
 		case "GET":
-			a.AuthRequiredHandler(a.refreshAuthorizationHandler()).ServeHTTP(w, r)
+			a.RoleRequiredHandler(Permission{}, a.refreshAuthorizationHandler()).ServeHTTP(w, r)
 
 		case "PUT":
-			a.AuthRequiredHandler(a.updateSystemUserPasswordHandler()).ServeHTTP(w, r)
+			p := Permission{
+				Write: user.RoleSuperadmin,
+				Scope: globalScope,
+				MFA:   true,
+			}
+			a.RoleRequiredHandler(p, a.updateSystemUserPasswordHandler()).ServeHTTP(w, r)
 			return
 
 		case "DELETE":
@@ -169,37 +289,45 @@ func getAuthorizationMethod(p string) string {
 	return method
 }
 
-func getBasicAuthPassword(authHeader string) (string, error) {
+func getBasicAuthCredentials(authHeader string) (string, string, error) {
 	parts := strings.SplitN(authHeader, " ", 2)
 	if len(parts) != 2 {
-		return "", errors.New("authorization expect two parts")
+		return "", "", errors.New("authorization expect two parts")
 	}
 	if parts[0] != "Basic" {
-		return "", errors.New("not basic auth")
+		return "", "", errors.New("not basic auth")
 	}
 	auth, err := base64.StdEncoding.DecodeString(parts[1])
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	parts = strings.Split(string(auth), ":")
+	parts = strings.SplitN(string(auth), ":", 2)
 	if len(parts) != 2 {
-		return "", errors.New("password expect two parts")
+		return "", "", errors.New("password expect two parts")
 	}
-	return parts[1], nil
+	return parts[0], parts[1], nil
 }
 
+// authenticateBasicAuth authenticates a user with basic auth
+//
+// An empty user name or the system user name will authenticate against the system
+// password.
 func (a *AdminAPI) authenticateBasicAuth(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Authorization") == "" {
 		requestBasicAuth(w)
 		return
 	}
-	if pw, err := getBasicAuthPassword(r.Header.Get("Authorization")); err != nil {
+	name, pw, err := getBasicAuthCredentials(r.Header.Get("Authorization"))
+	if err != nil {
 		a.log.Warn("error on basic auth", log15.Ctx{"err": err})
 		requestBasicAuth(w)
 		return
-	} else {
+	}
+	if name == "" || name == systemUserID {
 		a.authenticateSystemPassword(pw, w)
+		return
 	}
+	a.authenticateUserPassword(name, pw, w, r)
 }
 
 func (a *AdminAPI) authenticateBodyAuth(w http.ResponseWriter, r *http.Request) {
@@ -223,7 +351,7 @@ func requestBasicAuth(w http.ResponseWriter) {
 
 func (a *AdminAPI) updateSystemUserPasswordHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := a.log.New(log15.Ctx{"method": "updateSystemUserPasswordHandler"})
+		log := a.log.New(log15.Ctx{"method": "updateSystemUserPasswordHandler", "requestID": service.RequestID(r)})
 		w.Header().Set("Content-Type", "text/plain")
 		if !strings.Contains(r.Header.Get("Content-Type"), "text/plain") {
 			w.WriteHeader(http.StatusUnsupportedMediaType)
@@ -235,6 +363,13 @@ func (a *AdminAPI) updateSystemUserPasswordHandler() http.Handler {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		// the system password is stored in the payment database, so the audit log
+		// entry is written before the change
+		err = a.audit(r, audit.ActionChange, auditEntitySystemPassword, systemUserID, nil, nil)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		err = config.Set(a.ctx.PaymentDB(), config.SetPassword(pw))
 		if err != nil {
 			log.Error("error setting system password", log15.Ctx{"err": err})
@@ -247,7 +382,14 @@ func (a *AdminAPI) updateSystemUserPasswordHandler() http.Handler {
 
 func (a *AdminAPI) refreshAuthorizationHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		a.respondWithAuthorization(w)
+		auth, err := getAuthContainer(r)
+		if err != nil {
+			a.log.Crit("auth container error", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mfa, _ := auth[AuthMFAKey].(bool)
+		a.respondWithAuthorization(w, auth[AuthUserIDKey].(string), mfa)
 	})
 }
 
@@ -268,7 +410,7 @@ func (a *AdminAPI) AuthRequiredHandler(parent http.Handler) http.Handler {
 // the failed handler will be called
 func (a *AdminAPI) AuthHandler(success, failed http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := a.log.New(log15.Ctx{"method": "AuthHandler"})
+		log := a.log.New(log15.Ctx{"method": "AuthHandler", "requestID": service.RequestID(r)})
 
 		authStr := r.Header.Get("Authorization")
 		if authStr == "" {
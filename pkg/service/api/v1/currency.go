@@ -18,7 +18,7 @@ func (a *AdminAPI) CurrencyGetRequest() http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		log := a.log.New(log15.Ctx{"method": "CurrencyGetRequest"})
+		log := a.log.New(log15.Ctx{"method": "CurrencyGetRequest", "requestID": service.RequestID(r)})
 
 		// get param
 		vars := mux.Vars(r)
@@ -69,7 +69,7 @@ func (a *AdminAPI) CurrencyGetAllRequest() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		// get all
-		log := a.log.New(log15.Ctx{"method": "CurrencyGetAllRequest"})
+		log := a.log.New(log15.Ctx{"method": "CurrencyGetAllRequest", "requestID": service.RequestID(r)})
 
 		db := a.ctx.PaymentDB(service.ReadOnly)
 		cl, err := currency.CurrencyAllDB(db)
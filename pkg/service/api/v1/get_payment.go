@@ -103,6 +103,7 @@ func (r *GetPaymentRequest) ReadFromRequest(req *http.Request) error {
 	if r.Nonce == "" {
 		return errors.New("no nonce")
 	}
+	r.hexSignature = q.Get("Signature")
 	return nil
 }
 
@@ -110,7 +111,8 @@ func (a *PaymentAPI) GetPayment() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		log := a.log.New(log15.Ctx{
-			"method": "GetPayment",
+			"method":    "GetPayment",
+			"requestID": service.RequestID(r),
 		})
 		var err error
 		req := &GetPaymentRequest{}
@@ -137,7 +139,7 @@ func (a *PaymentAPI) GetPayment() http.Handler {
 			return
 		}
 		var projectKey *project.Projectkey
-		if projectKey = a.authenticateRequest(req, log, w); projectKey == nil {
+		if projectKey = a.authenticateRequest(r, req, project.ScopePaymentRead, log, w); projectKey == nil {
 			return
 		}
 		var p *payment.Payment
@@ -193,13 +195,13 @@ func (a *PaymentAPI) GetPayment() http.Handler {
 			ErrSystem.Write(w)
 			return
 		}
-		secret, err := projectKey.SecretBytes()
+		signer, err := a.paymentService.Signer(projectKey)
 		if err != nil {
-			log.Error("error retrieving project secret", log15.Ctx{"err": err})
+			log.Error("error retrieving signer", log15.Ctx{"err": err})
 			ErrSystem.Write(w)
 			return
 		}
-		err = not.Sign(time.Now(), non.Nonce, secret)
+		err = not.SignWith(time.Now(), non.Nonce, signer)
 		if err != nil {
 			log.Error("error signing", log15.Ctx{"err": err})
 			ErrSystem.Write(w)
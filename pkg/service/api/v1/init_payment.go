@@ -15,6 +15,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/fritzpay/paymentd/pkg/dialect"
 	jsonutil "github.com/fritzpay/paymentd/pkg/json"
 	"github.com/fritzpay/paymentd/pkg/maputil"
 	"github.com/fritzpay/paymentd/pkg/paymentd/currency"
@@ -23,7 +24,6 @@ import (
 	"github.com/fritzpay/paymentd/pkg/paymentd/project"
 	"github.com/fritzpay/paymentd/pkg/service"
 	paymentService "github.com/fritzpay/paymentd/pkg/service/payment"
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/text/language"
 	"gopkg.in/inconshreveable/log15.v2"
 )
@@ -302,6 +302,8 @@ type InitPaymentResponse struct {
 	Timestamp int64 `json:",string"`
 	Nonce     string
 	Signature string
+	// KeyId identifies the paymentd key pair if the response was signed with Ed25519
+	KeyId string `json:",omitempty"`
 }
 
 // ConfirmationFromPayment populates the response "Confirmation" object with
@@ -450,7 +452,8 @@ func (a *PaymentAPI) InitPayment() http.Handler {
 			return
 		}
 		log := a.log.New(log15.Ctx{
-			"method": "InitPayment",
+			"method":    "InitPayment",
+			"requestID": service.RequestID(r),
 		})
 		var responseWritten bool
 		var resp ServiceResponse
@@ -478,7 +481,7 @@ func (a *PaymentAPI) InitPayment() http.Handler {
 			return
 		}
 		var projectKey *project.Projectkey
-		if projectKey = a.authenticateRequest(req, log, w); projectKey == nil {
+		if projectKey = a.authenticateRequest(r, req, project.ScopePaymentInit, log, w); projectKey == nil {
 			responseWritten = true
 			return
 		}
@@ -573,6 +576,17 @@ func (a *PaymentAPI) InitPayment() http.Handler {
 			case paymentService.ErrDuplicateIdent:
 				resp = ErrConflict
 				resp.Info = "your ident was already used"
+			case paymentService.ErrPaymentMethodNotFound,
+				paymentService.ErrPaymentMethodConflict,
+				paymentService.ErrPaymentMethodInactive:
+				resp = ErrInval
+				resp.Info = err.Error()
+			case paymentService.ErrPaymentMethodCurrency:
+				resp = ErrCurrencyNotSupported
+			case paymentService.ErrPaymentMethodCountry:
+				resp = ErrCountryNotSupported
+			case paymentService.ErrPaymentMethodAmount:
+				resp = ErrAmountOutOfRange
 			default:
 				resp = ErrSystem
 				log.Error("unknown error in payment service")
@@ -638,29 +652,27 @@ func (a *PaymentAPI) InitPayment() http.Handler {
 		paymentResp.Nonce = n.Nonce
 		paymentResp.Timestamp = time.Now().Unix()
 
-		secret, err := projectKey.SecretBytes()
+		signer, err := a.paymentService.Signer(projectKey)
 		if err != nil {
-			log.Error("error retrieving project secret", log15.Ctx{"err": err})
+			log.Error("error retrieving signer", log15.Ctx{"err": err})
 			resp = ErrSystem
 			return
 		}
-		sig, err := service.Sign(paymentResp, secret)
+		sig, err := signer.Sign(paymentResp)
 		if err != nil {
 			log.Error("error signing response", log15.Ctx{"err": err})
 			resp = ErrSystem
 			return
 		}
 		paymentResp.Signature = hex.EncodeToString(sig)
+		paymentResp.KeyId = signer.KeyID()
 
 		err = tx.Commit()
 		if err != nil {
-			if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-				// lock error
-				if mysqlErr.Number == 1213 {
-					retries++
-					time.Sleep(time.Second)
-					goto beginTx
-				}
+			if dialect.IsDeadlock(err) {
+				retries++
+				time.Sleep(time.Second)
+				goto beginTx
 			}
 			commit = true
 			log.Crit("error on commit tx", log15.Ctx{"err": err})
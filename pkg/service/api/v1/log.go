@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fritzpay/paymentd/pkg/env"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// LogResponse is the representation of the log levels of the daemon
+type LogResponse struct {
+	Level string
+	// Packages are the level overrides per package
+	Packages map[string]string
+	// Debug is true if all records are logged regardless of the levels
+	Debug bool
+}
+
+// LogChangeRequest switches the debug mode of the log
+type LogChangeRequest struct {
+	Debug bool
+}
+
+func logLevels() LogResponse {
+	pkgs := env.Levels.PackageLevels()
+	resp := LogResponse{
+		Level:    env.Levels.Level().String(),
+		Packages: make(map[string]string, len(pkgs)),
+		Debug:    env.Levels.Debug(),
+	}
+	for pkg, lvl := range pkgs {
+		resp.Packages[pkg] = lvl.String()
+	}
+	return resp
+}
+
+// LogRequest handles the log level of the daemon
+//
+// GET returns the levels
+// PUT switches the debug mode
+func (a *AdminAPI) LogRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "LogRequest", "requestID": service.RequestID(r)})
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			req := LogChangeRequest{}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			r.Body.Close()
+			if err != nil {
+				ErrReadJson.Write(w)
+				log.Warn("json decode failed", log15.Ctx{"err": err})
+				return
+			}
+			before := logLevels()
+			after := before
+			after.Debug = req.Debug
+			err = a.audit(r, audit.ActionChange, auditEntityLog, "", before, after)
+			if err != nil {
+				ErrDatabase.Write(w)
+				return
+			}
+			env.Levels.SetDebug(req.Debug)
+			log.Info("log debug mode switched", log15.Ctx{"debug": req.Debug})
+		default:
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		resp := ServiceResponse{}
+		resp.Status = StatusSuccess
+		resp.HttpStatus = http.StatusOK
+		resp.Info = "log levels"
+		resp.Response = logLevels()
+		err := resp.Write(w)
+		if err != nil {
+			log.Error("write error", log15.Ctx{"err": err})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
@@ -0,0 +1,283 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// TOTPIssuer is the issuer name shown in authenticator apps
+const TOTPIssuer = "paymentd"
+
+const auditEntityUserMFA = "user_mfa"
+
+// MFAResponse is the two-factor authentication status of the authorized user
+type MFAResponse struct {
+	Required          bool
+	TOTPEnabled       bool
+	RecoveryCodesLeft int
+	// Verified is true if the current authorization includes a verified second factor
+	Verified bool
+}
+
+// TOTPEnrollmentResponse is the response for starting a TOTP enrollment
+type TOTPEnrollmentResponse struct {
+	Secret string
+	// URI is the otpauth URI, which can be displayed as a QR code
+	URI string
+}
+
+// RecoveryCodesResponse contains new recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string
+}
+
+// TOTPRequest is the request body for confirming a TOTP enrollment
+type TOTPRequest struct {
+	Code string
+}
+
+// contextUser returns the authorized user from the request context
+func contextUser(r *http.Request) (*user.User, bool) {
+	ctx := service.RequestContext(r)
+	if ctx == nil {
+		return nil, false
+	}
+	u, ok := ctx.Value(contextVarUserKey).(*user.User)
+	return u, ok
+}
+
+// MFARequest returns the two-factor authentication status of the authorized user
+func (a *AdminAPI) MFARequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "MFARequest", "requestID": service.RequestID(r)})
+		if r.Method != "GET" {
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		u, ok := contextUser(r)
+		if !ok {
+			log.Crit("user not present in request context")
+			ErrSystem.Write(w)
+			return
+		}
+		resp := UserAdminAPIResponse{}
+		resp.Status = StatusSuccess
+		resp.Info = "two-factor authentication status"
+		resp.Response = MFAResponse{
+			Required:          u.MFA.Required,
+			TOTPEnabled:       u.MFA.Enrolled(),
+			RecoveryCodesLeft: len(u.MFA.RecoveryCodes),
+			Verified:          requestMFA(r),
+		}
+		err := resp.Write(w)
+		if err != nil {
+			log.Error("write error", log15.Ctx{"err": err})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// TOTPRequest handles the TOTP enrollment of the authorized user
+//
+// PUT starts an enrollment with a new secret
+// POST confirms the enrollment with a code and returns the recovery codes
+// DELETE removes the TOTP second factor
+func (a *AdminAPI) TOTPRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "TOTPRequest", "requestID": service.RequestID(r)})
+		switch r.Method {
+		case "PUT":
+			a.changeMFA(w, r, func(tx *sql.Tx, u *user.User) (*ServiceResponse, interface{}) {
+				if u.MFA.Enrolled() {
+					return &ErrConflict, nil
+				}
+				secret, err := user.NewTOTPSecret()
+				if err != nil {
+					log.Error("error generating secret", log15.Ctx{"err": err})
+					return &ErrSystem, nil
+				}
+				u.MFA.TOTPSecret = secret
+				u.MFA.TOTPEnabled = false
+				return nil, TOTPEnrollmentResponse{
+					Secret: secret,
+					URI:    user.TOTPURI(TOTPIssuer, u.Name, secret),
+				}
+			})
+		case "POST":
+			req := TOTPRequest{}
+			err := json.NewDecoder(r.Body).Decode(&req)
+			r.Body.Close()
+			if err != nil {
+				ErrReadJson.Write(w)
+				log.Warn("json decode failed", log15.Ctx{"err": err})
+				return
+			}
+			a.changeMFA(w, r, func(tx *sql.Tx, u *user.User) (*ServiceResponse, interface{}) {
+				if u.MFA.Enrolled() {
+					return &ErrConflict, nil
+				}
+				step, err := u.MFA.ValidateTOTP(req.Code, time.Now())
+				if err != nil {
+					resp := ErrInval
+					resp.Info = err.Error()
+					return &resp, nil
+				}
+				err = user.UseTOTPStepTx(tx, u, step)
+				if err == user.ErrInvalidTOTPCode {
+					resp := ErrInval
+					resp.Info = err.Error()
+					return &resp, nil
+				}
+				if err != nil {
+					log.Error("error saving totp step", log15.Ctx{"err": err})
+					return &ErrDatabase, nil
+				}
+				u.MFA.TOTPEnabled = true
+				codes, err := u.MFA.NewRecoveryCodes()
+				if err != nil {
+					log.Error("error generating recovery codes", log15.Ctx{"err": err})
+					return &ErrSystem, nil
+				}
+				return nil, RecoveryCodesResponse{RecoveryCodes: codes}
+			})
+		case "DELETE":
+			if !requestMFA(r) {
+				resp := ErrForbidden
+				resp.Info = "two-factor authentication required"
+				resp.Write(w)
+				return
+			}
+			a.changeMFA(w, r, func(tx *sql.Tx, u *user.User) (*ServiceResponse, interface{}) {
+				if !u.MFA.Enrolled() {
+					return &ErrNotFound, nil
+				}
+				u.MFA.Reset()
+				return nil, nil
+			})
+		default:
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// RecoveryCodesRequest replaces the recovery codes of the authorized user
+func (a *AdminAPI) RecoveryCodesRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "RecoveryCodesRequest", "requestID": service.RequestID(r)})
+		if r.Method != "POST" {
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		if !requestMFA(r) {
+			resp := ErrForbidden
+			resp.Info = "two-factor authentication required"
+			resp.Write(w)
+			return
+		}
+		a.changeMFA(w, r, func(tx *sql.Tx, u *user.User) (*ServiceResponse, interface{}) {
+			if !u.MFA.Enrolled() {
+				return &ErrNotFound, nil
+			}
+			codes, err := u.MFA.NewRecoveryCodes()
+			if err != nil {
+				log.Error("error generating recovery codes", log15.Ctx{"err": err})
+				return &ErrSystem, nil
+			}
+			return nil, RecoveryCodesResponse{RecoveryCodes: codes}
+		})
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// changeMFA stores new two-factor authentication settings of the authorized user
+// after applying the given change
+//
+// The change function runs in the transaction of the change and can return a
+// response to abort the change. Otherwise it returns the response value.
+func (a *AdminAPI) changeMFA(w http.ResponseWriter, r *http.Request, change func(tx *sql.Tx, u *user.User) (*ServiceResponse, interface{})) {
+	log := a.log.New(log15.Ctx{"method": "changeMFA", "requestID": service.RequestID(r)})
+	cu, ok := contextUser(r)
+	if !ok {
+		log.Crit("user not present in request context")
+		ErrSystem.Write(w)
+		return
+	}
+	if cu == systemUser {
+		resp := ErrInval
+		resp.Info = "the system user cannot use two-factor authentication"
+		resp.Write(w)
+		return
+	}
+	log = log.New(log15.Ctx{"userName": cu.Name})
+
+	var tx *sql.Tx
+	var commit bool
+	var err error
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		log.Crit("error on begin", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	u, err := user.UserByIDTx(tx, cu.ID)
+	if err != nil {
+		log.Error("error retrieving user", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	before := u.MFA
+	errResp, result := change(tx, u)
+	if errResp != nil {
+		errResp.Write(w)
+		return
+	}
+	err = user.InsertUserMFATx(tx, u, u.Name)
+	if err != nil {
+		log.Error("error saving two-factor authentication", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	err = a.auditTx(tx, r, audit.ActionChange, auditEntityUserMFA, auditID(u.ID), before, u.MFA)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Crit("error on commit", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	commit = true
+
+	resp := UserAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "two-factor authentication changed"
+	resp.Response = result
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("write error", log15.Ctx{"err": err})
+	}
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"time"
@@ -50,14 +51,120 @@ func (a *PaymentAPI) authenticateMessage(projectKey *project.Projectkey, msg ser
 	if projectKey == nil || !projectKey.IsValid() {
 		return false, fmt.Errorf("invalid project key: %+v", projectKey)
 	}
-	secret, err := projectKey.SecretBytes()
+	// keys with public keys only accept Ed25519 signatures, so a leaked shared secret
+	// cannot be used to forge requests
+	if projectKey.Asymmetric() {
+		for _, pub := range projectKey.PublicKeys {
+			auth, err := service.IsAuthenticEd25519(msg, pub)
+			if err != nil || auth {
+				return auth, err
+			}
+		}
+		return false, nil
+	}
+	secrets, err := projectKey.Secrets(time.Now())
 	if err != nil {
 		return false, err
 	}
-	return service.IsAuthentic(msg, secret)
+	for _, secret := range secrets {
+		auth, err := service.IsAuthentic(msg, secret)
+		if err != nil || auth {
+			return auth, err
+		}
+	}
+	return false, nil
+}
+
+// clientAllowed checks the client address against the IP allowlists of the project
+// and the project key
+func (a *PaymentAPI) clientAllowed(r *http.Request, projectKey *project.Projectkey, log log15.Logger, w http.ResponseWriter) bool {
+	projectNets, err := projectKey.Project.Config.AllowedNetworks()
+	if err != nil {
+		log.Error("invalid project IP allowlist", log15.Ctx{
+			"projectID": projectKey.Project.ID,
+			"err":       err,
+		})
+		ErrSystem.Write(w)
+		return false
+	}
+	if len(projectNets) == 0 && len(projectKey.IPAllowlist) == 0 {
+		return true
+	}
+	ip := a.ctx.ClientIP(r)
+	if (len(projectNets) == 0 || projectNets.Contains(ip)) &&
+		(len(projectKey.IPAllowlist) == 0 || projectKey.IPAllowlist.Contains(ip)) {
+		return true
+	}
+	log.Warn("request from client outside of IP allowlist", log15.Ctx{
+		"ProjectKey": projectKey.Key,
+		"clientIP":   ip,
+	})
+	resp := ErrForbidden
+	if Debug {
+		resp.Info = fmt.Sprintf("client address %s is not allowed", ip)
+	}
+	resp.Write(w)
+	return false
+}
+
+// clientCertAllowed checks the verified TLS client certificate of the request against
+// the client certificates bound to the project key
+//
+// Certificates which were not verified against the client CAs, e.g. with the client
+// auth "request", are not considered.
+func (a *PaymentAPI) clientCertAllowed(r *http.Request, projectKey *project.Projectkey, log log15.Logger, w http.ResponseWriter) bool {
+	var cert *x509.Certificate
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert = r.TLS.VerifiedChains[0][0]
+	}
+	if projectKey.ClientCertAllowed(cert) {
+		return true
+	}
+	ctx := log15.Ctx{"ProjectKey": projectKey.Key}
+	if cert != nil {
+		ctx["clientCert"] = project.ClientCertFingerprint(cert)
+	}
+	log.Warn("request without a client certificate bound to the project key", ctx)
+	resp := ErrUnauthorized
+	if Debug {
+		resp.Info = fmt.Sprintf("project key %s requires a bound client certificate", projectKey.Key)
+	}
+	resp.Write(w)
+	return false
+}
+
+// clientLimit wraps the given handler with the rate limit per client IP
+func (a *PaymentAPI) clientLimit(parent http.Handler) http.Handler {
+	return a.ctx.ClientRateLimitHandler(parent, writeTooManyRequests)
 }
 
-func (a *PaymentAPI) authenticateRequest(req ProjectKeyRequester, log log15.Logger, w http.ResponseWriter) *project.Projectkey {
+// withinRateLimits checks the rate limits of the project and the project key
+func (a *PaymentAPI) withinRateLimits(projectKey *project.Projectkey, log log15.Logger, w http.ResponseWriter) bool {
+	limits := a.ctx.RateLimits()
+	burst := projectKey.Project.Config.RateLimitBurst
+	ok, wait := limits.AllowProject(projectKey.Project.ID, burst)
+	if ok {
+		ok, wait = limits.AllowProjectKey(projectKey.Key, burst)
+	}
+	if ok {
+		return true
+	}
+	log.Warn("rate limit exceeded", log15.Ctx{
+		"projectID":  projectKey.Project.ID,
+		"ProjectKey": projectKey.Key,
+		"retryAfter": wait,
+	})
+	writeTooManyRequests(w, wait)
+	return false
+}
+
+// authenticateRequest authenticates the request and checks whether the used project
+// key may be used for the operation identified by the given scope
+//
+// Requests from clients outside of the IP allowlists of the project and the key are
+// rejected before the signature is verified. Authenticated requests exceeding the rate
+// limits of the project or the key are rejected with 429 Too Many Requests.
+func (a *PaymentAPI) authenticateRequest(r *http.Request, req ProjectKeyRequester, scope project.Scope, log log15.Logger, w http.ResponseWriter) *project.Projectkey {
 	projectKey, err := project.ProjectKeyByKeyDB(a.ctx.PrincipalDB(service.ReadOnly), req.RequestProjectKey())
 	if err != nil {
 		if err == project.ErrProjectKeyNotFound {
@@ -87,6 +194,12 @@ func (a *PaymentAPI) authenticateRequest(req ProjectKeyRequester, log log15.Logg
 		resp.Write(w)
 		return nil
 	}
+	if !a.clientAllowed(r, projectKey, log, w) {
+		return nil
+	}
+	if !a.clientCertAllowed(r, projectKey, log, w) {
+		return nil
+	}
 	// authenticate
 	// skip if dev mode
 	if !Debug {
@@ -104,5 +217,18 @@ func (a *PaymentAPI) authenticateRequest(req ProjectKeyRequester, log log15.Logg
 		}
 		// TODO include nonce handling
 	}
+	if !projectKey.HasScope(scope) {
+		log.Warn("project key used outside of its scopes", log15.Ctx{
+			"ProjectKey": projectKey.Key,
+			"scope":      scope,
+		})
+		resp := ErrForbidden
+		resp.Info = fmt.Sprintf("project key is not allowed for %s", scope)
+		resp.Write(w)
+		return nil
+	}
+	if !a.withinRateLimits(projectKey, log, w) {
+		return nil
+	}
 	return projectKey
 }
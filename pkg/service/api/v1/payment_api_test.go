@@ -0,0 +1,219 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"github.com/fritzpay/paymentd/pkg/testutil"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// WithProjectKey is a test decorator and will provide a new project key of a new
+// project of the test principal
+//
+// The setup function can change the project and the key before they are stored.
+func WithProjectKey(prDB *sql.DB, setup func(pr *project.Project, pk *project.Projectkey), f func(pk *project.Projectkey)) func() {
+	return func() {
+		tx, err := prDB.Begin()
+		So(err, ShouldBeNil)
+
+		pr := &project.Project{
+			PrincipalID: 1,
+			Name:        fmt.Sprintf("test%d", time.Now().UnixNano()),
+			Created:     time.Now(),
+			CreatedBy:   "test",
+		}
+		pk, err := project.NewProjectkey(*pr, "test")
+		So(err, ShouldBeNil)
+		setup(pr, pk)
+
+		err = project.InsertProjectTx(tx, pr)
+		So(err, ShouldBeNil)
+		err = project.InsertProjectConfigTx(tx, pr)
+		So(err, ShouldBeNil)
+		pk.Project = *pr
+		err = project.InsertProjectKeyTx(tx, pk)
+		So(err, ShouldBeNil)
+		err = tx.Commit()
+		So(err, ShouldBeNil)
+
+		f(pk)
+	}
+}
+
+// newGetPaymentRequest returns a GET /payment request for a nonexistent payment,
+// signed with the secret of the given project key
+func newGetPaymentRequest(pk *project.Projectkey) *http.Request {
+	req := &GetPaymentRequest{
+		ProjectKey: pk.Key,
+		Ident:      "nonexistent",
+		Timestamp:  time.Now().Unix(),
+		Nonce:      "nonce",
+	}
+	secret, err := pk.SecretBytes()
+	So(err, ShouldBeNil)
+	sig, err := service.Sign(req, secret)
+	So(err, ShouldBeNil)
+
+	q := url.Values{}
+	q.Set("ProjectKey", req.ProjectKey)
+	q.Set("Timestamp", fmt.Sprintf("%d", req.Timestamp))
+	q.Set("Nonce", req.Nonce)
+	q.Set("Signature", hex.EncodeToString(sig))
+	r, err := http.NewRequest("GET", ServicePath+"/payment/ident/"+req.Ident+"?"+q.Encode(), nil)
+	So(err, ShouldBeNil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	return r
+}
+
+func TestPaymentAPIScopes(t *testing.T) {
+	Convey("Given a test context", t, testutil.WithContext(func(ctx *service.Context, logChan <-chan *log15.Record) {
+		Convey("Given a service", WithService(ctx, logChan, func(s *Service, mx *mux.Router) {
+
+			Convey("Given a payment db", testutil.WithPaymentDB(t, func(db *sql.DB) {
+				ctx.SetPaymentDB(db, nil)
+				Reset(func() { db.Close() })
+
+				Convey("Given a principal db", testutil.WithPrincipalDB(t, func(prDB *sql.DB) {
+					ctx.SetPrincipalDB(prDB, nil)
+					Reset(func() { prDB.Close() })
+
+					get := func(r *http.Request) int {
+						w := testutil.NewResponseWriter()
+						mx.ServeHTTP(w, r)
+						So(w.HeaderWritten, ShouldBeTrue)
+						return w.StatusCode
+					}
+
+					Convey("Given a project key restricted to initializing payments", WithProjectKey(prDB, func(pr *project.Project, pk *project.Projectkey) {
+						So(pk.SetScopes([]project.Scope{project.ScopePaymentInit}), ShouldBeNil)
+					}, func(pk *project.Projectkey) {
+
+						Convey("Reading a payment should be forbidden", func() {
+							So(get(newGetPaymentRequest(pk)), ShouldEqual, http.StatusForbidden)
+						})
+					}))
+
+					Convey("Given a project key allowed to read payments", WithProjectKey(prDB, func(pr *project.Project, pk *project.Projectkey) {
+						So(pk.SetScopes([]project.Scope{project.ScopePaymentRead}), ShouldBeNil)
+					}, func(pk *project.Projectkey) {
+
+						Convey("Reading a payment should pass the authentication", func() {
+							So(get(newGetPaymentRequest(pk)), ShouldEqual, http.StatusNotFound)
+						})
+					}))
+				}))
+			}))
+		}))
+	}))
+}
+
+func TestPaymentAPIClientAllowlist(t *testing.T) {
+	Convey("Given a context with a trusted proxy", t, func() {
+		cfg := config.DefaultConfig()
+		cfg.API.TrustedProxies = []string{"10.0.0.0/8"}
+		log := log15.New()
+		log.SetHandler(log15.DiscardHandler())
+		ctx, err := service.NewContext(context.Background(), cfg, log)
+		So(err, ShouldBeNil)
+
+		mx := mux.NewRouter()
+		_, err = NewService(ctx, mx)
+		So(err, ShouldBeNil)
+
+		Convey("Given a payment db", testutil.WithPaymentDB(t, func(db *sql.DB) {
+			ctx.SetPaymentDB(db, nil)
+			Reset(func() { db.Close() })
+
+			Convey("Given a principal db", testutil.WithPrincipalDB(t, func(prDB *sql.DB) {
+				ctx.SetPrincipalDB(prDB, nil)
+				Reset(func() { prDB.Close() })
+
+				get := func(r *http.Request) int {
+					w := testutil.NewResponseWriter()
+					mx.ServeHTTP(w, r)
+					So(w.HeaderWritten, ShouldBeTrue)
+					return w.StatusCode
+				}
+
+				Convey("Given a project key with an IP allowlist", WithProjectKey(prDB, func(pr *project.Project, pk *project.Projectkey) {
+					So(pk.SetIPAllowlist([]string{"192.0.2.0/24"}), ShouldBeNil)
+				}, func(pk *project.Projectkey) {
+
+					Convey("A request from an allowed client should pass", func() {
+						So(get(newGetPaymentRequest(pk)), ShouldEqual, http.StatusNotFound)
+					})
+
+					Convey("Given a request from a rejected client", func() {
+						r := newGetPaymentRequest(pk)
+						r.RemoteAddr = "198.51.100.1:1234"
+
+						Convey("It should be forbidden", func() {
+							So(get(r), ShouldEqual, http.StatusForbidden)
+						})
+						Convey("It should be forbidden before the signature is checked", func() {
+							q := r.URL.Query()
+							q.Set("Signature", "00")
+							r.URL.RawQuery = q.Encode()
+							So(get(r), ShouldEqual, http.StatusForbidden)
+						})
+					})
+
+					Convey("An allowed client with a wrong signature should be unauthorized", func() {
+						r := newGetPaymentRequest(pk)
+						q := r.URL.Query()
+						q.Set("Signature", "00")
+						r.URL.RawQuery = q.Encode()
+						So(get(r), ShouldEqual, http.StatusUnauthorized)
+					})
+
+					Convey("Given a request forwarded for an allowed client", func() {
+						r := newGetPaymentRequest(pk)
+						r.Header.Set("X-Forwarded-For", "192.0.2.1")
+
+						Convey("It should pass when forwarded by the trusted proxy", func() {
+							r.RemoteAddr = "10.0.0.1:1234"
+							So(get(r), ShouldEqual, http.StatusNotFound)
+						})
+						Convey("It should be forbidden when forwarded by an untrusted proxy", func() {
+							r.RemoteAddr = "198.51.100.1:1234"
+							So(get(r), ShouldEqual, http.StatusForbidden)
+						})
+					})
+				}))
+
+				Convey("Given a project and a project key with IP allowlists", WithProjectKey(prDB, func(pr *project.Project, pk *project.Projectkey) {
+					pr.Config.SetIPAllowlist([]string{"192.0.2.0/24"})
+					So(pk.SetIPAllowlist([]string{"192.0.2.128/25", "198.51.100.0/24"}), ShouldBeNil)
+				}, func(pk *project.Projectkey) {
+					request := func(remoteAddr string) *http.Request {
+						r := newGetPaymentRequest(pk)
+						r.RemoteAddr = remoteAddr
+						return r
+					}
+
+					Convey("A client in both allowlists should pass", func() {
+						So(get(request("192.0.2.200:1234")), ShouldEqual, http.StatusNotFound)
+					})
+					Convey("A client only in the project allowlist should be forbidden", func() {
+						So(get(request("192.0.2.1:1234")), ShouldEqual, http.StatusForbidden)
+					})
+					Convey("A client only in the key allowlist should be forbidden", func() {
+						So(get(request("198.51.100.1:1234")), ShouldEqual, http.StatusForbidden)
+					})
+				}))
+			}))
+		}))
+	})
+}
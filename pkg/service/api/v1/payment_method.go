@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/fritzpay/paymentd/pkg/metadata"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
 	"github.com/fritzpay/paymentd/pkg/paymentd/project"
 	"github.com/fritzpay/paymentd/pkg/paymentd/provider"
@@ -24,13 +25,15 @@ type PaymentMethodRequest struct {
 	Status    string
 	CreatedBy string
 	Metadata  map[string]string
+	// Limits replace the current payment method limits if set
+	Limits *payment_method.Limits
 }
 
 func (a *AdminAPI) PaymentMethodGetRequest() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		log := a.log.New(log15.Ctx{"method": "Project payment methods GET"})
+		log := a.log.New(log15.Ctx{"method": "Project payment methods GET", "requestID": service.RequestID(r)})
 
 		// parameter
 		vars := mux.Vars(r)
@@ -85,7 +88,7 @@ func (a *AdminAPI) PaymentMethodGetRequest() http.Handler {
 func (a *AdminAPI) PaymentMethodRequest() http.Handler {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		log := a.log.New(log15.Ctx{"method": "PaymentMethodRequest"})
+		log := a.log.New(log15.Ctx{"method": "PaymentMethodRequest", "requestID": service.RequestID(r)})
 
 		log.Info("project method", log15.Ctx{"method": r.Method})
 
@@ -103,7 +106,7 @@ func (a *AdminAPI) PaymentMethodRequest() http.Handler {
 }
 
 func (a *AdminAPI) putNewPaymentMethod(w http.ResponseWriter, r *http.Request) {
-	log := a.log.New(log15.Ctx{"method": "PaymentMethod PUT Request"})
+	log := a.log.New(log15.Ctx{"method": "PaymentMethod PUT Request", "requestID": service.RequestID(r)})
 	// get parameters
 	// projectid and methodname
 	vars := mux.Vars(r)
@@ -185,6 +188,15 @@ func (a *AdminAPI) putNewPaymentMethod(w http.ResponseWriter, r *http.Request) {
 	pm.Provider = prov
 	pm.MethodKey = pmr.MethodKey
 	pm.Metadata = pmr.Metadata
+	if pmr.Limits != nil {
+		if err = pmr.Limits.Validate(); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			resp.Write(w)
+			return
+		}
+		pm.Limits = *pmr.Limits
+	}
 
 	// check if payment_method already exists
 	_, err = payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, pm.ProjectID, pm.Provider.Name, pm.MethodKey)
@@ -228,6 +240,16 @@ func (a *AdminAPI) putNewPaymentMethod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// insert method limits
+	if pmr.Limits != nil {
+		err = payment_method.InsertPaymentMethodLimitsTx(tx, &pm, pm.CreatedBy)
+		if err != nil {
+			ErrDatabase.Write(w)
+			log.Error("database error", log15.Ctx{"err": err})
+			return
+		}
+	}
+
 	// get payment_method from db with all set values like status created
 	pmdb, err := payment_method.PaymentMethodByProjectIDProviderNameMethodKeyTx(tx, pm.ProjectID, pm.Provider.Name, pm.MethodKey)
 	if err != nil {
@@ -236,6 +258,14 @@ func (a *AdminAPI) putNewPaymentMethod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the payment method is stored in the payment database, so the audit log entry
+	// is written before the commit
+	err = a.audit(r, audit.ActionCreate, auditEntityPaymentMethod, auditID(pmdb.ID), nil, pmdb)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+
 	commit = true
 	err = tx.Commit()
 	if err != nil {
@@ -255,7 +285,7 @@ func (a *AdminAPI) putNewPaymentMethod(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *AdminAPI) postChangePaymentMethod(w http.ResponseWriter, r *http.Request) {
-	log := a.log.New(log15.Ctx{"method": "PaymentMethod POST Request"})
+	log := a.log.New(log15.Ctx{"method": "PaymentMethod POST Request", "requestID": service.RequestID(r)})
 	// get parameters
 	// projectid and methodname
 	vars := mux.Vars(r)
@@ -311,6 +341,8 @@ func (a *AdminAPI) postChangePaymentMethod(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	before := *pm
+
 	// user data
 	auth := service.RequestContextAuth(r)
 	// insert new status if set
@@ -365,12 +397,28 @@ func (a *AdminAPI) postChangePaymentMethod(w http.ResponseWriter, r *http.Reques
 		pm.Metadata = pmmd
 	}
 
-	resp := ProjectAdminAPIResponse{}
-	resp.Status = StatusSuccess
-	resp.Info = "changed " + methodKey
-	resp.Response = pm
-	resp.Write(w)
+	// insert limits if set
+	if pmr.Limits != nil {
+		if err = pmr.Limits.Validate(); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			resp.Write(w)
+			return
+		}
+		pm.Limits = *pmr.Limits
+		err = payment_method.InsertPaymentMethodLimitsTx(tx, pm, auth[AuthUserIDKey].(string))
+		if err != nil {
+			ErrDatabase.Write(w)
+			log.Error("database error", log15.Ctx{"err": err})
+			return
+		}
+	}
 
+	err = a.audit(r, audit.ActionChange, auditEntityPaymentMethod, auditID(pm.ID), before, pm)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
 	err = tx.Commit()
 	if err != nil {
 		ErrDatabase.Write(w)
@@ -378,4 +426,10 @@ func (a *AdminAPI) postChangePaymentMethod(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	commit = true
+
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "changed " + methodKey
+	resp.Response = pm
+	resp.Write(w)
 }
@@ -0,0 +1,340 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"github.com/gorilla/mux"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// contextVarUserKey is the request context key for the authorized user
+	contextVarUserKey = "AdminUser"
+)
+
+var errScopeParam = errors.New("scope parameter malformed")
+
+// systemUser is the user representation of the system password login
+var systemUser = &user.User{
+	Name:   systemUserID,
+	Status: user.UserStatusActive,
+	Grants: []user.Grant{
+		{Role: user.RoleSuperadmin},
+	},
+}
+
+// ScopeFunc resolves the scope of the resource which is accessed by the request
+//
+// A nil scope means the resource is not restricted to any scope and any grant of the
+// required role will permit the access.
+type ScopeFunc func(a *AdminAPI, r *http.Request) (*user.Scope, error)
+
+// Permission describes the roles required to access an admin API resource
+//
+// An empty role only requires an authorized active user.
+type Permission struct {
+	// Read is the role required for GET and HEAD requests
+	Read user.Role
+	// Write is the role required for all other requests
+	Write user.Role
+	// Scope resolves the resource scope
+	Scope ScopeFunc
+	// MFA requires users to have verified a second factor
+	//
+	// The system user cannot enroll a second factor and will be rejected.
+	MFA bool
+	// Bootstrap exempts the system user from the MFA requirement while no users
+	// exist, so the first user can be created
+	Bootstrap bool
+	// MFAEnrollment permits users who are required to use two-factor authentication
+	// but did not yet enroll
+	MFAEnrollment bool
+}
+
+// admin API route permissions
+var (
+	// userPermission restricts the user management to superadmins
+	userPermission = Permission{
+		Read:      user.RoleSuperadmin,
+		Write:     user.RoleSuperadmin,
+		Scope:     globalScope,
+		MFA:       true,
+		Bootstrap: true,
+	}
+	// mfaPermission permits users to manage their own second factor
+	mfaPermission = Permission{
+		MFAEnrollment: true,
+	}
+	// auditPermission permits global admins to query the audit log
+	auditPermission = Permission{
+		Read:  user.RoleAdmin,
+		Write: user.RoleSuperadmin,
+		Scope: globalScope,
+	}
+	// logPermission permits global admins to view and superadmins to switch the log
+	// levels
+	logPermission = Permission{
+		Read:  user.RoleAdmin,
+		Write: user.RoleSuperadmin,
+		Scope: globalScope,
+		MFA:   true,
+	}
+	// principalPermission requires global roles for listing and creating principals
+	principalPermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleSuperadmin,
+		Scope: globalScope,
+	}
+	principalNamePermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleAdmin,
+		Scope: principalScope,
+	}
+	// referencePermission permits all users to read reference data like currencies
+	// and providers
+	referencePermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleSuperadmin,
+	}
+	// projectPermission is the permission for requests where the principal is part
+	// of the request parameters. The handlers check the role for the principal.
+	projectPermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleAdmin,
+	}
+	projectIDPermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleAdmin,
+		Scope: projectScope,
+	}
+	// projectKeyPermission is the permission for managing the keys of a project
+	projectKeyPermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleAdmin,
+		Scope: projectScope,
+		MFA:   true,
+	}
+	// operatorPermission is the permission for the payment configuration of a project
+	operatorPermission = Permission{
+		Read:  user.RoleViewer,
+		Write: user.RoleOperator,
+		Scope: projectScope,
+	}
+)
+
+func (p Permission) role(r *http.Request) user.Role {
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return p.Read
+	}
+	return p.Write
+}
+
+// globalScope is the scope of resources which are not associated with a principal
+func globalScope(a *AdminAPI, r *http.Request) (*user.Scope, error) {
+	return &user.Scope{}, nil
+}
+
+// principalScope is the scope of the principal identified by the name route var
+func principalScope(a *AdminAPI, r *http.Request) (*user.Scope, error) {
+	pr, err := principal.PrincipalByNameDB(a.ctx.PrincipalDB(service.ReadOnly), mux.Vars(r)["name"])
+	if err != nil {
+		return nil, err
+	}
+	return &user.Scope{PrincipalID: pr.ID}, nil
+}
+
+// projectScope is the scope of the project identified by the projectid route var
+func projectScope(a *AdminAPI, r *http.Request) (*user.Scope, error) {
+	projectID, err := strconv.ParseInt(mux.Vars(r)["projectid"], 10, 64)
+	if err != nil {
+		return nil, errScopeParam
+	}
+	pr, err := project.ProjectByIDDB(a.ctx.PrincipalDB(service.ReadOnly), projectID)
+	if err != nil {
+		return nil, err
+	}
+	return &user.Scope{PrincipalID: pr.PrincipalID, ProjectID: pr.ID}, nil
+}
+
+// requestUser returns the user of the authorization container
+//
+// The user is loaded on every request so disabled users and revoked grants take
+// effect immediately.
+func (a *AdminAPI) requestUser(r *http.Request) (*user.User, error) {
+	auth, err := getAuthContainer(r)
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := auth[AuthUserIDKey].(string)
+	if !ok {
+		return nil, errors.New("auth container has no user")
+	}
+	if userID == systemUserID {
+		permitted, err := a.systemPasswordLogin()
+		if err != nil {
+			return nil, err
+		}
+		if !permitted {
+			return nil, user.ErrUserInactive
+		}
+		return systemUser, nil
+	}
+	u, err := user.UserByNameDB(a.ctx.PrincipalDB(service.ReadOnly), userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = u.Active(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// systemPasswordLogin returns whether the system password login is permitted
+//
+// In bootstrap mode, the system password is permitted only while no users exist.
+func (a *AdminAPI) systemPasswordLogin() (bool, error) {
+	switch a.ctx.Config().API.SystemPasswordLogin {
+	case config.SystemPasswordLoginEnabled:
+		return true, nil
+	case "", config.SystemPasswordLoginBootstrap:
+		return a.bootstrapping()
+	default:
+		return false, nil
+	}
+}
+
+// bootstrapping returns true if no users exist
+func (a *AdminAPI) bootstrapping() (bool, error) {
+	n, err := user.UserCountDB(a.ctx.PrincipalDB(service.ReadOnly))
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// requestMFA returns true if the authorization of the request includes a verified
+// second factor
+func requestMFA(r *http.Request) bool {
+	auth, err := getAuthContainer(r)
+	if err != nil {
+		return false
+	}
+	mfa, _ := auth[AuthMFAKey].(bool)
+	return mfa
+}
+
+// RoleRequiredHandler wraps the given handler with an authorization and a permission
+// check
+//
+// The authorized user will be stored in the request context.
+func (a *AdminAPI) RoleRequiredHandler(p Permission, parent http.Handler) http.Handler {
+	return a.AuthRequiredHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := a.log.New(log15.Ctx{"method": "RoleRequiredHandler", "requestID": service.RequestID(r)})
+
+		u, err := a.requestUser(r)
+		if err != nil {
+			if err == user.ErrUserNotFound || err == user.ErrUserInactive {
+				log.Warn("user not authorized", log15.Ctx{"err": err})
+				a.resetCookie(w, r)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			log.Error("error retrieving user", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		service.SetRequestContextVar(r, contextVarUserKey, u)
+
+		if !requestMFA(r) && ((u.MFA.Required && !p.MFAEnrollment) || p.MFA) {
+			var exempt bool
+			if u == systemUser && p.Bootstrap {
+				exempt, err = a.bootstrapping()
+				if err != nil {
+					log.Error("error checking for bootstrapping", log15.Ctx{"err": err})
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+			if !exempt {
+				log.Warn("second factor required", log15.Ctx{"userName": u.Name})
+				w.Header().Set("Content-Type", "application/json")
+				resp := ErrForbidden
+				resp.Info = "two-factor authentication required"
+				resp.Write(w)
+				return
+			}
+		}
+
+		role := p.role(r)
+		if role == "" {
+			parent.ServeHTTP(w, r)
+			return
+		}
+		var scope *user.Scope
+		if p.Scope != nil {
+			scope, err = p.Scope(a, r)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				switch err {
+				case errScopeParam:
+					ErrReadParam.Write(w)
+				case principal.ErrPrincipalNotFound, project.ErrProjectNotFound:
+					ErrNotFound.Write(w)
+				default:
+					log.Error("error resolving scope", log15.Ctx{"err": err})
+					ErrDatabase.Write(w)
+				}
+				return
+			}
+		}
+		var permitted bool
+		if scope == nil {
+			permitted = u.HasRole(role)
+		} else {
+			permitted = u.Authorized(role, *scope)
+		}
+		if !permitted {
+			log.Warn("permission denied", log15.Ctx{
+				"userName": u.Name,
+				"role":     role,
+				"scope":    scope,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			ErrForbidden.Write(w)
+			return
+		}
+		parent.ServeHTTP(w, r)
+	}))
+}
+
+// authorized checks whether the authorized user has the given role in the given
+// scope
+//
+// It is used by handlers which can only determine the scope from the request body.
+// If the user is not permitted, an ErrForbidden response will be written.
+func (a *AdminAPI) authorized(w http.ResponseWriter, r *http.Request, role user.Role, s user.Scope) bool {
+	log := a.log.New(log15.Ctx{"method": "authorized", "requestID": service.RequestID(r)})
+	u, ok := contextUser(r)
+	if !ok {
+		log.Crit("user not present in request context")
+		ErrSystem.Write(w)
+		return false
+	}
+	if !u.Authorized(role, s) {
+		log.Warn("permission denied", log15.Ctx{
+			"userName": u.Name,
+			"role":     role,
+			"scope":    s,
+		})
+		ErrForbidden.Write(w)
+		return false
+	}
+	return true
+}
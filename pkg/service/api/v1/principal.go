@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/fritzpay/paymentd/pkg/metadata"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
 	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
 	"github.com/fritzpay/paymentd/pkg/service"
 	"github.com/gorilla/mux"
@@ -24,7 +25,7 @@ type PrincipalAdminAPIResponse struct {
 func (a *AdminAPI) PrincipalRequest() http.Handler {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		log := a.log.New(log15.Ctx{"method": "PrincipalRequest"})
+		log := a.log.New(log15.Ctx{"method": "PrincipalRequest", "requestID": service.RequestID(r)})
 
 		switch r.Method {
 		case "PUT":
@@ -56,7 +57,7 @@ func (a *AdminAPI) PrincipalNameRequest() http.Handler {
 // handler to display a specific existing principal
 func (a *AdminAPI) getPrincipal(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	log := a.log.New(log15.Ctx{"method": "getPrincipal"})
+	log := a.log.New(log15.Ctx{"method": "getPrincipal", "requestID": service.RequestID(r)})
 
 	// get principal by name
 	vars := mux.Vars(r)
@@ -101,7 +102,7 @@ func (a *AdminAPI) getPrincipal(w http.ResponseWriter, r *http.Request) {
 func (a *AdminAPI) getAllPrincipals(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
-	log := a.log.New(log15.Ctx{"method": "getAllPrincipals"})
+	log := a.log.New(log15.Ctx{"method": "getAllPrincipals", "requestID": service.RequestID(r)})
 
 	params := r.URL.Query()
 	metadataRequested := params.Get("metadata") != ""
@@ -144,7 +145,7 @@ func (a *AdminAPI) getAllPrincipals(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *AdminAPI) putNewPrincipal(w http.ResponseWriter, r *http.Request) {
-	log := a.log.New(log15.Ctx{"method": "putNewPrincipal"})
+	log := a.log.New(log15.Ctx{"method": "putNewPrincipal", "requestID": service.RequestID(r)})
 
 	// create new principal
 	jd := json.NewDecoder(r.Body)
@@ -206,6 +207,12 @@ func (a *AdminAPI) putNewPrincipal(w http.ResponseWriter, r *http.Request) {
 		log.Error("metadata insert failed.", log15.Ctx{"err": err})
 		return
 	}
+	err = a.auditTx(tx, r, audit.ActionCreate, auditEntityPrincipal, auditID(pr.ID), nil, pr)
+	if err != nil {
+		tx.Rollback()
+		ErrDatabase.Write(w)
+		return
+	}
 
 	//commit tx
 	err = tx.Commit()
@@ -230,7 +237,7 @@ func (a *AdminAPI) putNewPrincipal(w http.ResponseWriter, r *http.Request) {
 
 // post method to add and change the metadata
 func (a *AdminAPI) postChangePrincipal(w http.ResponseWriter, r *http.Request) {
-	log := a.log.New(log15.Ctx{"method": "postChangePrincipal"})
+	log := a.log.New(log15.Ctx{"method": "postChangePrincipal", "requestID": service.RequestID(r)})
 
 	vars := mux.Vars(r)
 	principalName := vars["name"]
@@ -282,9 +289,19 @@ func (a *AdminAPI) postChangePrincipal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	pr.ID = prByName.ID
+	before := prByName
+	md, err := metadata.MetadataByPrimaryTx(tx, principal.MetadataModel, pr.ID)
+	if err != nil {
+		tx.Rollback()
+		log.Error("get metadata failed", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	if len(md) > 0 {
+		before.Metadata = md.Values()
+	}
 
 	// insert Metadata
-	md := metadata.MetadataFromValues(pr.Metadata, pr.CreatedBy)
 	err = insertPrincipalMetadata(tx, &pr)
 	if err != nil {
 		tx.Rollback()
@@ -304,6 +321,14 @@ func (a *AdminAPI) postChangePrincipal(w http.ResponseWriter, r *http.Request) {
 	if len(md) > 0 {
 		pr.Metadata = md.Values()
 	}
+	after := before
+	after.Metadata = pr.Metadata
+	err = a.auditTx(tx, r, audit.ActionChange, auditEntityPrincipal, auditID(pr.ID), before, after)
+	if err != nil {
+		tx.Rollback()
+		ErrDatabase.Write(w)
+		return
+	}
 	err = tx.Commit()
 	if err != nil {
 		log.Crit("error on commit", log15.Ctx{"err": err})
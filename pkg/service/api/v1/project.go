@@ -3,13 +3,16 @@ package v1
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/fritzpay/paymentd/pkg/metadata"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
 	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
 	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
 	"github.com/fritzpay/paymentd/pkg/service"
 	"github.com/gorilla/mux"
 	"gopkg.in/inconshreveable/log15.v2"
@@ -24,7 +27,7 @@ func (a *AdminAPI) ProjectRequest() http.Handler {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		log := a.log.New(log15.Ctx{"method": "ProjectRequest"})
+		log := a.log.New(log15.Ctx{"method": "ProjectRequest", "requestID": service.RequestID(r)})
 		switch r.Method {
 		case "PUT":
 			a.putNewProject(w, r)
@@ -47,7 +50,7 @@ func (a *AdminAPI) ProjectGetRequest() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
-		log := a.log.New(log15.Ctx{"method": "ProjectGetRequest"})
+		log := a.log.New(log15.Ctx{"method": "ProjectGetRequest", "requestID": service.RequestID(r)})
 
 		// @todo restrict by projectid
 		if r.Method != "GET" {
@@ -63,7 +66,7 @@ func (a *AdminAPI) ProjectGetRequest() http.Handler {
 
 func (a *AdminAPI) getProject(w http.ResponseWriter, r *http.Request) {
 
-	log := a.log.New(log15.Ctx{"method": "getProject"})
+	log := a.log.New(log15.Ctx{"method": "getProject", "requestID": service.RequestID(r)})
 
 	// parse request paramter
 	// project_id
@@ -114,7 +117,7 @@ func (a *AdminAPI) getProject(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *AdminAPI) getAllProjects(w http.ResponseWriter, r *http.Request) {
-	log := a.log.New(log15.Ctx{"method": "getAllProjects"})
+	log := a.log.New(log15.Ctx{"method": "getAllProjects", "requestID": service.RequestID(r)})
 
 	// parse request paramter
 	// principal_id
@@ -129,6 +132,9 @@ func (a *AdminAPI) getAllProjects(w http.ResponseWriter, r *http.Request) {
 		ErrReadParam.Write(w)
 		return
 	}
+	if !a.authorized(w, r, user.RoleViewer, user.Scope{PrincipalID: principalID}) {
+		return
+	}
 
 	// get projects from database
 	db := a.ctx.PrincipalDB(service.ReadOnly)
@@ -180,7 +186,7 @@ func (a *AdminAPI) getAllProjects(w http.ResponseWriter, r *http.Request) {
 // add new project
 func (a *AdminAPI) putNewProject(w http.ResponseWriter, r *http.Request) {
 
-	log := a.log.New(log15.Ctx{"method": "putNewProject"})
+	log := a.log.New(log15.Ctx{"method": "putNewProject", "requestID": service.RequestID(r)})
 	auth, err := getAuthContainer(r)
 	if err != nil {
 		log.Crit("auth container error", log15.Ctx{"err": err})
@@ -217,6 +223,12 @@ func (a *AdminAPI) putNewProject(w http.ResponseWriter, r *http.Request) {
 		ErrInval.Write(w)
 		return
 	}
+	if !validProjectConfig(w, pr.Config) {
+		return
+	}
+	if !a.authorized(w, r, user.RoleAdmin, user.Scope{PrincipalID: pr.PrincipalID}) {
+		return
+	}
 
 	log = log.New(log15.Ctx{"projectName": pr.Name, "principalID": pr.PrincipalID})
 
@@ -292,6 +304,12 @@ func (a *AdminAPI) putNewProject(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	err = a.auditTx(tx, r, audit.ActionCreate, auditEntityProject, auditID(pr.ID), nil, pr)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Crit("error on commit", log15.Ctx{"err": err})
@@ -313,7 +331,7 @@ func (a *AdminAPI) putNewProject(w http.ResponseWriter, r *http.Request) {
 // add change project data
 func (a *AdminAPI) postChangeProject(w http.ResponseWriter, r *http.Request) {
 
-	log := a.log.New(log15.Ctx{"method": "postChangeProject"})
+	log := a.log.New(log15.Ctx{"method": "postChangeProject", "requestID": service.RequestID(r)})
 
 	auth, err := getAuthContainer(r)
 	if err != nil {
@@ -341,6 +359,9 @@ func (a *AdminAPI) postChangeProject(w http.ResponseWriter, r *http.Request) {
 		log.Error("json decode failed", log15.Ctx{"err": err})
 		return
 	}
+	if !validProjectConfig(w, pr.Config) {
+		return
+	}
 
 	// created
 	pr.CreatedBy = auth[AuthUserIDKey].(string)
@@ -379,7 +400,18 @@ func (a *AdminAPI) postChangeProject(w http.ResponseWriter, r *http.Request) {
 		ErrConflict.Write(w)
 		return
 	}
+	if !a.authorized(w, r, user.RoleAdmin, user.Scope{PrincipalID: prDB.PrincipalID, ProjectID: prDB.ID}) {
+		return
+	}
 	pr.ID = prDB.ID
+	before := *prDB
+	beforeMd, err := metadata.MetadataByPrimaryTx(tx, project.MetadataModel, prDB.ID)
+	if err != nil {
+		log.Error("get metadata failed", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	before.Metadata = beforeMd.Values()
 	// update config data
 	if pr.Config.HasValues() {
 		err = project.InsertProjectConfigTx(tx, pr)
@@ -413,6 +445,12 @@ func (a *AdminAPI) postChangeProject(w http.ResponseWriter, r *http.Request) {
 	}
 	pr.Metadata = md.Values()
 
+	err = a.auditTx(tx, r, audit.ActionChange, auditEntityProject, auditID(pr.ID), before, pr)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		ErrDatabase.Write(w)
@@ -434,3 +472,21 @@ func (a *AdminAPI) postChangeProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// validProjectConfig validates the given project config and writes an error response
+// if it is invalid
+func validProjectConfig(w http.ResponseWriter, cfg project.Config) bool {
+	if _, err := cfg.AllowedNetworks(); err != nil {
+		resp := ErrInval
+		resp.Info = fmt.Sprintf("invalid IP allowlist: %v", err)
+		resp.Write(w)
+		return false
+	}
+	if cfg.RateLimitBurst.Valid && cfg.RateLimitBurst.Int64 < 1 {
+		resp := ErrInval
+		resp.Info = "rate limit burst must be positive"
+		resp.Write(w)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,476 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"github.com/gorilla/mux"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// DefaultKeyRotationOverlap is the default duration during which the previous
+	// secret of a rotated project key remains valid
+	DefaultKeyRotationOverlap = 24 * time.Hour
+)
+
+// ProjectKeyResponse is the admin API representation of a project key
+//
+// The secret is only included when a key is created or rotated.
+type ProjectKeyResponse struct {
+	Key             string
+	ProjectID       int64 `json:",string"`
+	Timestamp       time.Time
+	CreatedBy       string
+	Active          bool
+	Expires         *time.Time      `json:",omitempty"`
+	PreviousExpires *time.Time      `json:",omitempty"`
+	Scopes          []project.Scope `json:",omitempty"`
+	IPAllowlist     []string        `json:",omitempty"`
+	PublicKeys      []string        `json:",omitempty"`
+	ClientCerts     []string        `json:",omitempty"`
+	Secret          string          `json:",omitempty"`
+}
+
+func newProjectKeyResponse(pk *project.Projectkey, withSecret bool) ProjectKeyResponse {
+	resp := ProjectKeyResponse{
+		Key:         pk.Key,
+		ProjectID:   pk.Project.ID,
+		Timestamp:   pk.Timestamp,
+		CreatedBy:   pk.CreatedBy,
+		Active:      pk.Active,
+		Expires:     pk.Expires,
+		Scopes:      pk.Scopes,
+		ClientCerts: pk.ClientCerts,
+	}
+	if len(pk.IPAllowlist) > 0 {
+		resp.IPAllowlist = pk.IPAllowlist.Strings()
+	}
+	if pk.Asymmetric() {
+		resp.PublicKeys = pk.HexPublicKeys()
+	}
+	if pk.InOverlap(time.Now()) {
+		resp.PreviousExpires = pk.PreviousExpires
+	}
+	if withSecret {
+		resp.Secret = pk.Secret
+	}
+	return resp
+}
+
+// ProjectKeyRequest is the request JSON struct for creating and changing project keys
+type ProjectKeyRequest struct {
+	Active  *bool
+	Expires *time.Time
+	// Scopes restricts the key to the listed operations. An empty list removes
+	// all restrictions.
+	Scopes *[]project.Scope
+	// IPAllowlist restricts the networks (CIDR notation) from which the key may be
+	// used. An empty list removes the restriction.
+	IPAllowlist *[]string
+	// PublicKeys are the hex-encoded Ed25519 public keys of the merchant. If present,
+	// requests must be signed with Ed25519. An empty list switches back to the shared
+	// secret.
+	PublicKeys *[]string
+	// ClientCerts are the hex-encoded SHA-256 fingerprints of the TLS client
+	// certificates bound to the key. If present, requests must be made with one of
+	// them. An empty list removes the binding.
+	ClientCerts *[]string
+	// Overlap is the rotation overlap duration, e.g. "24h"
+	Overlap string
+}
+
+func readProjectKeyRequest(r *http.Request) (ProjectKeyRequest, error) {
+	req := ProjectKeyRequest{}
+	if r.ContentLength == 0 {
+		return req, nil
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	r.Body.Close()
+	return req, err
+}
+
+// applyRestrictions sets the scopes, the IP allowlist, the public keys and the client
+// certificates of the request on the project key, if present
+func (req ProjectKeyRequest) applyRestrictions(pk *project.Projectkey) *ServiceResponse {
+	if req.Scopes != nil {
+		if err := pk.SetScopes(*req.Scopes); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			return &resp
+		}
+	}
+	if req.IPAllowlist != nil {
+		if err := pk.SetIPAllowlist(*req.IPAllowlist); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			return &resp
+		}
+	}
+	if req.PublicKeys != nil {
+		if err := pk.SetPublicKeys(*req.PublicKeys); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			return &resp
+		}
+	}
+	if req.ClientCerts != nil {
+		if err := pk.SetClientCerts(*req.ClientCerts); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			return &resp
+		}
+	}
+	return nil
+}
+
+func projectIDParam(r *http.Request) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)["projectid"], 10, 64)
+}
+
+// ProjectKeyRequest returns a handler to list (GET) and create (PUT) the keys of a
+// project
+func (a *AdminAPI) ProjectKeyRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "ProjectKeyRequest", "requestID": service.RequestID(r)})
+
+		projectID, err := projectIDParam(r)
+		if err != nil {
+			ErrReadParam.Write(w)
+			log.Info("malformed param", log15.Ctx{"err": err})
+			return
+		}
+		switch r.Method {
+		case "GET":
+			a.getProjectKeys(w, r, projectID)
+		case "PUT":
+			a.putNewProjectKey(w, r, projectID)
+		default:
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// ProjectKeyNameRequest returns a handler to retrieve (GET), change (POST) and
+// revoke (DELETE) a project key
+func (a *AdminAPI) ProjectKeyNameRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "ProjectKeyNameRequest", "requestID": service.RequestID(r)})
+
+		projectID, err := projectIDParam(r)
+		if err != nil {
+			ErrReadParam.Write(w)
+			log.Info("malformed param", log15.Ctx{"err": err})
+			return
+		}
+		key := mux.Vars(r)["key"]
+		switch r.Method {
+		case "GET":
+			a.getProjectKey(w, r, projectID, key)
+		case "POST":
+			req, err := readProjectKeyRequest(r)
+			if err != nil {
+				ErrReadJson.Write(w)
+				log.Warn("json decoding failed", log15.Ctx{"err": err})
+				return
+			}
+			a.changeProjectKey(w, r, projectID, key, audit.ActionChange, func(pk *project.Projectkey) *ServiceResponse {
+				if req.Active != nil {
+					pk.Active = *req.Active
+				}
+				if req.Expires != nil {
+					exp := req.Expires.UTC()
+					pk.Expires = &exp
+				}
+				return req.applyRestrictions(pk)
+			})
+		case "DELETE":
+			a.changeProjectKey(w, r, projectID, key, audit.ActionRevoke, func(pk *project.Projectkey) *ServiceResponse {
+				pk.Active = false
+				return nil
+			})
+		default:
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// ProjectKeyRotateRequest returns a handler to rotate the secret of a project key
+// (POST)
+//
+// The previous secret remains valid during the overlap duration given in the request.
+func (a *AdminAPI) ProjectKeyRotateRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "ProjectKeyRotateRequest", "requestID": service.RequestID(r)})
+
+		if r.Method != "POST" {
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+			return
+		}
+		projectID, err := projectIDParam(r)
+		if err != nil {
+			ErrReadParam.Write(w)
+			log.Info("malformed param", log15.Ctx{"err": err})
+			return
+		}
+		req, err := readProjectKeyRequest(r)
+		if err != nil {
+			ErrReadJson.Write(w)
+			log.Warn("json decoding failed", log15.Ctx{"err": err})
+			return
+		}
+		overlap := DefaultKeyRotationOverlap
+		if req.Overlap != "" {
+			overlap, err = time.ParseDuration(req.Overlap)
+			if err != nil || overlap < 0 {
+				resp := ErrInval
+				resp.Info = "invalid overlap duration"
+				resp.Write(w)
+				return
+			}
+		}
+		a.changeProjectKey(w, r, projectID, mux.Vars(r)["key"], audit.ActionRotate, func(pk *project.Projectkey) *ServiceResponse {
+			if !pk.IsValid() {
+				resp := ErrInval
+				resp.Info = "cannot rotate an invalid project key"
+				return &resp
+			}
+			err := pk.Rotate(overlap)
+			if err != nil {
+				log.Error("error generating secret", log15.Ctx{"err": err})
+				return &ErrSystem
+			}
+			return nil
+		})
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+func (a *AdminAPI) getProjectKeys(w http.ResponseWriter, r *http.Request, projectID int64) {
+	log := a.log.New(log15.Ctx{
+		"method":    "getProjectKeys",
+		"projectID": projectID,
+		"requestID": service.RequestID(r),
+	})
+	keys, err := project.ProjectKeysByProjectIDDB(a.ctx.PrincipalDB(service.ReadOnly), projectID)
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	keysResp := make([]ProjectKeyResponse, len(keys))
+	for i, pk := range keys {
+		keysResp[i] = newProjectKeyResponse(pk, false)
+	}
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "project keys found"
+	resp.Response = keysResp
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
+
+func (a *AdminAPI) getProjectKey(w http.ResponseWriter, r *http.Request, projectID int64, key string) {
+	log := a.log.New(log15.Ctx{
+		"method":    "getProjectKey",
+		"projectID": projectID,
+		"requestID": service.RequestID(r),
+	})
+	pk, err := project.ProjectKeyByKeyDB(a.ctx.PrincipalDB(service.ReadOnly), key)
+	if err != nil && err != project.ErrProjectKeyNotFound {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	if err == project.ErrProjectKeyNotFound || pk.Project.ID != projectID {
+		ErrNotFound.Write(w)
+		return
+	}
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "project key found"
+	resp.Response = newProjectKeyResponse(pk, false)
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
+
+func (a *AdminAPI) putNewProjectKey(w http.ResponseWriter, r *http.Request, projectID int64) {
+	log := a.log.New(log15.Ctx{
+		"method":    "putNewProjectKey",
+		"projectID": projectID,
+		"requestID": service.RequestID(r),
+	})
+	req, err := readProjectKeyRequest(r)
+	if err != nil {
+		ErrReadJson.Write(w)
+		log.Warn("json decoding failed", log15.Ctx{"err": err})
+		return
+	}
+	pr, err := project.ProjectByIDDB(a.ctx.PrincipalDB(service.ReadOnly), projectID)
+	if err != nil {
+		if err == project.ErrProjectNotFound {
+			ErrNotFound.Write(w)
+			return
+		}
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	auth := service.RequestContextAuth(r)
+	pk, err := project.NewProjectkey(*pr, auth[AuthUserIDKey].(string))
+	if err != nil {
+		ErrSystem.Write(w)
+		log.Error("error generating project key", log15.Ctx{"err": err})
+		return
+	}
+	if req.Active != nil {
+		pk.Active = *req.Active
+	}
+	if req.Expires != nil {
+		exp := req.Expires.UTC()
+		pk.Expires = &exp
+	}
+	if errResp := req.applyRestrictions(pk); errResp != nil {
+		errResp.Write(w)
+		return
+	}
+
+	var tx *sql.Tx
+	var commit bool
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Crit("error on begin tx", log15.Ctx{"err": err})
+		return
+	}
+	err = project.InsertProjectKeyTx(tx, pk)
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Error("error saving project key", log15.Ctx{"err": err})
+		return
+	}
+	err = a.auditTx(tx, r, audit.ActionCreate, auditEntityProjectKey, pk.Key, nil, newProjectKeyResponse(pk, false))
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Crit("error on commit", log15.Ctx{"err": err})
+		return
+	}
+	commit = true
+
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "project key created"
+	resp.Response = newProjectKeyResponse(pk, true)
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
+
+// changeProjectKey stores a new version of the project key after applying the
+// given change
+//
+// The change function can return a response to abort the change. The response will
+// include the secret if it was rotated. The change is recorded in the audit log with
+// the given action.
+func (a *AdminAPI) changeProjectKey(w http.ResponseWriter, r *http.Request, projectID int64, key, action string, change func(pk *project.Projectkey) *ServiceResponse) {
+	log := a.log.New(log15.Ctx{
+		"method":     "changeProjectKey",
+		"projectID":  projectID,
+		"projectKey": key,
+		"requestID":  service.RequestID(r),
+	})
+	var tx *sql.Tx
+	var commit bool
+	var err error
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Crit("error on begin tx", log15.Ctx{"err": err})
+		return
+	}
+	pk, err := project.ProjectKeyByKeyTx(tx, key)
+	if err != nil && err != project.ErrProjectKeyNotFound {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	if err == project.ErrProjectKeyNotFound || pk.Project.ID != projectID {
+		ErrNotFound.Write(w)
+		return
+	}
+	secret := pk.Secret
+	before := newProjectKeyResponse(pk, false)
+	if errResp := change(pk); errResp != nil {
+		errResp.Write(w)
+		return
+	}
+	auth := service.RequestContextAuth(r)
+	pk.NextVersion(auth[AuthUserIDKey].(string))
+	err = project.InsertProjectKeyTx(tx, pk)
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Error("error saving project key", log15.Ctx{"err": err})
+		return
+	}
+	err = a.auditTx(tx, r, action, auditEntityProjectKey, pk.Key, before, newProjectKeyResponse(pk, false))
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Crit("error on commit", log15.Ctx{"err": err})
+		return
+	}
+	commit = true
+
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "project key changed"
+	resp.Response = newProjectKeyResponse(pk, pk.Secret != secret)
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
@@ -18,7 +18,7 @@ func (a *AdminAPI) ProviderGetRequest() http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		log := a.log.New(log15.Ctx{"method": "Provider Request"})
+		log := a.log.New(log15.Ctx{"method": "Provider Request", "requestID": service.RequestID(r)})
 
 		if r.Method != "GET" {
 			ErrInval.Write(w)
@@ -62,7 +62,7 @@ func (a *AdminAPI) ProviderGetAllRequest() http.Handler {
 
 		w.Header().Set("Content-Type", "application/json")
 		// get all
-		log := a.log.New(log15.Ctx{"method": "Provider Request"})
+		log := a.log.New(log15.Ctx{"method": "Provider Request", "requestID": service.RequestID(r)})
 		db := a.ctx.PaymentDB(service.ReadOnly)
 		prl, err := provider.ProviderAllDB(db)
 		if err != nil {
@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/paymentd/routing"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"github.com/gorilla/mux"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// RoutingRequest is the request JSON struct for PUT project/(id)/routing
+type RoutingRequest struct {
+	Rules []routing.Rule
+}
+
+// RoutingRequest returns a handler to retrieve (GET) and replace (PUT) the payment
+// method routing rules of a project
+func (a *AdminAPI) RoutingRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{"method": "RoutingRequest", "requestID": service.RequestID(r)})
+
+		projectID, err := strconv.ParseInt(mux.Vars(r)["projectid"], 10, 64)
+		if err != nil {
+			ErrReadParam.Write(w)
+			log.Info("malformed param", log15.Ctx{"err": err})
+			return
+		}
+		switch r.Method {
+		case "GET":
+			a.getRouting(w, r, projectID)
+		case "PUT":
+			a.putRouting(w, r, projectID)
+		default:
+			ErrMethod.Write(w)
+			log.Info("http method not supported", log15.Ctx{"requestMethod": r.Method})
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+func (a *AdminAPI) getRouting(w http.ResponseWriter, r *http.Request, projectID int64) {
+	log := a.log.New(log15.Ctx{
+		"method":    "Routing GET Request",
+		"projectID": projectID,
+		"requestID": service.RequestID(r),
+	})
+	rs, err := routing.RulesetByProjectIDDB(a.ctx.PaymentDB(service.ReadOnly), projectID)
+	if err != nil {
+		if err == routing.ErrRulesetNotFound {
+			ErrNotFound.Write(w)
+			return
+		}
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "routing rules found"
+	resp.Response = rs
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
+
+func (a *AdminAPI) putRouting(w http.ResponseWriter, r *http.Request, projectID int64) {
+	log := a.log.New(log15.Ctx{
+		"method":    "Routing PUT Request",
+		"projectID": projectID,
+		"requestID": service.RequestID(r),
+	})
+	_, err := project.ProjectByIDDB(a.ctx.PrincipalDB(service.ReadOnly), projectID)
+	if err != nil {
+		if err == project.ErrProjectNotFound {
+			ErrNotFound.Write(w)
+			return
+		}
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	before, err := routing.RulesetByProjectIDDB(a.ctx.PaymentDB(), projectID)
+	if err != nil && err != routing.ErrRulesetNotFound {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+
+	req := RoutingRequest{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	r.Body.Close()
+	if err != nil {
+		ErrReadJson.Write(w)
+		log.Error("json decoding failed", log15.Ctx{"err": err})
+		return
+	}
+	auth := service.RequestContextAuth(r)
+	rs := &routing.Ruleset{
+		ProjectID: projectID,
+		Timestamp: time.Now(),
+		CreatedBy: auth[AuthUserIDKey].(string),
+		Rules:     req.Rules,
+	}
+	err = rs.Validate()
+	if err != nil {
+		resp := ErrInval
+		resp.Info = err.Error()
+		resp.Write(w)
+		return
+	}
+
+	var tx *sql.Tx
+	var commit bool
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PaymentDB().Begin()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Crit("error on begin tx", log15.Ctx{"err": err})
+		return
+	}
+	// all targets must be payment methods of the project
+	for _, rule := range rs.Rules {
+		for _, t := range rule.Methods {
+			pm, err := payment_method.PaymentMethodByIDTx(tx, t.PaymentMethodID)
+			if err != nil && err != payment_method.ErrPaymentMethodNotFound {
+				ErrDatabase.Write(w)
+				log.Error("database error", log15.Ctx{"err": err})
+				return
+			}
+			if err == payment_method.ErrPaymentMethodNotFound || pm.ProjectID != projectID {
+				resp := ErrInval
+				resp.Info = "invalid payment method id " + strconv.FormatInt(t.PaymentMethodID, 10)
+				resp.Write(w)
+				return
+			}
+		}
+	}
+	err = routing.InsertRulesetTx(tx, rs)
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+	// the routing rules are stored in the payment database, so the audit log entry is
+	// written before the commit
+	err = a.audit(r, audit.ActionChange, auditEntityRouting, auditID(projectID), before, rs)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	commit = true
+	err = tx.Commit()
+	if err != nil {
+		ErrDatabase.Write(w)
+		log.Error("database error", log15.Ctx{"err": err})
+		return
+	}
+
+	resp := ProjectAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "routing rules set"
+	resp.Response = rs
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("error writing response", log15.Ctx{"err": err})
+	}
+}
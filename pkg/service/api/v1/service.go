@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"net/http"
+
 	"github.com/fritzpay/paymentd/pkg/service"
 	"github.com/gorilla/mux"
 	"gopkg.in/inconshreveable/log15.v2"
@@ -36,10 +38,14 @@ type Service struct {
 // NewService creates a new API service
 // It requires a valid service context and takes a router to which
 // the service routes will be attached
-func NewService(ctx *service.Context, mux *mux.Router) (*Service, error) {
+func NewService(ctx *service.Context, router *mux.Router) (*Service, error) {
 	s := &Service{
 		log: ctx.Log().New(log15.Ctx{"pkg": "github.com/fritzpay/paymentd/pkg/service/api/v1"}),
 	}
+	// handle registers a route, recording the requests to it in the metrics
+	handle := func(path string, h http.Handler) *mux.Route {
+		return router.Handle(path, ctx.InstrumentHandler("api", path, h))
+	}
 
 	cfg := ctx.Config()
 
@@ -47,22 +53,34 @@ func NewService(ctx *service.Context, mux *mux.Router) (*Service, error) {
 		s.log.Info("registering admin API...")
 
 		admin := NewAdminAPI(ctx)
-		mux.Handle(ServicePath+"/authorization", admin.AuthorizationHandler())
-		mux.Handle(ServicePath+"/authorization/{method}", admin.AuthorizeHandler())
-		mux.Handle(ServicePath+"/user", admin.AuthRequiredHandler(admin.GetUserID()))
+		handle(ServicePath+"/authorization", admin.AuthorizationHandler())
+		handle(ServicePath+"/authorization/{method}", admin.AuthorizeHandler())
+		handle(ServicePath+"/user", admin.RoleRequiredHandler(mfaPermission, admin.GetUserID()))
+		handle(ServicePath+"/user/mfa", admin.RoleRequiredHandler(mfaPermission, admin.MFARequest()))
+		handle(ServicePath+"/user/mfa/totp", admin.RoleRequiredHandler(mfaPermission, admin.TOTPRequest()))
+		handle(ServicePath+"/user/mfa/recovery", admin.RoleRequiredHandler(mfaPermission, admin.RecoveryCodesRequest()))
+		handle(ServicePath+"/users", admin.RoleRequiredHandler(userPermission, admin.UserRequest()))
+		handle(ServicePath+"/users/{username}", admin.RoleRequiredHandler(userPermission, admin.UserNameRequest()))
 
-		mux.Handle(ServicePath+"/principal", admin.AuthRequiredHandler(admin.PrincipalRequest()))
-		mux.Handle(ServicePath+"/principal/{name:[-A-Za-z0-9_]+}", admin.AuthRequiredHandler(admin.PrincipalNameRequest()))
-		mux.Handle(ServicePath+"/provider", admin.AuthRequiredHandler(admin.ProviderGetAllRequest()))
-		mux.Handle(ServicePath+"/provider/{provider}", admin.AuthRequiredHandler(admin.ProviderGetRequest()))
-		mux.Handle(ServicePath+"/project", admin.AuthRequiredHandler(admin.ProjectRequest()))
-		mux.Handle(ServicePath+"/project/{name:[-A-Za-z0-9_]+}/", admin.AuthRequiredHandler(admin.ProjectRequest()))
-		mux.Handle(ServicePath+"/project/{projectid}", admin.AuthRequiredHandler(admin.ProjectGetRequest()))
-		mux.Handle(ServicePath+"/project/{projectid}/method/{methodkey}/provider/{provider}", admin.AuthRequiredHandler(admin.PaymentMethodGetRequest()))
-		mux.Handle(ServicePath+"/project/{projectid}/method/", admin.AuthRequiredHandler(admin.PaymentMethodRequest()))
-		mux.Handle(ServicePath+"/project/{projectid}/method/{methodkey}", admin.AuthRequiredHandler(admin.PaymentMethodRequest()))
-		mux.Handle(ServicePath+"/currency", admin.AuthRequiredHandler(admin.CurrencyGetAllRequest()))
-		mux.Handle(ServicePath+"/currency/{currencycode}", admin.AuthRequiredHandler(admin.CurrencyGetRequest()))
+		handle(ServicePath+"/audit", admin.RoleRequiredHandler(auditPermission, admin.AuditRequest()))
+		handle(ServicePath+"/audit/verify", admin.RoleRequiredHandler(auditPermission, admin.AuditVerifyRequest()))
+		handle(ServicePath+"/log", admin.RoleRequiredHandler(logPermission, admin.LogRequest()))
+		handle(ServicePath+"/principal", admin.RoleRequiredHandler(principalPermission, admin.PrincipalRequest()))
+		handle(ServicePath+"/principal/{name:[-A-Za-z0-9_]+}", admin.RoleRequiredHandler(principalNamePermission, admin.PrincipalNameRequest()))
+		handle(ServicePath+"/provider", admin.RoleRequiredHandler(referencePermission, admin.ProviderGetAllRequest()))
+		handle(ServicePath+"/provider/{provider}", admin.RoleRequiredHandler(referencePermission, admin.ProviderGetRequest()))
+		handle(ServicePath+"/project", admin.RoleRequiredHandler(projectPermission, admin.ProjectRequest()))
+		handle(ServicePath+"/project/{name:[-A-Za-z0-9_]+}/", admin.RoleRequiredHandler(projectPermission, admin.ProjectRequest()))
+		handle(ServicePath+"/project/{projectid}", admin.RoleRequiredHandler(projectIDPermission, admin.ProjectGetRequest()))
+		handle(ServicePath+"/project/{projectid}/method/{methodkey}/provider/{provider}", admin.RoleRequiredHandler(operatorPermission, admin.PaymentMethodGetRequest()))
+		handle(ServicePath+"/project/{projectid}/method/", admin.RoleRequiredHandler(operatorPermission, admin.PaymentMethodRequest()))
+		handle(ServicePath+"/project/{projectid}/method/{methodkey}", admin.RoleRequiredHandler(operatorPermission, admin.PaymentMethodRequest()))
+		handle(ServicePath+"/project/{projectid}/key", admin.RoleRequiredHandler(projectKeyPermission, admin.ProjectKeyRequest()))
+		handle(ServicePath+"/project/{projectid}/key/{key}", admin.RoleRequiredHandler(projectKeyPermission, admin.ProjectKeyNameRequest()))
+		handle(ServicePath+"/project/{projectid}/key/{key}/rotate", admin.RoleRequiredHandler(projectKeyPermission, admin.ProjectKeyRotateRequest()))
+		handle(ServicePath+"/project/{projectid}/routing", admin.RoleRequiredHandler(operatorPermission, admin.RoutingRequest()))
+		handle(ServicePath+"/currency", admin.RoleRequiredHandler(referencePermission, admin.CurrencyGetAllRequest()))
+		handle(ServicePath+"/currency/{currencycode}", admin.RoleRequiredHandler(referencePermission, admin.CurrencyGetRequest()))
 	}
 
 	s.log.Info("registering payment API...")
@@ -71,11 +89,12 @@ func NewService(ctx *service.Context, mux *mux.Router) (*Service, error) {
 		s.log.Error("error registering payment API", log15.Ctx{"err": err})
 		return nil, err
 	}
-	mux.Handle(ServicePath+"/payment", ctx.RateLimitHandler(payment.InitPayment())).Methods("POST")
-	mux.Handle(ServicePath+"/payment/paymentId/{paymentId}", payment.GetPayment()).Methods("GET")
-	mux.Handle(ServicePath+"/payment/PaymentId/{paymentId}", payment.GetPayment()).Methods("GET")
-	mux.Handle(ServicePath+"/payment/ident/{ident}", payment.GetPayment()).Methods("GET")
-	mux.Handle(ServicePath+"/payment/Ident/{ident}", payment.GetPayment()).Methods("GET")
+	handle(ServicePath+"/payment", ctx.RateLimitHandler(payment.clientLimit(payment.InitPayment()))).Methods("POST")
+	handle(ServicePath+"/payment/paymentId/{paymentId}", payment.clientLimit(payment.GetPayment())).Methods("GET")
+	handle(ServicePath+"/payment/PaymentId/{paymentId}", payment.clientLimit(payment.GetPayment())).Methods("GET")
+	handle(ServicePath+"/payment/ident/{ident}", payment.clientLimit(payment.GetPayment())).Methods("GET")
+	handle(ServicePath+"/payment/Ident/{ident}", payment.clientLimit(payment.GetPayment())).Methods("GET")
+	handle(ServicePath+"/signingkeys", payment.clientLimit(payment.SigningKeys())).Methods("GET")
 
 	return s, nil
 }
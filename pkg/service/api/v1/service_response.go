@@ -3,6 +3,10 @@ package v1
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
 
 	"gopkg.in/inconshreveable/log15.v2"
 )
@@ -12,6 +16,11 @@ const (
 	StatusUnauthorized        = "unauthorized"
 	StatusError               = "error"
 	StatusSuccess             = "success"
+
+	// statuses of payments which exceed the limits of the payment method
+	StatusCurrencyNotSupported = "currencyNotSupported"
+	StatusCountryNotSupported  = "countryNotSupported"
+	StatusAmountOutOfRange     = "amountOutOfRange"
 )
 
 const (
@@ -54,6 +63,14 @@ var (
 		nil,
 		nil,
 	}
+	ErrForbidden = ServiceResponse{
+		http.StatusForbidden,
+		APIVersion,
+		StatusUnauthorized,
+		"insufficient permissions",
+		nil,
+		nil,
+	}
 	ErrDatabase = ServiceResponse{
 		http.StatusInternalServerError,
 		APIVersion,
@@ -110,8 +127,46 @@ var (
 		nil,
 		nil,
 	}
+	ErrTooManyRequests = ServiceResponse{
+		429,
+		APIVersion,
+		StatusError,
+		"too many requests",
+		nil,
+		nil,
+	}
+	ErrCurrencyNotSupported = ServiceResponse{
+		http.StatusBadRequest,
+		APIVersion,
+		StatusCurrencyNotSupported,
+		"currency not supported by payment method",
+		nil,
+		nil,
+	}
+	ErrCountryNotSupported = ServiceResponse{
+		http.StatusBadRequest,
+		APIVersion,
+		StatusCountryNotSupported,
+		"country not supported by payment method",
+		nil,
+		nil,
+	}
+	ErrAmountOutOfRange = ServiceResponse{
+		http.StatusBadRequest,
+		APIVersion,
+		StatusAmountOutOfRange,
+		"amount out of payment method range",
+		nil,
+		nil,
+	}
 )
 
+// writeTooManyRequests writes ErrTooManyRequests with a Retry-After header
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+	ErrTooManyRequests.Write(w)
+}
+
 func (sr *ServiceResponse) Write(w http.ResponseWriter) error {
 	if sr.Version == "" {
 		sr.Version = APIVersion
@@ -12,7 +12,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
 	"github.com/fritzpay/paymentd/pkg/paymentd/principal"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
 
 	"github.com/fritzpay/paymentd/pkg/service"
 	"github.com/fritzpay/paymentd/pkg/testutil"
@@ -121,6 +124,8 @@ func TestGetCredentialsWithBasicAuth(t *testing.T) {
 	Convey("Given a new context", t, testutil.WithContext(func(ctx *service.Context, logChan <-chan *log15.Record) {
 		ctx.Config().API.ServeAdmin = true
 		So(ctx.Config().API.ServeAdmin, ShouldBeTrue)
+		// there is no principal DB to check for bootstrapping
+		ctx.Config().API.SystemPasswordLogin = config.SystemPasswordLoginEnabled
 
 		Convey("Given a new API service", WithService(ctx, logChan, func(s *Service, mx *mux.Router) {
 
@@ -374,12 +379,17 @@ func TestInitPaymentRequestValidation(t *testing.T) {
 func TestGetProvider(t *testing.T) {
 	Convey("Given a test context", t, testutil.WithContext(func(ctx *service.Context, logChan <-chan *log15.Record) {
 		ctx.Config().API.ServeAdmin = true
+		ctx.Config().API.SystemPasswordLogin = config.SystemPasswordLoginEnabled
 
 		Convey("Given a service", WithService(ctx, logChan, func(s *Service, mx *mux.Router) {
 
 			Convey("Given a request for the test provider", func() {
 				req, err := http.NewRequest("GET", ServicePath+"/provider/fritzpay", nil)
 				So(err, ShouldBeNil)
+				service.SetRequestContext(req, ctx)
+				Reset(func() {
+					service.ClearRequestContext(req)
+				})
 
 				rm := mux.RouteMatch{}
 				match := mx.Match(req, &rm)
@@ -408,6 +418,115 @@ func TestGetProvider(t *testing.T) {
 	}))
 }
 
+func TestSystemPasswordLogin(t *testing.T) {
+	Convey("Given a test context", t, testutil.WithContext(func(ctx *service.Context, logChan <-chan *log15.Record) {
+		ctx.Config().API.ServeAdmin = true
+
+		Convey("Given a service", WithService(ctx, logChan, func(s *Service, mx *mux.Router) {
+
+			Convey("Given a payment db", testutil.WithPaymentDB(t, func(db *sql.DB) {
+				ctx.SetPaymentDB(db, nil)
+				Reset(func() { db.Close() })
+
+				Convey("Given a principal db", testutil.WithPrincipalDB(t, func(prDB *sql.DB) {
+					ctx.SetPrincipalDB(prDB, nil)
+					Reset(func() { prDB.Close() })
+
+					login := func() *testutil.ResponseWriter {
+						r, err := http.NewRequest("GET", ServicePath+"/authorization/basic", nil)
+						So(err, ShouldBeNil)
+						r.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("root:password")))
+						w := testutil.NewResponseWriter()
+						mx.ServeHTTP(w, r)
+						return w
+					}
+					get := func(path, auth string) *testutil.ResponseWriter {
+						r, err := http.NewRequest("GET", ServicePath+path, nil)
+						So(err, ShouldBeNil)
+						r.Header.Set("Authorization", auth)
+						service.SetRequestContext(r, ctx)
+						Reset(func() {
+							service.ClearRequestContext(r)
+						})
+						w := testutil.NewResponseWriter()
+						mx.ServeHTTP(w, r)
+						return w
+					}
+					createUser := func() {
+						tx, err := prDB.Begin()
+						So(err, ShouldBeNil)
+						u := &user.User{
+							Created:   time.Now(),
+							CreatedBy: "test",
+							Name:      fmt.Sprintf("test%d", time.Now().UnixNano()),
+						}
+						So(user.InsertUserTx(tx, u), ShouldBeNil)
+						So(tx.Commit(), ShouldBeNil)
+						Reset(func() {
+							_, err := prDB.Exec("DELETE FROM `user` WHERE id = ?", u.ID)
+							So(err, ShouldBeNil)
+						})
+					}
+
+					Convey("When the system password login is disabled", func() {
+						ctx.Config().API.SystemPasswordLogin = config.SystemPasswordLoginDisabled
+
+						Convey("The system password should be rejected", func() {
+							So(login().StatusCode, ShouldEqual, http.StatusUnauthorized)
+						})
+					})
+
+					Convey("When bootstrapping", func() {
+						ctx.Config().API.SystemPasswordLogin = config.SystemPasswordLoginBootstrap
+
+						Convey("Given no users exist", func() {
+							Convey("The system password should be accepted", func() {
+								So(login().StatusCode, ShouldEqual, http.StatusOK)
+							})
+						})
+
+						Convey("Given an authorization of the system user", WithAuthorization(mx, func(auth string) {
+
+							Convey("The system user should be permitted to manage users", func() {
+								So(get("/users", auth).StatusCode, ShouldEqual, http.StatusOK)
+							})
+							Convey("The system user should be rejected on other methods requiring a second factor", func() {
+								So(get("/log", auth).StatusCode, ShouldEqual, http.StatusForbidden)
+							})
+
+							Convey("When a user is created", func() {
+								createUser()
+
+								Convey("The system password should be rejected", func() {
+									So(login().StatusCode, ShouldEqual, http.StatusUnauthorized)
+								})
+								Convey("The authorization should be rejected", func() {
+									So(get("/user", auth).StatusCode, ShouldEqual, http.StatusUnauthorized)
+								})
+							})
+						}))
+					})
+
+					Convey("When the system password login is enabled", func() {
+						ctx.Config().API.SystemPasswordLogin = config.SystemPasswordLoginEnabled
+
+						Convey("Given an authorization of the system user", WithAuthorization(mx, func(auth string) {
+
+							Convey("When a user is created", func() {
+								createUser()
+
+								Convey("The system user should be rejected on the user management", func() {
+									So(get("/users", auth).StatusCode, ShouldEqual, http.StatusForbidden)
+								})
+							})
+						}))
+					})
+				}))
+			}))
+		}))
+	}))
+}
+
 func WithCreatePrincipalRequest(ctx *service.Context, pr principal.Principal, f func(req *http.Request)) func() {
 	return func() {
 		jsonB, err := json.Marshal(pr)
@@ -482,6 +601,14 @@ func TestPrincipal(t *testing.T) {
 											So(err, ShouldBeNil)
 											So(principalID, ShouldNotEqual, 0)
 
+											entries, err := audit.EntriesDB(prDB, audit.Filter{
+												Entity:   auditEntityPrincipal,
+												EntityID: principalIDStr,
+											})
+											So(err, ShouldBeNil)
+											So(len(entries), ShouldEqual, 1)
+											So(entries[0].Action, ShouldEqual, audit.ActionCreate)
+
 											Convey("Given an update request", func() {
 												pr.Metadata["test2"] = "two"
 												jsonB, err := json.Marshal(pr)
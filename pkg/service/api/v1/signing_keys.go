@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"encoding/hex"
+	"net/http"
+
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// SigningKeyResponse is the representation of a public paymentd signing key
+type SigningKeyResponse struct {
+	KeyId     string
+	Algorithm string
+	// PublicKey is the hex-encoded Ed25519 public key
+	PublicKey string
+	// Current is true for the key which signs new messages
+	Current bool
+}
+
+// SigningKeys returns a handler which publishes the public keys with which paymentd
+// signs responses and notifications for project keys with public keys
+//
+// Receivers select the key for verification by the KeyId of a message.
+func (a *PaymentAPI) SigningKeys() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		log := a.log.New(log15.Ctx{
+			"method":    "SigningKeys",
+			"requestID": service.RequestID(r),
+		})
+		keys := a.ctx.SigningKeys().Keys()
+		keysResp := make([]SigningKeyResponse, len(keys))
+		for i, k := range keys {
+			keysResp[i] = SigningKeyResponse{
+				KeyId:     k.KeyID(),
+				Algorithm: "Ed25519",
+				PublicKey: hex.EncodeToString(k.PublicKey()),
+				Current:   i == 0,
+			}
+		}
+		resp := ServiceResponse{}
+		resp.Status = StatusSuccess
+		resp.HttpStatus = http.StatusOK
+		resp.Info = "signing keys"
+		resp.Response = keysResp
+		err := resp.Write(w)
+		if err != nil {
+			log.Error("error writing response", log15.Ctx{"err": err})
+		}
+	})
+}
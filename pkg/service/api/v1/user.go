@@ -1,8 +1,15 @@
 package v1
 
 import (
+	"database/sql"
+	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/fritzpay/paymentd/pkg/paymentd/audit"
+	"github.com/fritzpay/paymentd/pkg/paymentd/user"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"github.com/gorilla/mux"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -19,7 +26,7 @@ func (a *AdminAPI) GetUserID() http.Handler {
 			ErrMethod.Write(w)
 			return
 		}
-		log := a.log.New(log15.Ctx{"method": "GetUserID"})
+		log := a.log.New(log15.Ctx{"method": "GetUserID", "requestID": service.RequestID(r)})
 
 		auth, err := getAuthContainer(r)
 		if err != nil {
@@ -35,3 +42,389 @@ func (a *AdminAPI) GetUserID() http.Handler {
 		resp.Write(w)
 	})
 }
+
+// UserRequest is the request body for creating and changing admin users
+//
+// On changes, only the provided fields will be updated.
+type UserRequest struct {
+	Name     string
+	Password string
+	Status   string
+	Grants   *[]user.Grant
+	// MFARequired enforces two-factor authentication for the user
+	MFARequired *bool
+	// ResetMFA removes the user's second factor, e.g. when the device was lost
+	ResetMFA bool
+}
+
+// UserRequest returns a handler to list and create admin users
+//
+// GET lists all users
+// PUT creates a new user
+func (a *AdminAPI) UserRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			a.getAllUsers(w, r)
+		case "PUT":
+			a.putNewUser(w, r)
+		default:
+			ErrMethod.Write(w)
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+// UserNameRequest returns a handler to display and change an admin user
+//
+// GET displays the user
+// POST changes the password, status or grants of the user
+func (a *AdminAPI) UserNameRequest() http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			a.getUser(w, r)
+		case "POST":
+			a.postChangeUser(w, r)
+		default:
+			ErrMethod.Write(w)
+		}
+	})
+	return a.ctx.RateLimitHandler(h)
+}
+
+func (a *AdminAPI) getAllUsers(w http.ResponseWriter, r *http.Request) {
+	log := a.log.New(log15.Ctx{"method": "getAllUsers", "requestID": service.RequestID(r)})
+
+	users, err := user.UserAllDB(a.ctx.PrincipalDB(service.ReadOnly))
+	if err != nil {
+		log.Error("error retrieving users", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	resp := UserAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "users found"
+	resp.Response = users
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("write error", log15.Ctx{"err": err})
+	}
+}
+
+func (a *AdminAPI) getUser(w http.ResponseWriter, r *http.Request) {
+	log := a.log.New(log15.Ctx{"method": "getUser", "requestID": service.RequestID(r)})
+
+	u, err := user.UserByNameDB(a.ctx.PrincipalDB(service.ReadOnly), mux.Vars(r)["username"])
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrNotFound.Write(w)
+			return
+		}
+		log.Error("error retrieving user", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	resp := UserAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "user " + u.Name + " found"
+	resp.Response = u
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("write error", log15.Ctx{"err": err})
+	}
+}
+
+// auditUser is the audit log representation of a changed user
+//
+// Password hashes are not recorded, only whether the password was changed.
+type auditUser struct {
+	user.User
+	PasswordChanged bool `json:",omitempty"`
+}
+
+func (a *AdminAPI) putNewUser(w http.ResponseWriter, r *http.Request) {
+	log := a.log.New(log15.Ctx{"method": "putNewUser", "requestID": service.RequestID(r)})
+
+	auth, err := getAuthContainer(r)
+	if err != nil {
+		log.Crit("auth container error", log15.Ctx{"err": err})
+		ErrSystem.Write(w)
+		return
+	}
+	req := UserRequest{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	r.Body.Close()
+	if err != nil {
+		log.Warn("json decode failed", log15.Ctx{"err": err})
+		ErrReadJson.Write(w)
+		return
+	}
+
+	u := &user.User{
+		Created:   time.Now().UTC().Round(time.Second),
+		CreatedBy: auth[AuthUserIDKey].(string),
+		Name:      req.Name,
+		Status:    req.Status,
+	}
+	if u.Status == "" {
+		u.Status = user.UserStatusActive
+	}
+	if req.Grants != nil {
+		u.Grants = *req.Grants
+	}
+	if req.MFARequired != nil {
+		u.MFA.Required = *req.MFARequired
+	}
+	log = log.New(log15.Ctx{"userName": u.Name})
+	if err = u.ValidName(); err != nil || u.Name == systemUserID {
+		resp := ErrInval
+		resp.Info = "invalid user name"
+		resp.Write(w)
+		return
+	}
+	if err = u.ValidStatus(); err != nil {
+		resp := ErrInval
+		resp.Info = err.Error()
+		resp.Write(w)
+		return
+	}
+	if err = u.ValidGrants(); err != nil {
+		resp := ErrInval
+		resp.Info = err.Error()
+		resp.Write(w)
+		return
+	}
+	if err = u.SetPassword([]byte(req.Password)); err != nil {
+		if err == user.ErrPasswordTooShort {
+			resp := ErrInval
+			resp.Info = err.Error()
+			resp.Write(w)
+			return
+		}
+		log.Error("error hashing password", log15.Ctx{"err": err})
+		ErrSystem.Write(w)
+		return
+	}
+
+	var tx *sql.Tx
+	var commit bool
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		commit = true
+		log.Crit("error on begin", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	_, err = user.UserByNameTx(tx, u.Name)
+	if err != user.ErrUserNotFound {
+		if err != nil {
+			log.Error("error retrieving user", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+		ErrConflict.Write(w)
+		return
+	}
+	err = user.InsertUserTx(tx, u)
+	if err != nil {
+		log.Error("error saving user", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	err = user.InsertUserStatusTx(tx, u, u.CreatedBy)
+	if err != nil {
+		log.Error("error saving user status", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	err = user.InsertUserPasswordTx(tx, u, u.CreatedBy)
+	if err != nil {
+		log.Error("error saving user password", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	err = user.InsertUserGrantsTx(tx, u, u.CreatedBy)
+	if err != nil {
+		log.Error("error saving user grants", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	if u.MFA.Required {
+		err = user.InsertUserMFATx(tx, u, u.CreatedBy)
+		if err != nil {
+			log.Error("error saving user two-factor authentication", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+	}
+	err = a.auditTx(tx, r, audit.ActionCreate, auditEntityUser, auditID(u.ID), nil, u)
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Crit("error on commit", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	commit = true
+
+	resp := UserAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "user " + u.Name + " created"
+	resp.Response = u
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("write error", log15.Ctx{"err": err})
+	}
+}
+
+func (a *AdminAPI) postChangeUser(w http.ResponseWriter, r *http.Request) {
+	log := a.log.New(log15.Ctx{"method": "postChangeUser", "requestID": service.RequestID(r)})
+
+	auth, err := getAuthContainer(r)
+	if err != nil {
+		log.Crit("auth container error", log15.Ctx{"err": err})
+		ErrSystem.Write(w)
+		return
+	}
+	createdBy := auth[AuthUserIDKey].(string)
+	req := UserRequest{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	r.Body.Close()
+	if err != nil {
+		log.Warn("json decode failed", log15.Ctx{"err": err})
+		ErrReadJson.Write(w)
+		return
+	}
+	name := mux.Vars(r)["username"]
+	log = log.New(log15.Ctx{"userName": name})
+
+	var tx *sql.Tx
+	var commit bool
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	tx, err = a.ctx.PrincipalDB().Begin()
+	if err != nil {
+		commit = true
+		log.Crit("error on begin", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	u, err := user.UserByNameTx(tx, name)
+	if err != nil {
+		if err == user.ErrUserNotFound {
+			ErrNotFound.Write(w)
+			return
+		}
+		log.Error("error retrieving user", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	before := *u
+	if req.Status != "" && req.Status != u.Status {
+		u.Status = req.Status
+		if err = u.ValidStatus(); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			resp.Write(w)
+			return
+		}
+		err = user.InsertUserStatusTx(tx, u, createdBy)
+		if err != nil {
+			log.Error("error saving user status", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+	}
+	if req.Password != "" {
+		if err = u.SetPassword([]byte(req.Password)); err != nil {
+			if err == user.ErrPasswordTooShort {
+				resp := ErrInval
+				resp.Info = err.Error()
+				resp.Write(w)
+				return
+			}
+			log.Error("error hashing password", log15.Ctx{"err": err})
+			ErrSystem.Write(w)
+			return
+		}
+		err = user.InsertUserPasswordTx(tx, u, createdBy)
+		if err != nil {
+			log.Error("error saving user password", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+	}
+	if req.Grants != nil {
+		u.Grants = *req.Grants
+		if err = u.ValidGrants(); err != nil {
+			resp := ErrInval
+			resp.Info = err.Error()
+			resp.Write(w)
+			return
+		}
+		err = user.InsertUserGrantsTx(tx, u, createdBy)
+		if err != nil {
+			log.Error("error saving user grants", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+	}
+	if req.ResetMFA || (req.MFARequired != nil && *req.MFARequired != u.MFA.Required) {
+		if req.ResetMFA {
+			u.MFA.Reset()
+		}
+		if req.MFARequired != nil {
+			u.MFA.Required = *req.MFARequired
+		}
+		err = user.InsertUserMFATx(tx, u, createdBy)
+		if err != nil {
+			log.Error("error saving user two-factor authentication", log15.Ctx{"err": err})
+			ErrDatabase.Write(w)
+			return
+		}
+	}
+	err = a.auditTx(tx, r, audit.ActionChange, auditEntityUser, auditID(u.ID), before, auditUser{
+		User:            *u,
+		PasswordChanged: req.Password != "",
+	})
+	if err != nil {
+		ErrDatabase.Write(w)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Crit("error on commit", log15.Ctx{"err": err})
+		ErrDatabase.Write(w)
+		return
+	}
+	commit = true
+
+	resp := UserAdminAPIResponse{}
+	resp.Status = StatusSuccess
+	resp.Info = "user " + u.Name + " changed"
+	resp.Response = u
+	err = resp.Write(w)
+	if err != nil {
+		log.Error("write error", log15.Ctx{"err": err})
+	}
+}
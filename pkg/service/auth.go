@@ -74,6 +74,23 @@ func (k *Keychain) pushKey(key []byte) {
 	k.m.Unlock()
 }
 
+// SetKeys replaces all keys of the keychain. The first key is the preferred key
+func (k *Keychain) SetKeys(keys [][]byte) {
+	newKeys := make([][]byte, len(keys), len(keys)+keychainLen)
+	copy(newKeys, keys)
+	k.m.Lock()
+	k.keys = newKeys
+	k.m.Unlock()
+}
+
+func (k *Keychain) binKeys() [][]byte {
+	k.m.RLock()
+	keys := make([][]byte, len(k.keys))
+	copy(keys, k.keys)
+	k.m.RUnlock()
+	return keys
+}
+
 // AddKey adds a (hex-encoded) key to the keychain
 func (k *Keychain) AddKey(newKey string) error {
 	key, err := hex.DecodeString(newKey)
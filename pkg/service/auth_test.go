@@ -108,6 +108,20 @@ func TestKeychain(t *testing.T) {
 				So(err, ShouldEqual, ErrInvalidKey)
 			})
 		})
+
+		Convey("When replacing the keys", func() {
+			c.AddBinKey([]byte{'o', 'l', 'd'})
+			c.SetKeys([][]byte{{'n', 'e', 'w'}, {'p', 'r', 'e', 'v'}})
+
+			Convey("Only the new keys should be in the keychain", func() {
+				So(c.KeyCount(), ShouldEqual, 2)
+			})
+			Convey("The first key should be preferred", func() {
+				key, err := c.BinKey()
+				So(err, ShouldBeNil)
+				So(key, ShouldResemble, []byte{'n', 'e', 'w'})
+			})
+		})
 	})
 }
 
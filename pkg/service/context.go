@@ -4,10 +4,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/netutil"
+	"github.com/fritzpay/paymentd/pkg/paymentd/authkey"
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
 	"golang.org/x/net/context"
 	"gopkg.in/inconshreveable/log15.v2"
 )
@@ -22,12 +28,16 @@ const (
 type Context struct {
 	context.Context
 
-	cfg config.Config
+	// mu guards the config and the rate limits, which are replaced on reload
+	mu  sync.RWMutex
+	cfg *config.Config
 	log log15.Logger
 
 	apiKeychain *Keychain
 	webKeychain *Keychain
 
+	signingKeys *SigningKeyring
+
 	principalDBWrite    *sql.DB
 	principalDBReadOnly *sql.DB
 
@@ -35,13 +45,30 @@ type Context struct {
 	paymentDBReadOnly *sql.DB
 
 	rateLimit chan struct{}
+
+	trustedProxies netutil.Networks
+
+	rateLimits RateLimits
+
+	metrics *Metrics
+
+	callbackBacklog CallbackBacklog
+}
+
+// RateLimits holds the token bucket limiters of a context
+//
+// Disabled limits are nil limiters, which allow all requests.
+type RateLimits struct {
+	ProjectKey *ratelimit.Limiter
+	Project    *ratelimit.Limiter
+	ClientIP   *ratelimit.Limiter
 }
 
 // Value wraps the Context.Value
 func (ctx *Context) Value(key interface{}) interface{} {
 	switch key {
 	case "cfg":
-		return ctx.cfg
+		return *ctx.Config()
 	case "log":
 		return ctx.log
 	case "keychain":
@@ -55,25 +82,13 @@ func (ctx *Context) Value(key interface{}) interface{} {
 	}
 }
 
-// SetValue creates a new service context with the given value
-func (ctx *Context) WithValue(key, value interface{}) *Context {
-	return &Context{
-		Context:             context.WithValue(ctx.Context, key, value),
-		cfg:                 ctx.cfg,
-		log:                 ctx.log,
-		apiKeychain:         ctx.apiKeychain,
-		webKeychain:         ctx.webKeychain,
-		principalDBWrite:    ctx.principalDBWrite,
-		principalDBReadOnly: ctx.principalDBReadOnly,
-		paymentDBWrite:      ctx.paymentDBWrite,
-		paymentDBReadOnly:   ctx.paymentDBReadOnly,
-		rateLimit:           ctx.rateLimit,
-	}
-}
-
 // Config returns the config.Config associated with the context
+//
+// The config is replaced on reload, so it should not be retained.
 func (ctx *Context) Config() *config.Config {
-	return &ctx.cfg
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.cfg
 }
 
 // Log returns the log15.Logger associated with the context
@@ -154,6 +169,12 @@ func (ctx *Context) SetPaymentDB(w, ro *sql.DB) {
 	ctx.paymentDBWrite, ctx.paymentDBReadOnly = w, ro
 }
 
+// SigningKeys returns the keyring with which paymentd signs messages for project keys
+// with public keys
+func (ctx *Context) SigningKeys() *SigningKeyring {
+	return ctx.signingKeys
+}
+
 func (ctx *Context) registerKeychain(kc *Keychain, keys []string) error {
 	var err error
 	for _, k := range keys {
@@ -174,9 +195,73 @@ func (ctx *Context) registerKeychainFromConfig() error {
 	if err != nil {
 		return err
 	}
+	seeds, err := signingKeysFromConfig(*ctx.Config())
+	if err != nil {
+		return err
+	}
+	for _, seed := range seeds {
+		err = ctx.signingKeys.AddKey(seed)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// TrustedProxies returns the networks of proxies which are trusted to pass on
+// client addresses
+func (ctx *Context) TrustedProxies() netutil.Networks {
+	return ctx.trustedProxies
+}
+
+// ClientIP returns the IP of the client which sent the request, resolved through
+// the trusted proxies
+func (ctx *Context) ClientIP(r *http.Request) net.IP {
+	return netutil.ClientIP(r, ctx.trustedProxies)
+}
+
+// EnableKeychainStores switches the API and the web keychain to keys stored in the
+// payment database
+//
+// The keys are loaded immediately and refreshed in the configured interval until the
+// context is done.
+func (ctx *Context) EnableKeychainStores() error {
+	cfg := ctx.Config().Keychain
+	rotation, err := cfg.RotationInterval.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid keychain rotation interval: %v", err)
+	}
+	maxAge, err := cfg.MaxAge.Duration()
+	if err != nil {
+		return fmt.Errorf("invalid keychain max age: %v", err)
+	}
+	refresh, err := cfg.RefreshInterval.Duration()
+	if err != nil || refresh <= 0 {
+		return fmt.Errorf("invalid keychain refresh interval: %s", cfg.RefreshInterval)
+	}
+	stores := []*KeychainStore{
+		NewKeychainStore(ctx.PaymentDB(), authkey.KeychainAPI, ctx.apiKeychain, rotation, maxAge, ctx.log),
+		NewKeychainStore(ctx.PaymentDB(), authkey.KeychainWeb, ctx.webKeychain, rotation, maxAge, ctx.log),
+	}
+	for _, s := range stores {
+		err = s.Refresh()
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range stores {
+		go s.Run(ctx, refresh)
+	}
+	return nil
+}
+
+// RateLimits returns the token bucket limiters of the context
+func (ctx *Context) RateLimits() RateLimits {
+	ctx.mu.RLock()
+	defer ctx.mu.RUnlock()
+	return ctx.rateLimits
+}
+
 // RateLimitHandler wraps the given handler with a context-wide rate limit
 //
 // The capacity of the ctx.rateLimit buffered channel determines the maximum
@@ -191,6 +276,43 @@ func (ctx *Context) RateLimitHandler(parent http.Handler) http.Handler {
 	})
 }
 
+// ClientRateLimitHandler wraps the given handler with the rate limit per client IP
+//
+// Limited requests are passed to reject along with the duration after which the
+// client may retry.
+func (ctx *Context) ClientRateLimitHandler(parent http.Handler, reject func(w http.ResponseWriter, wait time.Duration)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := ctx.RateLimits().ClientIP.Allow(ctx.ClientIP(r).String()); !ok {
+			ctx.log.Warn("client rate limit exceeded", log15.Ctx{
+				"clientIP": ctx.ClientIP(r),
+				"path":     r.URL.Path,
+			})
+			reject(w, wait)
+			return
+		}
+		parent.ServeHTTP(w, r)
+	})
+}
+
+// AllowProject takes a token from the rate limit bucket of the given project
+//
+// The burst size of the bucket can be overridden in the project config.
+func (r RateLimits) AllowProject(projectID int64, burst sql.NullInt64) (bool, time.Duration) {
+	return r.Project.AllowBurst(strconv.FormatInt(projectID, 10), burstOverride(burst))
+}
+
+// AllowProjectKey takes a token from the rate limit bucket of the given project key
+func (r RateLimits) AllowProjectKey(key string, burst sql.NullInt64) (bool, time.Duration) {
+	return r.ProjectKey.AllowBurst(key, burstOverride(burst))
+}
+
+func burstOverride(burst sql.NullInt64) int {
+	if !burst.Valid {
+		return 0
+	}
+	return int(burst.Int64)
+}
+
 // NewContext creates a new service context for use in the service pkg
 func NewContext(ctx context.Context, cfg config.Config, log log15.Logger) (*Context, error) {
 	if log == nil {
@@ -198,19 +320,26 @@ func NewContext(ctx context.Context, cfg config.Config, log log15.Logger) (*Cont
 	}
 	c := &Context{
 		Context:     ctx,
-		cfg:         cfg,
+		cfg:         &cfg,
 		log:         log,
 		apiKeychain: NewKeychain(),
 		webKeychain: NewKeychain(),
+		signingKeys: NewSigningKeyring(),
 	}
 	err := c.registerKeychainFromConfig()
 	if err != nil {
 		return nil, fmt.Errorf("error loading keys from config: %v", err)
 	}
+	c.trustedProxies, err = netutil.ParseNetworks(cfg.API.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("error loading trusted proxies from config: %v", err)
+	}
 	if cfg.Database.MaxOpenConns <= 0 {
 		return nil, fmt.Errorf("invalid value for max open db conns %d", cfg.Database.MaxOpenConns)
 	}
+	c.rateLimits = c.rateLimits.update(cfg)
 	c.rateLimit = make(chan struct{}, cfg.Database.MaxOpenConns)
+	c.metrics = newMetrics(c)
 	for i := 0; i < cfg.Database.MaxOpenConns; i++ {
 		c.rateLimit <- struct{}{}
 	}
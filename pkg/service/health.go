@@ -0,0 +1,268 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// LivenessPath is the path of the liveness endpoint
+	LivenessPath = "/healthz"
+	// ReadinessPath is the path of the readiness endpoint
+	ReadinessPath = "/readyz"
+
+	// HealthCheckTimeout is the maximum duration of a single readiness check
+	HealthCheckTimeout = 2 * time.Second
+)
+
+// health states
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+var (
+	ErrHealthCheckTimeout = errors.New("health check timed out")
+)
+
+// HealthCheck is a named readiness check
+//
+// A nil error means the checked dependency is ready.
+type HealthCheck struct {
+	Name  string
+	Check func() error
+}
+
+// HealthCheckResult is the result of a HealthCheck
+type HealthCheckResult struct {
+	Name     string
+	Status   string
+	Error    string `json:",omitempty"`
+	Duration string `json:",omitempty"`
+}
+
+// HealthResponse is the JSON response of the health endpoints
+type HealthResponse struct {
+	Status    string
+	Checks    []HealthCheckResult   `json:",omitempty"`
+	Callbacks *CallbackBacklogState `json:",omitempty"`
+}
+
+// DBHealthChecks returns the checks pinging all configured database connections
+func (ctx *Context) DBHealthChecks() []HealthCheck {
+	dbs := []struct {
+		name string
+		db   *sql.DB
+	}{
+		{"db.principal.write", ctx.principalDBWrite},
+		{"db.principal.readonly", ctx.principalDBReadOnly},
+		{"db.payment.write", ctx.paymentDBWrite},
+		{"db.payment.readonly", ctx.paymentDBReadOnly},
+	}
+	checks := make([]HealthCheck, 0, len(dbs))
+	for _, db := range dbs {
+		if db.db == nil {
+			continue
+		}
+		checks = append(checks, HealthCheck{Name: db.name, Check: db.db.Ping})
+	}
+	return checks
+}
+
+// DirHealthCheck returns a check whether the given directory is readable
+func DirHealthCheck(name, dir string) HealthCheck {
+	return HealthCheck{
+		Name: name,
+		Check: func() error {
+			f, err := os.Open(dir)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			inf, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			if !inf.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			_, err = f.Readdirnames(1)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// LivenessHandler returns the handler of the liveness endpoint
+//
+// It reports whether the process is able to serve requests at all and does not check
+// any dependencies.
+func (ctx *Context) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, ctx.log, http.StatusOK, HealthResponse{Status: HealthOK})
+	})
+}
+
+// ReadinessHandler returns the handler of the public readiness endpoint
+//
+// It runs the database checks and the given checks concurrently. If any check fails,
+// it responds with 503 Service Unavailable. Only the names and states of the checks are
+// reported, the errors are logged. Requests are subject to the rate limit per client
+// IP, since every request pings the databases.
+func (ctx *Context) ReadinessHandler(checks ...HealthCheck) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, resp := ctx.readiness(checks)
+		for i := range resp.Checks {
+			resp.Checks[i].Error = ""
+			resp.Checks[i].Duration = ""
+		}
+		writeHealth(w, ctx.log, status, resp)
+	})
+	return ctx.ClientRateLimitHandler(h, func(w http.ResponseWriter, wait time.Duration) {
+		w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+		writeHealth(w, ctx.log, http.StatusTooManyRequests, HealthResponse{Status: HealthFail})
+	})
+}
+
+// ReadinessDetailsHandler returns the handler of the readiness endpoint of the
+// metrics server
+//
+// Like the ReadinessHandler, but it reports the errors and durations of the checks and
+// the state of the callback delivery backlog. The backlog does not affect the
+// readiness.
+func (ctx *Context) ReadinessDetailsHandler(checks ...HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, resp := ctx.readiness(checks)
+		callbacks := ctx.callbackBacklog.State()
+		resp.Callbacks = &callbacks
+		writeHealth(w, ctx.log, status, resp)
+	})
+}
+
+// readiness runs the database checks and the given checks and logs failed checks
+func (ctx *Context) readiness(checks []HealthCheck) (int, HealthResponse) {
+	all := append(ctx.DBHealthChecks(), checks...)
+	resp := HealthResponse{
+		Status: HealthOK,
+		Checks: RunHealthChecks(all),
+	}
+	status := http.StatusOK
+	for _, res := range resp.Checks {
+		if res.Status != HealthOK {
+			resp.Status = HealthFail
+			status = http.StatusServiceUnavailable
+			ctx.log.Warn("readiness check failed", log15.Ctx{
+				"check": res.Name,
+				"err":   res.Error,
+			})
+		}
+	}
+	return status, resp
+}
+
+// RunHealthChecks runs the given checks concurrently
+//
+// Checks which do not finish within the HealthCheckTimeout fail.
+func RunHealthChecks(checks []HealthCheck) []HealthCheckResult {
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			c := make(chan error, 1)
+			go func() { c <- check.Check() }()
+			var err error
+			select {
+			case err = <-c:
+			case <-time.After(HealthCheckTimeout):
+				err = ErrHealthCheckTimeout
+			}
+			results[i] = HealthCheckResult{
+				Name:     check.Name,
+				Status:   HealthOK,
+				Duration: time.Since(start).String(),
+			}
+			if err != nil {
+				results[i].Status = HealthFail
+				results[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+	return results
+}
+
+func writeHealth(w http.ResponseWriter, log log15.Logger, status int, resp HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.Error("error writing health response", log15.Ctx{"err": err})
+	}
+}
+
+// CallbackBacklog tracks the delivery of callback notifications
+type CallbackBacklog struct {
+	mu    sync.Mutex
+	state CallbackBacklogState
+}
+
+// CallbackBacklogState is the state of the callback deliveries
+type CallbackBacklogState struct {
+	// Number of callbacks being delivered
+	Pending int
+	// Number of failed deliveries since the last successful delivery
+	ConsecutiveFailures int
+	LastSuccess         *time.Time `json:",omitempty"`
+	LastFailure         *time.Time `json:",omitempty"`
+}
+
+// Begin registers a pending delivery
+func (b *CallbackBacklog) Begin() {
+	b.mu.Lock()
+	b.state.Pending++
+	b.mu.Unlock()
+}
+
+// Done registers the result of a pending delivery
+func (b *CallbackBacklog) Done(delivered bool) {
+	now := time.Now()
+	b.mu.Lock()
+	b.state.Pending--
+	if delivered {
+		b.state.ConsecutiveFailures = 0
+		b.state.LastSuccess = &now
+	} else {
+		b.state.ConsecutiveFailures++
+		b.state.LastFailure = &now
+	}
+	b.mu.Unlock()
+}
+
+// State returns the current state of the deliveries
+func (b *CallbackBacklog) State() CallbackBacklogState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// CallbackBacklog returns the backlog of callback deliveries of the context
+func (ctx *Context) CallbackBacklog() *CallbackBacklog {
+	return &ctx.callbackBacklog
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealth(t *testing.T) {
+	Convey("Given a new service context", t, WithContext(func(ctx *Context) {
+		r, err := http.NewRequest("GET", ReadinessPath, nil)
+		So(err, ShouldBeNil)
+
+		Convey("The liveness endpoint should report ok", func() {
+			w := httptest.NewRecorder()
+			ctx.LivenessHandler().ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			resp := HealthResponse{}
+			So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+			So(resp.Status, ShouldEqual, HealthOK)
+		})
+
+		Convey("When all readiness checks pass", func() {
+			dir := os.TempDir()
+			w := httptest.NewRecorder()
+			ctx.ReadinessHandler(DirHealthCheck("dir.tmp", dir)).ServeHTTP(w, r)
+
+			Convey("It should report ready", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				resp := HealthResponse{}
+				So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+				So(resp.Status, ShouldEqual, HealthOK)
+				So(len(resp.Checks), ShouldEqual, 1)
+				So(resp.Checks[0].Name, ShouldEqual, "dir.tmp")
+				So(resp.Callbacks, ShouldBeNil)
+			})
+		})
+
+		Convey("When a readiness check fails", func() {
+			missing := filepath.Join(os.TempDir(), "paymentd-health-test-missing")
+			failing := HealthCheck{Name: "failing", Check: func() error { return errors.New("broken") }}
+			w := httptest.NewRecorder()
+			ctx.ReadinessHandler(DirHealthCheck("dir.missing", missing), failing).ServeHTTP(w, r)
+
+			Convey("It should report unavailable with the failed checks", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				resp := HealthResponse{}
+				So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+				So(resp.Status, ShouldEqual, HealthFail)
+				So(len(resp.Checks), ShouldEqual, 2)
+				So(resp.Checks[0].Status, ShouldEqual, HealthFail)
+			})
+			Convey("It should not report the errors", func() {
+				So(w.Body.String(), ShouldNotContainSubstring, "broken")
+				So(w.Body.String(), ShouldNotContainSubstring, missing)
+			})
+		})
+
+		Convey("When a readiness check fails on the detailed endpoint", func() {
+			failing := HealthCheck{Name: "failing", Check: func() error { return errors.New("broken") }}
+			w := httptest.NewRecorder()
+			ctx.ReadinessDetailsHandler(failing).ServeHTTP(w, r)
+
+			Convey("It should report the errors and the callback backlog", func() {
+				So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+				resp := HealthResponse{}
+				So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+				So(resp.Checks[0].Error, ShouldEqual, "broken")
+				So(resp.Checks[0].Duration, ShouldNotEqual, "")
+				So(resp.Callbacks, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When callbacks are delivered", func() {
+			b := ctx.CallbackBacklog()
+			b.Begin()
+			b.Begin()
+			b.Done(false)
+
+			Convey("The backlog should report the pending and failed deliveries", func() {
+				state := b.State()
+				So(state.Pending, ShouldEqual, 1)
+				So(state.ConsecutiveFailures, ShouldEqual, 1)
+				So(state.LastFailure, ShouldNotBeNil)
+
+				b.Done(true)
+				state = b.State()
+				So(state.Pending, ShouldEqual, 0)
+				So(state.ConsecutiveFailures, ShouldEqual, 0)
+				So(state.LastSuccess, ShouldNotBeNil)
+			})
+		})
+	}))
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/authkey"
+	"golang.org/x/net/context"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// KeychainStore keeps a keychain in sync with the keys stored in the database
+//
+// A new primary key is generated when the current primary key is older than the
+// rotation interval. Keys from the config are kept after the stored keys, so
+// authorization containers issued before switching to the database stay valid.
+type KeychainStore struct {
+	db       *sql.DB
+	name     string
+	keychain *Keychain
+	static   [][]byte
+
+	rotationInterval time.Duration
+	maxAge           time.Duration
+
+	log log15.Logger
+}
+
+// NewKeychainStore creates a store for the keychain with the given name
+func NewKeychainStore(db *sql.DB, name string, kc *Keychain, rotationInterval, maxAge time.Duration, log log15.Logger) *KeychainStore {
+	return &KeychainStore{
+		db:               db,
+		name:             name,
+		keychain:         kc,
+		static:           kc.binKeys(),
+		rotationInterval: rotationInterval,
+		maxAge:           maxAge,
+		log: log.New(log15.Ctx{
+			"keychain": name,
+		}),
+	}
+}
+
+// Refresh loads the active keys from the database into the keychain and rotates the
+// primary key if it is due
+func (s *KeychainStore) Refresh() error {
+	keys, err := authkey.ActiveKeysDB(s.db, s.name, s.maxAge)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 || time.Since(keys[0].Created) >= s.rotationInterval {
+		err = s.rotate(keys)
+		if err == authkey.ErrGenerationConflict {
+			s.log.Info("key was rotated by another node")
+		} else if err != nil {
+			return err
+		}
+		keys, err = authkey.ActiveKeysDB(s.db, s.name, s.maxAge)
+		if err != nil {
+			return err
+		}
+	}
+	binKeys := make([][]byte, 0, len(keys)+len(s.static))
+	for _, k := range keys {
+		binKeys = append(binKeys, k.Key)
+	}
+	binKeys = append(binKeys, s.static...)
+	s.keychain.SetKeys(binKeys)
+	return nil
+}
+
+func (s *KeychainStore) rotate(keys []*authkey.Key) error {
+	k := &authkey.Key{
+		Keychain:   s.name,
+		Generation: 1,
+		Created:    time.Now(),
+		Key:        make([]byte, defaultKeySize),
+	}
+	if len(keys) > 0 {
+		k.Generation = keys[0].Generation + 1
+	}
+	_, err := rand.Read(k.Key)
+	if err != nil {
+		return err
+	}
+	err = authkey.InsertKeyDB(s.db, k)
+	if err != nil {
+		return err
+	}
+	s.log.Info("rotated primary key", log15.Ctx{"generation": k.Generation})
+	return nil
+}
+
+// Run refreshes the keychain in the given interval until the context is done
+func (s *KeychainStore) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			err := s.Refresh()
+			if err != nil {
+				s.log.Error("error refreshing keychain", log15.Ctx{"err": err})
+			}
+		}
+	}
+}
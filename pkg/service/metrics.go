@@ -0,0 +1,241 @@
+package service
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/metrics"
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
+)
+
+// MetricsPath is the path under which the metrics are served
+const MetricsPath = "/metrics"
+
+// Metrics holds the metrics of a service context
+type Metrics struct {
+	Registry *metrics.Registry
+
+	// HTTP requests by service, route, method and status
+	HTTPRequests *metrics.CounterVec
+	HTTPDuration *metrics.HistogramVec
+
+	// Payment intents by intent (the requested payment status) and outcome
+	Intents *metrics.CounterVec
+
+	// Callback deliveries by result
+	CallbackDeliveries *metrics.CounterVec
+	CallbackDuration   *metrics.HistogramVec
+
+	// Calls of provider drivers to payment service providers by provider and
+	// operation
+	ProviderDuration *metrics.HistogramVec
+	ProviderErrors   *metrics.CounterVec
+}
+
+// results of callback deliveries
+const (
+	CallbackResultSuccess   = "success"
+	CallbackResultHTTPError = "http_error"
+	CallbackResultError     = "error"
+)
+
+// outcomes of payment intents
+const (
+	IntentOutcomeAccepted   = "accepted"
+	IntentOutcomeRejected   = "rejected"
+	IntentOutcomeNotAllowed = "not_allowed"
+	IntentOutcomeCancelled  = "cancelled"
+	IntentOutcomeTimeout    = "timeout"
+)
+
+func newMetrics(ctx *Context) *Metrics {
+	r := metrics.NewRegistry()
+	m := &Metrics{
+		Registry: r,
+		HTTPRequests: r.NewCounterVec("paymentd_http_requests_total",
+			"Number of HTTP requests.",
+			"service", "route", "method", "status"),
+		HTTPDuration: r.NewHistogramVec("paymentd_http_request_duration_seconds",
+			"Duration of HTTP requests in seconds.",
+			nil, "service", "route", "method", "status"),
+		Intents: r.NewCounterVec("paymentd_payment_intents_total",
+			"Number of payment intents.",
+			"intent", "outcome"),
+		CallbackDeliveries: r.NewCounterVec("paymentd_callback_deliveries_total",
+			"Number of callback notification deliveries.",
+			"result"),
+		CallbackDuration: r.NewHistogramVec("paymentd_callback_duration_seconds",
+			"Duration of callback notification deliveries in seconds.",
+			nil, "result"),
+		ProviderDuration: r.NewHistogramVec("paymentd_provider_request_duration_seconds",
+			"Duration of requests to payment service providers in seconds.",
+			nil, "provider", "operation"),
+		ProviderErrors: r.NewCounterVec("paymentd_provider_errors_total",
+			"Number of failed requests to payment service providers.",
+			"provider", "operation"),
+	}
+
+	dbLabels := []string{"db", "mode"}
+	r.NewGaugeFunc("paymentd_db_max_open_connections",
+		"Maximum number of open database connections.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.NewGaugeFunc("paymentd_db_open_connections",
+		"Number of open database connections.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.NewGaugeFunc("paymentd_db_in_use_connections",
+		"Number of database connections in use.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.NewGaugeFunc("paymentd_db_idle_connections",
+		"Number of idle database connections.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.NewCounterFunc("paymentd_db_wait_count_total",
+		"Number of waits for a database connection.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.NewCounterFunc("paymentd_db_wait_duration_seconds_total",
+		"Time spent waiting for a database connection in seconds.",
+		dbLabels, ctx.dbStats(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+
+	limitLabels := []string{"limit"}
+	r.NewCounterFunc("paymentd_rate_limit_rejected_total",
+		"Number of requests rejected by a rate limit.",
+		limitLabels, ctx.rateLimitStats(func(l *ratelimit.Limiter) float64 { return float64(l.Rejected()) }))
+	r.NewGaugeFunc("paymentd_rate_limit_buckets",
+		"Number of tracked rate limit buckets.",
+		limitLabels, ctx.rateLimitStats(func(l *ratelimit.Limiter) float64 { return float64(l.Len()) }))
+	r.NewGaugeFunc("paymentd_concurrent_requests",
+		"Number of requests holding a slot of the concurrency limit.",
+		nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(cap(ctx.rateLimit) - len(ctx.rateLimit))}}
+		})
+	r.NewGaugeFunc("paymentd_concurrent_requests_limit",
+		"Maximum number of concurrent requests.",
+		nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(cap(ctx.rateLimit))}}
+		})
+	return m
+}
+
+// dbStats returns a metric function collecting a value of the pool stats of every
+// database
+func (ctx *Context) dbStats(f func(sql.DBStats) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		dbs := []struct {
+			db, mode string
+			conn     *sql.DB
+		}{
+			{"principal", "write", ctx.principalDBWrite},
+			{"principal", "readonly", ctx.principalDBReadOnly},
+			{"payment", "write", ctx.paymentDBWrite},
+			{"payment", "readonly", ctx.paymentDBReadOnly},
+		}
+		samples := make([]metrics.Sample, 0, len(dbs))
+		for _, db := range dbs {
+			if db.conn == nil {
+				continue
+			}
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{db.db, db.mode},
+				Value:       f(db.conn.Stats()),
+			})
+		}
+		return samples
+	}
+}
+
+// rateLimitStats returns a metric function collecting a value of every enabled rate
+// limit
+func (ctx *Context) rateLimitStats(f func(*ratelimit.Limiter) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		rateLimits := ctx.RateLimits()
+		limits := []struct {
+			name string
+			l    *ratelimit.Limiter
+		}{
+			{"project_key", rateLimits.ProjectKey},
+			{"project", rateLimits.Project},
+			{"client_ip", rateLimits.ClientIP},
+		}
+		samples := make([]metrics.Sample, 0, len(limits))
+		for _, limit := range limits {
+			if limit.l == nil {
+				continue
+			}
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{limit.name},
+				Value:       f(limit.l),
+			})
+		}
+		return samples
+	}
+}
+
+// Metrics returns the metrics of the context
+func (ctx *Context) Metrics() *Metrics {
+	return ctx.metrics
+}
+
+// MetricsHandler returns the handler of the metrics service
+//
+// It also serves the detailed readiness endpoint, see ReadinessDetailsHandler.
+func (ctx *Context) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, ctx.metrics.Registry.Handler())
+	mux.Handle(ReadinessPath, ctx.ReadinessDetailsHandler())
+	return mux
+}
+
+// InstrumentHandler wraps the given handler and records the requests to it
+//
+// The route should be the path template of the route, so requests to the same route
+// with different parameters are recorded together.
+func (ctx *Context) InstrumentHandler(service, route string, parent http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		parent.ServeHTTP(sw, r)
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := []string{service, route, r.Method, strconv.Itoa(status)}
+		ctx.metrics.HTTPRequests.Inc(labels...)
+		ctx.metrics.HTTPDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// ObserveProvider records a request of a provider driver to its payment service
+// provider, which started at the given time
+func (m *Metrics) ObserveProvider(provider, operation string, start time.Time, err error) {
+	m.ProviderDuration.Observe(time.Since(start).Seconds(), provider, operation)
+	if err != nil {
+		m.ProviderErrors.Inc(provider, operation)
+	}
+}
+
+// ObserveCallback records a callback delivery, which started at the given time
+func (m *Metrics) ObserveCallback(result string, start time.Time) {
+	m.CallbackDeliveries.Inc(result)
+	m.CallbackDuration.Observe(time.Since(start).Seconds(), result)
+}
+
+// statusWriter records the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
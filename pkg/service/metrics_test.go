@@ -0,0 +1,39 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetrics(t *testing.T) {
+	Convey("Given a new service context", t, WithContext(func(ctx *Context) {
+
+		Convey("When a request to an instrumented handler is made", func() {
+			h := ctx.InstrumentHandler("api", "/v1/payment/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			r, err := http.NewRequest("GET", "/v1/payment/123", nil)
+			So(err, ShouldBeNil)
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			Convey("It should be recorded by route and status", func() {
+				So(ctx.Metrics().HTTPRequests.Value("api", "/v1/payment/{id}", "GET", "404"), ShouldEqual, 1)
+				So(ctx.Metrics().HTTPDuration.Count("api", "/v1/payment/{id}", "GET", "404"), ShouldEqual, 1)
+			})
+
+			Convey("The metrics handler should serve it", func() {
+				w := httptest.NewRecorder()
+				r, err := http.NewRequest("GET", MetricsPath, nil)
+				So(err, ShouldBeNil)
+				ctx.MetricsHandler().ServeHTTP(w, r)
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldContainSubstring,
+					`paymentd_http_requests_total{service="api",route="/v1/payment/{id}",method="GET",status="404"} 1`)
+				So(w.Body.String(), ShouldContainSubstring, "paymentd_concurrent_requests_limit 10")
+			})
+		})
+	}))
+}
@@ -29,23 +29,11 @@ func (s *Service) notify(paymentTx *payment.PaymentTransaction) error {
 		"method":    "notify",
 		"projectID": paymentTx.Payment.ProjectID(),
 		"paymentID": paymentTx.Payment.ID(),
+		"requestID": paymentTx.RequestID.String,
 	})
-	var callback Callbacker
-	if CanCallback(&paymentTx.Payment.Config) {
-		callback = &paymentTx.Payment.Config
-	} else {
-		pr, err := project.ProjectByIDDB(s.ctx.PrincipalDB(service.ReadOnly), paymentTx.Payment.ProjectID())
-		if err != nil {
-			if err == project.ErrProjectNotFound {
-				log.Crit("payment with invalid project", log15.Ctx{"projectID": paymentTx.Payment.ProjectID()})
-				return ErrInternal
-			}
-			log.Error("error retrieving project", log15.Ctx{"err": err})
-			return ErrDB
-		}
-		if CanCallback(pr.Config) {
-			callback = pr.Config
-		}
+	callback, err := s.callback(log, paymentTx.Payment)
+	if err != nil {
+		return err
 	}
 	if callback != nil {
 		s.doNotify(callback, paymentTx)
@@ -55,7 +43,57 @@ func (s *Service) notify(paymentTx *payment.PaymentTransaction) error {
 	return nil
 }
 
-func (s *Service) doNotify(c Callbacker, paymentTx *payment.PaymentTransaction) {
+// Notify performs the callback notification of the given payment transaction
+//
+// Unlike the notification on committed intents, it returns an error if the
+// notification could not be delivered. It can be used to resend notifications.
+func (s *Service) Notify(paymentTx *payment.PaymentTransaction) error {
+	log := s.log.New(log15.Ctx{
+		"method":    "Notify",
+		"projectID": paymentTx.Payment.ProjectID(),
+		"paymentID": paymentTx.Payment.ID(),
+		"requestID": paymentTx.RequestID.String,
+	})
+	callback, err := s.callback(log, paymentTx.Payment)
+	if err != nil {
+		return err
+	}
+	if callback == nil {
+		log.Warn("payment without configured callback")
+		return ErrPaymentCallbackConfig
+	}
+	return s.doNotify(callback, paymentTx)
+}
+
+// callback returns the callback config of the payment or, if the payment has no
+// callback configured, the one of its project
+//
+// It returns nil if neither has a callback configured.
+func (s *Service) callback(log log15.Logger, p *payment.Payment) (Callbacker, error) {
+	if CanCallback(&p.Config) {
+		return &p.Config, nil
+	}
+	pr, err := project.ProjectByIDDB(s.ctx.PrincipalDB(service.ReadOnly), p.ProjectID())
+	if err != nil {
+		if err == project.ErrProjectNotFound {
+			log.Crit("payment with invalid project", log15.Ctx{"projectID": p.ProjectID()})
+			return nil, ErrInternal
+		}
+		log.Error("error retrieving project", log15.Ctx{"err": err})
+		return nil, ErrDB
+	}
+	if CanCallback(pr.Config) {
+		return pr.Config, nil
+	}
+	return nil, nil
+}
+
+func (s *Service) doNotify(c Callbacker, paymentTx *payment.PaymentTransaction) error {
+	var delivered bool
+	s.ctx.CallbackBacklog().Begin()
+	defer func() {
+		s.ctx.CallbackBacklog().Done(delivered)
+	}()
 	cbURL, cbAPIVersion, cbProjectKey := c.CallbackConfig()
 	log := s.log.New(log15.Ctx{
 		"method":                      "doNotify",
@@ -65,73 +103,92 @@ func (s *Service) doNotify(c Callbacker, paymentTx *payment.PaymentTransaction)
 		"callbackURL":                 cbURL,
 		"callbackAPIVersion":          cbAPIVersion,
 		"callbackProjectKey":          cbProjectKey,
+		"requestID":                   paymentTx.RequestID.String,
 	})
 	log.Info("notifying...")
 	projectKey, err := project.ProjectKeyByKeyDB(s.ctx.PrincipalDB(service.ReadOnly), cbProjectKey)
 	if err != nil {
 		if err == project.ErrProjectKeyNotFound {
 			log.Error("invalid project key")
-			return
+			return ErrPaymentCallbackConfig
 		}
 		log.Error("error retrieving project key", log15.Ctx{"err": err})
-		return
+		return ErrDB
 	}
 	if !projectKey.IsValid() {
 		log.Warn("cannot notify with invalid project key", log15.Ctx{"projectKey": projectKey})
-		return
+		return ErrPaymentCallbackConfig
+	}
+	if !projectKey.HasScope(project.ScopeCallbackSign) {
+		log.Warn("cannot notify with project key without callback scope", log15.Ctx{"projectKey": projectKey.Key})
+		return ErrPaymentCallbackConfig
 	}
 	// metadata
 	err = payment.PaymentMetadataDB(s.ctx.PaymentDB(service.ReadOnly), paymentTx.Payment)
 	if err != nil {
 		log.Error("error retrieving payment metadata", log15.Ctx{"err": err})
-		return
+		return ErrDB
 	}
 	// create new notification
 	notF, err := notification.NotificationByVersion(cbAPIVersion)
 	if err != nil {
 		log.Error("error retrieving notification by version", log15.Ctx{"err": err})
-		return
+		return ErrPaymentCallbackConfig
 	}
 	not, err := notF(s.EncodedPaymentID(paymentTx.Payment.PaymentID()), paymentTx.Payment)
 	if err != nil {
 		log.Error("error creating notification", log15.Ctx{"err": err})
-		return
+		return ErrInternal
 	}
 	// balance
 	tl, err := payment.PaymentTransactionsBeforeDB(s.ctx.PaymentDB(service.ReadOnly), paymentTx)
 	if err != nil {
 		log.Error("error retrieving transaction history", log15.Ctx{"err": err})
-		return
+		return ErrDB
 	}
 	not.SetTransactions(tl)
 	// signing
 	non, err := nonce.New()
 	if err != nil {
 		log.Error("error generating nonce", log15.Ctx{"err": err})
-		return
+		return ErrInternal
 	}
-	secret, err := projectKey.SecretBytes()
+	signer, err := s.Signer(projectKey)
 	if err != nil {
-		log.Error("error retrieving secret", log15.Ctx{"err": err})
-		return
+		log.Error("error retrieving signer", log15.Ctx{"err": err})
+		return ErrInternal
 	}
-	err = not.Sign(time.Now(), non.Nonce, secret)
+	err = not.SignWith(time.Now(), non.Nonce, signer)
 	if err != nil {
 		log.Error("error signing notification", log15.Ctx{"err": err})
-		return
+		return ErrInternal
 	}
 
 	req, err := http.NewRequest("POST", cbURL, not.Reader())
 	if err != nil {
 		log.Error("error creating HTTP request", log15.Ctx{"err": err})
-		return
+		return ErrPaymentCallbackConfig
 	}
 	req.Header.Set("User-Agent", not.Identification())
+	if paymentTx.RequestID.Valid {
+		req.Header.Set(service.RequestIDHeader, paymentTx.RequestID.String)
+	}
 	req.Close = true
+	start := time.Now()
 	res, err := s.cl.Do(req)
 	if err != nil {
+		s.ctx.Metrics().ObserveCallback(service.CallbackResultError, start)
 		log.Error("error on HTTP request", log15.Ctx{"err": err})
-	} else {
-		log.Info("notified", log15.Ctx{"HTTPStatusCode": res.StatusCode})
+		return ErrCallbackDelivery
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		s.ctx.Metrics().ObserveCallback(service.CallbackResultHTTPError, start)
+		log.Warn("callback failed", log15.Ctx{"HTTPStatusCode": res.StatusCode})
+		return ErrCallbackDelivery
 	}
+	delivered = true
+	s.ctx.Metrics().ObserveCallback(service.CallbackResultSuccess, start)
+	log.Info("notified", log15.Ctx{"HTTPStatusCode": res.StatusCode})
+	return nil
 }
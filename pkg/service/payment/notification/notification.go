@@ -20,6 +20,7 @@ type Notification interface {
 	service.Signable
 	SetTransactions(payment.PaymentTransactionList)
 	Sign(time.Time, string, []byte) error
+	SignWith(time.Time, string, service.Signer) error
 	Reader() io.ReadCloser
 	Identification() string
 }
@@ -40,6 +40,9 @@ type Notification struct {
 	Timestamp            int64             `json:",string"`
 	Nonce                string            `json:",omitempty"`
 	Signature            string            `json:",omitempty"`
+	// KeyId identifies the paymentd key pair if the notification was signed with
+	// Ed25519
+	KeyId string `json:",omitempty"`
 }
 
 func New(encodedPaymentID payment.PaymentID, p *payment.Payment) (*Notification, error) {
@@ -80,14 +83,21 @@ func (n *Notification) SetTransactions(tl payment.PaymentTransactionList) {
 	n.Balance = tl.Balance()
 }
 
+// Sign signs the notification with the HMAC of the given shared secret
 func (n *Notification) Sign(timestamp time.Time, nonce string, secret []byte) error {
+	return n.SignWith(timestamp, nonce, service.HMACSigner(secret))
+}
+
+// SignWith signs the notification with the given signer
+func (n *Notification) SignWith(timestamp time.Time, nonce string, signer service.Signer) error {
 	n.Timestamp = timestamp.Unix()
 	n.Nonce = nonce
-	sig, err := service.Sign(n, secret)
+	sig, err := signer.Sign(n)
 	if err != nil {
 		return err
 	}
 	n.Signature = hex.EncodeToString(sig)
+	n.KeyId = signer.KeyID()
 	return nil
 }
 
@@ -7,12 +7,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fritzpay/paymentd/pkg/dialect"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
 	"github.com/fritzpay/paymentd/pkg/paymentd/project"
 	"github.com/fritzpay/paymentd/pkg/server"
 	"github.com/fritzpay/paymentd/pkg/service"
-	"github.com/go-sql-driver/mysql"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -42,6 +42,14 @@ func (e errorID) Error() string {
 		return "intent timeout"
 	case ErrIntentNotAllowed:
 		return "intent not allowed"
+	case ErrPaymentMethodCurrency:
+		return "currency not supported by payment method"
+	case ErrPaymentMethodCountry:
+		return "country not supported by payment method"
+	case ErrPaymentMethodAmount:
+		return "amount out of payment method range"
+	case ErrCallbackDelivery:
+		return "callback delivery failed"
 	default:
 		return "unknown error"
 	}
@@ -70,6 +78,14 @@ const (
 	ErrIntentTimeout
 	// intent not allowed
 	ErrIntentNotAllowed
+	// currency not supported by payment method
+	ErrPaymentMethodCurrency
+	// country not supported by payment method
+	ErrPaymentMethodCountry
+	// amount out of payment method range
+	ErrPaymentMethodAmount
+	// callback delivery failed
+	ErrCallbackDelivery
 )
 
 const (
@@ -230,6 +246,21 @@ func (s *Service) DecodedPaymentID(id payment.PaymentID) payment.PaymentID {
 	return id
 }
 
+// Signer returns the signer for messages to the holder of the given project key
+//
+// Project keys with public keys get messages signed with the paymentd key pair,
+// all others with the shared secret.
+func (s *Service) Signer(pk *project.Projectkey) (service.Signer, error) {
+	if pk.Asymmetric() {
+		return s.ctx.SigningKeys().Current()
+	}
+	secret, err := pk.SigningSecretBytes(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return service.HMACSigner(secret), nil
+}
+
 // CreatePayment creates a new payment
 func (s *Service) CreatePayment(tx *sql.Tx, p *payment.Payment) error {
 	log := s.log.New(log15.Ctx{
@@ -253,13 +284,17 @@ func (s *Service) CreatePayment(tx *sql.Tx, p *payment.Payment) error {
 			})
 			return ErrPaymentCallbackConfig
 		}
+		if !callbackProjectKey.HasScope(project.ScopeCallbackSign) {
+			log.Error("callback project key not allowed for signing callbacks", log15.Ctx{
+				"callbackProjectKey": callbackProjectKey.Key,
+			})
+			return ErrPaymentCallbackConfig
+		}
 	}
 	err := payment.InsertPaymentTx(tx, p)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return ErrDBLockTimeout
 		}
 		_, existErr := payment.PaymentByProjectIDAndIdentTx(tx, p.ProjectID(), p.Ident)
 		if existErr != nil && existErr != payment.ErrPaymentNotFound {
@@ -284,6 +319,27 @@ func (s *Service) CreatePayment(tx *sql.Tx, p *payment.Payment) error {
 	return nil
 }
 
+// checkPaymentMethodLimits returns an error if the payment method cannot process the
+// payment due to its limits
+func checkPaymentMethodLimits(p *payment.Payment, meth *payment_method.Method) error {
+	var country string
+	if p.Config.Country.Valid {
+		country = p.Config.Country.String
+	}
+	switch meth.Limits.Check(p.Currency, country, p.Decimal()) {
+	case nil:
+		return nil
+	case payment_method.ErrLimitCurrency:
+		return ErrPaymentMethodCurrency
+	case payment_method.ErrLimitCountry:
+		return ErrPaymentMethodCountry
+	case payment_method.ErrLimitAmount:
+		return ErrPaymentMethodAmount
+	default:
+		return ErrInternal
+	}
+}
+
 // SetPaymentConfig sets/updates the payment configuration
 func (s *Service) SetPaymentConfig(tx *sql.Tx, p *payment.Payment) error {
 	log := s.log.New(log15.Ctx{"method": "SetPaymentConfig"})
@@ -291,10 +347,8 @@ func (s *Service) SetPaymentConfig(tx *sql.Tx, p *payment.Payment) error {
 		log = log.New(log15.Ctx{"paymentMethodID": p.Config.PaymentMethodID.Int64})
 		meth, err := payment_method.PaymentMethodByIDTx(tx, p.Config.PaymentMethodID.Int64)
 		if err != nil {
-			if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-				if mysqlErr.Number == 1213 {
-					return ErrDBLockTimeout
-				}
+			if dialect.IsDeadlock(err) {
+				return ErrDBLockTimeout
 			}
 			if err == payment_method.ErrPaymentMethodNotFound {
 				log.Warn(ErrPaymentMethodNotFound.Error())
@@ -311,13 +365,15 @@ func (s *Service) SetPaymentConfig(tx *sql.Tx, p *payment.Payment) error {
 			log.Warn(ErrPaymentMethodInactive.Error())
 			return ErrPaymentMethodInactive
 		}
+		if err = checkPaymentMethodLimits(p, meth); err != nil {
+			log.Warn(err.Error())
+			return err
+		}
 	}
 	err := payment.InsertPaymentConfigTx(tx, p)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return ErrDBLockTimeout
 		}
 		log.Error("error on insert payment config", log15.Ctx{"err": err})
 		return ErrDB
@@ -334,10 +390,8 @@ func (s *Service) SetPaymentMetadata(tx *sql.Tx, p *payment.Payment) error {
 	}
 	err := payment.InsertPaymentMetadataTx(tx, p)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return ErrDBLockTimeout
 		}
 		log.Error("error on insert payment metadata", log15.Ctx{"err": err})
 		return ErrDB
@@ -375,13 +429,16 @@ func (s *Service) IsInitialized(p *payment.Payment) bool {
 // If a callback method is configured for this payment/project, it will send a callback
 // notification
 func (s *Service) SetPaymentTransaction(tx *sql.Tx, paymentTx *payment.PaymentTransaction) error {
-	log := s.log.New(log15.Ctx{"method": "SetPaymentTransaction"})
+	log := s.log.New(log15.Ctx{
+		"method":    "SetPaymentTransaction",
+		"projectID": paymentTx.Payment.ProjectID(),
+		"paymentID": paymentTx.Payment.ID(),
+		"requestID": paymentTx.RequestID.String,
+	})
 	err := payment.InsertPaymentTransactionTx(tx, paymentTx)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return ErrDBLockTimeout
 		}
 		log.Error("error saving payment transaction", log15.Ctx{"err": err})
 		return ErrDB
@@ -404,6 +461,7 @@ func (s *Service) handleIntent(
 
 	if deadline, ok := s.ctx.Deadline(); ok {
 		if time.Now().Add(timeout).After(deadline) {
+			s.recordIntent(paymentTx, service.IntentOutcomeTimeout)
 			return nil, nil, ErrIntentTimeout
 		}
 	}
@@ -426,12 +484,14 @@ func (s *Service) handleIntent(
 		case <-s.ctx.Done():
 			close(done)
 			s.mIntent.RUnlock()
+			s.recordIntent(paymentTx, service.IntentOutcomeCancelled)
 			return nil, nil, s.ctx.Err()
 
 		// error received
 		case err := <-c:
 			close(done)
 			s.mIntent.RUnlock()
+			s.recordIntent(paymentTx, service.IntentOutcomeRejected)
 			return nil, nil, err
 
 		// continue
@@ -504,12 +564,25 @@ func (s *Service) handleIntent(
 	}
 	s.mIntent.RUnlock()
 
+	s.recordIntent(paymentTx, service.IntentOutcomeAccepted)
 	return paymentTx, commitFunc, nil
 }
 
+// recordIntent records the outcome of an intent in the metrics
+func (s *Service) recordIntent(paymentTx *payment.PaymentTransaction, outcome string) {
+	s.ctx.Metrics().Intents.Inc(paymentTx.Status.String(), outcome)
+}
+
+// intentNotAllowed records an intent which is not allowed for the current payment
+// status
+func (s *Service) intentNotAllowed(status payment.PaymentTransactionStatus) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	s.ctx.Metrics().Intents.Inc(status.String(), service.IntentOutcomeNotAllowed)
+	return nil, nil, ErrIntentNotAllowed
+}
+
 func (s *Service) IntentOpen(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
 	if !s.IsProcessablePayment(p) {
-		return nil, nil, ErrIntentNotAllowed
+		return s.intentNotAllowed(payment.PaymentStatusOpen)
 	}
 	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
 	if err != nil {
@@ -518,14 +591,23 @@ func (s *Service) IntentOpen(p *payment.Payment, timeout time.Duration) (*paymen
 	if !meth.Active() {
 		return nil, nil, ErrPaymentMethodInactive
 	}
+	if err = checkPaymentMethodLimits(p, meth); err != nil {
+		return nil, nil, err
+	}
 	paymentTx := p.NewTransaction(payment.PaymentStatusOpen)
 	paymentTx.Amount = paymentTx.Amount * -1
 	return s.handleIntent(p, paymentTx, timeout)
 }
 
+// isAwaitingPayment returns true if the payment is open or pending, i.e. the PSP
+// can still report it as failed
+func isAwaitingPayment(p *payment.Payment) bool {
+	return p.Status == payment.PaymentStatusOpen || p.Status == payment.PaymentStatusPending
+}
+
 func (s *Service) IntentCancel(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
 	if p.Status != payment.PaymentStatusOpen {
-		return nil, nil, ErrIntentNotAllowed
+		return s.intentNotAllowed(payment.PaymentStatusCancelled)
 	}
 	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
 	if err != nil {
@@ -541,7 +623,26 @@ func (s *Service) IntentCancel(p *payment.Payment, timeout time.Duration) (*paym
 
 func (s *Service) IntentPaid(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
 	if p.Status != payment.PaymentStatusOpen {
-		return nil, nil, ErrIntentNotAllowed
+		return s.intentNotAllowed(payment.PaymentStatusPaid)
+	}
+	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meth.Disabled() {
+		return nil, nil, ErrPaymentMethodDisabled
+	}
+	paymentTx := p.NewTransaction(payment.PaymentStatusPaid)
+	return s.handleIntent(p, paymentTx, timeout)
+}
+
+// IntentPendingPaid is the paid intent for drivers which set payments to pending
+// while the PSP processes them
+//
+// Unlike IntentPaid, it accepts pending payments as well as open payments.
+func (s *Service) IntentPendingPaid(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	if !isAwaitingPayment(p) {
+		return s.intentNotAllowed(payment.PaymentStatusPaid)
 	}
 	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
 	if err != nil {
@@ -556,7 +657,7 @@ func (s *Service) IntentPaid(p *payment.Payment, timeout time.Duration) (*paymen
 
 func (s *Service) IntentAuthorized(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
 	if p.Status != payment.PaymentStatusOpen {
-		return nil, nil, ErrIntentNotAllowed
+		return s.intentNotAllowed(payment.PaymentStatusAuthorized)
 	}
 	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
 	if err != nil {
@@ -570,6 +671,141 @@ func (s *Service) IntentAuthorized(p *payment.Payment, timeout time.Duration) (*
 	return s.handleIntent(p, paymentTx, timeout)
 }
 
+func (s *Service) IntentFailed(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	if !isAwaitingPayment(p) {
+		return s.intentNotAllowed(payment.PaymentStatusFailed)
+	}
+	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meth.Disabled() {
+		return nil, nil, ErrPaymentMethodDisabled
+	}
+	paymentTx := p.NewTransaction(payment.PaymentStatusFailed)
+	paymentTx.Amount = 0
+	return s.handleIntent(p, paymentTx, timeout)
+}
+
+func (s *Service) IntentError(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	if !isAwaitingPayment(p) {
+		return s.intentNotAllowed(payment.PaymentStatusError)
+	}
+	paymentTx := p.NewTransaction(payment.PaymentStatusError)
+	paymentTx.Amount = 0
+	return s.handleIntent(p, paymentTx, timeout)
+}
+
+func (s *Service) IntentChargeback(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	if p.Status != payment.PaymentStatusPaid {
+		return s.intentNotAllowed(payment.PaymentStatusChargeback)
+	}
+	paymentTx := p.NewTransaction(payment.PaymentStatusChargeback)
+	paymentTx.Amount = paymentTx.Amount * -1
+	return s.handleIntent(p, paymentTx, timeout)
+}
+
+func (s *Service) IntentRefund(p *payment.Payment, timeout time.Duration) (*payment.PaymentTransaction, CommitIntentFunc, error) {
+	if p.Status != payment.PaymentStatusPaid {
+		return s.intentNotAllowed(payment.PaymentStatusRefunded)
+	}
+	meth, err := payment_method.PaymentMethodByIDDB(s.ctx.PaymentDB(service.ReadOnly), p.Config.PaymentMethodID.Int64)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meth.Disabled() {
+		return nil, nil, ErrPaymentMethodDisabled
+	}
+	paymentTx := p.NewTransaction(payment.PaymentStatusRefunded)
+	paymentTx.Amount = paymentTx.Amount * -1
+	return s.handleIntent(p, paymentTx, timeout)
+}
+
+// isManuallyCancellable returns true if the payment can be cancelled manually, i.e.
+// it did not receive any funds and is not cancelled already
+func isManuallyCancellable(p *payment.Payment) bool {
+	switch p.Status {
+	case payment.PaymentStatusNone,
+		payment.PaymentStatusOpen,
+		payment.PaymentStatusPending,
+		payment.PaymentStatusAuthorized,
+		payment.PaymentStatusError:
+		return true
+	}
+	return false
+}
+
+// isManuallyPayable returns true if the payment can be marked paid manually, i.e.
+// it is initialized and did not receive any funds
+func isManuallyPayable(p *payment.Payment) bool {
+	switch p.Status {
+	case payment.PaymentStatusOpen,
+		payment.PaymentStatusPending,
+		payment.PaymentStatusAuthorized,
+		payment.PaymentStatusError,
+		payment.PaymentStatusFailed,
+		payment.PaymentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// ForceCancel cancels the payment as a manual intervention
+//
+// Unlike IntentCancel, it does not involve the intent workers and the payment
+// method. It can cancel uninitialized, authorized and erroneous payments as well.
+// The payment transaction carrying the given comment will be saved within the
+// given tx. The caller should notify after committing.
+func (s *Service) ForceCancel(tx *sql.Tx, p *payment.Payment, comment string) (*payment.PaymentTransaction, error) {
+	return s.manualTransaction(tx, p, payment.PaymentStatusCancelled, comment)
+}
+
+// MarkPaid marks the payment paid as a manual intervention, e.g. when the payment
+// was received but the provider notification was lost
+//
+// Unlike IntentPaid, it does not involve the intent workers and the payment method.
+// Failed and cancelled payments can be marked paid as well. The payment transaction
+// carrying the given comment will be saved within the given tx. The caller should
+// notify after committing.
+func (s *Service) MarkPaid(tx *sql.Tx, p *payment.Payment, comment string) (*payment.PaymentTransaction, error) {
+	return s.manualTransaction(tx, p, payment.PaymentStatusPaid, comment)
+}
+
+func (s *Service) manualTransaction(tx *sql.Tx, p *payment.Payment, status payment.PaymentTransactionStatus, comment string) (*payment.PaymentTransaction, error) {
+	log := s.log.New(log15.Ctx{
+		"method":    "manualTransaction",
+		"projectID": p.ProjectID(),
+		"paymentID": p.ID(),
+		"status":    status,
+	})
+	// the status might have changed since the payment was read
+	_, err := payment.PaymentTransactionCurrentTx(tx, p)
+	if err != nil && err != payment.ErrPaymentTransactionNotFound {
+		log.Error("error retrieving payment transaction", log15.Ctx{"err": err})
+		return nil, ErrDB
+	}
+	var allowed bool
+	if status == payment.PaymentStatusPaid {
+		allowed = isManuallyPayable(p)
+	} else {
+		allowed = isManuallyCancellable(p)
+	}
+	if !allowed {
+		return nil, ErrIntentNotAllowed
+	}
+	paymentTx := p.NewTransaction(status)
+	if status != payment.PaymentStatusPaid {
+		paymentTx.Amount = 0
+	}
+	paymentTx.Comment.String, paymentTx.Comment.Valid = comment, comment != ""
+	err = s.SetPaymentTransaction(tx, paymentTx)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("manual payment transaction", log15.Ctx{"comment": comment})
+	return paymentTx, nil
+}
+
 // CreatePaymentToken creates a new random payment token
 func (s *Service) CreatePaymentToken(tx *sql.Tx, p *payment.Payment) (*payment.PaymentToken, error) {
 	log := s.log.New(log15.Ctx{"method": "CreatePaymentToken"})
@@ -580,10 +816,8 @@ func (s *Service) CreatePaymentToken(tx *sql.Tx, p *payment.Payment) (*payment.P
 	}
 	err = payment.InsertPaymentTokenTx(tx, token)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return nil, ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return nil, ErrDBLockTimeout
 		}
 		log.Error("error saving payment token", log15.Ctx{"err": err})
 		return nil, ErrDB
@@ -604,10 +838,8 @@ func (s *Service) DeletePaymentToken(tx *sql.Tx, token string) error {
 	log := s.log.New(log15.Ctx{"method": "DeletePaymentToken"})
 	err := payment.DeletePaymentTokenTx(tx, token)
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				return ErrDBLockTimeout
-			}
+		if dialect.IsDeadlock(err) {
+			return ErrDBLockTimeout
 		}
 		log.Error("error deleting payment token", log15.Ctx{"err": err})
 		return ErrDB
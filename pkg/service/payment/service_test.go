@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -88,6 +89,7 @@ func TestPaymentNotification(t *testing.T) {
 											So(err, ShouldBeNil)
 											So(commitIntent, ShouldNotBeNil)
 											So(paymentTx.Timestamp.UnixNano(), ShouldNotEqual, 0)
+											paymentTx.SetRequestID("test-request")
 											err = s.SetPaymentTransaction(tx, paymentTx)
 											So(err, ShouldBeNil)
 
@@ -125,6 +127,10 @@ func TestPaymentNotification(t *testing.T) {
 															So(not.TransactionTimestamp, ShouldNotEqual, 0)
 															So(not.Status, ShouldEqual, payment.PaymentStatusOpen)
 														})
+
+														Convey("The notification should carry the request ID", func() {
+															So(req.Header.Get(service.RequestIDHeader), ShouldEqual, "test-request")
+														})
 													})
 												})
 											})
@@ -139,3 +145,191 @@ func TestPaymentNotification(t *testing.T) {
 		}))
 	}))
 }
+
+func TestManualIntervention(t *testing.T) {
+	Convey("Given a payment db connection", t, testutil.WithPaymentDB(t, func(db *sql.DB) {
+		Convey("Given a principal db connection", testutil.WithPrincipalDB(t, func(principalDB *sql.DB) {
+			Convey("Given a transaction", func() {
+				tx, err := db.Begin()
+				So(err, ShouldBeNil)
+				Reset(func() {
+					tx.Rollback()
+				})
+
+				Convey("Given a service context", testutil.WithContext(func(ctx *service.Context, logs <-chan *log15.Record) {
+					ctx.SetPaymentDB(db, nil)
+					ctx.SetPrincipalDB(principalDB, nil)
+
+					Convey("Given a payment service", WithService(ctx, func(s *paymentService.Service) {
+
+						Convey("Given an uninitialized payment", testPay.WithPaymentInTx(tx, func(p *payment.Payment) {
+
+							Convey("When marking the payment paid", func() {
+								_, err := s.MarkPaid(tx, p, "test")
+
+								Convey("It should not be allowed", func() {
+									So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+								})
+							})
+
+							Convey("When force cancelling the payment", func() {
+								paymentTx, err := s.ForceCancel(tx, p, "test cancel")
+								So(err, ShouldBeNil)
+								So(paymentTx.Amount, ShouldEqual, 0)
+
+								Convey("The payment should be cancelled with the comment", func() {
+									current, err := s.PaymentTransaction(tx, p)
+									So(err, ShouldBeNil)
+									So(current.Status, ShouldEqual, payment.PaymentStatusCancelled)
+									So(current.Comment.String, ShouldEqual, "test cancel")
+								})
+
+								Convey("When force cancelling the payment again", func() {
+									_, err := s.ForceCancel(tx, p, "test")
+
+									Convey("It should not be allowed", func() {
+										So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+									})
+								})
+
+								Convey("When marking the payment paid", func() {
+									paymentTx, err := s.MarkPaid(tx, p, "test paid")
+									So(err, ShouldBeNil)
+
+									Convey("The payment should be paid with the full amount", func() {
+										So(paymentTx.Amount, ShouldEqual, p.Amount)
+										current, err := s.PaymentTransaction(tx, p)
+										So(err, ShouldBeNil)
+										So(current.Status, ShouldEqual, payment.PaymentStatusPaid)
+									})
+
+									Convey("When force cancelling the paid payment", func() {
+										_, err := s.ForceCancel(tx, p, "test")
+
+										Convey("It should not be allowed", func() {
+											So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+										})
+									})
+								})
+							})
+						}))
+					}))
+				}))
+			})
+		}))
+	}))
+}
+
+func TestPaymentCallbackScope(t *testing.T) {
+	Convey("Given a payment db connection", t, testutil.WithPaymentDB(t, func(db *sql.DB) {
+		Convey("Given a principal db connection", testutil.WithPrincipalDB(t, func(principalDB *sql.DB) {
+			Convey("Given a transaction", func() {
+				tx, err := db.Begin()
+				So(err, ShouldBeNil)
+				Reset(func() {
+					tx.Rollback()
+				})
+
+				Convey("Given a service context", testutil.WithContext(func(ctx *service.Context, logs <-chan *log15.Record) {
+					ctx.SetPaymentDB(db, nil)
+					ctx.SetPrincipalDB(principalDB, nil)
+
+					Convey("Given a payment service", WithService(ctx, func(s *paymentService.Service) {
+
+						Convey("Given a project key without the callback scope", func() {
+							pr, err := project.ProjectByIDDB(principalDB, 1)
+							So(err, ShouldBeNil)
+							pk, err := project.NewProjectkey(*pr, "test")
+							So(err, ShouldBeNil)
+							So(pk.SetScopes([]project.Scope{project.ScopePaymentInit, project.ScopePaymentRead}), ShouldBeNil)
+							prTx, err := principalDB.Begin()
+							So(err, ShouldBeNil)
+							err = project.InsertProjectKeyTx(prTx, pk)
+							So(err, ShouldBeNil)
+							err = prTx.Commit()
+							So(err, ShouldBeNil)
+
+							Convey("Given a test HTTP server", func() {
+								var called bool
+								testSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+									called = true
+								}))
+								Reset(func() {
+									testSrv.Close()
+								})
+
+								Convey("When creating a payment with the key as its callback key", func() {
+									p := &payment.Payment{
+										Created:  time.Now(),
+										Ident:    "test_" + fmt.Sprintf("%d", time.Now().UnixNano()),
+										Amount:   1234,
+										Subunits: 2,
+										Currency: "EUR",
+									}
+									So(p.SetProject(pr), ShouldBeNil)
+									p.Config.SetCallbackURL(testSrv.URL)
+									p.Config.SetCallbackAPIVersion("2")
+									p.Config.SetCallbackProjectKey(pk.Key)
+									err = s.CreatePayment(tx, p)
+
+									Convey("It should be refused", func() {
+										So(err, ShouldEqual, paymentService.ErrPaymentCallbackConfig)
+									})
+								})
+
+								Convey("Given a payment with the key as its callback key", testPay.WithPaymentInTx(tx, func(p *payment.Payment) {
+									p.Config.SetCallbackURL(testSrv.URL)
+									p.Config.SetCallbackAPIVersion("2")
+									p.Config.SetCallbackProjectKey(pk.Key)
+
+									Convey("When notifying", func() {
+										err = s.Notify(&payment.PaymentTransaction{
+											Payment:   p,
+											Timestamp: time.Now(),
+										})
+
+										Convey("It should be refused", func() {
+											So(err, ShouldEqual, paymentService.ErrPaymentCallbackConfig)
+										})
+										Convey("No callback should be made", func() {
+											So(called, ShouldBeFalse)
+										})
+									})
+								}))
+							})
+						})
+					}))
+				}))
+			})
+		}))
+	}))
+}
+
+func TestIntentStatus(t *testing.T) {
+	Convey("Given a payment service", t, testutil.WithContext(func(ctx *service.Context, logs <-chan *log15.Record) {
+		s, err := paymentService.NewService(ctx)
+		So(err, ShouldBeNil)
+
+		Convey("Given a pending payment", func() {
+			p := &payment.Payment{Status: payment.PaymentStatusPending}
+
+			Convey("It should only be paid with the pending intent", func() {
+				_, _, err := s.IntentPaid(p, time.Millisecond)
+				So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+				_, _, err = s.IntentAuthorized(p, time.Millisecond)
+				So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+				_, _, err = s.IntentCancel(p, time.Millisecond)
+				So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+			})
+		})
+
+		Convey("Given a paid payment", func() {
+			p := &payment.Payment{Status: payment.PaymentStatusPaid}
+
+			Convey("It should not be paid again with the pending intent", func() {
+				_, _, err := s.IntentPendingPaid(p, time.Millisecond)
+				So(err, ShouldEqual, paymentService.ErrIntentNotAllowed)
+			})
+		})
+	}))
+}
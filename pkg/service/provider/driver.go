@@ -21,5 +21,18 @@ const (
 type Driver interface {
 	Attach(ctx *service.Context, mux *mux.Router) error
 
-	InitPayment(p *payment.Payment, method *payment_method.Method) (http.Handler, error)
+	// InitPayment initializes the payment and returns the handler serving the
+	// request r
+	//
+	// Background work started for the payment should carry the ID of the request,
+	// see service.RequestID.
+	InitPayment(r *http.Request, p *payment.Payment, method *payment_method.Method) (http.Handler, error)
+}
+
+// HealthChecker is an optional interface of drivers which can report whether they are
+// ready to process payments
+//
+// Drivers which do not implement it are considered ready once they are attached.
+type HealthChecker interface {
+	Ready() error
 }
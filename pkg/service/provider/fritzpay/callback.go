@@ -0,0 +1,57 @@
+package fritzpay
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/url"
+)
+
+const (
+	callbackParamPaymentID  = "paymentID"
+	callbackParamFritzpayID = "fritzpayID"
+	callbackParamStatus     = "status"
+	callbackParamSignature  = "sig"
+)
+
+// callbackMessage is the signed notification the PSP sends to the callback URL
+//
+// The signature prevents anyone but the PSP worker from changing payment states
+// through the callback handler.
+type callbackMessage struct {
+	PaymentID  string
+	FritzpayID string
+	Status     string
+	Sig        string
+}
+
+func callbackMessageFromQuery(q url.Values) callbackMessage {
+	return callbackMessage{
+		PaymentID:  q.Get(callbackParamPaymentID),
+		FritzpayID: q.Get(callbackParamFritzpayID),
+		Status:     q.Get(callbackParamStatus),
+		Sig:        q.Get(callbackParamSignature),
+	}
+}
+
+// Message implements service.Signable
+func (c callbackMessage) Message() ([]byte, error) {
+	return []byte(c.PaymentID + "\n" + c.FritzpayID + "\n" + c.Status), nil
+}
+
+// HashFunc implements service.Signable
+func (c callbackMessage) HashFunc() func() hash.Hash {
+	return sha256.New
+}
+
+// Signature implements service.Signed
+func (c callbackMessage) Signature() ([]byte, error) {
+	return hex.DecodeString(c.Sig)
+}
+
+func (c callbackMessage) setQuery(q url.Values) {
+	q.Set(callbackParamPaymentID, c.PaymentID)
+	q.Set(callbackParamFritzpayID, c.FritzpayID)
+	q.Set(callbackParamStatus, c.Status)
+	q.Set(callbackParamSignature, c.Sig)
+}
@@ -19,5 +19,28 @@ Package fritzpay provides the Test-/Demo-Payment Service Provider (PSP) FritzPay
 
 This package demonstrates how to add new PSP drivers. This provider can also be used
 to test out the functionality of paymentd when interacting as an end-user with it.
+
+The simulated PSP can run through different scenarios, so that every payment status
+(including the callback notifications to the merchant) can be tested end to end.
+
+The scenario is selected through the payment metadata entry "fritzpayScenario" with
+one of the values "success", "decline", "delayed", "error", "timeout", "chargeback"
+or "refund".
+
+If no scenario is set in the metadata, the last two digits of the payment amount
+(in the smallest currency unit) select the scenario:
+
+	01 decline
+	02 delayed
+	03 error
+	04 timeout
+	05 chargeback
+	06 refund
+
+Any other amount will succeed. The delays can be configured with the metadata entries
+"fritzpayDelay" (until the PSP confirms the payment) and "fritzpayFollowUpDelay" (until
+a chargeback or refund happens). Values are Go durations, e.g. "1500ms" or "2m".
+
+The current FritzPay status of a payment can be queried at /fritzpay/payment?paymentID=...
 */
 package fritzpay
@@ -2,6 +2,7 @@ package fritzpay
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -29,16 +30,15 @@ const (
 )
 
 var (
-	ErrDB       = errors.New("database error")
-	ErrConflict = errors.New("conflict")
+	ErrDB          = errors.New("database error")
+	ErrConflict    = errors.New("conflict")
+	ErrNotAttached = errors.New("driver not attached")
 )
 
 type Driver struct {
-	ctx     *service.Context
-	mux     *mux.Router
-	log     log15.Logger
-	tmplDir string
-
+	ctx            *service.Context
+	mux            *mux.Router
+	log            log15.Logger
 	paymentService *paymentService.Service
 }
 
@@ -59,32 +59,95 @@ func (d *Driver) Attach(ctx *service.Context, mux *mux.Router) error {
 	if cfg.Provider.ProviderTemplateDir == "" {
 		return fmt.Errorf("provider template dir not set")
 	}
-	d.tmplDir = path.Join(cfg.Provider.ProviderTemplateDir, FritzpayTemplateDir)
-	dirInfo, err := os.Stat(d.tmplDir)
+	tmplDir := d.templateDir()
+	dirInfo, err := os.Stat(tmplDir)
 	if err != nil {
 		d.log.Error("error opening template dir", log15.Ctx{
 			"err":     err,
-			"tmplDir": d.tmplDir,
+			"tmplDir": tmplDir,
 		})
 		return err
 	}
 	if !dirInfo.IsDir() {
-		return fmt.Errorf("provider template dir %s is not a directory", d.tmplDir)
+		return fmt.Errorf("provider template dir %s is not a directory", tmplDir)
 	}
 
 	d.mux = mux
-	mux.HandleFunc(FritzpayDriverPath+"/status", d.Status)
-	mux.Handle(FritzpayDriverPath+"/payment", d.PaymentInfo())
-	mux.HandleFunc(FritzpayDriverPath+"/f", d.Callback).Name("fritzpayCallback")
+	mux.Handle(FritzpayDriverPath+"/status", ctx.InstrumentHandler("provider", FritzpayDriverPath+"/status", http.HandlerFunc(d.Status)))
+	mux.Handle(FritzpayDriverPath+"/payment", ctx.InstrumentHandler("provider", FritzpayDriverPath+"/payment", d.PaymentInfo()))
+	mux.Handle(FritzpayDriverPath+"/f", ctx.InstrumentHandler("provider", FritzpayDriverPath+"/f", http.HandlerFunc(d.Callback))).Name("fritzpayCallback")
 	return nil
 }
 
+// templateDir returns the template dir of the driver
+//
+// It is read from the config on use, so it changes when the config is reloaded.
+func (d *Driver) templateDir() string {
+	return path.Join(d.ctx.Config().Provider.ProviderTemplateDir, FritzpayTemplateDir)
+}
+
+// Ready implements the provider.HealthChecker
+//
+// The driver is ready if it is attached and its templates are readable.
+func (d *Driver) Ready() error {
+	if d.ctx == nil {
+		return ErrNotAttached
+	}
+	return service.DirHealthCheck("", d.templateDir()).Check()
+}
+
 func (d *Driver) Status(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "FritzPay OK.")
 }
 
+// PaymentInfo serves the current FritzPay status of a payment as JSON
+//
+// This can be used to follow the progress of a simulated scenario.
 func (d *Driver) PaymentInfo() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := d.log.New(log15.Ctx{
+			"method":    "PaymentInfo",
+			"requestID": service.RequestID(r),
+		})
+		paymentID, err := payment.ParsePaymentIDStr(r.URL.Query().Get("paymentID"))
+		if err != nil {
+			http.Error(w, "invalid payment id", http.StatusBadRequest)
+			return
+		}
+		paymentID = d.paymentService.DecodedPaymentID(paymentID)
+		fritzpayP, err := PaymentByPaymentIDDB(d.ctx.PaymentDB(service.ReadOnly), paymentID)
+		if err != nil {
+			if err == ErrPaymentNotFound {
+				http.Error(w, "payment not found", http.StatusNotFound)
+				return
+			}
+			log.Error("error retrieving payment", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		info := struct {
+			Status     string
+			FritzpayID string `json:",omitempty"`
+			Timestamp  int64  `json:",omitempty"`
+		}{}
+		fritzpayTx, err := PaymentTransactionCurrentByPaymentIDDB(d.ctx.PaymentDB(service.ReadOnly), fritzpayP.ID)
+		if err != nil && err != ErrTransactionNotFound {
+			log.Error("error retrieving payment tx", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err == ErrTransactionNotFound {
+			info.Status = TransactionInit
+		} else {
+			info.Status = fritzpayTx.Status
+			info.FritzpayID = fritzpayTx.FritzpayID.String
+			info.Timestamp = fritzpayTx.Timestamp.Unix()
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			log.Error("error encoding payment info", log15.Ctx{"err": err})
+		}
 	})
 }
 
@@ -96,7 +159,8 @@ func (d *Driver) PaymentInfo() http.Handler {
 // other than 200
 func (d *Driver) Callback(w http.ResponseWriter, r *http.Request) {
 	log := d.log.New(log15.Ctx{
-		"method": "Callback",
+		"method":    "Callback",
+		"requestID": service.RequestID(r),
 	})
 	if Debug {
 		log.Debug("received callback", log15.Ctx{"query": r.URL.Query()})
@@ -104,7 +168,13 @@ func (d *Driver) Callback(w http.ResponseWriter, r *http.Request) {
 	// always answer with ok
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 
-	paymentIDStr := r.URL.Query().Get("paymentID")
+	msg := callbackMessageFromQuery(r.URL.Query())
+	if _, err := d.ctx.WebKeychain().MatchKey(msg); err != nil {
+		log.Warn("callback with invalid signature", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	paymentIDStr := msg.PaymentID
 	if paymentIDStr == "" {
 		log.Warn("no payment id in callback")
 		w.WriteHeader(http.StatusOK)
@@ -209,19 +279,39 @@ func (d *Driver) Callback(w http.ResponseWriter, r *http.Request) {
 		FritzpayPaymentID: fritzpayP.ID,
 		Timestamp:         time.Now(),
 	}
-	if r.URL.Query().Get("fritzpayID") != "" {
-		fritzpayTx.FritzpayID.String, fritzpayTx.FritzpayID.Valid = r.URL.Query().Get("fritzpayID"), true
+	if msg.FritzpayID != "" {
+		fritzpayTx.FritzpayID.String, fritzpayTx.FritzpayID.Valid = msg.FritzpayID, true
 	}
-	switch r.URL.Query().Get("status") {
+	var intent func(*payment.Payment, time.Duration) (*payment.PaymentTransaction, paymentService.CommitIntentFunc, error)
+	switch msg.Status {
 	case TransactionPSPInit:
-		if currentTx.Status == TransactionOpen {
-			// noop
-			w.WriteHeader(http.StatusOK)
-			return
-		}
 		fritzpayTx.Status = TransactionOpen
+	case TransactionPSPPaid:
+		fritzpayTx.Status = TransactionPaid
+		// the payment is pending since the init
+		intent = d.paymentService.IntentPendingPaid
+	case TransactionPSPDeclined:
+		fritzpayTx.Status = TransactionDeclined
+		intent = d.paymentService.IntentFailed
+	case TransactionPSPError:
+		fritzpayTx.Status = TransactionError
+		intent = d.paymentService.IntentError
+	case TransactionPSPTimeout:
+		fritzpayTx.Status = TransactionTimeout
+		intent = d.paymentService.IntentError
+	case TransactionPSPChargeback:
+		fritzpayTx.Status = TransactionChargeback
+		intent = d.paymentService.IntentChargeback
+	case TransactionPSPRefunded:
+		fritzpayTx.Status = TransactionRefunded
+		intent = d.paymentService.IntentRefund
 	default:
-		log.Warn("invalid status", log15.Ctx{"status": r.URL.Query().Get("status")})
+		log.Warn("invalid status", log15.Ctx{"status": msg.Status})
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if currentTx.Status == fritzpayTx.Status {
+		// noop
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -232,6 +322,33 @@ func (d *Driver) Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var commitIntent paymentService.CommitIntentFunc
+	if intent != nil {
+		var paymentTx *payment.PaymentTransaction
+		paymentTx, commitIntent, err = intent(p, 500*time.Millisecond)
+		if err != nil {
+			if err == paymentService.ErrIntentNotAllowed {
+				log.Warn("payment status change not allowed", log15.Ctx{
+					"paymentStatus": p.Status.String(),
+					"status":        msg.Status,
+				})
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			log.Error("error on payment intent", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		paymentTx.Comment.String, paymentTx.Comment.Valid = "FritzPay "+fritzpayTx.Status, true
+		paymentTx.SetRequestID(service.RequestID(r))
+		err = d.paymentService.SetPaymentTransaction(tx, paymentTx)
+		if err != nil {
+			log.Error("error setting payment tx", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	commit = true
 	err = tx.Commit()
 	if err != nil {
@@ -239,4 +356,7 @@ func (d *Driver) Callback(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if commitIntent != nil {
+		commitIntent()
+	}
 }
@@ -3,24 +3,28 @@ package fritzpay
 import (
 	"database/sql"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/fritzpay/paymentd/pkg/dialect"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/service"
 	paymentService "github.com/fritzpay/paymentd/pkg/service/payment"
-	"github.com/go-sql-driver/mysql"
+	tmpl "github.com/fritzpay/paymentd/pkg/template"
 	"golang.org/x/net/context"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
-func (d *Driver) InitPayment(p *payment.Payment, method *payment_method.Method) (http.Handler, error) {
+func (d *Driver) InitPayment(r *http.Request, p *payment.Payment, method *payment_method.Method) (http.Handler, error) {
 	log := d.log.New(log15.Ctx{
 		"method":          "InitPayment",
 		"projectID":       p.ProjectID(),
 		"paymentID":       p.ID(),
 		"paymentMethodID": method.ID,
+		"requestID":       service.RequestID(r),
 	})
 	if Debug {
 		log.Debug("initialize payment")
@@ -93,6 +97,7 @@ beginTx:
 			paymentTx := p.NewTransaction(payment.PaymentStatusPending)
 			paymentTx.Amount = 0
 			paymentTx.Comment.String, paymentTx.Comment.Valid = "initialized by FritzPay demo provider", true
+			paymentTx.SetRequestID(service.RequestID(r))
 			err = d.paymentService.SetPaymentTransaction(tx, paymentTx)
 			if err != nil {
 				if err == paymentService.ErrDBLockTimeout {
@@ -107,12 +112,10 @@ beginTx:
 	}
 	err = tx.Commit()
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				retries++
-				time.Sleep(time.Second)
-				goto beginTx
-			}
+		if dialect.IsDeadlock(err) {
+			retries++
+			time.Sleep(time.Second)
+			goto beginTx
 		}
 		log.Crit("error on commit", log15.Ctx{"err": err})
 		commit = true
@@ -143,8 +146,10 @@ beginTx:
 		q.Set("paymentID", d.paymentService.EncodedPaymentID(p.PaymentID()).String())
 		callbackURL.RawQuery = q.Encode()
 
-		workerCtx, _ := context.WithTimeout(d.ctx, fritzpayDefaultTimeout)
-		go pspInit(workerCtx, fritzpayP, callbackURL.String())
+		scenario := ScenarioByPayment(p)
+		log = log.New(log15.Ctx{"scenario": scenario.Name})
+		workerCtx, _ := context.WithTimeout(d.ctx, fritzpayDefaultTimeout+scenario.Duration())
+		go pspRun(workerCtx, d.ctx.WebKeychain(), fritzpayP, scenario, callbackURL.String(), service.RequestID(r))
 		defer func() {
 			if err := recover(); err != nil {
 				log.Crit("panic on worker", log15.Ctx{"err": err})
@@ -152,5 +157,26 @@ beginTx:
 		}()
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		t, err := d.initTemplate(p.Config.Locale.String)
+		if err != nil {
+			// the init page is optional, the worker runs without it
+			log.Warn("no init template. serving empty page", log15.Ctx{"err": err})
+			return
+		}
+		err = t.Execute(w, map[string]interface{}{
+			"paymentID": d.paymentService.EncodedPaymentID(p.PaymentID()),
+			"scenario":  scenario.Name,
+		})
+		if err != nil {
+			log.Error("error on init template", log15.Ctx{"err": err})
+		}
 	}), nil
 }
+
+func (d *Driver) initTemplate(locale string) (*template.Template, error) {
+	tmplFile, err := tmpl.TemplateFileName(d.templateDir(), locale, defaultLocale, "init.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	return template.ParseFiles(tmplFile)
+}
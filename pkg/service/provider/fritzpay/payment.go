@@ -13,11 +13,26 @@ type Payment struct {
 	MethodKey string
 }
 
+// transaction states
+//
+// States prefixed with "psp_" are set by the (simulated) payment service provider,
+// the others are set by the driver when handling the callback
 const (
-	TransactionPSPInit  = "psp_init"
-	TransactionInit     = "initialized"
-	TransactionPSPError = "psp_error"
-	TransactionOpen     = "open"
+	TransactionPSPInit       = "psp_init"
+	TransactionInit          = "initialized"
+	TransactionPSPError      = "psp_error"
+	TransactionOpen          = "open"
+	TransactionPSPPaid       = "psp_paid"
+	TransactionPaid          = "paid"
+	TransactionPSPDeclined   = "psp_declined"
+	TransactionDeclined      = "declined"
+	TransactionError         = "error"
+	TransactionPSPTimeout    = "psp_timeout"
+	TransactionTimeout       = "timeout"
+	TransactionPSPChargeback = "psp_chargeback"
+	TransactionChargeback    = "chargeback"
+	TransactionPSPRefunded   = "psp_refunded"
+	TransactionRefunded      = "refunded"
 )
 
 type PaymentTransaction struct {
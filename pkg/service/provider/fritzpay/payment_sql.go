@@ -31,6 +31,15 @@ WHERE
 
 func PaymentByPaymentIDTx(db *sql.Tx, id payment.PaymentID) (Payment, error) {
 	row := db.QueryRow(selectPaymentByPaymentID, id.ProjectID, id.PaymentID)
+	return scanPayment(row)
+}
+
+func PaymentByPaymentIDDB(db *sql.DB, id payment.PaymentID) (Payment, error) {
+	row := db.QueryRow(selectPaymentByPaymentID, id.ProjectID, id.PaymentID)
+	return scanPayment(row)
+}
+
+func scanPayment(row *sql.Row) (Payment, error) {
 	p := Payment{}
 	err := row.Scan(
 		&p.ID,
@@ -155,3 +164,42 @@ func PaymentTransactionCurrentByPaymentIDProviderTx(db *sql.Tx, id int64) (Payme
 	row := db.QueryRow(selectPaymentTransactionByIDProvider, id)
 	return scanSingleTx(row)
 }
+
+func PaymentTransactionCurrentByPaymentIDDB(db *sql.DB, id int64) (PaymentTransaction, error) {
+	row := db.QueryRow(selectPaymentTransactionByID, id)
+	return scanSingleTx(row)
+}
+
+const selectPaymentTransactionsByID = selectPaymentTransaction + `
+WHERE
+	t.fritzpay_payment_id = ?
+ORDER BY t.timestamp ASC
+`
+
+// PaymentTransactionsByPaymentIDDB returns all transactions of the given fritzpay
+// payment, the earliest first
+func PaymentTransactionsByPaymentIDDB(db *sql.DB, id int64) ([]PaymentTransaction, error) {
+	rows, err := db.Query(selectPaymentTransactionsByID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	txs := make([]PaymentTransaction, 0)
+	for rows.Next() {
+		paymentTx := PaymentTransaction{}
+		var ts int64
+		err = rows.Scan(
+			&paymentTx.FritzpayPaymentID,
+			&ts,
+			&paymentTx.Status,
+			&paymentTx.FritzpayID,
+			&paymentTx.Payload,
+		)
+		if err != nil {
+			return nil, err
+		}
+		paymentTx.Timestamp = time.Unix(0, ts)
+		txs = append(txs, paymentTx)
+	}
+	return txs, rows.Err()
+}
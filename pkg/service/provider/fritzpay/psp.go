@@ -4,122 +4,205 @@ import (
 	"crypto/sha1"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/fritzpay/paymentd/pkg/service"
 	"golang.org/x/net/context"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
-func pspInit(ctx context.Context, fritzpayP Payment, callbackURL string) {
+var (
+	errCallbackStatus = errors.New("callback answered with non-OK status")
+)
+
+// psp simulates the payment service provider end of a FritzPay payment
+//
+// It will run the steps of the given scenario and notify the callback URL about
+// every status change. The notifications carry the ID of the request which
+// initialized the payment.
+type psp struct {
+	ctx         context.Context
+	log         log15.Logger
+	keychain    *service.Keychain
+	fritzpayP   Payment
+	scenario    Scenario
+	callbackURL *url.URL
+	requestID   string
+}
+
+func pspRun(ctx context.Context, keychain *service.Keychain, fritzpayP Payment, scenario Scenario, callbackURL, requestID string) {
 	if deadline, ok := ctx.Deadline(); ok {
 		// let's assume we will need at least 3 seconds to run
-		if deadline.Before(time.Now().Add(3 * time.Second)) {
+		if deadline.Before(time.Now().Add(3*time.Second + scenario.Duration())) {
 			return
 		}
 	}
 	log := ctx.Value("log").(log15.Logger).New(log15.Ctx{
 		"pkg":         "github.com/fritzpay/paymentd/pkg/service/provider/fritzpay",
-		"method":      "doInit",
+		"method":      "pspRun",
 		"callbackURL": callbackURL,
+		"scenario":    scenario.Name,
+		"requestID":   requestID,
 	})
 	callback, err := url.Parse(callbackURL)
 	if err != nil {
 		log.Error("error on parsing callback URL", log15.Ctx{"err": err})
 		return
 	}
-	tx, err := ctx.Value("paymentDB").(*sql.DB).Begin()
-	if err != nil {
-		log.Crit("error on begin tx", log15.Ctx{"err": err})
-		return
+	p := &psp{
+		ctx:         ctx,
+		log:         log,
+		keychain:    keychain,
+		fritzpayP:   fritzpayP,
+		scenario:    scenario,
+		callbackURL: callback,
+		requestID:   requestID,
 	}
 	if Debug {
 		log.Debug("worker start...")
 	}
-	var req *http.Request
-	tr, cl := newClient()
-	ok := make(chan struct{})
-	errors := make(chan error)
-	go func() {
-		paymentTx, err := PaymentTransactionCurrentByPaymentIDProviderTx(tx, fritzpayP.ID)
-		if err != nil && err != ErrTransactionNotFound {
-			errors <- err
+
+	fritzpayID, err := p.init()
+	if err != nil {
+		log.Error("error on psp init", log15.Ctx{"err": err})
+		return
+	}
+	err = p.notify(fritzpayID, TransactionPSPInit)
+	if err != nil {
+		log.Error("error on init notification", log15.Ctx{"err": err})
+		p.setStatus(fritzpayID, TransactionPSPError, "error reaching callback URL")
+		return
+	}
+	for _, step := range scenario.steps {
+		select {
+		case <-ctx.Done():
+			log.Warn("cancelling worker...", log15.Ctx{"err": ctx.Err()})
 			return
+		case <-time.After(step.Delay):
 		}
-		if err == ErrTransactionNotFound {
-			h := sha1.New()
-			_, err = h.Write([]byte(fmt.Sprintf("%d", fritzpayP.ID)))
-			if err != nil {
-				errors <- err
-				return
-			}
-			paymentTx.FritzpayPaymentID = fritzpayP.ID
-			paymentTx.Timestamp = time.Now()
-			paymentTx.Status = TransactionPSPInit
-			paymentTx.FritzpayID.String, paymentTx.FritzpayID.Valid = hex.EncodeToString(h.Sum(nil)), true
-			paymentTx.Payload.String, paymentTx.Payload.Valid = "initialized on psp", true
-			err = InsertPaymentTransactionTx(tx, paymentTx)
-			if err != nil {
-				errors <- err
-				return
-			}
-		}
-		q := callback.Query()
-		q.Set("fritzpayID", paymentTx.FritzpayID.String)
-		q.Set("status", paymentTx.Status)
-		callback.RawQuery = q.Encode()
-		req, err = http.NewRequest("GET", callback.String(), nil)
+		err = p.setStatus(fritzpayID, step.Status, "scenario "+scenario.Name)
 		if err != nil {
-			errors <- err
+			log.Error("error on setting psp status", log15.Ctx{"err": err, "status": step.Status})
 			return
 		}
-		res, err := cl.Do(req)
+		err = p.notify(fritzpayID, step.Status)
 		if err != nil {
-			errors <- err
+			log.Error("error on notification", log15.Ctx{"err": err, "status": step.Status})
 			return
 		}
-		if res.StatusCode != http.StatusOK {
-			paymentTx.Timestamp = time.Now()
-			paymentTx.Status = TransactionPSPError
-			paymentTx.Payload.String = "error reaching callback URL"
-			err = InsertPaymentTransactionTx(tx, paymentTx)
-			if err != nil {
-				errors <- err
-				return
-			}
-		}
+	}
+	if Debug {
+		log.Debug("worker done")
+	}
+}
 
-		err = tx.Commit()
-		if err != nil {
-			log.Crit("error on commit", log15.Ctx{"err": err})
-			errors <- err
-			return
-		}
+// init registers the payment on the psp and returns the fritzpay id
+func (p *psp) init() (string, error) {
+	tx, err := p.ctx.Value("paymentDB").(*sql.DB).Begin()
+	if err != nil {
+		return "", err
+	}
+	paymentTx, err := PaymentTransactionCurrentByPaymentIDProviderTx(tx, p.fritzpayP.ID)
+	if err != nil && err != ErrTransactionNotFound {
+		tx.Rollback()
+		return "", err
+	}
+	if err == nil {
+		return paymentTx.FritzpayID.String, tx.Rollback()
+	}
+	h := sha1.New()
+	_, err = h.Write([]byte(fmt.Sprintf("%d", p.fritzpayP.ID)))
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	paymentTx.FritzpayPaymentID = p.fritzpayP.ID
+	paymentTx.Timestamp = time.Now()
+	paymentTx.Status = TransactionPSPInit
+	paymentTx.FritzpayID.String, paymentTx.FritzpayID.Valid = hex.EncodeToString(h.Sum(nil)), true
+	paymentTx.Payload.String, paymentTx.Payload.Valid = "initialized on psp", true
+	err = InsertPaymentTransactionTx(tx, paymentTx)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	return paymentTx.FritzpayID.String, tx.Commit()
+}
+
+func (p *psp) setStatus(fritzpayID, status, payload string) error {
+	tx, err := p.ctx.Value("paymentDB").(*sql.DB).Begin()
+	if err != nil {
+		return err
+	}
+	paymentTx := PaymentTransaction{
+		FritzpayPaymentID: p.fritzpayP.ID,
+		Timestamp:         time.Now(),
+		Status:            status,
+	}
+	paymentTx.FritzpayID.String, paymentTx.FritzpayID.Valid = fritzpayID, true
+	paymentTx.Payload.String, paymentTx.Payload.Valid = payload, true
+	err = InsertPaymentTransactionTx(tx, paymentTx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// notify calls the callback URL with a signed status notification
+func (p *psp) notify(fritzpayID, status string) error {
+	key, err := p.keychain.BinKey()
+	if err != nil {
+		return err
+	}
+	callback := *p.callbackURL
+	q := callback.Query()
+	msg := callbackMessage{
+		PaymentID:  q.Get(callbackParamPaymentID),
+		FritzpayID: fritzpayID,
+		Status:     status,
+	}
+	sig, err := service.Sign(msg, key)
+	if err != nil {
+		return err
+	}
+	msg.Sig = hex.EncodeToString(sig)
+	msg.setQuery(q)
+	callback.RawQuery = q.Encode()
 
-		close(ok)
+	req, err := http.NewRequest("GET", callback.String(), nil)
+	if err != nil {
+		return err
+	}
+	if p.requestID != "" {
+		req.Header.Set(service.RequestIDHeader, p.requestID)
+	}
+	tr, cl := newClient()
+	type result struct {
+		res *http.Response
+		err error
+	}
+	c := make(chan result, 1)
+	go func() {
+		res, err := cl.Do(req)
+		c <- result{res, err}
 	}()
 	select {
-	case <-ctx.Done():
-		log.Warn("cancelling worker...", log15.Ctx{"err": ctx.Err()})
-		err = tx.Rollback()
-		if err != nil {
-			log.Crit("error on rollback", log15.Ctx{"err": err})
-		}
+	case <-p.ctx.Done():
 		tr.CancelRequest(req)
-		return
-	case err := <-errors:
-		log.Error("error on worker", log15.Ctx{"err": err})
-		err = tx.Rollback()
-		if err != nil {
-			log.Crit("error on rollback", log15.Ctx{"err": err})
+		return p.ctx.Err()
+	case r := <-c:
+		if r.err != nil {
+			return r.err
 		}
-		return
-	case <-ok:
-		if Debug {
-			log.Debug("worker done")
+		r.res.Body.Close()
+		if r.res.StatusCode != http.StatusOK {
+			return errCallbackStatus
 		}
-		return
+		return nil
 	}
 }
@@ -0,0 +1,144 @@
+package fritzpay
+
+import (
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+)
+
+// Scenarios which can be simulated by the FritzPay PSP
+//
+// The scenario for a payment can be selected through the payment metadata entry
+// MetadataKeyScenario. If no scenario is set in the metadata, the scenario is
+// derived from the last two digits of the payment amount (see ScenarioByAmount).
+const (
+	// the payment will be confirmed as paid
+	ScenarioSuccess = "success"
+	// the payment will be declined by the PSP
+	ScenarioDecline = "decline"
+	// the payment will be confirmed after a longer delay
+	ScenarioDelayed = "delayed"
+	// the PSP will report an error
+	ScenarioError = "error"
+	// the PSP will not confirm the payment and the request will time out
+	ScenarioTimeout = "timeout"
+	// the payment will be confirmed and charged back later
+	ScenarioChargeback = "chargeback"
+	// the payment will be confirmed and refunded later
+	ScenarioRefund = "refund"
+)
+
+const (
+	// MetadataKeyScenario is the payment metadata key for selecting a scenario
+	MetadataKeyScenario = "fritzpayScenario"
+	// MetadataKeyDelay is the payment metadata key for the delay until the PSP
+	// confirms the payment. The value must be parseable by time.ParseDuration
+	MetadataKeyDelay = "fritzpayDelay"
+	// MetadataKeyFollowUpDelay is the payment metadata key for the delay between
+	// the confirmation and a follow-up event (chargeback, refund)
+	MetadataKeyFollowUpDelay = "fritzpayFollowUpDelay"
+)
+
+const (
+	defaultDelay         = time.Second
+	defaultDelayedDelay  = 10 * time.Second
+	defaultFollowUpDelay = 5 * time.Second
+	// maximum accepted delay from metadata values
+	maxDelay = 10 * time.Minute
+)
+
+// amount conventions
+//
+// The last two digits of the amount (in the smallest unit) select the scenario,
+// e.g. an amount of 10.01 EUR will be declined.
+var amountScenarios = map[int64]string{
+	1: ScenarioDecline,
+	2: ScenarioDelayed,
+	3: ScenarioError,
+	4: ScenarioTimeout,
+	5: ScenarioChargeback,
+	6: ScenarioRefund,
+}
+
+// scenarioStep is a single event the PSP will send a callback for
+type scenarioStep struct {
+	Delay  time.Duration
+	Status string
+}
+
+// Scenario is a simulated PSP behaviour
+type Scenario struct {
+	Name  string
+	steps []scenarioStep
+}
+
+// Duration returns the total time it takes to run all scenario steps
+func (s Scenario) Duration() time.Duration {
+	var d time.Duration
+	for _, st := range s.steps {
+		d += st.Delay
+	}
+	return d
+}
+
+// ValidScenario returns true if the given name is a known scenario
+func ValidScenario(name string) bool {
+	switch name {
+	case ScenarioSuccess, ScenarioDecline, ScenarioDelayed, ScenarioError,
+		ScenarioTimeout, ScenarioChargeback, ScenarioRefund:
+		return true
+	default:
+		return false
+	}
+}
+
+// ScenarioByAmount returns the scenario name for the given payment amount
+func ScenarioByAmount(amount int64) string {
+	if amount < 0 {
+		amount *= -1
+	}
+	if name, ok := amountScenarios[amount%100]; ok {
+		return name
+	}
+	return ScenarioSuccess
+}
+
+func metadataDelay(p *payment.Payment, key string, def time.Duration) time.Duration {
+	if p.Metadata == nil || p.Metadata[key] == "" {
+		return def
+	}
+	d, err := time.ParseDuration(p.Metadata[key])
+	if err != nil || d < 0 || d > maxDelay {
+		return def
+	}
+	return d
+}
+
+// ScenarioByPayment returns the scenario to simulate for the given payment
+func ScenarioByPayment(p *payment.Payment) Scenario {
+	name := ScenarioByAmount(p.Amount)
+	if p.Metadata != nil && ValidScenario(p.Metadata[MetadataKeyScenario]) {
+		name = p.Metadata[MetadataKeyScenario]
+	}
+	delay := metadataDelay(p, MetadataKeyDelay, defaultDelay)
+	followUp := metadataDelay(p, MetadataKeyFollowUpDelay, defaultFollowUpDelay)
+
+	s := Scenario{Name: name}
+	switch name {
+	case ScenarioDecline:
+		s.steps = []scenarioStep{{delay, TransactionPSPDeclined}}
+	case ScenarioDelayed:
+		s.steps = []scenarioStep{{metadataDelay(p, MetadataKeyDelay, defaultDelayedDelay), TransactionPSPPaid}}
+	case ScenarioError:
+		s.steps = []scenarioStep{{delay, TransactionPSPError}}
+	case ScenarioTimeout:
+		s.steps = []scenarioStep{{delay, TransactionPSPTimeout}}
+	case ScenarioChargeback:
+		s.steps = []scenarioStep{{delay, TransactionPSPPaid}, {followUp, TransactionPSPChargeback}}
+	case ScenarioRefund:
+		s.steps = []scenarioStep{{delay, TransactionPSPPaid}, {followUp, TransactionPSPRefunded}}
+	default:
+		s.steps = []scenarioStep{{delay, TransactionPSPPaid}}
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package fritzpay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/service/provider/fritzpay"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScenarioByPayment(t *testing.T) {
+	Convey("Given a payment", t, func() {
+		p := &payment.Payment{
+			Amount:   1000,
+			Currency: "EUR",
+		}
+
+		Convey("When the amount does not match a convention", func() {
+			s := fritzpay.ScenarioByPayment(p)
+
+			Convey("It should succeed", func() {
+				So(s.Name, ShouldEqual, fritzpay.ScenarioSuccess)
+				So(s.Duration(), ShouldEqual, time.Second)
+			})
+		})
+
+		Convey("When the amount ends with 01", func() {
+			p.Amount = 1001
+			s := fritzpay.ScenarioByPayment(p)
+
+			Convey("It should be declined", func() {
+				So(s.Name, ShouldEqual, fritzpay.ScenarioDecline)
+			})
+		})
+
+		Convey("When the amount ends with 05", func() {
+			p.Amount = 1005
+			s := fritzpay.ScenarioByPayment(p)
+
+			Convey("It should be charged back after the follow-up delay", func() {
+				So(s.Name, ShouldEqual, fritzpay.ScenarioChargeback)
+				So(s.Duration(), ShouldEqual, 6*time.Second)
+			})
+		})
+
+		Convey("Given a scenario in the metadata", func() {
+			p.Amount = 1001
+			p.Metadata = map[string]string{
+				fritzpay.MetadataKeyScenario: fritzpay.ScenarioRefund,
+				fritzpay.MetadataKeyDelay:    "10ms",
+			}
+
+			Convey("When the follow-up delay is set", func() {
+				p.Metadata[fritzpay.MetadataKeyFollowUpDelay] = "20ms"
+				s := fritzpay.ScenarioByPayment(p)
+
+				Convey("It should use the metadata scenario and delays", func() {
+					So(s.Name, ShouldEqual, fritzpay.ScenarioRefund)
+					So(s.Duration(), ShouldEqual, 30*time.Millisecond)
+				})
+			})
+
+			Convey("When the follow-up delay is invalid", func() {
+				p.Metadata[fritzpay.MetadataKeyFollowUpDelay] = "forever"
+				s := fritzpay.ScenarioByPayment(p)
+
+				Convey("It should use the default follow-up delay", func() {
+					So(s.Duration(), ShouldEqual, 10*time.Millisecond+5*time.Second)
+				})
+			})
+		})
+
+		Convey("Given an unknown scenario in the metadata", func() {
+			p.Amount = 1003
+			p.Metadata = map[string]string{
+				fritzpay.MetadataKeyScenario: "unknown",
+			}
+			s := fritzpay.ScenarioByPayment(p)
+
+			Convey("It should fall back to the amount convention", func() {
+				So(s.Name, ShouldEqual, fritzpay.ScenarioError)
+			})
+		})
+	})
+}
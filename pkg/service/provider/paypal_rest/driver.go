@@ -31,6 +31,9 @@ const (
 	// PaypalDriverPath is the (sub-)path under which PayPal driver endpoints
 	// will be attached
 	PaypalDriverPath = "/paypal"
+
+	// providerName is the name of the provider in the metrics
+	providerName = "paypal_rest"
 )
 
 const (
@@ -41,10 +44,11 @@ const (
 )
 
 var (
-	ErrDatabase = errors.New("database error")
-	ErrInternal = errors.New("paypal driver internal error")
-	ErrHTTP     = errors.New("HTTP error")
-	ErrProvider = errors.New("provider error")
+	ErrDatabase    = errors.New("database error")
+	ErrInternal    = errors.New("paypal driver internal error")
+	ErrHTTP        = errors.New("HTTP error")
+	ErrProvider    = errors.New("provider error")
+	ErrNotAttached = errors.New("driver not attached")
 )
 
 // Driver is the PayPal provider driver
@@ -53,8 +57,6 @@ type Driver struct {
 	mux *mux.Router
 	log log15.Logger
 
-	tmplDir string
-
 	paymentService *paymentService.Service
 
 	oauth *OAuthTransportStore
@@ -77,17 +79,17 @@ func (d *Driver) Attach(ctx *service.Context, mux *mux.Router) error {
 	if cfg.Provider.ProviderTemplateDir == "" {
 		return fmt.Errorf("provider template dir not set")
 	}
-	d.tmplDir = path.Join(cfg.Provider.ProviderTemplateDir, providerTemplateDir)
-	dirInfo, err := os.Stat(d.tmplDir)
+	tmplDir := d.templateDir()
+	dirInfo, err := os.Stat(tmplDir)
 	if err != nil {
 		d.log.Error("error opening template dir", log15.Ctx{
 			"err":     err,
-			"tmplDir": d.tmplDir,
+			"tmplDir": tmplDir,
 		})
 		return err
 	}
 	if !dirInfo.IsDir() {
-		return fmt.Errorf("provider template dir %s is not a directory", d.tmplDir)
+		return fmt.Errorf("provider template dir %s is not a directory", tmplDir)
 	}
 	_, err = url.Parse(cfg.Provider.URL)
 	if err != nil {
@@ -102,20 +104,44 @@ func (d *Driver) Attach(ctx *service.Context, mux *mux.Router) error {
 		return fmt.Errorf("error on subroute path: %v", err)
 	}
 	d.mux = driverRoute.Subrouter()
-	d.mux.Handle("/return", ctx.RateLimitHandler(d.ReturnHandler())).Name("returnHandler")
-	d.mux.Handle("/cancel", ctx.RateLimitHandler(d.CancelHandler())).Name("cancelHandler")
-	staticDir := path.Join(d.tmplDir, "static")
+	d.mux.Handle("/return", ctx.InstrumentHandler("provider", PaypalDriverPath+"/return", ctx.RateLimitHandler(d.ReturnHandler()))).Name("returnHandler")
+	d.mux.Handle("/cancel", ctx.InstrumentHandler("provider", PaypalDriverPath+"/cancel", ctx.RateLimitHandler(d.CancelHandler()))).Name("cancelHandler")
+	staticDir := path.Join(tmplDir, "static")
 	d.log.Info("serving static dir", log15.Ctx{
 		"staticDir": staticDir,
 		"prefix":    u.Path + "/static",
 	})
-	d.mux.PathPrefix("/static").Handler(http.StripPrefix(u.Path+"/static", http.FileServer(http.Dir(staticDir)))).Name("staticHandler")
+	d.mux.PathPrefix("/static").Handler(http.StripPrefix(u.Path+"/static", d.staticHandler())).Name("staticHandler")
 
 	d.oauth = NewOAuthTransportStore()
 
 	return nil
 }
 
+// templateDir returns the template dir of the driver
+//
+// It is read from the config on use, so it changes when the config is reloaded.
+func (d *Driver) templateDir() string {
+	return path.Join(d.ctx.Config().Provider.ProviderTemplateDir, providerTemplateDir)
+}
+
+// staticHandler serves the static files of the template dir
+func (d *Driver) staticHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.Dir(path.Join(d.templateDir(), "static"))).ServeHTTP(w, r)
+	})
+}
+
+// Ready implements the provider.HealthChecker
+//
+// The driver is ready if it is attached and its templates are readable.
+func (d *Driver) Ready() error {
+	if d.ctx == nil {
+		return ErrNotAttached
+	}
+	return service.DirHealthCheck("", d.templateDir()).Check()
+}
+
 func (d *Driver) baseURL() (*url.URL, error) {
 	return url.Parse(d.ctx.Config().Provider.URL)
 }
@@ -142,13 +168,27 @@ func (d *Driver) setPayPalError(p *payment.Payment, data []byte) {
 	}
 }
 
+// setRequestID sets the request ID header on a request to PayPal
+func setRequestID(req *http.Request, requestID string) {
+	if requestID != "" {
+		req.Header.Set(service.RequestIDHeader, requestID)
+	}
+}
+
 // execute an HTTP request
+//
+// The operation names the request in the provider metrics.
 func httpDo(
 	ctx *service.Context,
+	operation string,
 	createTr func() (*oauth.Transport, error),
 	req *http.Request,
-	f func(*http.Response, error) error) error {
+	f func(*http.Response, error) error) (err error) {
 
+	start := time.Now()
+	defer func() {
+		ctx.Metrics().ObserveProvider(providerName, operation, start, err)
+	}()
 	tr, err := createTr()
 	if err != nil {
 		ctx.Log().Error("error on auth transport", log15.Ctx{"err": err})
@@ -250,18 +290,20 @@ func (d *Driver) getPayment(p *payment.Payment) {
 		return nil
 	}
 
-	err = httpDo(d.ctx, d.oAuthTransportFunc(p, cfg), req, responseFunc)
+	err = httpDo(d.ctx, "get_payment", d.oAuthTransportFunc(p, cfg), req, responseFunc)
 	if err != nil {
 		log.Error("error on executing HTTP request", log15.Ctx{"err": err})
 	}
 }
 
-func (d *Driver) InitPayment(p *payment.Payment, method *payment_method.Method) (http.Handler, error) {
+func (d *Driver) InitPayment(r *http.Request, p *payment.Payment, method *payment_method.Method) (http.Handler, error) {
+	requestID := service.RequestID(r)
 	log := d.log.New(log15.Ctx{
 		"method":          "InitPayment",
 		"projectID":       p.ProjectID(),
 		"paymentID":       p.ID(),
 		"paymentMethodID": method.ID,
+		"requestID":       requestID,
 	})
 
 	var tx *sql.Tx
@@ -351,18 +393,19 @@ func (d *Driver) InitPayment(p *payment.Payment, method *payment_method.Method)
 		return nil, ErrDatabase
 	}
 
-	go d.doInit(cfg, endpoint, p, string(jsonBytes))
+	go d.doInit(cfg, endpoint, p, string(jsonBytes), requestID)
 
 	return d.statusHandler(currentTx, p, d.InitPageHandler(p)), nil
 }
 
-func (d *Driver) doInit(cfg *Config, reqURL *url.URL, p *payment.Payment, body string) {
+func (d *Driver) doInit(cfg *Config, reqURL *url.URL, p *payment.Payment, body, requestID string) {
 	log := d.log.New(log15.Ctx{
 		"method":      "doInit",
 		"projectID":   p.ProjectID(),
 		"paymentID":   p.ID(),
 		"methodKey":   cfg.MethodKey,
 		"requestBody": body,
+		"requestID":   requestID,
 	})
 	if Debug {
 		log.Debug("posting...")
@@ -374,6 +417,7 @@ func (d *Driver) doInit(cfg *Config, reqURL *url.URL, p *payment.Payment, body s
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setRequestID(req, requestID)
 	responseFunc := func(resp *http.Response, err error) error {
 		if err != nil {
 			log.Error("error on HTTP", log15.Ctx{"err": err})
@@ -461,7 +505,7 @@ func (d *Driver) doInit(cfg *Config, reqURL *url.URL, p *payment.Payment, body s
 		return nil
 	}
 
-	err = httpDo(d.ctx, d.oAuthTransportFunc(p, cfg), req, responseFunc)
+	err = httpDo(d.ctx, "create_payment", d.oAuthTransportFunc(p, cfg), req, responseFunc)
 	if err != nil {
 		log.Error("error on create payment request", log15.Ctx{"err": err})
 	}
@@ -469,7 +513,7 @@ func (d *Driver) doInit(cfg *Config, reqURL *url.URL, p *payment.Payment, body s
 
 func (d *Driver) ReturnHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "ReturnHandler"})
+		log := d.log.New(log15.Ctx{"method": "ReturnHandler", "requestID": service.RequestID(r)})
 		paymentIDStr := r.URL.Query().Get(paymentIDParam)
 		if paymentIDStr == "" {
 			log.Info("request without payment ID")
@@ -622,19 +666,20 @@ func (d *Driver) ReturnHandler() http.Handler {
 			return
 		}
 
-		go d.executePayment(cfg, execURL, p, currentTx.Intent.String, string(execJSON))
+		go d.executePayment(cfg, execURL, p, currentTx.Intent.String, string(execJSON), service.RequestID(r))
 
 		d.statusHandler(execTx, p, d.ReturnPageHandler(p)).ServeHTTP(w, r)
 	})
 }
 
-func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment, intent string, body string) {
+func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment, intent, body, requestID string) {
 	log := d.log.New(log15.Ctx{
 		"method":    "executePayment",
 		"projectID": p.ProjectID(),
 		"paymentID": p.ID(),
 		"intent":    intent,
 		"body":      body,
+		"requestID": requestID,
 	})
 	log.Debug("executing payment...")
 
@@ -656,6 +701,7 @@ func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment
 		d.setPayPalError(p, nil)
 		return
 	}
+	paymentTx.SetRequestID(requestID)
 
 	req, err := http.NewRequest("POST", reqURL.String(), strings.NewReader(body))
 	if err != nil {
@@ -663,6 +709,7 @@ func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setRequestID(req, requestID)
 	responseFunc := func(resp *http.Response, err error) error {
 		if err != nil {
 			log.Error("error on request", log15.Ctx{"err": err})
@@ -760,7 +807,7 @@ func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment
 
 		return nil
 	}
-	err = httpDo(d.ctx, d.oAuthTransportFunc(p, cfg), req, responseFunc)
+	err = httpDo(d.ctx, "execute_payment", d.oAuthTransportFunc(p, cfg), req, responseFunc)
 	if err != nil {
 		log.Error("error on executing HTTP request", log15.Ctx{"err": err})
 	}
@@ -768,7 +815,7 @@ func (d *Driver) executePayment(cfg *Config, reqURL *url.URL, p *payment.Payment
 
 func (d *Driver) CancelHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "CancelHandler"})
+		log := d.log.New(log15.Ctx{"method": "CancelHandler", "requestID": service.RequestID(r)})
 		paymentIDStr := r.URL.Query().Get(paymentIDParam)
 		if paymentIDStr == "" {
 			log.Info("request without payment ID")
@@ -844,6 +891,7 @@ func (d *Driver) CancelHandler() http.Handler {
 				d.PaymentErrorHandler(p).ServeHTTP(w, r)
 				return
 			}
+			paymentTx.SetRequestID(service.RequestID(r))
 			err = d.paymentService.SetPaymentTransaction(tx, paymentTx)
 			if err != nil {
 				log.Error("error creating payment transaction", log15.Ctx{"err": err})
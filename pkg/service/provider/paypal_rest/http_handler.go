@@ -13,6 +13,7 @@ import (
 	tmpl "github.com/fritzpay/paymentd/pkg/template"
 
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/service"
 	"gopkg.in/inconshreveable/log15.v2"
 )
 
@@ -76,10 +77,10 @@ func writeTemplateBuf(log log15.Logger, w io.Writer, tmpl *template.Template, tm
 func (d *Driver) InitPageHandler(p *payment.Payment) http.Handler {
 	const baseName = "init.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "InitPageHandler"})
+		log := d.log.New(log15.Ctx{"method": "InitPageHandler", "requestID": service.RequestID(r)})
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		tmpl := template.New("init")
-		err := d.getTemplate(tmpl, d.tmplDir, p.Config.Locale.String, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), p.Config.Locale.String, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			w.WriteHeader(http.StatusInternalServerError)
@@ -100,7 +101,7 @@ func (d *Driver) InitPageHandler(p *payment.Payment) http.Handler {
 func (d *Driver) InternalErrorHandler(p *payment.Payment) http.Handler {
 	const baseName = "internal_error.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "InternalErrorHandler"})
+		log := d.log.New(log15.Ctx{"method": "InternalErrorHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		// do log so we can find the timestamp in the logs
@@ -111,7 +112,7 @@ func (d *Driver) InternalErrorHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("internal_error")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
@@ -131,7 +132,7 @@ func (d *Driver) BadRequestHandler() http.Handler {
 func (d *Driver) NotFoundHandler(p *payment.Payment) http.Handler {
 	const baseName = "not_found.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "NotFoundHandler"})
+		log := d.log.New(log15.Ctx{"method": "NotFoundHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		// do log so we can find the timestamp in the logs
@@ -142,7 +143,7 @@ func (d *Driver) NotFoundHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("not_found")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
@@ -157,11 +158,12 @@ func (d *Driver) CancelPageHandler(p *payment.Payment) http.Handler {
 			"method":    "CancelPageHandler",
 			"projectID": p.ProjectID(),
 			"paymentID": p.PaymentID(),
+			"requestID": service.RequestID(r),
 		})
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		tmpl := template.New("cancel")
 		const baseName = "cancel.html.tmpl"
-		err := d.getTemplate(tmpl, d.tmplDir, p.Config.Locale.String, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), p.Config.Locale.String, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			w.WriteHeader(http.StatusInternalServerError)
@@ -181,11 +183,12 @@ func (d *Driver) ReturnPageHandler(p *payment.Payment) http.Handler {
 			"method":    "ReturnPageHandler",
 			"projectID": p.ProjectID(),
 			"paymentID": p.PaymentID(),
+			"requestID": service.RequestID(r),
 		})
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		tmpl := template.New("return")
 		const baseName = "return.html.tmpl"
-		err := d.getTemplate(tmpl, d.tmplDir, p.Config.Locale.String, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), p.Config.Locale.String, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			w.WriteHeader(http.StatusInternalServerError)
@@ -202,7 +205,7 @@ func (d *Driver) ReturnPageHandler(p *payment.Payment) http.Handler {
 func (d *Driver) SuccessHandler(p *payment.Payment) http.Handler {
 	const baseName = "success.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "SuccessHandler"})
+		log := d.log.New(log15.Ctx{"method": "SuccessHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		locale := defaultLocale
@@ -210,7 +213,7 @@ func (d *Driver) SuccessHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("success")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
@@ -226,6 +229,7 @@ func (d *Driver) ApprovalHandler(tx *Transaction, p *payment.Payment) http.Handl
 			"projectID":            p.ProjectID(),
 			"paymentID":            p.PaymentID(),
 			"transactionTimestamp": tx.Timestamp.UnixNano(),
+			"requestID":            service.RequestID(r),
 		})
 		links, err := tx.PayPalLinks()
 		if err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
 
+	"github.com/fritzpay/paymentd/pkg/envelope"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
 )
 
@@ -42,6 +43,11 @@ WHERE
 	)
 `
 
+// configAAD binds a sealed column to its config version
+func configAAD(cfg *Config, column string) []byte {
+	return envelope.AAD("provider_paypal_config", column, cfg.ProjectID, cfg.MethodKey, cfg.Created)
+}
+
 func scanConfig(row *sql.Row) (*Config, error) {
 	cfg := &Config{}
 	err := row.Scan(
@@ -60,6 +66,14 @@ func scanConfig(row *sql.Row) (*Config, error) {
 		}
 		return cfg, err
 	}
+	cfg.ClientID, err = envelope.OpenString(cfg.ClientID, configAAD(cfg, "client_id"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Secret, err = envelope.OpenString(cfg.Secret, configAAD(cfg, "secret"))
+	if err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
@@ -73,6 +87,43 @@ func ConfigByPaymentMethodDB(db *sql.DB, method *payment_method.Method) (*Config
 	return scanConfig(row)
 }
 
+const insertConfig = `
+INSERT INTO provider_paypal_config
+(project_id, method_key, created, created_by, endpoint, client_id, secret, type)
+VALUES
+(?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// InsertConfigTx saves a new version of the PayPal config of a payment method
+//
+// The client ID and the secret are sealed with the envelope keyring.
+func InsertConfigTx(db *sql.Tx, cfg *Config) error {
+	clientID, err := envelope.SealString(cfg.ClientID, configAAD(cfg, "client_id"))
+	if err != nil {
+		return err
+	}
+	secret, err := envelope.SealString(cfg.Secret, configAAD(cfg, "secret"))
+	if err != nil {
+		return err
+	}
+	stmt, err := db.Prepare(insertConfig)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		cfg.ProjectID,
+		cfg.MethodKey,
+		cfg.Created,
+		cfg.CreatedBy,
+		cfg.Endpoint,
+		clientID,
+		secret,
+		cfg.Type,
+	)
+	return err
+}
+
 const selectTransaction = `
 SELECT
 	t.project_id,
@@ -145,7 +196,11 @@ WHERE
 	)
 `
 
-func scanTransactionRow(row *sql.Row) (*Transaction, error) {
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransactionRow(row rowScanner) (*Transaction, error) {
 	t := &Transaction{}
 	var ts int64
 	err := row.Scan(
@@ -203,6 +258,34 @@ func TransactionByPaymentIDAndTypeDB(db *sql.DB, paymentID payment.PaymentID, t
 	return scanTransactionRow(row)
 }
 
+const selectTransactionsByPaymentID = selectTransaction + `
+FROM provider_paypal_transaction AS t
+WHERE
+	t.project_id = ?
+	AND
+	t.payment_id = ?
+ORDER BY t.timestamp ASC
+`
+
+// TransactionsByPaymentIDDB returns all PayPal transactions of the given payment,
+// the earliest first
+func TransactionsByPaymentIDDB(db *sql.DB, paymentID payment.PaymentID) ([]*Transaction, error) {
+	rows, err := db.Query(selectTransactionsByPaymentID, paymentID.ProjectID, paymentID.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	txs := make([]*Transaction, 0)
+	for rows.Next() {
+		t, err := scanTransactionRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, t)
+	}
+	return txs, rows.Err()
+}
+
 const insertTransaction = `
 INSERT INTO provider_paypal_transaction
 (project_id, payment_id, timestamp, type, nonce, intent, paypal_id, payer_id, paypal_create_time, paypal_state, paypal_update_time, links, data)
@@ -8,6 +8,7 @@ import (
 	testPay "github.com/fritzpay/paymentd/pkg/testutil/payment"
 
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
 
 	"github.com/fritzpay/paymentd/pkg/service/provider/paypal_rest"
 
@@ -92,3 +93,40 @@ func TestPaypalTransaction(t *testing.T) {
 		})
 	}))
 }
+
+func TestPaypalConfig(t *testing.T) {
+	Convey("Given a payment DB", t, testutil.WithPaymentDB(t, func(db *sql.DB) {
+		Convey("Given a db tx", func() {
+			tx, err := db.Begin()
+			So(err, ShouldBeNil)
+			Reset(func() {
+				err = tx.Rollback()
+				So(err, ShouldBeNil)
+			})
+
+			Convey("When inserting a config", func() {
+				cfg := &paypal_rest.Config{
+					ProjectID: 1,
+					MethodKey: "paypal_test",
+					Created:   time.Now().UTC().Round(time.Second),
+					CreatedBy: "test",
+					Endpoint:  "https://api.sandbox.paypal.com",
+					ClientID:  "client",
+					Secret:    "secret",
+					Type:      paypal_rest.IntentSale,
+				}
+				err = paypal_rest.InsertConfigTx(tx, cfg)
+				So(err, ShouldBeNil)
+
+				Convey("It should be the config of the payment method", func() {
+					pm := &payment_method.Method{ProjectID: 1, MethodKey: "paypal_test"}
+					cfgRet, err := paypal_rest.ConfigByPaymentMethodTx(tx, pm)
+					So(err, ShouldBeNil)
+					So(cfgRet.ClientID, ShouldEqual, "client")
+					So(cfgRet.Secret, ShouldEqual, "secret")
+					So(cfgRet.Type, ShouldEqual, paypal_rest.IntentSale)
+				})
+			})
+		})
+	}))
+}
@@ -76,6 +76,22 @@ func (s *Service) AttachDrivers(mux *mux.Router) error {
 	return nil
 }
 
+// HealthChecks returns the readiness checks of the attached drivers
+func (s *Service) HealthChecks() []service.HealthCheck {
+	checks := make([]service.HealthCheck, 0, len(s.drivers))
+	for name, dr := range s.drivers {
+		check := service.HealthCheck{
+			Name:  "provider." + name,
+			Check: func() error { return nil },
+		}
+		if hc, ok := dr.(HealthChecker); ok {
+			check.Check = hc.Ready
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
 func (s *Service) Driver(method *payment_method.Method) (Driver, error) {
 	if dr, ok := s.drivers[method.Provider.Name]; !ok {
 		return nil, ErrNoDriver
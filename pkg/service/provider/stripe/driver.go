@@ -27,6 +27,9 @@ const (
 	// StripeDriverPath is the (sub-)path under which Stripe driver endpoints
 	// will be attached
 	StripeDriverPath = "/stripe"
+
+	// providerName is the name of the provider in the metrics
+	providerName = "stripe"
 )
 
 const (
@@ -37,16 +40,16 @@ const (
 )
 
 var (
-	ErrDatabase = errors.New("database error")
-	ErrInternal = errors.New("stripe driver internal error")
-	ErrHTTP     = errors.New("HTTP error")
-	ErrProvider = errors.New("provider error")
+	ErrDatabase    = errors.New("database error")
+	ErrInternal    = errors.New("stripe driver internal error")
+	ErrHTTP        = errors.New("HTTP error")
+	ErrProvider    = errors.New("provider error")
+	ErrNotAttached = errors.New("driver not attached")
 )
 
 // Driver is the Stripe provider driver
 type Driver struct {
 	context        *service.Context
-	tmplDir        string
 	log            log15.Logger
 	mux            *mux.Router
 	paymentService *paymentService.Service
@@ -64,17 +67,17 @@ func (d *Driver) Attach(ctx *service.Context, m *mux.Router) error {
 	if cfg.Provider.ProviderTemplateDir == "" {
 		return fmt.Errorf("provider template dir not set")
 	}
-	d.tmplDir = path.Join(cfg.Provider.ProviderTemplateDir, providerTemplateDir)
-	dirInfo, err := os.Stat(d.tmplDir)
+	tmplDir := d.templateDir()
+	dirInfo, err := os.Stat(tmplDir)
 	if err != nil {
 		d.log.Error("error opening template dir", log15.Ctx{
 			"err":     err,
-			"tmplDir": d.tmplDir,
+			"tmplDir": tmplDir,
 		})
 		return err
 	}
 	if !dirInfo.IsDir() {
-		return fmt.Errorf("provider template dir %s is not a directory", d.tmplDir)
+		return fmt.Errorf("provider template dir %s is not a directory", tmplDir)
 	}
 	_, err = url.Parse(cfg.Provider.URL)
 	if err != nil {
@@ -92,13 +95,13 @@ func (d *Driver) Attach(ctx *service.Context, m *mux.Router) error {
 		return fmt.Errorf("error on subroute path: %v", err)
 	}
 	d.mux = driverRoute.Subrouter()
-	d.mux.Handle("/process", ctx.RateLimitHandler(d.ProcessHandler())).Name("processFormHandler")
-	staticDir := path.Join(d.tmplDir, "static")
+	d.mux.Handle("/process", ctx.InstrumentHandler("provider", StripeDriverPath+"/process", ctx.RateLimitHandler(d.ProcessHandler()))).Name("processFormHandler")
+	staticDir := path.Join(tmplDir, "static")
 	d.log.Info("serving static dir", log15.Ctx{
 		"staticDir": staticDir,
 		"prefix":    url.Path + "/static",
 	})
-	d.mux.PathPrefix("/static").Handler(http.StripPrefix(url.Path+"/static", http.FileServer(http.Dir(staticDir)))).Name("staticHandler")
+	d.mux.PathPrefix("/static").Handler(http.StripPrefix(url.Path+"/static", d.staticHandler())).Name("staticHandler")
 
 	if err != nil {
 		d.log.Error("error initializing payment service", log15.Ctx{"err": err})
@@ -108,7 +111,31 @@ func (d *Driver) Attach(ctx *service.Context, m *mux.Router) error {
 	return err
 }
 
-func (d *Driver) InitPayment(p *payment.Payment, pm *payment_method.Method) (http.Handler, error) {
+// templateDir returns the template dir of the driver
+//
+// It is read from the config on use, so it changes when the config is reloaded.
+func (d *Driver) templateDir() string {
+	return path.Join(d.context.Config().Provider.ProviderTemplateDir, providerTemplateDir)
+}
+
+// staticHandler serves the static files of the template dir
+func (d *Driver) staticHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.FileServer(http.Dir(path.Join(d.templateDir(), "static"))).ServeHTTP(w, r)
+	})
+}
+
+// Ready implements the provider.HealthChecker
+//
+// The driver is ready if it is attached and its templates are readable.
+func (d *Driver) Ready() error {
+	if d.context == nil {
+		return ErrNotAttached
+	}
+	return service.DirHealthCheck("", d.templateDir()).Check()
+}
+
+func (d *Driver) InitPayment(r *http.Request, p *payment.Payment, pm *payment_method.Method) (http.Handler, error) {
 
 	// start transaction
 	// show stripe.js form
@@ -120,10 +147,10 @@ func (d *Driver) InitPayment(p *payment.Payment, pm *payment_method.Method) (htt
 func (d *Driver) InitPageHandler(p *payment.Payment) http.Handler {
 	const baseName = "form.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "InitPageHandler"})
+		log := d.log.New(log15.Ctx{"method": "InitPageHandler", "requestID": service.RequestID(r)})
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		tmpl := template.New("init")
-		err := d.getTemplate(tmpl, d.tmplDir, p.Config.Locale.String, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), p.Config.Locale.String, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			w.WriteHeader(http.StatusInternalServerError)
@@ -141,7 +168,7 @@ func (d *Driver) InitPageHandler(p *payment.Payment) http.Handler {
 // takes the post request and handles the stripe checkout
 func (d *Driver) ProcessHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "ProcessHandler"})
+		log := d.log.New(log15.Ctx{"method": "ProcessHandler", "requestID": service.RequestID(r)})
 
 		r.ParseForm()
 		paymentIDStr := r.Form.Get("paymentid")
@@ -193,7 +220,10 @@ func (d *Driver) ProcessHandler() http.Handler {
 				Token: stripeTokenStr,
 			},
 		}
+		params.AddMeta("request_id", service.RequestID(r))
+		start := time.Now()
 		ch, err := charge.New(params)
+		d.context.Metrics().ObserveProvider(providerName, "create_charge", start, err)
 		if err != nil {
 			log.Error("error retrieving stripe charge object", log15.Ctx{"err": err})
 			d.InternalErrorHandler(nil).ServeHTTP(w, r)
@@ -219,10 +249,10 @@ func (d *Driver) ProcessHandler() http.Handler {
 func (d *Driver) processFormPageHandler(p *payment.Payment) http.Handler {
 	const baseName = "form.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "InitPageHandler"})
+		log := d.log.New(log15.Ctx{"method": "InitPageHandler", "requestID": service.RequestID(r)})
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		tmpl := template.New("init")
-		err := d.getTemplate(tmpl, d.tmplDir, p.Config.Locale.String, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), p.Config.Locale.String, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			w.WriteHeader(http.StatusInternalServerError)
@@ -286,7 +316,7 @@ func (d *Driver) BadRequestHandler() http.Handler {
 func (d *Driver) NotFoundHandler(p *payment.Payment) http.Handler {
 	const baseName = "not_found.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "NotFoundHandler"})
+		log := d.log.New(log15.Ctx{"method": "NotFoundHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		// do log so we can find the timestamp in the logs
@@ -297,7 +327,7 @@ func (d *Driver) NotFoundHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("not_found")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
@@ -312,7 +342,7 @@ func (d *Driver) NotFoundHandler(p *payment.Payment) http.Handler {
 func (d *Driver) InternalErrorHandler(p *payment.Payment) http.Handler {
 	const baseName = "internal_error.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "InternalErrorHandler"})
+		log := d.log.New(log15.Ctx{"method": "InternalErrorHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		// do log so we can find the timestamp in the logs
@@ -323,7 +353,7 @@ func (d *Driver) InternalErrorHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("internal_error")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
@@ -338,7 +368,7 @@ func (d *Driver) InternalErrorHandler(p *payment.Payment) http.Handler {
 func (d *Driver) SuccessHandler(p *payment.Payment) http.Handler {
 	const baseName = "success.html.tmpl"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := d.log.New(log15.Ctx{"method": "SuccessHandler"})
+		log := d.log.New(log15.Ctx{"method": "SuccessHandler", "requestID": service.RequestID(r)})
 
 		tmplData := d.templatePaymentData(p)
 		locale := defaultLocale
@@ -346,7 +376,7 @@ func (d *Driver) SuccessHandler(p *payment.Payment) http.Handler {
 			locale = p.Config.Locale.String
 		}
 		tmpl := template.New("success")
-		err := d.getTemplate(tmpl, d.tmplDir, locale, baseName)
+		err := d.getTemplate(tmpl, d.templateDir(), locale, baseName)
 		if err != nil {
 			log.Error("error initializing template", log15.Ctx{"err": err})
 			return
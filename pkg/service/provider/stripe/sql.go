@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/fritzpay/paymentd/pkg/envelope"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
 )
 
@@ -37,6 +38,11 @@ WHERE
 	)
 `
 
+// secretKeyAAD binds the sealed secret key to its config version
+func secretKeyAAD(cfg *Config) []byte {
+	return envelope.AAD("provider_stripe_config", "secure_key", cfg.ProjectID, cfg.MethodKey, cfg.Created)
+}
+
 func scanConfig(row *sql.Row) (*Config, error) {
 	cfg := &Config{}
 	err := row.Scan(
@@ -53,6 +59,10 @@ func scanConfig(row *sql.Row) (*Config, error) {
 		}
 		return cfg, err
 	}
+	cfg.SecretKey, err = envelope.OpenString(cfg.SecretKey, secretKeyAAD(cfg))
+	if err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
@@ -65,3 +75,34 @@ func ConfigByPaymentMethodDB(db *sql.DB, method *payment_method.Method) (*Config
 	row := db.QueryRow(selectConfigByProjectIDAndMethodKey, method.ProjectID, method.MethodKey)
 	return scanConfig(row)
 }
+
+const insertConfig = `
+INSERT INTO provider_stripe_config
+(project_id, method_key, created, created_by, secure_key, public_key)
+VALUES
+(?, ?, ?, ?, ?, ?)
+`
+
+// InsertConfigTx saves a new version of the Stripe config of a payment method
+//
+// The secret key is sealed with the envelope keyring.
+func InsertConfigTx(db *sql.Tx, cfg *Config) error {
+	secretKey, err := envelope.SealString(cfg.SecretKey, secretKeyAAD(cfg))
+	if err != nil {
+		return err
+	}
+	stmt, err := db.Prepare(insertConfig)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		cfg.ProjectID,
+		cfg.MethodKey,
+		cfg.Created,
+		cfg.CreatedBy,
+		secretKey,
+		cfg.PublicKey,
+	)
+	return err
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+// Reload applies the reloadable values of the given config to the context
+//
+// Template dirs and timeouts are read from the config on use. The rate limits keep
+// the state of their buckets. Unless the authorization keys are stored in the
+// database, the configured keys replace the keys of the keychains. The log and the
+// timeouts of the HTTP servers are not handled by the context.
+//
+// It returns the paths of the changed values which require a restart. These are not
+// applied.
+func (ctx *Context) Reload(cfg config.Config) ([]string, error) {
+	next, applied, restart := config.Reload(*ctx.Config(), cfg)
+	if len(applied) == 0 {
+		return restart, nil
+	}
+	for _, d := range []config.Duration{
+		next.API.Service.ReadTimeout,
+		next.API.Service.WriteTimeout,
+		next.API.Timeout,
+		next.Web.Service.ReadTimeout,
+		next.Web.Service.WriteTimeout,
+		next.Web.Timeout,
+		next.Metrics.Service.ReadTimeout,
+		next.Metrics.Service.WriteTimeout,
+	} {
+		_, err := d.Duration()
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %s: %v", d, err)
+		}
+	}
+	apiKeys, err := decodeKeys(next.API.AuthKeys)
+	if err != nil {
+		return nil, fmt.Errorf("error loading API keys from config: %v", err)
+	}
+	webKeys, err := decodeKeys(next.Web.AuthKeys)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Web keys from config: %v", err)
+	}
+
+	ctx.mu.Lock()
+	ctx.cfg = &next
+	ctx.rateLimits = ctx.rateLimits.update(next)
+	ctx.mu.Unlock()
+
+	if !next.Keychain.Database {
+		// without configured keys, the keychains hold generated keys which are kept
+		if len(apiKeys) > 0 {
+			ctx.apiKeychain.SetKeys(apiKeys)
+		}
+		if len(webKeys) > 0 {
+			ctx.webKeychain.SetKeys(webKeys)
+		}
+	}
+	ctx.log.Info("config reloaded", log15.Ctx{"changed": applied})
+	return restart, nil
+}
+
+// decodeKeys decodes the hex-encoded keys in the order of the keychain, i.e. the
+// last configured key is the preferred key
+func decodeKeys(keys []string) ([][]byte, error) {
+	bin := make([][]byte, len(keys))
+	for i, k := range keys {
+		key, err := hex.DecodeString(k)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+		bin[len(keys)-1-i] = key
+	}
+	return bin, nil
+}
+
+// update returns the rate limits according to the given config
+//
+// Enabled limiters are changed in place, so they keep the state of their buckets.
+func (r RateLimits) update(cfg config.Config) RateLimits {
+	return RateLimits{
+		ProjectKey: updateLimiter(r.ProjectKey, cfg.RateLimit.ProjectKey),
+		Project:    updateLimiter(r.Project, cfg.RateLimit.Project),
+		ClientIP:   updateLimiter(r.ClientIP, cfg.RateLimit.ClientIP),
+	}
+}
+
+func updateLimiter(l *ratelimit.Limiter, cfg config.RateLimitConfig) *ratelimit.Limiter {
+	if l == nil || cfg.Rate <= 0 {
+		return ratelimit.NewLimiter(cfg.Rate, cfg.Burst)
+	}
+	l.SetLimit(cfg.Rate, cfg.Burst)
+	return l
+}
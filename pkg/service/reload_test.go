@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReload(t *testing.T) {
+	Convey("Given a new service context", t, WithContext(func(ctx *Context) {
+		cfg := *ctx.Config()
+
+		Convey("When reloading a config with changed reloadable values", func() {
+			cfg.Web.TemplateDir = "/tmp/templates"
+			cfg.RateLimit.ClientIP = config.RateLimitConfig{Rate: 1, Burst: 1}
+			cfg.API.AuthKeys = []string{"aa", "bb"}
+			restart, err := ctx.Reload(cfg)
+
+			Convey("They should be applied", func() {
+				So(err, ShouldBeNil)
+				So(len(restart), ShouldEqual, 0)
+				So(ctx.Config().Web.TemplateDir, ShouldEqual, "/tmp/templates")
+				So(ctx.RateLimits().ClientIP, ShouldNotBeNil)
+				key, err := ctx.APIKeychain().Key()
+				So(err, ShouldBeNil)
+				So(key, ShouldEqual, "bb")
+				So(ctx.APIKeychain().KeyCount(), ShouldEqual, 2)
+			})
+
+			Convey("When changing the rate limit again", func() {
+				limiter := ctx.RateLimits().ClientIP
+				cfg.RateLimit.ClientIP.Rate = 5
+				_, err := ctx.Reload(cfg)
+				So(err, ShouldBeNil)
+
+				Convey("The limiter should be changed in place", func() {
+					So(ctx.RateLimits().ClientIP, ShouldEqual, limiter)
+					rate, _ := limiter.Limit()
+					So(rate, ShouldEqual, 5)
+				})
+			})
+		})
+
+		Convey("When reloading a config with changes requiring a restart", func() {
+			cfg.API.Service.Address = ":8081"
+			restart, err := ctx.Reload(cfg)
+
+			Convey("They should be reported and not applied", func() {
+				So(err, ShouldBeNil)
+				So(restart, ShouldResemble, []string{"API.Service.Address"})
+				So(ctx.Config().API.Service.Address, ShouldEqual, ":8080")
+			})
+		})
+
+		Convey("When reloading a config with invalid values", func() {
+			cfg.API.Timeout = config.Duration("soon")
+			_, err := ctx.Reload(cfg)
+
+			Convey("It should fail without applying the config", func() {
+				So(err, ShouldNotBeNil)
+				So(ctx.Config().API.Timeout, ShouldEqual, config.Duration("5s"))
+			})
+		})
+	}))
+}
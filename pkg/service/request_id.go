@@ -0,0 +1,94 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	// RequestIDHeader is the header which carries the request ID
+	//
+	// It is read from inbound requests, returned in the responses and sent on the
+	// requests to the PSPs and on callback notifications.
+	RequestIDHeader = "X-Request-Id"
+	// ContextVarRequestIDKey is the name of the key under which the request ID
+	// will be stored in request contexts
+	ContextVarRequestIDKey = "RequestID"
+	// MaxRequestIDLength is the maximum length of a request ID. Longer IDs provided by
+	// clients will be truncated.
+	MaxRequestIDLength = 64
+
+	requestIDBytes = 16
+)
+
+// NewRequestID returns a random request ID
+//
+// It returns an empty string if no random bytes could be read.
+func NewRequestID() string {
+	b := make([]byte, requestIDBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID returns true if the ID can be used as a request ID
+//
+// A valid ID consists of 1 to MaxRequestIDLength printable ASCII characters without
+// spaces, so it can be logged and passed on in headers as is.
+func ValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// headerRequestID returns the request ID provided by the client or an empty string
+// if the client did not provide a valid ID
+func headerRequestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if len(id) > MaxRequestIDLength {
+		id = id[:MaxRequestIDLength]
+	}
+	if !ValidRequestID(id) {
+		return ""
+	}
+	return id
+}
+
+// SetRequestID assigns an ID to the request
+//
+// The ID provided by the client in the RequestIDHeader is used. If the client did
+// not provide a valid ID, a random one will be generated. The ID will be stored in the
+// request context and set on the response header.
+//
+// The request context must have been set with SetRequestContext.
+func SetRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := headerRequestID(r)
+	if id == "" {
+		id = NewRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	SetRequestContextVar(r, ContextVarRequestIDKey, id)
+	return id
+}
+
+// RequestID returns the ID of the request
+//
+// If no ID was assigned with SetRequestID, it returns the valid ID provided by the
+// client or an empty string.
+func RequestID(r *http.Request) string {
+	if ctx := RequestContext(r); ctx != nil {
+		if id, ok := ctx.Value(ContextVarRequestIDKey).(string); ok {
+			return id
+		}
+	}
+	return headerRequestID(r)
+}
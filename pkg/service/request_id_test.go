@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestID(t *testing.T) {
+	Convey("Given a new request", t, WithContext(func(ctx *Context) {
+		r, err := http.NewRequest("GET", "www.example.com", nil)
+		So(err, ShouldBeNil)
+		SetRequestContext(r, ctx)
+		Reset(func() {
+			ClearRequestContext(r)
+		})
+		w := httptest.NewRecorder()
+
+		Convey("When the client did not provide an ID", func() {
+			id := SetRequestID(w, r)
+
+			Convey("A random ID should be assigned", func() {
+				So(len(id), ShouldEqual, 2*requestIDBytes)
+				So(RequestID(r), ShouldEqual, id)
+				So(w.Header().Get(RequestIDHeader), ShouldEqual, id)
+			})
+		})
+
+		Convey("When the client provided an ID", func() {
+			r.Header.Set(RequestIDHeader, "shop-4711")
+			id := SetRequestID(w, r)
+
+			Convey("It should be used", func() {
+				So(id, ShouldEqual, "shop-4711")
+				So(RequestID(r), ShouldEqual, id)
+				So(w.Header().Get(RequestIDHeader), ShouldEqual, id)
+			})
+		})
+
+		Convey("When the client provided a long ID", func() {
+			r.Header.Set(RequestIDHeader, strings.Repeat("a", 2*MaxRequestIDLength))
+			id := SetRequestID(w, r)
+
+			Convey("It should be truncated", func() {
+				So(id, ShouldEqual, strings.Repeat("a", MaxRequestIDLength))
+			})
+		})
+
+		Convey("When the client provided an invalid ID", func() {
+			r.Header.Set(RequestIDHeader, "shop 4711")
+			id := SetRequestID(w, r)
+
+			Convey("A random ID should be assigned", func() {
+				So(id, ShouldNotEqual, "shop 4711")
+				So(ValidRequestID(id), ShouldBeTrue)
+			})
+		})
+	}))
+
+	Convey("Given a request without a context", t, func() {
+		r, err := http.NewRequest("GET", "www.example.com", nil)
+		So(err, ShouldBeNil)
+		r.Header.Set(RequestIDHeader, "shop-4711")
+
+		Convey("The ID provided by the client should be returned", func() {
+			So(RequestID(r), ShouldEqual, "shop-4711")
+		})
+	})
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fritzpay/paymentd/pkg/config"
+)
+
+const (
+	// SigningKeyIDBytes is the number of bytes of the public key hash which form the
+	// ID of a signing key
+	SigningKeyIDBytes = 8
+)
+
+var (
+	ErrInvalidSigningKey = errors.New("invalid signing key")
+	ErrInvalidPublicKey  = errors.New("invalid public key")
+	ErrNoSigningKey      = errors.New("no signing key")
+)
+
+// Signer creates signatures for signable messages
+type Signer interface {
+	Sign(msg Signable) ([]byte, error)
+	// KeyID identifies the key which was used for signing
+	//
+	// An empty key ID denotes a shared secret.
+	KeyID() string
+}
+
+type hmacSigner []byte
+
+// HMACSigner returns a signer which signs messages with the HMAC of the given shared
+// secret
+func HMACSigner(secret []byte) Signer {
+	return hmacSigner(secret)
+}
+
+func (s hmacSigner) Sign(msg Signable) ([]byte, error) {
+	return Sign(msg, []byte(s))
+}
+
+func (s hmacSigner) KeyID() string {
+	return ""
+}
+
+// SigningKey is an Ed25519 key pair for signing messages
+type SigningKey struct {
+	id  string
+	key ed25519.PrivateKey
+}
+
+// NewSigningKey creates a signing key from the given Ed25519 seed
+func NewSigningKey(seed []byte) (*SigningKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrInvalidSigningKey
+	}
+	k := &SigningKey{key: ed25519.NewKeyFromSeed(seed)}
+	k.id = PublicKeyID(k.PublicKey())
+	return k, nil
+}
+
+// GenerateSigningKey generates a new random signing key
+func GenerateSigningKey() (*SigningKey, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	_, err := rand.Read(seed)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(seed)
+}
+
+// KeyID implements Signer
+func (k *SigningKey) KeyID() string {
+	return k.id
+}
+
+// Sign implements Signer
+func (k *SigningKey) Sign(msg Signable) ([]byte, error) {
+	msgBytes, err := msg.Message()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(k.key, msgBytes), nil
+}
+
+// PublicKey returns the public key of the key pair
+func (k *SigningKey) PublicKey() ed25519.PublicKey {
+	return k.key.Public().(ed25519.PublicKey)
+}
+
+// Seed returns the hex-encoded seed of the private key
+func (k *SigningKey) Seed() string {
+	return hex.EncodeToString(k.key.Seed())
+}
+
+// PublicKeyID returns the ID of the given public key
+func PublicKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:SigningKeyIDBytes])
+}
+
+// ParsePublicKey parses a hex-encoded Ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// IsAuthenticEd25519 returns true if the signed message has a correct Ed25519
+// signature for the given public key
+func IsAuthenticEd25519(msg Signed, pub ed25519.PublicKey) (bool, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return false, ErrInvalidPublicKey
+	}
+	msgBytes, err := msg.Message()
+	if err != nil {
+		return false, err
+	}
+	sig, err := msg.Signature()
+	if err != nil {
+		return false, err
+	}
+	return ed25519.Verify(pub, msgBytes, sig), nil
+}
+
+// SigningKeyring holds the key pairs with which paymentd signs messages
+//
+// The first key is used for signing. The other keys are kept for publishing, so
+// receivers can verify messages during a rotation.
+type SigningKeyring struct {
+	m    sync.RWMutex
+	keys []*SigningKey
+}
+
+// NewSigningKeyring creates an empty signing keyring
+func NewSigningKeyring() *SigningKeyring {
+	return &SigningKeyring{}
+}
+
+// KeyCount returns the number of keys in the keyring
+func (k *SigningKeyring) KeyCount() int {
+	k.m.RLock()
+	c := len(k.keys)
+	k.m.RUnlock()
+	return c
+}
+
+// Add adds the given key to the keyring
+func (k *SigningKeyring) Add(key *SigningKey) {
+	k.m.Lock()
+	k.keys = append(k.keys, key)
+	k.m.Unlock()
+}
+
+// AddKey adds a key with the given hex-encoded seed to the keyring
+func (k *SigningKeyring) AddKey(hexSeed string) error {
+	seed, err := hex.DecodeString(hexSeed)
+	if err != nil {
+		return ErrInvalidSigningKey
+	}
+	key, err := NewSigningKey(seed)
+	if err != nil {
+		return err
+	}
+	k.Add(key)
+	return nil
+}
+
+// Current returns the key which is used for signing
+func (k *SigningKeyring) Current() (*SigningKey, error) {
+	k.m.RLock()
+	defer k.m.RUnlock()
+	if len(k.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return k.keys[0], nil
+}
+
+// Keys returns all keys of the keyring
+func (k *SigningKeyring) Keys() []*SigningKey {
+	k.m.RLock()
+	keys := make([]*SigningKey, len(k.keys))
+	copy(keys, k.keys)
+	k.m.RUnlock()
+	return keys
+}
+
+// ReadSigningKeys reads hex-encoded seeds from the given reader, one per line
+//
+// Empty lines and lines starting with # are ignored.
+func ReadSigningKeys(r io.Reader) ([]string, error) {
+	var seeds []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	return seeds, s.Err()
+}
+
+func signingKeysFromConfig(cfg config.Config) ([]string, error) {
+	if cfg.Signing.PrivateKeyFile == "" {
+		return cfg.Signing.PrivateKeys, nil
+	}
+	f, err := os.Open(cfg.Signing.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSigningKeys(f)
+}
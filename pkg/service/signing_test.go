@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/sha256"
+	"hash"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type signedTestMsg struct {
+	msg []byte
+	sig []byte
+}
+
+func (t signedTestMsg) HashFunc() func() hash.Hash {
+	return sha256.New
+}
+
+func (t signedTestMsg) Message() ([]byte, error) {
+	return t.msg, nil
+}
+
+func (t signedTestMsg) Signature() ([]byte, error) {
+	return t.sig, nil
+}
+
+func TestSigningKey(t *testing.T) {
+	Convey("Given a generated signing key", t, func() {
+		key, err := GenerateSigningKey()
+		So(err, ShouldBeNil)
+		So(len(key.KeyID()), ShouldEqual, 2*SigningKeyIDBytes)
+
+		Convey("When signing a message", func() {
+			msg := signedTestMsg{msg: []byte("test message")}
+			msg.sig, err = key.Sign(msg)
+			So(err, ShouldBeNil)
+
+			Convey("It should be authentic with the public key", func() {
+				auth, err := IsAuthenticEd25519(msg, key.PublicKey())
+				So(err, ShouldBeNil)
+				So(auth, ShouldBeTrue)
+			})
+			Convey("It should not be authentic with another public key", func() {
+				other, err := GenerateSigningKey()
+				So(err, ShouldBeNil)
+				auth, err := IsAuthenticEd25519(msg, other.PublicKey())
+				So(err, ShouldBeNil)
+				So(auth, ShouldBeFalse)
+			})
+			Convey("A modified message should not be authentic", func() {
+				msg.msg = []byte("forged message")
+				auth, err := IsAuthenticEd25519(msg, key.PublicKey())
+				So(err, ShouldBeNil)
+				So(auth, ShouldBeFalse)
+			})
+		})
+
+		Convey("When restoring the key from its seed", func() {
+			kr := NewSigningKeyring()
+			err := kr.AddKey(key.Seed())
+			So(err, ShouldBeNil)
+
+			Convey("It should have the same ID", func() {
+				current, err := kr.Current()
+				So(err, ShouldBeNil)
+				So(current.KeyID(), ShouldEqual, key.KeyID())
+			})
+		})
+	})
+
+	Convey("Given an empty signing keyring", t, func() {
+		kr := NewSigningKeyring()
+
+		Convey("It should not have a current key", func() {
+			_, err := kr.Current()
+			So(err, ShouldEqual, ErrNoSigningKey)
+		})
+		Convey("It should reject invalid seeds", func() {
+			So(kr.AddKey("abcd"), ShouldEqual, ErrInvalidSigningKey)
+			So(kr.AddKey("xyz"), ShouldEqual, ErrInvalidSigningKey)
+		})
+	})
+
+	Convey("Given a signing key file", t, func() {
+		f := "# comment\n\n" + strings.Repeat("ab", 32) + "\n"
+		seeds, err := ReadSigningKeys(strings.NewReader(f))
+		So(err, ShouldBeNil)
+		So(len(seeds), ShouldEqual, 1)
+	})
+
+	Convey("Given an HMAC signer", t, func() {
+		s := HMACSigner([]byte("secret"))
+		msg := TestMsg{msg: []byte("test message"), key: []byte("secret")}
+
+		Convey("It should create the same signature as Sign", func() {
+			sig, err := s.Sign(msg)
+			So(err, ShouldBeNil)
+			expected, err := msg.Signature()
+			So(err, ShouldBeNil)
+			So(sig, ShouldResemble, expected)
+			So(s.KeyID(), ShouldBeBlank)
+		})
+	})
+}
@@ -2,9 +2,11 @@ package web
 
 import (
 	"fmt"
+	"math/rand"
 	"net/http"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
@@ -32,10 +34,13 @@ type Handler struct {
 	router  *mux.Router
 
 	paymentService *paymentService.Service
-	templateDir    string
 	keyChain       *service.Keychain
 
 	providerService *provider.Service
+
+	// random source for payment method routing
+	mRand sync.Mutex
+	rand  *rand.Rand
 }
 
 func NewHandler(ctx *service.Context) (*Handler, error) {
@@ -46,6 +51,7 @@ func NewHandler(ctx *service.Context) (*Handler, error) {
 		}),
 
 		router: mux.NewRouter(),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 
 	var err error
@@ -78,7 +84,6 @@ func NewHandler(ctx *service.Context) (*Handler, error) {
 	if err := h.requireDir(cfg.Web.TemplateDir); err != nil {
 		return nil, fmt.Errorf("error on template dir: %v", err)
 	}
-	h.templateDir = cfg.Web.TemplateDir
 
 	err = h.registerPayment()
 	if err != nil {
@@ -99,6 +104,8 @@ func NewHandler(ctx *service.Context) (*Handler, error) {
 		return nil, err
 	}
 
+	h.registerHealth()
+
 	return h, nil
 }
 
@@ -117,11 +124,34 @@ func (h *Handler) registerPayment() error {
 	h.log.Info("registering web payment handler...")
 	h.router.Handle(
 		PaymentPath,
-		h.paymentDefaultsHandler(h.ctx.RateLimitHandler(h.PaymentHandler()))).
+		h.ctx.InstrumentHandler("web", PaymentPath,
+			h.paymentDefaultsHandler(h.ctx.RateLimitHandler(h.ctx.ClientRateLimitHandler(h.PaymentHandler(), tooManyRequests))))).
 		Methods("GET")
 	return nil
 }
 
+func (h *Handler) registerHealth() {
+	h.log.Info("registering health endpoints...")
+	cfg := h.ctx.Config()
+	// the template dirs are read on each check, since they can be reloaded
+	checks := []service.HealthCheck{
+		{Name: "dir.template", Check: func() error {
+			return service.DirHealthCheck("", h.ctx.Config().Web.TemplateDir).Check()
+		}},
+	}
+	if cfg.Web.PubWWWDir != "" {
+		checks = append(checks, service.DirHealthCheck("dir.public", cfg.Web.PubWWWDir))
+	}
+	if cfg.Provider.ProviderTemplateDir != "" {
+		checks = append(checks, service.HealthCheck{Name: "dir.provider_template", Check: func() error {
+			return service.DirHealthCheck("", h.ctx.Config().Provider.ProviderTemplateDir).Check()
+		}})
+	}
+	checks = append(checks, h.providerService.HealthChecks()...)
+	h.router.Handle(service.LivenessPath, h.ctx.LivenessHandler()).Methods("GET")
+	h.router.Handle(service.ReadinessPath, h.ctx.ReadinessHandler(checks...)).Methods("GET")
+}
+
 func (h *Handler) registerPublic() error {
 	h.log.Info("registering www public directory...")
 	cfg := h.ctx.Config()
@@ -137,17 +167,18 @@ func (h *Handler) registerPublic() error {
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service.SetRequestContext(r, h.ctx)
+	defer service.ClearRequestContext(r)
+	requestID := service.SetRequestID(w, r)
 	defer func() {
 		if err := recover(); err != nil {
 			buf := make([]byte, 2048)
 			runtime.Stack(buf, true)
-			h.log.Crit("panic on serving HTTP", log15.Ctx{"panic": err, "stackTrace": string(buf)})
+			h.log.Crit("panic on serving HTTP", log15.Ctx{"panic": err, "stackTrace": string(buf), "requestID": requestID})
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}()
 	wr := &ResponseWriter{w: w}
-	service.SetRequestContext(r, h.ctx)
-	defer service.ClearRequestContext(r)
 	h.router.ServeHTTP(wr, r)
 	// service.TimeoutHandler(h.log.Warn, h.timeout, h.router).ServeHTTP(wr, r)
 }
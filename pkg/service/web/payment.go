@@ -16,11 +16,13 @@ import (
 
 	tmpl "github.com/fritzpay/paymentd/pkg/template"
 
+	"github.com/fritzpay/paymentd/pkg/dialect"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/paymentd/project"
+	"github.com/fritzpay/paymentd/pkg/ratelimit"
 	"github.com/fritzpay/paymentd/pkg/service"
 	paymentService "github.com/fritzpay/paymentd/pkg/service/payment"
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/text/language"
 	"gopkg.in/inconshreveable/log15.v2"
 )
@@ -45,7 +47,7 @@ func (h *Handler) authenticatePaymentRequest(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *Handler) authenticatePaymentToken(w http.ResponseWriter, r *http.Request, tokenStr string) {
-	log := h.log.New(log15.Ctx{"method": "authenticatePaymentToken"})
+	log := h.log.New(log15.Ctx{"method": "authenticatePaymentToken", "requestID": service.RequestID(r)})
 
 	var tx *sql.Tx
 	var commit bool
@@ -109,12 +111,10 @@ beginTx:
 	}
 	err = tx.Commit()
 	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			if mysqlErr.Number == 1213 {
-				retries++
-				time.Sleep(time.Second)
-				goto beginTx
-			}
+		if dialect.IsDeadlock(err) {
+			retries++
+			time.Sleep(time.Second)
+			goto beginTx
 		}
 		log.Crit("error on commit", log15.Ctx{"err": err})
 		w.WriteHeader(http.StatusInternalServerError)
@@ -134,7 +134,7 @@ func (h *Handler) redirectTokenRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) readPaymentCookie(w http.ResponseWriter, r *http.Request) (proceed bool) {
-	log := h.log.New(log15.Ctx{"method": "readPaymentCookie"})
+	log := h.log.New(log15.Ctx{"method": "readPaymentCookie", "requestID": service.RequestID(r)})
 
 	if c, err := r.Cookie(PaymentCookieName); err == nil {
 		auth := service.NewAuthorization(h.hashFunc())
@@ -230,6 +230,34 @@ func (h *Handler) resetPaymentCookie(w http.ResponseWriter) {
 	http.SetCookie(w, c)
 }
 
+// tooManyRequests responds with 429 Too Many Requests and a Retry-After header
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+	w.WriteHeader(429)
+}
+
+// withinProjectRateLimit checks the rate limit of the project of a payment
+//
+// It will write the appropriate response if false.
+func (h *Handler) withinProjectRateLimit(projectID int64, log log15.Logger, w http.ResponseWriter) bool {
+	limits := h.ctx.RateLimits()
+	if limits.Project == nil {
+		return true
+	}
+	pr, err := project.ProjectByIDDB(h.ctx.PrincipalDB(service.ReadOnly), projectID)
+	if err != nil {
+		log.Error("error retrieving project", log15.Ctx{"err": err})
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	ok, wait := limits.AllowProject(projectID, pr.Config.RateLimitBurst)
+	if !ok {
+		log.Warn("project rate limit exceeded", log15.Ctx{"retryAfter": wait})
+		tooManyRequests(w, wait)
+	}
+	return ok
+}
+
 func (h *Handler) PaymentHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -237,7 +265,7 @@ func (h *Handler) PaymentHandler() http.Handler {
 		if !h.authenticatePaymentRequest(w, r) {
 			return
 		}
-		log := h.log.New(log15.Ctx{"method": "PaymentHandler"})
+		log := h.log.New(log15.Ctx{"method": "PaymentHandler", "requestID": service.RequestID(r)})
 		paymentIDStr, ok := service.RequestContext(r).Value(PaymentAuthPaymentID).(string)
 		if !ok {
 			log.Crit("error in request context payment id", log15.Ctx{"hasType": fmt.Sprintf("%T", service.RequestContext(r).Value(PaymentAuthPaymentID))})
@@ -296,6 +324,9 @@ func (h *Handler) PaymentHandler() http.Handler {
 			"projectID": p.ProjectID(),
 			"paymentID": p.ID(),
 		})
+		if !h.withinProjectRateLimit(p.ProjectID(), log, w) {
+			return
+		}
 		err = payment.PaymentMetadataTx(tx, p)
 		if err != nil {
 			log.Error("error retrieving payment metadata", log15.Ctx{"err": err})
@@ -310,7 +341,8 @@ func (h *Handler) PaymentHandler() http.Handler {
 		h.determineLocale(p, r, &configChanged, &metadataChanged)
 		h.determineEnv(p, r, &configChanged, &metadataChanged)
 		var method *payment_method.Method
-		method, err = h.determinePaymentMethodID(tx, p, w, r, &configChanged, &metadataChanged)
+		var fallback []*payment_method.Method
+		method, fallback, err = h.determinePaymentMethodID(tx, p, w, r, &configChanged, &metadataChanged)
 		if err != nil {
 			log.Warn("error determining payment method id", log15.Ctx{"err": err})
 			return
@@ -324,8 +356,16 @@ func (h *Handler) PaymentHandler() http.Handler {
 					time.Sleep(time.Second)
 					goto beginTx
 				}
-				log.Error("error on saving payment config", log15.Ctx{"err": err})
-				w.WriteHeader(http.StatusInternalServerError)
+				switch err {
+				case paymentService.ErrPaymentMethodCurrency,
+					paymentService.ErrPaymentMethodCountry,
+					paymentService.ErrPaymentMethodAmount:
+					log.Warn("payment exceeds payment method limits", log15.Ctx{"err": err})
+					w.WriteHeader(http.StatusConflict)
+				default:
+					log.Error("error on saving payment config", log15.Ctx{"err": err})
+					w.WriteHeader(http.StatusInternalServerError)
+				}
 				return
 			}
 		}
@@ -352,13 +392,10 @@ func (h *Handler) PaymentHandler() http.Handler {
 			}
 			err = tx.Commit()
 			if err != nil {
-				if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-					// lock error
-					if mysqlErr.Number == 1213 {
-						retries++
-						time.Sleep(time.Second)
-						goto beginTx
-					}
+				if dialect.IsDeadlock(err) {
+					retries++
+					time.Sleep(time.Second)
+					goto beginTx
 				}
 				commit = true
 				log.Crit("error on commit tx", log15.Ctx{"err": err})
@@ -385,6 +422,7 @@ func (h *Handler) PaymentHandler() http.Handler {
 				w.WriteHeader(http.StatusConflict)
 				return
 			}
+			paymentTx.SetRequestID(service.RequestID(r))
 			err = h.paymentService.SetPaymentTransaction(tx, paymentTx)
 			if err != nil {
 				if err == paymentService.ErrDBLockTimeout {
@@ -400,13 +438,10 @@ func (h *Handler) PaymentHandler() http.Handler {
 
 		err = tx.Commit()
 		if err != nil {
-			if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-				// lock error
-				if mysqlErr.Number == 1213 {
-					retries++
-					time.Sleep(time.Second)
-					goto beginTx
-				}
+			if dialect.IsDeadlock(err) {
+				retries++
+				time.Sleep(time.Second)
+				goto beginTx
 			}
 			commit = true
 			log.Crit("error on commit tx", log15.Ctx{"err": err})
@@ -418,7 +453,7 @@ func (h *Handler) PaymentHandler() http.Handler {
 			commitIntent()
 		}
 
-		h.servePaymentHandler(p, method).ServeHTTP(w, r)
+		h.servePaymentHandler(p, method, fallback).ServeHTTP(w, r)
 	})
 }
 
@@ -461,7 +496,12 @@ func (h *Handler) determineEnv(p *payment.Payment, r *http.Request, configChange
 	}
 }
 
-func (h *Handler) determinePaymentMethodID(tx *sql.Tx, p *payment.Payment, w http.ResponseWriter, r *http.Request, configChanged, metadataChanged *bool) (*payment_method.Method, error) {
+// determinePaymentMethodID determines the payment method for the payment
+//
+// If the payment method was selected through routing rules, the remaining eligible
+// payment methods will be returned as fallbacks. If no payment method could be
+// determined, the returned method will be nil.
+func (h *Handler) determinePaymentMethodID(tx *sql.Tx, p *payment.Payment, w http.ResponseWriter, r *http.Request, configChanged, metadataChanged *bool) (*payment_method.Method, []*payment_method.Method, error) {
 	var paymentMethodID int64
 	if p.Config.PaymentMethodID.Valid {
 		paymentMethodID = p.Config.PaymentMethodID.Int64
@@ -470,34 +510,45 @@ func (h *Handler) determinePaymentMethodID(tx *sql.Tx, p *payment.Payment, w htt
 		paymentMethodID, err = strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			return nil, fmt.Errorf("invalid payment method id: %s", idStr)
+			return nil, nil, fmt.Errorf("invalid payment method id: %s", idStr)
 		}
+	} else {
+		return h.routePaymentMethod(tx, p, w, configChanged)
 	}
 	meth, err := payment_method.PaymentMethodByIDTx(tx, paymentMethodID)
 	if err != nil {
 		if err == payment_method.ErrPaymentMethodNotFound {
 			w.WriteHeader(http.StatusNotFound)
-			return nil, fmt.Errorf("payment method id %d not found", paymentMethodID)
+			return nil, nil, fmt.Errorf("payment method id %d not found", paymentMethodID)
 		}
 		w.WriteHeader(http.StatusInternalServerError)
-		return nil, fmt.Errorf("error selecting payment method id: %v", err)
+		return nil, nil, fmt.Errorf("error selecting payment method id: %v", err)
 	}
 	if meth.ProjectID != p.ProjectID() {
 		w.WriteHeader(http.StatusBadRequest)
-		return nil, fmt.Errorf("invalid payment method id %d. project mismatch", paymentMethodID)
+		return nil, nil, fmt.Errorf("invalid payment method id %d. project mismatch", paymentMethodID)
 	}
 	if !meth.Active() {
 		w.WriteHeader(http.StatusConflict)
-		return nil, fmt.Errorf("invalid payment method id %d. payment method not active", paymentMethodID)
+		return nil, nil, fmt.Errorf("invalid payment method id %d. payment method not active", paymentMethodID)
+	}
+	if !h.paymentMethodCompatible(p, meth) {
+		w.WriteHeader(http.StatusConflict)
+		return nil, nil, fmt.Errorf("invalid payment method id %d. payment method not compatible", paymentMethodID)
 	}
 	if !p.Config.PaymentMethodID.Valid {
 		p.Config.SetPaymentMethodID(meth.ID)
 		*configChanged = true
 	}
-	return meth, nil
+	return meth, nil, nil
 }
 
-func (h *Handler) servePaymentHandler(p *payment.Payment, method *payment_method.Method) http.Handler {
+// servePaymentHandler initializes the payment with the driver of the given payment
+// method
+//
+// If the initialization fails and fallback methods are given, the payment will be
+// switched to the next fallback method.
+func (h *Handler) servePaymentHandler(p *payment.Payment, method *payment_method.Method, fallback []*payment_method.Method) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log := h.log.New(log15.Ctx{
 			"method":          "servePaymentHandler",
@@ -505,6 +556,7 @@ func (h *Handler) servePaymentHandler(p *payment.Payment, method *payment_method
 			"paymentID":       p.ID(),
 			"paymentMethodID": method.ID,
 			"providerName":    method.Provider.Name,
+			"requestID":       service.RequestID(r),
 		})
 		driver, err := h.providerService.Driver(method)
 		if err != nil {
@@ -515,18 +567,29 @@ func (h *Handler) servePaymentHandler(p *payment.Payment, method *payment_method
 		if Debug {
 			log.Debug("initializing payment with driver...")
 		}
-		h, err := driver.InitPayment(p, method)
+		initHandler, err := driver.InitPayment(r, p, method)
 		if err != nil {
 			log.Error("error on driver init payment", log15.Ctx{"err": err})
+			if len(fallback) > 0 {
+				log.Warn("failing over to next payment method", log15.Ctx{"nextPaymentMethodID": fallback[0].ID})
+				err = h.switchPaymentMethod(p, fallback[0])
+				if err != nil {
+					log.Error("error switching payment method", log15.Ctx{"err": err})
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				h.servePaymentHandler(p, fallback[0], fallback[1:]).ServeHTTP(w, r)
+				return
+			}
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		if h == nil {
+		if initHandler == nil {
 			log.Error("driver did not return a handler")
 			w.WriteHeader(http.StatusServiceUnavailable)
 			return
 		}
-		h.ServeHTTP(w, r)
+		initHandler.ServeHTTP(w, r)
 	})
 }
 
@@ -603,7 +666,7 @@ func (h *Handler) defaultPage(base string, w http.ResponseWriter, r *http.Reques
 
 	tmpl := template.New("page")
 
-	err := h.getTemplate(tmpl, h.templateDir, locale, base)
+	err := h.getTemplate(tmpl, h.ctx.Config().Web.TemplateDir, locale, base)
 	if err != nil {
 		h.log.Error("error retrieving template", log15.Ctx{"err": err})
 		return
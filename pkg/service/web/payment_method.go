@@ -1,13 +1,101 @@
 package web
 
 import (
+	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/service"
+	"gopkg.in/inconshreveable/log15.v2"
 )
 
+const (
+	selectPaymentMethodTemplate = "/payment/select_method.html.tmpl"
+)
+
+// selectablePaymentMethod is the template representation of a payment method on the
+// selection page
+type selectablePaymentMethod struct {
+	ID        int64
+	MethodKey string
+	Provider  string
+	URL       string
+}
+
+// paymentMethodCompatible returns true if the payment method can be used to process
+// the payment, i.e. it is active and the payment is within its limits
+func (h *Handler) paymentMethodCompatible(p *payment.Payment, m *payment_method.Method) bool {
+	if m.ProjectID != p.ProjectID() || !m.Active() {
+		return false
+	}
+	var country string
+	if p.Config.Country.Valid {
+		country = p.Config.Country.String
+	}
+	return m.Limits.Check(p.Currency, country, p.Decimal()) == nil
+}
+
+// SelectPaymentMethodHandler serves the payment method selection page
+//
+// It lists all active payment methods of the payment's project which are compatible
+// with the payment. Selecting a method will request the payment page with the
+// paymentMethodId parameter, which stores the choice in the payment config.
 func (h *Handler) SelectPaymentMethodHandler(p *payment.Payment) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := h.log.New(log15.Ctx{
+			"method":    "SelectPaymentMethodHandler",
+			"projectID": p.ProjectID(),
+			"paymentID": p.ID(),
+			"requestID": service.RequestID(r),
+		})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		methods, err := payment_method.PaymentMethodsByProjectIDStatusDB(h.ctx.PaymentDB(service.ReadOnly), p.ProjectID(), payment_method.PaymentMethodStatusActive)
+		if err != nil {
+			log.Error("error retrieving payment methods", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		selectable := make([]selectablePaymentMethod, 0, len(methods))
+		for _, m := range methods {
+			if !h.paymentMethodCompatible(p, m) {
+				continue
+			}
+			u := &url.URL{Path: PaymentPath}
+			q := u.Query()
+			q.Set("paymentMethodId", strconv.FormatInt(m.ID, 10))
+			u.RawQuery = q.Encode()
+			selectable = append(selectable, selectablePaymentMethod{
+				ID:        m.ID,
+				MethodKey: m.MethodKey,
+				Provider:  m.Provider.Name,
+				URL:       u.String(),
+			})
+		}
+		if len(selectable) == 0 {
+			log.Warn("no compatible payment methods")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
 
+		tmpl := template.New("select")
+		err = h.getTemplate(tmpl, h.ctx.Config().Web.TemplateDir, p.Config.Locale.String, selectPaymentMethodTemplate)
+		if err != nil {
+			log.Error("error retrieving template", log15.Ctx{"err": err})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		err = tmpl.Execute(w, map[string]interface{}{
+			"payment":   p,
+			"paymentID": h.paymentService.EncodedPaymentID(p.PaymentID()),
+			"amount":    p.Decimal(),
+			"methods":   selectable,
+		})
+		if err != nil {
+			log.Error("template error", log15.Ctx{"err": err})
+		}
 	})
 }
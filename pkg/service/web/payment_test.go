@@ -98,6 +98,8 @@ func TestPayment(t *testing.T) {
 						ctx.Config().Web.PubWWWDir = os.TempDir()
 						ctx.Config().Provider.ProviderTemplateDir = os.TempDir()
 						os.Mkdir(path.Join(os.TempDir(), fritzpay.FritzpayTemplateDir), os.FileMode(0644))
+						os.Mkdir(path.Join(os.TempDir(), "paypal_rest"), os.FileMode(0644))
+						os.Mkdir(path.Join(os.TempDir(), "stripe"), os.FileMode(0644))
 
 						Convey("Given a web handler", WithWebHandler(ctx, func(h *Handler) {
 							Convey("Given an initialized payment", WithPayment(db, prDB, s, func(p *payment.Payment, token *payment.PaymentToken) {
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment"
+	"github.com/fritzpay/paymentd/pkg/paymentd/payment_method"
+	"github.com/fritzpay/paymentd/pkg/paymentd/routing"
+	paymentService "github.com/fritzpay/paymentd/pkg/service/payment"
+	"gopkg.in/inconshreveable/log15.v2"
+)
+
+func (h *Handler) intn(n int) int {
+	h.mRand.Lock()
+	i := h.rand.Intn(n)
+	h.mRand.Unlock()
+	return i
+}
+
+// routePaymentMethod selects a payment method using the routing rules of the
+// payment's project
+//
+// It returns the selected method and the remaining eligible methods in order.
+// If the project has no routing rules or no eligible method was found, the returned
+// method will be nil.
+func (h *Handler) routePaymentMethod(tx *sql.Tx, p *payment.Payment, w http.ResponseWriter, configChanged *bool) (*payment_method.Method, []*payment_method.Method, error) {
+	rs, err := routing.RulesetByProjectIDTx(tx, p.ProjectID())
+	if err != nil {
+		if err == routing.ErrRulesetNotFound {
+			return nil, nil, nil
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("error retrieving routing rules: %v", err)
+	}
+	var eligible []*payment_method.Method
+	for _, id := range rs.Candidates(p, time.Now(), h.intn) {
+		meth, err := payment_method.PaymentMethodByIDTx(tx, id)
+		if err != nil {
+			if err == payment_method.ErrPaymentMethodNotFound {
+				h.log.Warn("routing to unknown payment method", log15.Ctx{
+					"projectID":       p.ProjectID(),
+					"paymentMethodID": id,
+				})
+				continue
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return nil, nil, fmt.Errorf("error selecting payment method id: %v", err)
+		}
+		if !h.paymentMethodCompatible(p, meth) {
+			continue
+		}
+		eligible = append(eligible, meth)
+	}
+	if len(eligible) == 0 {
+		return nil, nil, nil
+	}
+	if Debug {
+		h.log.Debug("routed payment", log15.Ctx{
+			"projectID":       p.ProjectID(),
+			"paymentID":       p.ID(),
+			"paymentMethodID": eligible[0].ID,
+			"candidates":      len(eligible),
+		})
+	}
+	p.Config.SetPaymentMethodID(eligible[0].ID)
+	*configChanged = true
+	return eligible[0], eligible[1:], nil
+}
+
+// switchPaymentMethod sets a new payment method on the payment config
+func (h *Handler) switchPaymentMethod(p *payment.Payment, method *payment_method.Method) error {
+	log := h.log.New(log15.Ctx{
+		"method":          "switchPaymentMethod",
+		"projectID":       p.ProjectID(),
+		"paymentID":       p.ID(),
+		"paymentMethodID": method.ID,
+	})
+	var tx *sql.Tx
+	var commit bool
+	var err error
+	defer func() {
+		if tx != nil && !commit {
+			err = tx.Rollback()
+			if err != nil {
+				log.Crit("error on rollback", log15.Ctx{"err": err})
+			}
+		}
+	}()
+	maxRetries := h.ctx.Config().Database.TransactionMaxRetries
+	var retries int
+beginTx:
+	if retries >= maxRetries {
+		// no need to roll back
+		commit = true
+		log.Crit("too many retries on tx. aborting...", log15.Ctx{"maxRetries": maxRetries})
+		return paymentService.ErrDBLockTimeout
+	}
+	tx, err = h.ctx.PaymentDB().Begin()
+	if err != nil {
+		commit = true
+		return err
+	}
+	p.Config.SetPaymentMethodID(method.ID)
+	err = h.paymentService.SetPaymentConfig(tx, p)
+	if err != nil {
+		if err == paymentService.ErrDBLockTimeout {
+			retries++
+			time.Sleep(time.Second)
+			goto beginTx
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		if dialect.IsDeadlock(err) {
+			retries++
+			time.Sleep(time.Second)
+			goto beginTx
+		}
+		commit = true
+		return err
+	}
+	commit = true
+	return nil
+}
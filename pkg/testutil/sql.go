@@ -5,7 +5,8 @@ import (
 	"os"
 	"testing"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/migrate"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -17,8 +18,42 @@ const (
 	EnvVarMySQLTestPaymentDSN = "PAYMENTD_MYSQLTEST_PAYMENTDSN"
 	// EnvVarMySQLTestPaymentDSN holds the DSN for the test database for payment
 	EnvVarMySQLTestPrincipalDSN = "PAYMENTD_MYSQLTEST_PRINCIPALDSN"
+	// EnvVarMySQLTestDialect selects the backend of the test databases, e.g.
+	// "postgres". Defaults to "mysql"
+	//
+	// With "sqlite3" and no DSN, the test databases are created in a temporary
+	// directory, so no database server is required.
+	EnvVarMySQLTestDialect = "PAYMENTD_MYSQLTEST_DIALECT"
 )
 
+func testBackend() string {
+	backend := os.Getenv(EnvVarMySQLTestDialect)
+	if backend == "" {
+		return dialect.MySQL.Name()
+	}
+	return backend
+}
+
+// hasTestDSN returns true if the test database with the DSN in the given
+// environment var is available
+func hasTestDSN(dsnEnvVar string) bool {
+	return os.Getenv(dsnEnvVar) != "" || testBackend() == dialect.SQLite.Name()
+}
+
+// openTestDB opens the test database with the DSN in the given environment var
+func openTestDB(dsnEnvVar, database string) (*sql.DB, error) {
+	backend := testBackend()
+	dsn := os.Getenv(dsnEnvVar)
+	if dsn == "" && backend == dialect.SQLite.Name() {
+		var err error
+		dsn, err = sqliteTestDSN(database)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dialect.Open(backend, dsn)
+}
+
 // WithPaymentDB is a test decorator providing a DB connection to the test payment DB
 func WithPaymentDB(t *testing.T, f func(db *sql.DB)) func() {
 	return func() {
@@ -26,11 +61,11 @@ func WithPaymentDB(t *testing.T, f func(db *sql.DB)) func() {
 			t.Skip("Skipping MySQL test")
 			return
 		}
-		if os.Getenv(EnvVarMySQLTestPaymentDSN) == "" {
+		if !hasTestDSN(EnvVarMySQLTestPaymentDSN) {
 			t.Skip("No payment DB DSN present. Skipping.")
 			return
 		}
-		db, err := sql.Open("mysql", os.Getenv(EnvVarMySQLTestPaymentDSN))
+		db, err := openTestDB(EnvVarMySQLTestPaymentDSN, migrate.DatabasePayment)
 
 		So(err, ShouldBeNil)
 		So(db, ShouldNotBeNil)
@@ -49,11 +84,11 @@ func WithPrincipalDB(t *testing.T, f func(db *sql.DB)) func() {
 			t.Skip("Skipping MySQL test")
 			return
 		}
-		if os.Getenv(EnvVarMySQLTestPrincipalDSN) == "" {
+		if !hasTestDSN(EnvVarMySQLTestPrincipalDSN) {
 			t.Skip("No principal DB DSN present. Skipping.")
 			return
 		}
-		db, err := sql.Open("mysql", os.Getenv(EnvVarMySQLTestPrincipalDSN))
+		db, err := openTestDB(EnvVarMySQLTestPrincipalDSN, migrate.DatabasePrincipal)
 
 		So(err, ShouldBeNil)
 		So(db, ShouldNotBeNil)
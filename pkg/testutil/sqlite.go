@@ -0,0 +1,100 @@
+package testutil
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fritzpay/paymentd/pkg/dialect"
+	"github.com/fritzpay/paymentd/pkg/migrate"
+)
+
+// testData holds the statements which insert the test data, like the test scripts
+// of the MySQL test databases
+var testData = map[string][]string{
+	migrate.DatabasePrincipal: {
+		"INSERT INTO `principal` (`id`, `created`, `created_by`, `name`) VALUES (1, ?, 'test', 'testprincipal')",
+		"INSERT INTO `principal_status` (`principal_id`, `timestamp`, `created_by`, `status`) VALUES (1, 1, 'test', 'active')",
+		"INSERT INTO `project` (`id`, `principal_id`, `name`, `created`, `created_by`) VALUES (1, 1, 'testproject', ?, 'test')",
+		"INSERT INTO `project_key` (`key`, `timestamp`, `project_id`, `created_by`, `secret`, `active`) VALUES ('testkey', ?, 1, 'test', 'abcdef', 1)",
+	},
+	migrate.DatabasePayment: {
+		"INSERT INTO `currency` (`code_iso_4217`) VALUES ('EUR')",
+		"INSERT INTO `config` (`name`, `last_change`, `value`) VALUES ('SystemPassword', 1, '$2a$12$DCpkBx.8jVi/EJJ6wx.wn.pIh5HOs51/hWHbCoVarBTFyJkcfNtyu')",
+		"INSERT INTO `payment_method` (`id`, `project_id`, `provider`, `method_key`, `created`, `created_by`) VALUES (1, 1, 'fritzpay', 'test', ?, 'test')",
+		"INSERT INTO `payment_method_status` (`payment_method_id`, `timestamp`, `created_by`, `status`) VALUES (1, 1, 'test', 'active')",
+	},
+}
+
+var sqliteTestDBs = struct {
+	sync.Mutex
+	dir string
+	dsn map[string]string
+}{dsn: make(map[string]string)}
+
+// sqliteTestDSN returns the DSN of a SQLite test database with the given name
+//
+// The database is created in a temporary directory on the first call. It holds the
+// schema of the embedded migrations and the test data. All tests of the process
+// share the database.
+func sqliteTestDSN(database string) (string, error) {
+	sqliteTestDBs.Lock()
+	defer sqliteTestDBs.Unlock()
+	if dsn, ok := sqliteTestDBs.dsn[database]; ok {
+		return dsn, nil
+	}
+	if sqliteTestDBs.dir == "" {
+		dir, err := ioutil.TempDir("", "paymentd_test")
+		if err != nil {
+			return "", err
+		}
+		sqliteTestDBs.dir = dir
+	}
+	dsn := filepath.Join(sqliteTestDBs.dir, database+".db")
+	err := createSQLiteTestDB(dsn, database)
+	if err != nil {
+		return "", err
+	}
+	sqliteTestDBs.dsn[database] = dsn
+	return dsn, nil
+}
+
+func createSQLiteTestDB(dsn, database string) error {
+	db, err := dialect.Open(dialect.SQLite.Name(), dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrations, err := migrate.Migrations(dialect.SQLite, database)
+	if err != nil {
+		return err
+	}
+	_, err = migrate.NewMigrator(db, migrations).Up(0)
+	if err != nil {
+		return err
+	}
+	return insertTestData(db, testData[database])
+}
+
+func insertTestData(db *sql.DB, stmts []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, stmt := range stmts {
+		var args []interface{}
+		if strings.Contains(stmt, "?") {
+			args = append(args, now)
+		}
+		_, err = tx.Exec(stmt, args...)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
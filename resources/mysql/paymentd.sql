@@ -30,6 +30,20 @@ CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`config` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `fritzpay_payment`.`auth_key`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_payment`.`auth_key` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`auth_key` (
+  `keychain` VARCHAR(16) NOT NULL,
+  `generation` BIGINT UNSIGNED NOT NULL,
+  `created` BIGINT NOT NULL,
+  `key` TEXT NOT NULL,
+  PRIMARY KEY (`keychain`, `generation`))
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `fritzpay_payment`.`provider`
 -- -----------------------------------------------------
@@ -156,6 +170,25 @@ CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`payment_method_metadata` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `fritzpay_payment`.`payment_method_limits`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_payment`.`payment_method_limits` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`payment_method_limits` (
+  `payment_method_id` BIGINT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `limits` TEXT NOT NULL,
+  PRIMARY KEY (`payment_method_id`, `timestamp`),
+  CONSTRAINT `fk_payment_method_limits_payment_method_id`
+    FOREIGN KEY (`payment_method_id`)
+    REFERENCES `fritzpay_payment`.`payment_method` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `fritzpay_payment`.`payment`
 -- -----------------------------------------------------
@@ -288,8 +321,10 @@ CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`payment_transaction` (
   `currency` VARCHAR(3) NOT NULL,
   `status` VARCHAR(32) NOT NULL,
   `comment` TEXT NULL,
+  `request_id` VARCHAR(64) NULL,
   PRIMARY KEY (`project_id`, `payment_id`, `timestamp`),
   INDEX `status` (`status` ASC),
+  INDEX `request_id` (`request_id` ASC),
   INDEX `fk_payment_transaction_currency_idx` (`currency` ASC),
   INDEX `fk_payment_transaction_payment_id_idx` (`payment_id` ASC),
   CONSTRAINT `fk_payment_transaction_payment_id`
@@ -383,6 +418,27 @@ CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`provider_paypal_config` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `fritzpay_payment`.`provider_stripe_config`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_payment`.`provider_stripe_config` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`provider_stripe_config` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `method_key` VARCHAR(64) NOT NULL,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `secure_key` TEXT NOT NULL,
+  `public_key` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `method_key`, `created`),
+  CONSTRAINT `fk_provider_stripe_config_project_id`
+    FOREIGN KEY (`project_id`)
+    REFERENCES `fritzpay_principal`.`project` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `fritzpay_payment`.`provider_paypal_transaction`
 -- -----------------------------------------------------
@@ -454,6 +510,26 @@ CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`provider_paypal_authorization` (
     ON UPDATE CASCADE)
 ENGINE = InnoDB;
 
+
+-- -----------------------------------------------------
+-- Table `fritzpay_payment`.`payment_method_routing`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_payment`.`payment_method_routing` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`payment_method_routing` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `rules` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `timestamp`),
+  CONSTRAINT `fk_payment_method_routing_project_id`
+    FOREIGN KEY (`project_id`)
+    REFERENCES `fritzpay_principal`.`project` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 USE `fritzpay_principal` ;
 
 -- -----------------------------------------------------
@@ -508,6 +584,13 @@ CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`project_key` (
   `created_by` VARCHAR(64) NOT NULL,
   `secret` TEXT NOT NULL,
   `active` TINYINT(1) NOT NULL,
+  `expires` DATETIME NULL,
+  `previous_secret` TEXT NULL,
+  `previous_expires` DATETIME NULL,
+  `scopes` VARCHAR(255) NULL,
+  `ip_allowlist` TEXT NULL,
+  `public_keys` TEXT NULL,
+  `client_certs` TEXT NULL,
   PRIMARY KEY (`key`, `timestamp`),
   INDEX `fk_project_key_project_id_idx` (`project_id` ASC),
   CONSTRAINT `fk_project_key_project_id`
@@ -531,6 +614,8 @@ CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`project_config` (
   `callback_api_version` VARCHAR(32) NULL,
   `callback_project_key` VARCHAR(64) NULL,
   `return_url` TEXT NULL,
+  `ip_allowlist` TEXT NULL,
+  `rate_limit_burst` INT UNSIGNED NULL,
   PRIMARY KEY (`project_id`, `timestamp`),
   INDEX `fk_project_config_project_key_idx` (`callback_project_key` ASC),
   CONSTRAINT `fk_project_config_callback_project_key`
@@ -564,6 +649,158 @@ CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`principal_status` (
     ON UPDATE CASCADE)
 ENGINE = InnoDB;
 
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name_UNIQUE` (`name` ASC))
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_status`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_status` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_status` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `status` VARCHAR(32) NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_status_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_password`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_password` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_password` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `password` VARCHAR(255) NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_password_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_role`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_role` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_role` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `grants` TEXT NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_role_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_mfa`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_mfa` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_mfa` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `required` TINYINT(1) NOT NULL,
+  `totp_enabled` TINYINT(1) NOT NULL,
+  `totp_secret` TEXT NULL,
+  `recovery_codes` TEXT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_mfa_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_totp_step`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_totp_step` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_totp_step` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `step` BIGINT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `step`),
+  CONSTRAINT `fk_user_totp_step_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`user_recovery_code_use`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`user_recovery_code_use` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`user_recovery_code_use` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `code_hash` VARCHAR(64) NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `code_hash`),
+  CONSTRAINT `fk_user_recovery_code_use_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `fritzpay_principal`.`user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`audit_log`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `fritzpay_principal`.`audit_log` ;
+
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`audit_log` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `actor` VARCHAR(64) NOT NULL,
+  `action` VARCHAR(64) NOT NULL,
+  `entity` VARCHAR(64) NOT NULL,
+  `entity_id` VARCHAR(128) NOT NULL,
+  `before` TEXT NULL,
+  `after` TEXT NULL,
+  `source_ip` VARCHAR(64) NOT NULL,
+  `request_id` VARCHAR(64) NOT NULL,
+  `prev_hash` CHAR(64) NOT NULL,
+  `hash` CHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `prev_hash_UNIQUE` (`prev_hash` ASC),
+  INDEX `timestamp` (`timestamp` ASC),
+  INDEX `entity` (`entity` ASC, `entity_id` ASC),
+  INDEX `actor` (`actor` ASC))
+ENGINE = InnoDB;
+
 SET SQL_MODE = '';
 GRANT USAGE ON *.* TO paymentd;
  DROP USER paymentd;
@@ -585,6 +822,57 @@ START TRANSACTION;
 USE `fritzpay_payment`;
 INSERT INTO `fritzpay_payment`.`provider` (`name`) VALUES ('fritzpay');
 INSERT INTO `fritzpay_payment`.`provider` (`name`) VALUES ('paypal_rest');
+INSERT INTO `fritzpay_payment`.`provider` (`name`) VALUES ('stripe');
+
+COMMIT;
+
+-- -----------------------------------------------------
+-- Table `fritzpay_payment`.`schema_migration`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `fritzpay_payment`.`schema_migration` (
+  `version` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(128) NOT NULL,
+  `applied` BIGINT NOT NULL,
+  `dirty` TINYINT(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`version`))
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Data for table `fritzpay_payment`.`schema_migration`
+-- -----------------------------------------------------
+START TRANSACTION;
+USE `fritzpay_payment`;
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (1, 'initial', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (2, 'provider_stripe', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (3, 'payment_transaction_request_id', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (4, 'auth_key', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (5, 'payment_method_limits', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_payment`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (6, 'payment_method_routing', UNIX_TIMESTAMP(), 0);
 
 COMMIT;
 
+-- -----------------------------------------------------
+-- Table `fritzpay_principal`.`schema_migration`
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS `fritzpay_principal`.`schema_migration` (
+  `version` INT UNSIGNED NOT NULL,
+  `name` VARCHAR(128) NOT NULL,
+  `applied` BIGINT NOT NULL,
+  `dirty` TINYINT(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`version`))
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Data for table `fritzpay_principal`.`schema_migration`
+-- -----------------------------------------------------
+START TRANSACTION;
+USE `fritzpay_principal`;
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (1, 'initial', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (2, 'project_key_settings', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (3, 'project_config_settings', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (4, 'user', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (5, 'audit_log', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (6, 'project_key_client_certs', UNIX_TIMESTAMP(), 0);
+INSERT INTO `fritzpay_principal`.`schema_migration` (`version`, `name`, `applied`, `dirty`) VALUES (7, 'user_mfa_use', UNIX_TIMESTAMP(), 0);
+
+COMMIT;
@@ -25,6 +25,20 @@ CREATE TABLE IF NOT EXISTS `config` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `auth_key`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `auth_key` ;
+
+CREATE TABLE IF NOT EXISTS `auth_key` (
+  `keychain` VARCHAR(16) NOT NULL,
+  `generation` BIGINT UNSIGNED NOT NULL,
+  `created` BIGINT NOT NULL,
+  `key` TEXT NOT NULL,
+  PRIMARY KEY (`keychain`, `generation`))
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `provider`
 -- -----------------------------------------------------
@@ -110,6 +124,25 @@ CREATE TABLE IF NOT EXISTS `payment_method_metadata` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `payment_method_limits`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `payment_method_limits` ;
+
+CREATE TABLE IF NOT EXISTS `payment_method_limits` (
+  `payment_method_id` BIGINT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `limits` TEXT NOT NULL,
+  PRIMARY KEY (`payment_method_id`, `timestamp`),
+  CONSTRAINT `fk_payment_method_limits_payment_method_id`
+    FOREIGN KEY (`payment_method_id`)
+    REFERENCES `payment_method` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `payment`
 -- -----------------------------------------------------
@@ -227,8 +260,10 @@ CREATE TABLE IF NOT EXISTS `payment_transaction` (
   `currency` VARCHAR(3) NOT NULL,
   `status` VARCHAR(32) NOT NULL,
   `comment` TEXT NULL,
+  `request_id` VARCHAR(64) NULL,
   PRIMARY KEY (`project_id`, `payment_id`, `timestamp`),
   INDEX `status` (`status` ASC),
+  INDEX `request_id` (`request_id` ASC),
   INDEX `fk_payment_transaction_currency_idx` (`currency` ASC),
   INDEX `fk_payment_transaction_payment_id_idx` (`payment_id` ASC),
   CONSTRAINT `fk_payment_transaction_payment_id`
@@ -289,6 +324,40 @@ CREATE TABLE IF NOT EXISTS `provider_fritzpay_transaction` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `provider_paypal_config`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `provider_paypal_config` ;
+
+CREATE TABLE IF NOT EXISTS `provider_paypal_config` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `method_key` VARCHAR(64) NOT NULL,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `endpoint` TEXT NOT NULL,
+  `client_id` TEXT NOT NULL,
+  `secret` TEXT NOT NULL,
+  `type` VARCHAR(32) NOT NULL,
+  PRIMARY KEY (`project_id`, `method_key`, `created`))
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `provider_stripe_config`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `provider_stripe_config` ;
+
+CREATE TABLE IF NOT EXISTS `provider_stripe_config` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `method_key` VARCHAR(64) NOT NULL,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `secure_key` TEXT NOT NULL,
+  `public_key` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `method_key`, `created`))
+ENGINE = InnoDB;
+
+
 -- -----------------------------------------------------
 -- Table `provider_paypal_transaction`
 -- -----------------------------------------------------
@@ -350,6 +419,19 @@ CREATE TABLE IF NOT EXISTS `provider_paypal_authorization` (
     ON UPDATE CASCADE)
 ENGINE = InnoDB;
 
+-- -----------------------------------------------------
+-- Table `payment_method_routing`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `payment_method_routing` ;
+
+CREATE TABLE IF NOT EXISTS `payment_method_routing` (
+  `project_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `rules` TEXT NOT NULL,
+  PRIMARY KEY (`project_id`, `timestamp`))
+ENGINE = InnoDB;
+
 SET SQL_MODE=@OLD_SQL_MODE;
 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS;
 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS;
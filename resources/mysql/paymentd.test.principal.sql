@@ -119,6 +119,13 @@ CREATE TABLE IF NOT EXISTS `project_key` (
   `created_by` VARCHAR(64) NOT NULL,
   `secret` TEXT NOT NULL,
   `active` TINYINT(1) NOT NULL,
+  `expires` DATETIME NULL,
+  `previous_secret` TEXT NULL,
+  `previous_expires` DATETIME NULL,
+  `scopes` VARCHAR(255) NULL,
+  `ip_allowlist` TEXT NULL,
+  `public_keys` TEXT NULL,
+  `client_certs` TEXT NULL,
   PRIMARY KEY (`key`, `timestamp`),
   INDEX `fk_project_key_project_id_idx` (`project_id` ASC),
   CONSTRAINT `fk_project_key_project_id`
@@ -142,6 +149,8 @@ CREATE TABLE IF NOT EXISTS `project_config` (
   `callback_api_version` VARCHAR(32) NULL,
   `callback_project_key` VARCHAR(64) NULL,
   `return_url` TEXT NULL,
+  `ip_allowlist` TEXT NULL,
+  `rate_limit_burst` INT UNSIGNED NULL,
   PRIMARY KEY (`project_id`, `timestamp`),
   INDEX `fk_project_config_project_key_idx` (`callback_project_key` ASC),
   CONSTRAINT `fk_project_config_callback_project_key`
@@ -157,6 +166,158 @@ CREATE TABLE IF NOT EXISTS `project_config` (
 ENGINE = InnoDB;
 
 
+-- -----------------------------------------------------
+-- Table `user`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user` ;
+
+CREATE TABLE IF NOT EXISTS `user` (
+  `id` INT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created` DATETIME NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `name_UNIQUE` (`name` ASC))
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `user_status`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_status` ;
+
+CREATE TABLE IF NOT EXISTS `user_status` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `status` VARCHAR(32) NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_status_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `user_password`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_password` ;
+
+CREATE TABLE IF NOT EXISTS `user_password` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `password` VARCHAR(255) NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_password_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+
+-- -----------------------------------------------------
+-- Table `user_role`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_role` ;
+
+CREATE TABLE IF NOT EXISTS `user_role` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `grants` TEXT NOT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_role_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `user_mfa`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_mfa` ;
+
+CREATE TABLE IF NOT EXISTS `user_mfa` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `created_by` VARCHAR(64) NOT NULL,
+  `required` TINYINT(1) NOT NULL,
+  `totp_enabled` TINYINT(1) NOT NULL,
+  `totp_secret` TEXT NULL,
+  `recovery_codes` TEXT NULL,
+  PRIMARY KEY (`user_id`, `timestamp`),
+  CONSTRAINT `fk_user_mfa_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `user_totp_step`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_totp_step` ;
+
+CREATE TABLE IF NOT EXISTS `user_totp_step` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `step` BIGINT UNSIGNED NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `step`),
+  CONSTRAINT `fk_user_totp_step_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `user_recovery_code_use`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `user_recovery_code_use` ;
+
+CREATE TABLE IF NOT EXISTS `user_recovery_code_use` (
+  `user_id` INT UNSIGNED NOT NULL,
+  `code_hash` VARCHAR(64) NOT NULL,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`user_id`, `code_hash`),
+  CONSTRAINT `fk_user_recovery_code_use_user_id`
+    FOREIGN KEY (`user_id`)
+    REFERENCES `user` (`id`)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE)
+ENGINE = InnoDB;
+
+-- -----------------------------------------------------
+-- Table `audit_log`
+-- -----------------------------------------------------
+DROP TABLE IF EXISTS `audit_log` ;
+
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `timestamp` BIGINT UNSIGNED NOT NULL,
+  `actor` VARCHAR(64) NOT NULL,
+  `action` VARCHAR(64) NOT NULL,
+  `entity` VARCHAR(64) NOT NULL,
+  `entity_id` VARCHAR(128) NOT NULL,
+  `before` TEXT NULL,
+  `after` TEXT NULL,
+  `source_ip` VARCHAR(64) NOT NULL,
+  `request_id` VARCHAR(64) NOT NULL,
+  `prev_hash` CHAR(64) NOT NULL,
+  `hash` CHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `prev_hash_UNIQUE` (`prev_hash` ASC),
+  INDEX `timestamp` (`timestamp` ASC),
+  INDEX `entity` (`entity` ASC, `entity_id` ASC),
+  INDEX `actor` (`actor` ASC))
+ENGINE = InnoDB;
+
+
 SET SQL_MODE=@OLD_SQL_MODE;
 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS;
 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS;
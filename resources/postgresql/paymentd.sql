@@ -0,0 +1,739 @@
+-- PostgreSQL schema of paymentd, matching resources/mysql/paymentd.sql
+--
+-- The principal and the payment tables are created in the schemas
+-- fritzpay_principal and fritzpay_payment of the same database. Set the
+-- search_path in the DSNs accordingly.
+
+-- -----------------------------------------------------
+-- Schema fritzpay_payment
+-- -----------------------------------------------------
+DROP SCHEMA IF EXISTS fritzpay_payment CASCADE;
+CREATE SCHEMA fritzpay_payment;
+
+-- -----------------------------------------------------
+-- Schema fritzpay_principal
+-- -----------------------------------------------------
+DROP SCHEMA IF EXISTS fritzpay_principal CASCADE;
+CREATE SCHEMA fritzpay_principal;
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.config (
+  name VARCHAR(64) NOT NULL,
+  last_change BIGINT NOT NULL,
+  value TEXT NULL,
+  PRIMARY KEY (name, last_change));
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.auth_key
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.auth_key (
+  keychain VARCHAR(16) NOT NULL,
+  generation BIGINT NOT NULL,
+  created BIGINT NOT NULL,
+  key TEXT NOT NULL,
+  PRIMARY KEY (keychain, generation));
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider (
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (name));
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.principal
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.principal (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT principal_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.project
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.project (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT project_project_name UNIQUE (principal_id, name),
+  CONSTRAINT fk_project_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES fritzpay_principal.principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_method
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_method (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  project_id BIGINT NOT NULL,
+  provider VARCHAR(64) NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT payment_method_method_key UNIQUE (project_id, provider, method_key),
+  CONSTRAINT fk_payment_method_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_method_provider
+    FOREIGN KEY (provider)
+    REFERENCES fritzpay_payment.provider (name)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_method_project_id_idx ON fritzpay_payment.payment_method (project_id);
+CREATE INDEX IF NOT EXISTS fk_payment_method_provider_idx ON fritzpay_payment.payment_method (provider);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.currency
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.currency (
+  code_iso_4217 VARCHAR(3) NOT NULL,
+  PRIMARY KEY (code_iso_4217));
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_method_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_method_status (
+  payment_method_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (payment_method_id, timestamp),
+  CONSTRAINT fk_payment_method_status_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES fritzpay_payment.payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_method_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_method_metadata (
+  payment_method_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (payment_method_id, name, timestamp),
+  CONSTRAINT fk_principal_metadata_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES fritzpay_payment.payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_method_limits
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_method_limits (
+  payment_method_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  limits TEXT NOT NULL,
+  PRIMARY KEY (payment_method_id, timestamp),
+  CONSTRAINT fk_payment_method_limits_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES fritzpay_payment.payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  project_id BIGINT NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  ident VARCHAR(175) NOT NULL,
+  amount INTEGER NOT NULL,
+  subunits SMALLINT NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT payment_ident UNIQUE (project_id, ident),
+  CONSTRAINT payment_payment_id UNIQUE (project_id, id),
+  CONSTRAINT fk_payment_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_currency
+    FOREIGN KEY (currency)
+    REFERENCES fritzpay_payment.currency (code_iso_4217)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_created ON fritzpay_payment.payment (created);
+CREATE INDEX IF NOT EXISTS fk_payment_currency_idx ON fritzpay_payment.payment (currency);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_config (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  payment_method_id BIGINT NULL,
+  country VARCHAR(2) NULL,
+  locale VARCHAR(5) NULL,
+  callback_url TEXT NULL,
+  callback_api_version VARCHAR(32) NULL,
+  callback_project_key VARCHAR(64) NULL,
+  return_url TEXT NULL,
+  expires TIMESTAMP(0) NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_payment_config_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_config_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES fritzpay_payment.payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_config_payment_method_id_idx ON fritzpay_payment.payment_config (payment_method_id);
+CREATE INDEX IF NOT EXISTS fk_payment_config_payment_id_idx ON fritzpay_payment.payment_config (payment_id);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_metadata (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  name VARCHAR(125) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  value TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, name, timestamp),
+  CONSTRAINT fk_payment_metadata_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_metadata_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_metadata_payment_id_idx ON fritzpay_payment.payment_metadata (payment_id);
+CREATE INDEX IF NOT EXISTS payment_metadata_timestamp ON fritzpay_payment.payment_metadata (project_id, payment_id, timestamp);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_token
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_token (
+  token VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  PRIMARY KEY (token),
+  CONSTRAINT fk_payment_token_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_token_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_token_created ON fritzpay_payment.payment_token (created);
+CREATE INDEX IF NOT EXISTS fk_payment_token_payment_id_idx ON fritzpay_payment.payment_token (payment_id);
+CREATE INDEX IF NOT EXISTS fk_payment_token_project_id_idx ON fritzpay_payment.payment_token (project_id);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_transaction (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  amount INTEGER NOT NULL,
+  subunits SMALLINT NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  comment TEXT NULL,
+  request_id VARCHAR(64) NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_payment_transaction_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_transaction_currency
+    FOREIGN KEY (currency)
+    REFERENCES fritzpay_payment.currency (code_iso_4217)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_transaction_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_transaction_status ON fritzpay_payment.payment_transaction (status);
+CREATE INDEX IF NOT EXISTS payment_transaction_request_id ON fritzpay_payment.payment_transaction (request_id);
+CREATE INDEX IF NOT EXISTS fk_payment_transaction_currency_idx ON fritzpay_payment.payment_transaction (currency);
+CREATE INDEX IF NOT EXISTS fk_payment_transaction_payment_id_idx ON fritzpay_payment.payment_transaction (payment_id);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_fritzpay_payment
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_fritzpay_payment (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT provider_fritzpay_payment_payment_id UNIQUE (project_id, payment_id),
+  CONSTRAINT fk_provider_fritzpay_payment_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_provider_fritzpay_payment_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_provider_fritzpay_payment_payment_id_idx ON fritzpay_payment.provider_fritzpay_payment (payment_id);
+
+COMMENT ON TABLE fritzpay_payment.provider_fritzpay_payment IS 'Stores payments made with the FritzPay demo provider.';
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_fritzpay_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_fritzpay_transaction (
+  fritzpay_payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  fritzpay_id VARCHAR(64) NULL,
+  payload TEXT NULL,
+  PRIMARY KEY (fritzpay_payment_id, timestamp),
+  CONSTRAINT fk_provider_fritzpay_transaction_fritzpay_payment_id
+    FOREIGN KEY (fritzpay_payment_id)
+    REFERENCES fritzpay_payment.provider_fritzpay_payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS provider_fritzpay_transaction_fritzpay_id ON fritzpay_payment.provider_fritzpay_transaction (fritzpay_id);
+CREATE INDEX IF NOT EXISTS provider_fritzpay_transaction_status ON fritzpay_payment.provider_fritzpay_transaction (status);
+
+COMMENT ON COLUMN fritzpay_payment.provider_fritzpay_transaction.fritzpay_id IS 'This would be the ID which identifies the payment on the provider.';
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_paypal_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_paypal_config (
+  project_id BIGINT NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  endpoint TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  PRIMARY KEY (project_id, method_key, created),
+  CONSTRAINT fk_provider_paypal_config_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_stripe_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_stripe_config (
+  project_id BIGINT NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secure_key TEXT NOT NULL,
+  public_key TEXT NOT NULL,
+  PRIMARY KEY (project_id, method_key, created),
+  CONSTRAINT fk_provider_stripe_config_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_paypal_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_paypal_transaction (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  nonce VARCHAR(32) NULL,
+  intent VARCHAR(32) NULL,
+  paypal_id VARCHAR(128) NULL,
+  payer_id VARCHAR(64) NULL,
+  paypal_create_time TIMESTAMP(0) NULL,
+  paypal_state VARCHAR(32) NULL,
+  paypal_update_time TIMESTAMP(0) NULL,
+  links TEXT NULL,
+  data TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_provider_paypal_transaction_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_provider_paypal_transaction_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_id ON fritzpay_payment.provider_paypal_transaction (paypal_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_state ON fritzpay_payment.provider_paypal_transaction (paypal_state);
+CREATE INDEX IF NOT EXISTS fk_provider_paypal_transaction_payment_id_idx ON fritzpay_payment.provider_paypal_transaction (payment_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_payer_id ON fritzpay_payment.provider_paypal_transaction (payer_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_intent ON fritzpay_payment.provider_paypal_transaction (intent);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_nonce ON fritzpay_payment.provider_paypal_transaction (project_id, payment_id, nonce);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_type ON fritzpay_payment.provider_paypal_transaction (project_id, payment_id, type);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.provider_paypal_authorization
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.provider_paypal_authorization (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  valid_until TIMESTAMP(0) NOT NULL,
+  state VARCHAR(32) NOT NULL,
+  authorization_id VARCHAR(128) NOT NULL,
+  paypal_id VARCHAR(128) NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  links TEXT NULL,
+  data TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_provider_paypal_authorization_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_provider_paypal_authorization_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES fritzpay_payment.payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_provider_paypal_authorization_payment_id_idx ON fritzpay_payment.provider_paypal_authorization (payment_id);
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.payment_method_routing
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.payment_method_routing (
+  project_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  rules TEXT NOT NULL,
+  PRIMARY KEY (project_id, timestamp),
+  CONSTRAINT fk_payment_method_routing_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.principal_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.principal_metadata (
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (principal_id, name, timestamp),
+  CONSTRAINT fk_principal_metadata_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES fritzpay_principal.principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.project_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.project_metadata (
+  project_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (project_id, name, timestamp),
+  CONSTRAINT fk_project_metadata_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.project_key
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.project_key (
+  key VARCHAR(64) NOT NULL,
+  timestamp TIMESTAMP(0) NOT NULL,
+  project_id BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secret TEXT NOT NULL,
+  active BOOLEAN NOT NULL,
+  expires TIMESTAMP(0) NULL,
+  previous_secret TEXT NULL,
+  previous_expires TIMESTAMP(0) NULL,
+  scopes VARCHAR(255) NULL,
+  ip_allowlist TEXT NULL,
+  public_keys TEXT NULL,
+  client_certs TEXT NULL,
+  PRIMARY KEY (key, timestamp),
+  CONSTRAINT fk_project_key_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_key_project_id_idx ON fritzpay_principal.project_key (project_id);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.project_config
+-- The callback project key has no foreign key, since the key column of the
+-- project_key table is not unique.
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.project_config (
+  project_id BIGINT NOT NULL,
+  timestamp TIMESTAMP(0) NOT NULL,
+  web_url TEXT NULL,
+  callback_url TEXT NULL,
+  callback_api_version VARCHAR(32) NULL,
+  callback_project_key VARCHAR(64) NULL,
+  return_url TEXT NULL,
+  ip_allowlist TEXT NULL,
+  rate_limit_burst BIGINT NULL,
+  PRIMARY KEY (project_id, timestamp),
+  CONSTRAINT fk_project_config_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES fritzpay_principal.project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_config_project_key_idx ON fritzpay_principal.project_config (callback_project_key);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.principal_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.principal_status (
+  principal_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (principal_id, timestamp),
+  CONSTRAINT fk_principal_status_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES fritzpay_principal.principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal."user" (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  created TIMESTAMP(0) NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT user_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_status (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_status_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_password
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_password (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  password VARCHAR(255) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_password_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_role
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_role (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  grants TEXT NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_role_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_mfa
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_mfa (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  required BOOLEAN NOT NULL,
+  totp_enabled BOOLEAN NOT NULL,
+  totp_secret TEXT NULL,
+  recovery_codes TEXT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_mfa_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_totp_step
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_totp_step (
+  user_id BIGINT NOT NULL,
+  step BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, step),
+  CONSTRAINT fk_user_totp_step_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.user_recovery_code_use
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.user_recovery_code_use (
+  user_id BIGINT NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, code_hash),
+  CONSTRAINT fk_user_recovery_code_use_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES fritzpay_principal."user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.audit_log
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.audit_log (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+  timestamp BIGINT NOT NULL,
+  actor VARCHAR(64) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  entity VARCHAR(64) NOT NULL,
+  entity_id VARCHAR(128) NOT NULL,
+  before TEXT NULL,
+  after TEXT NULL,
+  source_ip VARCHAR(64) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  prev_hash CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT audit_log_prev_hash_UNIQUE UNIQUE (prev_hash));
+
+CREATE INDEX IF NOT EXISTS audit_log_timestamp ON fritzpay_principal.audit_log (timestamp);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON fritzpay_principal.audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON fritzpay_principal.audit_log (actor);
+
+-- -----------------------------------------------------
+-- User paymentd
+-- -----------------------------------------------------
+DROP ROLE IF EXISTS paymentd;
+CREATE ROLE paymentd LOGIN;
+
+GRANT USAGE ON SCHEMA fritzpay_payment, fritzpay_principal TO paymentd;
+GRANT SELECT, INSERT ON ALL TABLES IN SCHEMA fritzpay_payment TO paymentd;
+GRANT SELECT, INSERT ON ALL TABLES IN SCHEMA fritzpay_principal TO paymentd;
+GRANT DELETE ON TABLE fritzpay_payment.payment_token TO paymentd;
+-- lastval() requires the usage of the ID sequences
+GRANT USAGE ON ALL SEQUENCES IN SCHEMA fritzpay_payment TO paymentd;
+GRANT USAGE ON ALL SEQUENCES IN SCHEMA fritzpay_principal TO paymentd;
+
+-- -----------------------------------------------------
+-- Data for table fritzpay_payment.provider
+-- -----------------------------------------------------
+INSERT INTO fritzpay_payment.provider (name) VALUES ('fritzpay');
+INSERT INTO fritzpay_payment.provider (name) VALUES ('paypal_rest');
+INSERT INTO fritzpay_payment.provider (name) VALUES ('stripe');
+
+-- -----------------------------------------------------
+-- Table fritzpay_payment.schema_migration
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_payment.schema_migration (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (version));
+
+-- -----------------------------------------------------
+-- Data for table fritzpay_payment.schema_migration
+-- -----------------------------------------------------
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (1, 'initial', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (2, 'provider_stripe', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (3, 'payment_transaction_request_id', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (4, 'auth_key', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (5, 'payment_method_limits', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_payment.schema_migration (version, name, applied, dirty) VALUES (6, 'payment_method_routing', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+
+-- -----------------------------------------------------
+-- Table fritzpay_principal.schema_migration
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS fritzpay_principal.schema_migration (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (version));
+
+-- -----------------------------------------------------
+-- Data for table fritzpay_principal.schema_migration
+-- -----------------------------------------------------
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (1, 'initial', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (2, 'project_key_settings', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (3, 'project_config_settings', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (4, 'user', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (5, 'audit_log', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (6, 'project_key_client_certs', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
+INSERT INTO fritzpay_principal.schema_migration (version, name, applied, dirty) VALUES (7, 'user_mfa_use', EXTRACT(EPOCH FROM NOW())::BIGINT, FALSE);
@@ -0,0 +1,386 @@
+-- SQLite schema of the paymentd payment database, matching resources/mysql/paymentd.sql
+--
+-- SQLite keeps each database in its own file. Create the file with
+--
+--   sqlite3 /path/to/payment.db < paymentd.payment.sql
+
+-- -----------------------------------------------------
+-- Table config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS config (
+  name VARCHAR(64) NOT NULL,
+  last_change BIGINT NOT NULL,
+  value TEXT NULL,
+  PRIMARY KEY (name, last_change));
+
+-- -----------------------------------------------------
+-- Table auth_key
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS auth_key (
+  keychain VARCHAR(16) NOT NULL,
+  generation BIGINT NOT NULL,
+  created BIGINT NOT NULL,
+  key TEXT NOT NULL,
+  PRIMARY KEY (keychain, generation));
+
+-- -----------------------------------------------------
+-- Table provider
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider (
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (name));
+
+-- -----------------------------------------------------
+-- Table payment_method
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method (
+  id INTEGER,
+  project_id BIGINT NOT NULL,
+  provider VARCHAR(64) NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT payment_method_method_key UNIQUE (project_id, provider, method_key),
+  CONSTRAINT fk_payment_method_provider
+    FOREIGN KEY (provider)
+    REFERENCES provider (name)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_method_project_id_idx ON payment_method (project_id);
+CREATE INDEX IF NOT EXISTS fk_payment_method_provider_idx ON payment_method (provider);
+
+-- -----------------------------------------------------
+-- Table currency
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS currency (
+  code_iso_4217 VARCHAR(3) NOT NULL,
+  PRIMARY KEY (code_iso_4217));
+
+-- -----------------------------------------------------
+-- Table payment_method_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_status (
+  payment_method_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (payment_method_id, timestamp),
+  CONSTRAINT fk_payment_method_status_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table payment_method_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_metadata (
+  payment_method_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (payment_method_id, name, timestamp),
+  CONSTRAINT fk_principal_metadata_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table payment_method_limits
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_limits (
+  payment_method_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  limits TEXT NOT NULL,
+  PRIMARY KEY (payment_method_id, timestamp),
+  CONSTRAINT fk_payment_method_limits_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table payment
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment (
+  id INTEGER,
+  project_id BIGINT NOT NULL,
+  created DATETIME NOT NULL,
+  ident VARCHAR(175) NOT NULL,
+  amount INTEGER NOT NULL,
+  subunits SMALLINT NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT payment_ident UNIQUE (project_id, ident),
+  CONSTRAINT payment_payment_id UNIQUE (project_id, id),
+  CONSTRAINT fk_payment_currency
+    FOREIGN KEY (currency)
+    REFERENCES currency (code_iso_4217)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_created ON payment (created);
+CREATE INDEX IF NOT EXISTS fk_payment_currency_idx ON payment (currency);
+
+-- -----------------------------------------------------
+-- Table payment_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_config (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  payment_method_id BIGINT NULL,
+  country VARCHAR(2) NULL,
+  locale VARCHAR(5) NULL,
+  callback_url TEXT NULL,
+  callback_api_version VARCHAR(32) NULL,
+  callback_project_key VARCHAR(64) NULL,
+  return_url TEXT NULL,
+  expires DATETIME NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_payment_config_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_config_payment_method_id
+    FOREIGN KEY (payment_method_id)
+    REFERENCES payment_method (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_config_payment_method_id_idx ON payment_config (payment_method_id);
+CREATE INDEX IF NOT EXISTS fk_payment_config_payment_id_idx ON payment_config (payment_id);
+
+-- -----------------------------------------------------
+-- Table payment_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_metadata (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  name VARCHAR(125) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  value TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, name, timestamp),
+  CONSTRAINT fk_payment_metadata_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_payment_metadata_payment_id_idx ON payment_metadata (payment_id);
+CREATE INDEX IF NOT EXISTS payment_metadata_timestamp ON payment_metadata (project_id, payment_id, timestamp);
+
+-- -----------------------------------------------------
+-- Table payment_token
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_token (
+  token VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  PRIMARY KEY (token),
+  CONSTRAINT fk_payment_token_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_token_created ON payment_token (created);
+CREATE INDEX IF NOT EXISTS fk_payment_token_payment_id_idx ON payment_token (payment_id);
+CREATE INDEX IF NOT EXISTS fk_payment_token_project_id_idx ON payment_token (project_id);
+
+-- -----------------------------------------------------
+-- Table payment_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_transaction (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  amount INTEGER NOT NULL,
+  subunits SMALLINT NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  comment TEXT NULL,
+  request_id VARCHAR(64) NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_payment_transaction_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE,
+  CONSTRAINT fk_payment_transaction_currency
+    FOREIGN KEY (currency)
+    REFERENCES currency (code_iso_4217)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS payment_transaction_status ON payment_transaction (status);
+CREATE INDEX IF NOT EXISTS payment_transaction_request_id ON payment_transaction (request_id);
+CREATE INDEX IF NOT EXISTS fk_payment_transaction_currency_idx ON payment_transaction (currency);
+CREATE INDEX IF NOT EXISTS fk_payment_transaction_payment_id_idx ON payment_transaction (payment_id);
+
+-- -----------------------------------------------------
+-- Table provider_fritzpay_payment
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_fritzpay_payment (
+  id INTEGER,
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  created DATETIME NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT provider_fritzpay_payment_payment_id UNIQUE (project_id, payment_id),
+  CONSTRAINT fk_provider_fritzpay_payment_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_provider_fritzpay_payment_payment_id_idx ON provider_fritzpay_payment (payment_id);
+
+-- -----------------------------------------------------
+-- Table provider_fritzpay_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_fritzpay_transaction (
+  fritzpay_payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  fritzpay_id VARCHAR(64) NULL,
+  payload TEXT NULL,
+  PRIMARY KEY (fritzpay_payment_id, timestamp),
+  CONSTRAINT fk_provider_fritzpay_transaction_fritzpay_payment_id
+    FOREIGN KEY (fritzpay_payment_id)
+    REFERENCES provider_fritzpay_payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS provider_fritzpay_transaction_fritzpay_id ON provider_fritzpay_transaction (fritzpay_id);
+CREATE INDEX IF NOT EXISTS provider_fritzpay_transaction_status ON provider_fritzpay_transaction (status);
+
+-- -----------------------------------------------------
+-- Table provider_paypal_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_paypal_config (
+  project_id BIGINT NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  endpoint TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  PRIMARY KEY (project_id, method_key, created));
+
+-- -----------------------------------------------------
+-- Table provider_paypal_transaction
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_paypal_transaction (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  nonce VARCHAR(32) NULL,
+  intent VARCHAR(32) NULL,
+  paypal_id VARCHAR(128) NULL,
+  payer_id VARCHAR(64) NULL,
+  paypal_create_time DATETIME NULL,
+  paypal_state VARCHAR(32) NULL,
+  paypal_update_time DATETIME NULL,
+  links TEXT NULL,
+  data TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_provider_paypal_transaction_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_id ON provider_paypal_transaction (paypal_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_state ON provider_paypal_transaction (paypal_state);
+CREATE INDEX IF NOT EXISTS fk_provider_paypal_transaction_payment_id_idx ON provider_paypal_transaction (payment_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_payer_id ON provider_paypal_transaction (payer_id);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_intent ON provider_paypal_transaction (intent);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_paypal_nonce ON provider_paypal_transaction (project_id, payment_id, nonce);
+CREATE INDEX IF NOT EXISTS provider_paypal_transaction_type ON provider_paypal_transaction (project_id, payment_id, type);
+
+-- -----------------------------------------------------
+-- Table provider_paypal_authorization
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_paypal_authorization (
+  project_id BIGINT NOT NULL,
+  payment_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  valid_until DATETIME NOT NULL,
+  state VARCHAR(32) NOT NULL,
+  authorization_id VARCHAR(128) NOT NULL,
+  paypal_id VARCHAR(128) NOT NULL,
+  amount VARCHAR(64) NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  links TEXT NULL,
+  data TEXT NULL,
+  PRIMARY KEY (project_id, payment_id, timestamp),
+  CONSTRAINT fk_provider_paypal_authorization_payment_id
+    FOREIGN KEY (payment_id)
+    REFERENCES payment (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_provider_paypal_authorization_payment_id_idx ON provider_paypal_authorization (payment_id);
+
+-- -----------------------------------------------------
+-- Table payment_method_routing
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS payment_method_routing (
+  project_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  rules TEXT NOT NULL,
+  PRIMARY KEY (project_id, timestamp));
+
+-- -----------------------------------------------------
+-- Data for table provider
+-- -----------------------------------------------------
+
+INSERT OR IGNORE INTO provider (name) VALUES ('fritzpay');
+
+INSERT OR IGNORE INTO provider (name) VALUES ('paypal_rest');
+
+-- -----------------------------------------------------
+-- Table provider_stripe_config
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS provider_stripe_config (
+  project_id BIGINT NOT NULL,
+  method_key VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secure_key TEXT NOT NULL,
+  public_key TEXT NOT NULL,
+  PRIMARY KEY (project_id, method_key, created));
+
+INSERT OR IGNORE INTO provider (name) VALUES ('stripe');
+
+-- -----------------------------------------------------
+-- Table schema_migration
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS schema_migration (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT 0,
+  PRIMARY KEY (version));
+
+-- -----------------------------------------------------
+-- Data for table schema_migration
+-- -----------------------------------------------------
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (1, 'initial', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (2, 'provider_stripe', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (3, 'payment_transaction_request_id', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (4, 'auth_key', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (5, 'payment_method_limits', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (6, 'payment_method_routing', CAST(strftime('%s', 'now') AS INTEGER), 0);
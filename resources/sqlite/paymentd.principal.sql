@@ -0,0 +1,276 @@
+-- SQLite schema of the paymentd principal database, matching resources/mysql/paymentd.sql
+--
+-- SQLite keeps each database in its own file. Create the file with
+--
+--   sqlite3 /path/to/principal.db < paymentd.principal.sql
+
+-- -----------------------------------------------------
+-- Table principal
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal (
+  id INTEGER,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT principal_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table principal_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal_status (
+  principal_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (principal_id, timestamp),
+  CONSTRAINT fk_principal_status_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project (
+  id INTEGER,
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT project_project_name UNIQUE (principal_id, name),
+  CONSTRAINT fk_project_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table principal_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS principal_metadata (
+  principal_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (principal_id, name, timestamp),
+  CONSTRAINT fk_principal_metadata_principal_id
+    FOREIGN KEY (principal_id)
+    REFERENCES principal (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project_metadata
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_metadata (
+  project_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  value TEXT NOT NULL,
+  PRIMARY KEY (project_id, name, timestamp),
+  CONSTRAINT fk_project_metadata_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table project_key
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_key (
+  key VARCHAR(64) NOT NULL,
+  timestamp DATETIME NOT NULL,
+  project_id BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  secret TEXT NOT NULL,
+  active BOOLEAN NOT NULL,
+  expires DATETIME NULL,
+  previous_secret TEXT NULL,
+  previous_expires DATETIME NULL,
+  scopes VARCHAR(255) NULL,
+  ip_allowlist TEXT NULL,
+  public_keys TEXT NULL,
+  client_certs TEXT NULL,
+  PRIMARY KEY (key, timestamp),
+  CONSTRAINT fk_project_key_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_key_project_id_idx ON project_key (project_id);
+
+-- -----------------------------------------------------
+-- Table project_config
+-- The callback project key has no foreign key, since the key column of the
+-- project_key table is not unique.
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS project_config (
+  project_id BIGINT NOT NULL,
+  timestamp DATETIME NOT NULL,
+  web_url TEXT NULL,
+  callback_url TEXT NULL,
+  callback_api_version VARCHAR(32) NULL,
+  callback_project_key VARCHAR(64) NULL,
+  return_url TEXT NULL,
+  ip_allowlist TEXT NULL,
+  rate_limit_burst BIGINT NULL,
+  PRIMARY KEY (project_id, timestamp),
+  CONSTRAINT fk_project_config_project_id
+    FOREIGN KEY (project_id)
+    REFERENCES project (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+CREATE INDEX IF NOT EXISTS fk_project_config_project_key_idx ON project_config (callback_project_key);
+
+-- -----------------------------------------------------
+-- Table user
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS "user" (
+  id INTEGER,
+  created DATETIME NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT user_name_UNIQUE UNIQUE (name));
+
+-- -----------------------------------------------------
+-- Table user_status
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_status (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_status_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_password
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_password (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  password VARCHAR(255) NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_password_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_role
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_role (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  grants TEXT NOT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_role_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_mfa
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  created_by VARCHAR(64) NOT NULL,
+  required BOOLEAN NOT NULL,
+  totp_enabled BOOLEAN NOT NULL,
+  totp_secret TEXT NULL,
+  recovery_codes TEXT NULL,
+  PRIMARY KEY (user_id, timestamp),
+  CONSTRAINT fk_user_mfa_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_totp_step
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_totp_step (
+  user_id BIGINT NOT NULL,
+  step BIGINT NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, step),
+  CONSTRAINT fk_user_totp_step_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table user_recovery_code_use
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS user_recovery_code_use (
+  user_id BIGINT NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  PRIMARY KEY (user_id, code_hash),
+  CONSTRAINT fk_user_recovery_code_use_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES "user" (id)
+    ON DELETE RESTRICT
+    ON UPDATE CASCADE);
+
+-- -----------------------------------------------------
+-- Table audit_log
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS audit_log (
+  id INTEGER,
+  timestamp BIGINT NOT NULL,
+  actor VARCHAR(64) NOT NULL,
+  action VARCHAR(64) NOT NULL,
+  entity VARCHAR(64) NOT NULL,
+  entity_id VARCHAR(128) NOT NULL,
+  before TEXT NULL,
+  after TEXT NULL,
+  source_ip VARCHAR(64) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  prev_hash CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT audit_log_prev_hash_UNIQUE UNIQUE (prev_hash));
+
+CREATE INDEX IF NOT EXISTS audit_log_timestamp ON audit_log (timestamp);
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor);
+
+-- -----------------------------------------------------
+-- Table schema_migration
+-- -----------------------------------------------------
+CREATE TABLE IF NOT EXISTS schema_migration (
+  version INTEGER NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  applied BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL DEFAULT 0,
+  PRIMARY KEY (version));
+
+-- -----------------------------------------------------
+-- Data for table schema_migration
+-- -----------------------------------------------------
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (1, 'initial', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (2, 'project_key_settings', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (3, 'project_config_settings', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (4, 'user', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (5, 'audit_log', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (6, 'project_key_client_certs', CAST(strftime('%s', 'now') AS INTEGER), 0);
+INSERT OR IGNORE INTO schema_migration (version, name, applied, dirty) VALUES (7, 'user_mfa_use', CAST(strftime('%s', 'now') AS INTEGER), 0);